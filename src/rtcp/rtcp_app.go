@@ -0,0 +1,78 @@
+package rtcp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/lioneagle/goutil/src/buffer"
+)
+
+type RtcpApp struct {
+	Subtype byte
+	Ssrc    uint32
+	Name    string
+	// application-dependent data, a multiple of 4 octets
+	Data []byte
+}
+
+func (this *RtcpApp) GetPacketType() byte {
+	return RTCP_PT_APP
+}
+
+func (this *RtcpApp) MarshalSize() int {
+	return RTCP_HEADER_LEN + 8 + len(this.Data)
+}
+
+func (this *RtcpApp) MarshalTo(buf []byte) (int, error) {
+	if len(this.Name) != 4 {
+		return 0, ErrBadAppName
+	}
+	if this.Subtype > RTCP_MAX_COUNT {
+		return 0, ErrBadAppSubtype
+	}
+	if len(this.Data)&0x3 != 0 {
+		return 0, ErrBadAppDataSize
+	}
+	size := this.MarshalSize()
+	err := marshalRtcpHeader(buf, RTCP_PT_APP, int(this.Subtype), size)
+	if err != nil {
+		return 0, err
+	}
+
+	binary.BigEndian.PutUint32(buf[4:], this.Ssrc)
+	copy(buf[8:], this.Name)
+	copy(buf[12:], this.Data)
+	return size, nil
+}
+
+func (this *RtcpApp) Unmarshal(data []byte) error {
+	header := RtcpHeader{}
+	body, err := parseRtcpHeader(&header, data, RTCP_PT_APP)
+	if err != nil {
+		return err
+	}
+	if len(body) < 8 {
+		return ErrTooShort
+	}
+
+	this.Subtype = header.Count
+	this.Ssrc = binary.BigEndian.Uint32(body)
+	this.Name = string(body[4:8])
+	this.Data = nil
+	if len(body) > 8 {
+		this.Data = append([]byte(nil), body[8:]...)
+	}
+	return nil
+}
+
+func (this *RtcpApp) Print(w io.Writer) {
+	header := RtcpHeader{Version: RTCP_VERSION, Count: this.Subtype, PacketType: RTCP_PT_APP, Length: uint16(this.MarshalSize()/4 - 1)}
+	header.Print(w, "Subtype")
+	fmt.Fprintf(w, "Identifier: 0x%08x (%d)\n", this.Ssrc, this.Ssrc)
+	fmt.Fprintf(w, "Name (ASCII): %s\n", this.Name)
+	if len(this.Data) > 0 {
+		fmt.Fprintf(w, "Application specific data:\n")
+		buffer.PrintAsHex(w, this.Data, 0, len(this.Data))
+	}
+}
@@ -0,0 +1,98 @@
+package rtcp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+var (
+	ErrBadReason      = errors.New("rtcp: bye reason exceeds packet")
+	ErrReasonTooBig   = errors.New("rtcp: bye reason too long")
+	ErrBadAppName     = errors.New("rtcp: app name must be 4 octets")
+	ErrBadAppSubtype  = errors.New("rtcp: app subtype exceeds 5 bits")
+	ErrBadAppDataSize = errors.New("rtcp: app data is not a multiple of 4 octets")
+)
+
+type RtcpBye struct {
+	Sources []uint32
+	Reason  string
+}
+
+func (this *RtcpBye) GetPacketType() byte {
+	return RTCP_PT_BYE
+}
+
+func (this *RtcpBye) MarshalSize() int {
+	size := RTCP_HEADER_LEN + len(this.Sources)*4
+	if len(this.Reason) > 0 {
+		size += pad4(1 + len(this.Reason))
+	}
+	return size
+}
+
+func (this *RtcpBye) MarshalTo(buf []byte) (int, error) {
+	if len(this.Reason) > 255 {
+		return 0, ErrReasonTooBig
+	}
+	size := this.MarshalSize()
+	err := marshalRtcpHeader(buf, RTCP_PT_BYE, len(this.Sources), size)
+	if err != nil {
+		return 0, err
+	}
+
+	pos := RTCP_HEADER_LEN
+	for _, v := range this.Sources {
+		binary.BigEndian.PutUint32(buf[pos:], v)
+		pos += 4
+	}
+	if len(this.Reason) > 0 {
+		buf[pos] = byte(len(this.Reason))
+		pos++
+		pos += copy(buf[pos:], this.Reason)
+		for ; pos < size; pos++ {
+			buf[pos] = 0
+		}
+	}
+	return pos, nil
+}
+
+func (this *RtcpBye) Unmarshal(data []byte) error {
+	header := RtcpHeader{}
+	body, err := parseRtcpHeader(&header, data, RTCP_PT_BYE)
+	if err != nil {
+		return err
+	}
+	if len(body) < int(header.Count)*4 {
+		return ErrTooShort
+	}
+
+	this.Sources = nil
+	for i := 0; i < int(header.Count); i++ {
+		this.Sources = append(this.Sources, binary.BigEndian.Uint32(body[i*4:]))
+	}
+
+	this.Reason = ""
+	rest := body[int(header.Count)*4:]
+	if len(rest) > 0 {
+		length := int(rest[0])
+		if 1+length > len(rest) {
+			return ErrBadReason
+		}
+		this.Reason = string(rest[1 : 1+length])
+	}
+	return nil
+}
+
+func (this *RtcpBye) Print(w io.Writer) {
+	header := RtcpHeader{Version: RTCP_VERSION, Count: byte(len(this.Sources)), PacketType: RTCP_PT_BYE, Length: uint16(this.MarshalSize()/4 - 1)}
+	header.Print(w, "Source count")
+	for i, v := range this.Sources {
+		fmt.Fprintf(w, "Identifier %d: 0x%08x (%d)\n", i+1, v, v)
+	}
+	if len(this.Reason) > 0 {
+		fmt.Fprintf(w, "Length: %d\n", len(this.Reason))
+		fmt.Fprintf(w, "Reason for leaving: %s\n", this.Reason)
+	}
+}
@@ -0,0 +1,95 @@
+package rtcp
+
+import (
+	"math"
+	"time"
+)
+
+// basic signal-to-noise ratio R0 minus the simultaneous impairment Is with
+// the default values of ITU-T G.107
+const RTCP_EMODEL_R0 = 93.2
+
+// RtcpEModel holds the codec dependent inputs of the ITU-T G.107 E-model: the
+// equipment impairment factor Ie and the packet-loss robustness factor Bpl.
+type RtcpEModel struct {
+	Ie  float64
+	Bpl float64
+}
+
+// values of ITU-T G.113 appendix I for G.711 with packet loss concealment
+var RtcpEModelG711 = RtcpEModel{Ie: 0, Bpl: 25.1}
+
+// EffectiveIe returns Ie-eff for lossPercent packets lost or discarded, burstR
+// is 1 for random loss and larger for bursty loss.
+func (this *RtcpEModel) EffectiveIe(lossPercent, burstR float64) float64 {
+	if lossPercent <= 0 {
+		return this.Ie
+	}
+	if burstR < 1 {
+		burstR = 1
+	}
+	return this.Ie + (95-this.Ie)*lossPercent/(lossPercent/burstR+this.Bpl)
+}
+
+// RtcpDelayImpairment returns the simplified delay impairment Id of a one way
+// mouth to ear delay.
+func RtcpDelayImpairment(delay time.Duration) float64 {
+	d := float64(delay) / float64(time.Millisecond)
+	id := 0.024 * d
+	if d > 177.3 {
+		id += 0.11 * (d - 177.3)
+	}
+	return id
+}
+
+// RFactor returns the transmission rating R for a one way delay and the loss
+// of the stream, limited to 0..100.
+func (this *RtcpEModel) RFactor(delay time.Duration, lossPercent, burstR float64) float64 {
+	return clampRFactor(RTCP_EMODEL_R0 - RtcpDelayImpairment(delay) - this.EffectiveIe(lossPercent, burstR))
+}
+
+func clampRFactor(r float64) float64 {
+	if r < 0 {
+		return 0
+	}
+	if r > 100 {
+		return 100
+	}
+	return r
+}
+
+// RtcpMos converts R to a mean opinion score with ITU-T G.107 annex B.
+func RtcpMos(r float64) float64 {
+	if r <= 0 {
+		return 1
+	}
+	if r >= 100 {
+		return 4.5
+	}
+	return 1 + 0.035*r + r*(r-60)*(100-r)*7e-6
+}
+
+// SetQuality fills RFactor, MosLq and MosCq from the loss and delay metrics
+// of the block. Burst and gap periods are rated separately and weighted by
+// their durations; the one way delay is half the round trip delay plus the
+// end system delay. The listening quality leaves the delay out.
+func (this *RtcpXrVoipMetrics) SetQuality(model *RtcpEModel) {
+	var ie float64
+	total := float64(this.BurstDuration) + float64(this.GapDuration)
+	if total > 0 {
+		burst := model.EffectiveIe(float64(this.BurstDensity)*100/256, 1)
+		gap := model.EffectiveIe(float64(this.GapDensity)*100/256, 1)
+		ie = (burst*float64(this.BurstDuration) + gap*float64(this.GapDuration)) / total
+	} else {
+		ie = model.EffectiveIe((float64(this.LossRate)+float64(this.DiscardRate))*100/256, 1)
+	}
+
+	delay := time.Duration(int(this.RoundTripDelay)/2+int(this.EndSystemDelay)) * time.Millisecond
+	conversational := clampRFactor(RTCP_EMODEL_R0 - RtcpDelayImpairment(delay) - ie)
+	listening := clampRFactor(RTCP_EMODEL_R0 - ie)
+
+	this.RFactor = byte(math.Floor(conversational + 0.5))
+	this.ExtRFactor = RTCP_XR_UNAVAILABLE
+	this.MosLq = byte(math.Floor(RtcpMos(listening)*10 + 0.5))
+	this.MosCq = byte(math.Floor(RtcpMos(conversational)*10 + 0.5))
+}
@@ -0,0 +1,558 @@
+package rtcp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// feedback message types from RFC4585, RFC5104 and draft-alvestrand-rmcat-remb
+const (
+	RTCP_RTPFB_FMT_NACK  = 1
+	RTCP_RTPFB_FMT_TMMBR = 3
+	RTCP_RTPFB_FMT_TMMBN = 4
+	RTCP_RTPFB_FMT_TWCC  = 15
+
+	RTCP_PSFB_FMT_PLI = 1
+	RTCP_PSFB_FMT_FIR = 4
+	RTCP_PSFB_FMT_AFB = 15
+
+	RTCP_FEEDBACK_HEADER_LEN = 12
+	RTCP_REMB_IDENTIFIER     = "REMB"
+	RTCP_NACK_BLP_BITS       = 16
+)
+
+var (
+	ErrBadFeedbackType = errors.New("rtcp: unexpected feedback message type")
+	ErrBadFci          = errors.New("rtcp: bad feedback control information")
+	ErrBadBitrate      = errors.New("rtcp: bitrate can not be represented")
+)
+
+func newRtpFeedback(header *RtcpHeader, data []byte) RtcpPacket {
+	switch header.Count {
+	case RTCP_RTPFB_FMT_NACK:
+		return &RtcpNack{}
+	case RTCP_RTPFB_FMT_TMMBR:
+		return &RtcpTmmbr{}
+	case RTCP_RTPFB_FMT_TMMBN:
+		return &RtcpTmmbn{}
+	case RTCP_RTPFB_FMT_TWCC:
+		return &RtcpTransportCc{}
+	}
+	return nil
+}
+
+func newPayloadSpecificFeedback(header *RtcpHeader, data []byte) RtcpPacket {
+	switch header.Count {
+	case RTCP_PSFB_FMT_PLI:
+		return &RtcpPli{}
+	case RTCP_PSFB_FMT_FIR:
+		return &RtcpFir{}
+	case RTCP_PSFB_FMT_AFB:
+		if len(data) >= RTCP_FEEDBACK_HEADER_LEN+4 && string(data[RTCP_FEEDBACK_HEADER_LEN:RTCP_FEEDBACK_HEADER_LEN+4]) == RTCP_REMB_IDENTIFIER {
+			return &RtcpRemb{}
+		}
+	}
+	return nil
+}
+
+// parseFeedbackHeader checks the common header of a feedback message and
+// returns the feedback control information.
+func parseFeedbackHeader(header *RtcpHeader, data []byte, packetType, format byte) (senderSsrc, mediaSsrc uint32, fci []byte, err error) {
+	body, err := parseRtcpHeader(header, data, packetType)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	if header.Count != format {
+		return 0, 0, nil, ErrBadFeedbackType
+	}
+	if len(body) < 8 {
+		return 0, 0, nil, ErrTooShort
+	}
+	return binary.BigEndian.Uint32(body), binary.BigEndian.Uint32(body[4:]), body[8:], nil
+}
+
+func marshalFeedbackHeader(buf []byte, packetType, format byte, size int, senderSsrc, mediaSsrc uint32) error {
+	err := marshalRtcpHeader(buf, packetType, int(format), size)
+	if err != nil {
+		return err
+	}
+	binary.BigEndian.PutUint32(buf[4:], senderSsrc)
+	binary.BigEndian.PutUint32(buf[8:], mediaSsrc)
+	return nil
+}
+
+func printFeedbackHeader(w io.Writer, packetType, format byte, size int, senderSsrc, mediaSsrc uint32) {
+	header := RtcpHeader{Version: RTCP_VERSION, Count: format, PacketType: packetType, Length: uint16(size/4 - 1)}
+	header.Print(w, "RTCP Feedback message type (FMT)")
+	fmt.Fprintf(w, "Sender SSRC: 0x%08x (%d)\n", senderSsrc, senderSsrc)
+	fmt.Fprintf(w, "Media source SSRC: 0x%08x (%d)\n", mediaSsrc, mediaSsrc)
+}
+
+// encodeBitrate splits bitrate into a 6-bit exponent and a mantissa of
+// mantissaBits bits.
+func encodeBitrate(bitrate uint64, mantissaBits uint) (exp byte, mantissa uint32, err error) {
+	for bitrate >= 1<<mantissaBits {
+		bitrate >>= 1
+		exp++
+	}
+	if exp > 63 {
+		return 0, 0, ErrBadBitrate
+	}
+	return exp, uint32(bitrate), nil
+}
+
+func decodeBitrate(exp byte, mantissa uint32) uint64 {
+	return uint64(mantissa) << exp
+}
+
+// RtcpNackPair is one generic NACK item: PacketId is lost, and bit i of
+// LostPackets reports PacketId+i+1 lost.
+type RtcpNackPair struct {
+	PacketId    uint16
+	LostPackets uint16
+}
+
+// PacketList expands the item into the lost sequence numbers.
+func (this *RtcpNackPair) PacketList() []uint16 {
+	list := []uint16{this.PacketId}
+	for i := uint16(0); i < RTCP_NACK_BLP_BITS; i++ {
+		if this.LostPackets&(1<<i) != 0 {
+			list = append(list, this.PacketId+i+1)
+		}
+	}
+	return list
+}
+
+// NewRtcpNackPairs returns the minimal list of NACK items covering the lost
+// rtp sequence numbers, which may be unordered, duplicated or wrap around.
+func NewRtcpNackPairs(lost []uint16) []RtcpNackPair {
+	if len(lost) == 0 {
+		return nil
+	}
+
+	seqs := append([]uint16(nil), lost...)
+	sort.Slice(seqs, func(i, j int) bool {
+		return int16(seqs[i]-seqs[j]) < 0
+	})
+
+	var pairs []RtcpNackPair
+	for _, v := range seqs {
+		if len(pairs) > 0 {
+			last := &pairs[len(pairs)-1]
+			diff := v - last.PacketId
+			if diff == 0 {
+				continue
+			}
+			if diff <= RTCP_NACK_BLP_BITS {
+				last.LostPackets |= 1 << (diff - 1)
+				continue
+			}
+		}
+		pairs = append(pairs, RtcpNackPair{PacketId: v})
+	}
+	return pairs
+}
+
+// RtcpNack is the generic NACK from RFC4585 section 6.2.1.
+type RtcpNack struct {
+	SenderSsrc uint32
+	MediaSsrc  uint32
+	Nacks      []RtcpNackPair
+}
+
+// PacketList returns every sequence number reported lost.
+func (this *RtcpNack) PacketList() []uint16 {
+	var list []uint16
+	for i := range this.Nacks {
+		list = append(list, this.Nacks[i].PacketList()...)
+	}
+	return list
+}
+
+func (this *RtcpNack) GetPacketType() byte {
+	return RTCP_PT_RTPFB
+}
+
+func (this *RtcpNack) MarshalSize() int {
+	return RTCP_FEEDBACK_HEADER_LEN + len(this.Nacks)*4
+}
+
+func (this *RtcpNack) MarshalTo(buf []byte) (int, error) {
+	size := this.MarshalSize()
+	err := marshalFeedbackHeader(buf, RTCP_PT_RTPFB, RTCP_RTPFB_FMT_NACK, size, this.SenderSsrc, this.MediaSsrc)
+	if err != nil {
+		return 0, err
+	}
+
+	pos := RTCP_FEEDBACK_HEADER_LEN
+	for _, v := range this.Nacks {
+		binary.BigEndian.PutUint16(buf[pos:], v.PacketId)
+		binary.BigEndian.PutUint16(buf[pos+2:], v.LostPackets)
+		pos += 4
+	}
+	return pos, nil
+}
+
+func (this *RtcpNack) Unmarshal(data []byte) error {
+	header := RtcpHeader{}
+	senderSsrc, mediaSsrc, fci, err := parseFeedbackHeader(&header, data, RTCP_PT_RTPFB, RTCP_RTPFB_FMT_NACK)
+	if err != nil {
+		return err
+	}
+	if len(fci) == 0 || len(fci)&0x3 != 0 {
+		return ErrBadFci
+	}
+
+	this.SenderSsrc = senderSsrc
+	this.MediaSsrc = mediaSsrc
+	this.Nacks = nil
+	for pos := 0; pos < len(fci); pos += 4 {
+		this.Nacks = append(this.Nacks, RtcpNackPair{
+			PacketId:    binary.BigEndian.Uint16(fci[pos:]),
+			LostPackets: binary.BigEndian.Uint16(fci[pos+2:]),
+		})
+	}
+	return nil
+}
+
+func (this *RtcpNack) Print(w io.Writer) {
+	printFeedbackHeader(w, RTCP_PT_RTPFB, RTCP_RTPFB_FMT_NACK, this.MarshalSize(), this.SenderSsrc, this.MediaSsrc)
+	for _, v := range this.Nacks {
+		fmt.Fprintf(w, "RTCP Transport Feedback NACK PID: %d\n", v.PacketId)
+		fmt.Fprintf(w, "RTCP Transport Feedback NACK BLP: 0x%04x\n", v.LostPackets)
+	}
+}
+
+// RtcpPli is the picture loss indication from RFC4585 section 6.3.1.
+type RtcpPli struct {
+	SenderSsrc uint32
+	MediaSsrc  uint32
+}
+
+func (this *RtcpPli) GetPacketType() byte {
+	return RTCP_PT_PSFB
+}
+
+func (this *RtcpPli) MarshalSize() int {
+	return RTCP_FEEDBACK_HEADER_LEN
+}
+
+func (this *RtcpPli) MarshalTo(buf []byte) (int, error) {
+	err := marshalFeedbackHeader(buf, RTCP_PT_PSFB, RTCP_PSFB_FMT_PLI, RTCP_FEEDBACK_HEADER_LEN, this.SenderSsrc, this.MediaSsrc)
+	if err != nil {
+		return 0, err
+	}
+	return RTCP_FEEDBACK_HEADER_LEN, nil
+}
+
+func (this *RtcpPli) Unmarshal(data []byte) error {
+	header := RtcpHeader{}
+	senderSsrc, mediaSsrc, _, err := parseFeedbackHeader(&header, data, RTCP_PT_PSFB, RTCP_PSFB_FMT_PLI)
+	if err != nil {
+		return err
+	}
+	this.SenderSsrc = senderSsrc
+	this.MediaSsrc = mediaSsrc
+	return nil
+}
+
+func (this *RtcpPli) Print(w io.Writer) {
+	printFeedbackHeader(w, RTCP_PT_PSFB, RTCP_PSFB_FMT_PLI, this.MarshalSize(), this.SenderSsrc, this.MediaSsrc)
+}
+
+type RtcpFirEntry struct {
+	Ssrc           uint32
+	SequenceNumber byte
+}
+
+// RtcpFir is the full intra request from RFC5104 section 4.3.1, MediaSsrc is
+// unused and should be 0.
+type RtcpFir struct {
+	SenderSsrc uint32
+	MediaSsrc  uint32
+	Entries    []RtcpFirEntry
+}
+
+func (this *RtcpFir) GetPacketType() byte {
+	return RTCP_PT_PSFB
+}
+
+func (this *RtcpFir) MarshalSize() int {
+	return RTCP_FEEDBACK_HEADER_LEN + len(this.Entries)*8
+}
+
+func (this *RtcpFir) MarshalTo(buf []byte) (int, error) {
+	size := this.MarshalSize()
+	err := marshalFeedbackHeader(buf, RTCP_PT_PSFB, RTCP_PSFB_FMT_FIR, size, this.SenderSsrc, this.MediaSsrc)
+	if err != nil {
+		return 0, err
+	}
+
+	pos := RTCP_FEEDBACK_HEADER_LEN
+	for _, v := range this.Entries {
+		binary.BigEndian.PutUint32(buf[pos:], v.Ssrc)
+		binary.BigEndian.PutUint32(buf[pos+4:], uint32(v.SequenceNumber)<<24)
+		pos += 8
+	}
+	return pos, nil
+}
+
+func (this *RtcpFir) Unmarshal(data []byte) error {
+	header := RtcpHeader{}
+	senderSsrc, mediaSsrc, fci, err := parseFeedbackHeader(&header, data, RTCP_PT_PSFB, RTCP_PSFB_FMT_FIR)
+	if err != nil {
+		return err
+	}
+	if len(fci) == 0 || len(fci)%8 != 0 {
+		return ErrBadFci
+	}
+
+	this.SenderSsrc = senderSsrc
+	this.MediaSsrc = mediaSsrc
+	this.Entries = nil
+	for pos := 0; pos < len(fci); pos += 8 {
+		this.Entries = append(this.Entries, RtcpFirEntry{Ssrc: binary.BigEndian.Uint32(fci[pos:]), SequenceNumber: fci[pos+4]})
+	}
+	return nil
+}
+
+func (this *RtcpFir) Print(w io.Writer) {
+	printFeedbackHeader(w, RTCP_PT_PSFB, RTCP_PSFB_FMT_FIR, this.MarshalSize(), this.SenderSsrc, this.MediaSsrc)
+	for _, v := range this.Entries {
+		fmt.Fprintf(w, "RTCP FIR SSRC: 0x%08x (%d)\n", v.Ssrc, v.Ssrc)
+		fmt.Fprintf(w, "RTCP FIR Command Sequence Number: %d\n", v.SequenceNumber)
+	}
+}
+
+// RtcpRemb is the receiver estimated maximum bitrate application layer
+// feedback, Bitrate is in bits per second.
+type RtcpRemb struct {
+	SenderSsrc uint32
+	MediaSsrc  uint32
+	Bitrate    uint64
+	Ssrcs      []uint32
+}
+
+func (this *RtcpRemb) GetPacketType() byte {
+	return RTCP_PT_PSFB
+}
+
+func (this *RtcpRemb) MarshalSize() int {
+	return RTCP_FEEDBACK_HEADER_LEN + 8 + len(this.Ssrcs)*4
+}
+
+func (this *RtcpRemb) MarshalTo(buf []byte) (int, error) {
+	if len(this.Ssrcs) > 255 {
+		return 0, ErrTooManyItems
+	}
+	exp, mantissa, err := encodeBitrate(this.Bitrate, 18)
+	if err != nil {
+		return 0, err
+	}
+	size := this.MarshalSize()
+	err = marshalFeedbackHeader(buf, RTCP_PT_PSFB, RTCP_PSFB_FMT_AFB, size, this.SenderSsrc, this.MediaSsrc)
+	if err != nil {
+		return 0, err
+	}
+
+	pos := RTCP_FEEDBACK_HEADER_LEN
+	copy(buf[pos:], RTCP_REMB_IDENTIFIER)
+	binary.BigEndian.PutUint32(buf[pos+4:], uint32(len(this.Ssrcs))<<24|uint32(exp)<<18|mantissa)
+	pos += 8
+	for _, v := range this.Ssrcs {
+		binary.BigEndian.PutUint32(buf[pos:], v)
+		pos += 4
+	}
+	return pos, nil
+}
+
+func (this *RtcpRemb) Unmarshal(data []byte) error {
+	header := RtcpHeader{}
+	senderSsrc, mediaSsrc, fci, err := parseFeedbackHeader(&header, data, RTCP_PT_PSFB, RTCP_PSFB_FMT_AFB)
+	if err != nil {
+		return err
+	}
+	if len(fci) < 8 || string(fci[:4]) != RTCP_REMB_IDENTIFIER {
+		return ErrBadFci
+	}
+	val := binary.BigEndian.Uint32(fci[4:])
+	num := int(val >> 24)
+	if len(fci) < 8+num*4 {
+		return ErrBadFci
+	}
+
+	this.SenderSsrc = senderSsrc
+	this.MediaSsrc = mediaSsrc
+	this.Bitrate = decodeBitrate(byte(val>>18)&0x3F, val&0x3FFFF)
+	this.Ssrcs = nil
+	for i := 0; i < num; i++ {
+		this.Ssrcs = append(this.Ssrcs, binary.BigEndian.Uint32(fci[8+i*4:]))
+	}
+	return nil
+}
+
+func (this *RtcpRemb) Print(w io.Writer) {
+	printFeedbackHeader(w, RTCP_PT_PSFB, RTCP_PSFB_FMT_AFB, this.MarshalSize(), this.SenderSsrc, this.MediaSsrc)
+	fmt.Fprintf(w, "Unique Identifier: %s\n", RTCP_REMB_IDENTIFIER)
+	fmt.Fprintf(w, "Number of Ssrcs: %d\n", len(this.Ssrcs))
+	fmt.Fprintf(w, "Maximum bitrate: %d\n", this.Bitrate)
+	for _, v := range this.Ssrcs {
+		fmt.Fprintf(w, "SSRC feedback: 0x%08x (%d)\n", v, v)
+	}
+}
+
+// RtcpTmmbItem is one TMMBR or TMMBN entry, Bitrate is the maximum total
+// media bitrate in bits per second and Overhead the measured overhead in
+// octets per packet.
+type RtcpTmmbItem struct {
+	Ssrc     uint32
+	Bitrate  uint64
+	Overhead uint16
+}
+
+func marshalTmmbItems(buf []byte, items []RtcpTmmbItem) (int, error) {
+	pos := 0
+	for _, v := range items {
+		if v.Overhead > 0x1FF {
+			return 0, ErrBadFci
+		}
+		exp, mantissa, err := encodeBitrate(v.Bitrate, 17)
+		if err != nil {
+			return 0, err
+		}
+		binary.BigEndian.PutUint32(buf[pos:], v.Ssrc)
+		binary.BigEndian.PutUint32(buf[pos+4:], uint32(exp)<<26|mantissa<<9|uint32(v.Overhead))
+		pos += 8
+	}
+	return pos, nil
+}
+
+func unmarshalTmmbItems(fci []byte) ([]RtcpTmmbItem, error) {
+	if len(fci)%8 != 0 {
+		return nil, ErrBadFci
+	}
+	var items []RtcpTmmbItem
+	for pos := 0; pos < len(fci); pos += 8 {
+		val := binary.BigEndian.Uint32(fci[pos+4:])
+		items = append(items, RtcpTmmbItem{
+			Ssrc:     binary.BigEndian.Uint32(fci[pos:]),
+			Bitrate:  decodeBitrate(byte(val>>26), (val>>9)&0x1FFFF),
+			Overhead: uint16(val & 0x1FF),
+		})
+	}
+	return items, nil
+}
+
+func printTmmbItems(w io.Writer, items []RtcpTmmbItem) {
+	for _, v := range items {
+		fmt.Fprintf(w, "SSRC: 0x%08x (%d)\n", v.Ssrc, v.Ssrc)
+		fmt.Fprintf(w, "Maximum total media bit rate: %d\n", v.Bitrate)
+		fmt.Fprintf(w, "Measured overhead: %d\n", v.Overhead)
+	}
+}
+
+// RtcpTmmbr is the temporary maximum media stream bit rate request from
+// RFC5104 section 4.2.1, MediaSsrc is unused and should be 0.
+type RtcpTmmbr struct {
+	SenderSsrc uint32
+	MediaSsrc  uint32
+	Entries    []RtcpTmmbItem
+}
+
+func (this *RtcpTmmbr) GetPacketType() byte {
+	return RTCP_PT_RTPFB
+}
+
+func (this *RtcpTmmbr) MarshalSize() int {
+	return RTCP_FEEDBACK_HEADER_LEN + len(this.Entries)*8
+}
+
+func (this *RtcpTmmbr) MarshalTo(buf []byte) (int, error) {
+	size := this.MarshalSize()
+	err := marshalFeedbackHeader(buf, RTCP_PT_RTPFB, RTCP_RTPFB_FMT_TMMBR, size, this.SenderSsrc, this.MediaSsrc)
+	if err != nil {
+		return 0, err
+	}
+	n, err := marshalTmmbItems(buf[RTCP_FEEDBACK_HEADER_LEN:], this.Entries)
+	if err != nil {
+		return 0, err
+	}
+	return RTCP_FEEDBACK_HEADER_LEN + n, nil
+}
+
+func (this *RtcpTmmbr) Unmarshal(data []byte) error {
+	header := RtcpHeader{}
+	senderSsrc, mediaSsrc, fci, err := parseFeedbackHeader(&header, data, RTCP_PT_RTPFB, RTCP_RTPFB_FMT_TMMBR)
+	if err != nil {
+		return err
+	}
+	if len(fci) == 0 {
+		return ErrBadFci
+	}
+	entries, err := unmarshalTmmbItems(fci)
+	if err != nil {
+		return err
+	}
+	this.SenderSsrc = senderSsrc
+	this.MediaSsrc = mediaSsrc
+	this.Entries = entries
+	return nil
+}
+
+func (this *RtcpTmmbr) Print(w io.Writer) {
+	printFeedbackHeader(w, RTCP_PT_RTPFB, RTCP_RTPFB_FMT_TMMBR, this.MarshalSize(), this.SenderSsrc, this.MediaSsrc)
+	printTmmbItems(w, this.Entries)
+}
+
+// RtcpTmmbn is the temporary maximum media stream bit rate notification from
+// RFC5104 section 4.2.2, it may hold no entries.
+type RtcpTmmbn struct {
+	SenderSsrc uint32
+	MediaSsrc  uint32
+	Entries    []RtcpTmmbItem
+}
+
+func (this *RtcpTmmbn) GetPacketType() byte {
+	return RTCP_PT_RTPFB
+}
+
+func (this *RtcpTmmbn) MarshalSize() int {
+	return RTCP_FEEDBACK_HEADER_LEN + len(this.Entries)*8
+}
+
+func (this *RtcpTmmbn) MarshalTo(buf []byte) (int, error) {
+	size := this.MarshalSize()
+	err := marshalFeedbackHeader(buf, RTCP_PT_RTPFB, RTCP_RTPFB_FMT_TMMBN, size, this.SenderSsrc, this.MediaSsrc)
+	if err != nil {
+		return 0, err
+	}
+	n, err := marshalTmmbItems(buf[RTCP_FEEDBACK_HEADER_LEN:], this.Entries)
+	if err != nil {
+		return 0, err
+	}
+	return RTCP_FEEDBACK_HEADER_LEN + n, nil
+}
+
+func (this *RtcpTmmbn) Unmarshal(data []byte) error {
+	header := RtcpHeader{}
+	senderSsrc, mediaSsrc, fci, err := parseFeedbackHeader(&header, data, RTCP_PT_RTPFB, RTCP_RTPFB_FMT_TMMBN)
+	if err != nil {
+		return err
+	}
+	entries, err := unmarshalTmmbItems(fci)
+	if err != nil {
+		return err
+	}
+	this.SenderSsrc = senderSsrc
+	this.MediaSsrc = mediaSsrc
+	this.Entries = entries
+	return nil
+}
+
+func (this *RtcpTmmbn) Print(w io.Writer) {
+	printFeedbackHeader(w, RTCP_PT_RTPFB, RTCP_RTPFB_FMT_TMMBN, this.MarshalSize(), this.SenderSsrc, this.MediaSsrc)
+	printTmmbItems(w, this.Entries)
+}
@@ -0,0 +1,140 @@
+package rtcp
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/lioneagle/goutil/src/buffer"
+	"github.com/lioneagle/goutil/src/test"
+)
+
+func TestRtcpFeedbackRoundTrip(t *testing.T) {
+	testdata := []struct {
+		packet RtcpPacket
+		data   []byte
+	}{
+		{&RtcpNack{SenderSsrc: 0x01020304, MediaSsrc: 0x05060708, Nacks: []RtcpNackPair{{PacketId: 0xa8f, LostPackets: 0x0005}}},
+			[]byte{0x81, 0xcd, 0x00, 0x03, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x0a, 0x8f, 0x00, 0x05}},
+		{&RtcpPli{SenderSsrc: 0x01020304, MediaSsrc: 0x05060708},
+			[]byte{0x81, 0xce, 0x00, 0x02, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08}},
+		{&RtcpFir{SenderSsrc: 0x01020304, Entries: []RtcpFirEntry{{Ssrc: 0x05060708, SequenceNumber: 9}}},
+			[]byte{0x84, 0xce, 0x00, 0x04, 0x01, 0x02, 0x03, 0x04, 0x00, 0x00, 0x00, 0x00, 0x05, 0x06, 0x07, 0x08, 0x09, 0x00, 0x00, 0x00}},
+		{&RtcpRemb{SenderSsrc: 0x01020304, Bitrate: 8927168, Ssrcs: []uint32{0x05060708, 0x090a0b0c}},
+			[]byte{0x8f, 0xce, 0x00, 0x06, 0x01, 0x02, 0x03, 0x04, 0x00, 0x00, 0x00, 0x00, 'R', 'E', 'M', 'B', 0x02, 0x1a, 0x20, 0xdf,
+				0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c}},
+		{&RtcpTmmbr{SenderSsrc: 0x01020304, Entries: []RtcpTmmbItem{{Ssrc: 0x05060708, Bitrate: 1000000, Overhead: 40}}},
+			[]byte{0x83, 0xcd, 0x00, 0x04, 0x01, 0x02, 0x03, 0x04, 0x00, 0x00, 0x00, 0x00, 0x05, 0x06, 0x07, 0x08, 0x0f, 0xd0, 0x90, 0x28}},
+		{&RtcpTmmbn{SenderSsrc: 0x01020304},
+			[]byte{0x84, 0xcd, 0x00, 0x02, 0x01, 0x02, 0x03, 0x04, 0x00, 0x00, 0x00, 0x00}},
+	}
+
+	for i, v := range testdata {
+		v := v
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			t.Parallel()
+
+			data, err := MarshalRtcpPacket(v.packet)
+			test.EXPECT_EQ(t, err, nil, "")
+			test.EXPECT_EQ(t, data, v.data, "")
+
+			packets, err := ParseRtcpPackets(v.data)
+			test.EXPECT_EQ(t, err, nil, "")
+			test.EXPECT_EQ(t, packets, []RtcpPacket{v.packet}, "")
+		})
+	}
+}
+
+func TestRtcpFeedbackUnknownAfb(t *testing.T) {
+	data := []byte{0x8f, 0xce, 0x00, 0x03, 0x01, 0x02, 0x03, 0x04, 0x00, 0x00, 0x00, 0x00, 'A', 'B', 'C', 'D'}
+	packets, err := ParseRtcpPackets(data)
+	test.EXPECT_EQ(t, err, nil, "")
+	test.EXPECT_EQ(t, packets, []RtcpPacket{&RtcpRawPacket{Header: RtcpHeader{Version: 2, Count: 15, PacketType: RTCP_PT_PSFB, Length: 3}, Data: data}}, "")
+}
+
+func TestRtcpFeedbackBitrate(t *testing.T) {
+	testdata := []struct {
+		bitrate  uint64
+		bits     uint
+		exp      byte
+		mantissa uint32
+		err      error
+	}{
+		{0, 18, 0, 0, nil},
+		{0x3ffff, 18, 0, 0x3ffff, nil},
+		{0x40000, 18, 1, 0x20000, nil},
+		{0x40001, 18, 1, 0x20000, nil},
+		{1 << 40, 17, 24, 0x10000, nil},
+		{1<<63 + 1<<62, 17, 47, 0x18000, nil},
+	}
+
+	for i, v := range testdata {
+		exp, mantissa, err := encodeBitrate(v.bitrate, v.bits)
+		test.EXPECT_EQ(t, exp, v.exp, "[%d]", i)
+		test.EXPECT_EQ(t, mantissa, v.mantissa, "[%d]", i)
+		test.EXPECT_EQ(t, err, v.err, "[%d]", i)
+	}
+}
+
+func TestNewRtcpNackPairs(t *testing.T) {
+	testdata := []struct {
+		lost  []uint16
+		pairs []RtcpNackPair
+	}{
+		{nil, nil},
+		{[]uint16{100}, []RtcpNackPair{{100, 0}}},
+		{[]uint16{100, 101, 103, 116}, []RtcpNackPair{{100, 0x8005}}},
+		{[]uint16{116, 100, 103, 101, 101}, []RtcpNackPair{{100, 0x8005}}},
+		{[]uint16{100, 117}, []RtcpNackPair{{100, 0}, {117, 0}}},
+		{[]uint16{65534, 65535, 0, 1, 20}, []RtcpNackPair{{65534, 0x0007}, {20, 0}}},
+		{[]uint16{0, 65535}, []RtcpNackPair{{65535, 0x0001}}},
+	}
+
+	for i, v := range testdata {
+		pairs := NewRtcpNackPairs(v.lost)
+		test.EXPECT_EQ(t, pairs, v.pairs, "[%d]", i)
+
+		nack := RtcpNack{Nacks: pairs}
+		list := nack.PacketList()
+		for _, seq := range v.lost {
+			found := false
+			for _, lost := range list {
+				found = found || lost == seq
+			}
+			test.EXPECT_EQ(t, found, true, "[%d] %d", i, seq)
+		}
+	}
+}
+
+func TestRtcpFeedbackPrint(t *testing.T) {
+	packets := []RtcpPacket{
+		&RtcpNack{SenderSsrc: 1, MediaSsrc: 2, Nacks: []RtcpNackPair{{PacketId: 100, LostPackets: 0x8005}}},
+		&RtcpRemb{SenderSsrc: 1, Bitrate: 256000, Ssrcs: []uint32{2}},
+	}
+
+	buf := buffer.NewByteBuffer(nil)
+	PrintRtcpCompound(buf, packets)
+
+	wanted := `10.. .... = version: 2
+..0. .... = Padding: false
+...0 0001 = RTCP Feedback message type (FMT): 1
+Packet type: Generic RTP Feedback (205)
+Length: 3 (16 bytes)
+Sender SSRC: 0x00000001 (1)
+Media source SSRC: 0x00000002 (2)
+RTCP Transport Feedback NACK PID: 100
+RTCP Transport Feedback NACK BLP: 0x8005
+
+10.. .... = version: 2
+..0. .... = Padding: false
+...0 1111 = RTCP Feedback message type (FMT): 15
+Packet type: Payload-specific Feedback (206)
+Length: 5 (24 bytes)
+Sender SSRC: 0x00000001 (1)
+Media source SSRC: 0x00000000 (0)
+Unique Identifier: REMB
+Number of Ssrcs: 1
+Maximum bitrate: 256000
+SSRC feedback: 0x00000002 (2)
+`
+	test.EXPECT_EQ(t, buf.String(), wanted, "")
+}
@@ -0,0 +1,58 @@
+package rtcp
+
+import (
+	"time"
+)
+
+// constants of the rtcp transmission interval from RFC3550 section 6.3 and
+// appendix A.7
+const (
+	RTCP_MIN_TIME              = 5 * time.Second
+	RTCP_BANDWIDTH_FRACTION    = 0.05
+	RTCP_SENDER_BW_FRACTION    = 0.25
+	RTCP_RECEIVER_BW_FRACTION  = 1 - RTCP_SENDER_BW_FRACTION
+	RTCP_COMPENSATION          = 2.71828 - 1.5
+	RTCP_AVPF_INITIAL_MIN_TIME = time.Second
+
+	// octets of the IPv4 and UDP headers added to every packet when
+	// averaging the rtcp packet size
+	RTCP_UDP_IP_OVERHEAD = 28
+)
+
+// RtcpDeterministicInterval is the interval of RFC3550 appendix A.7 before
+// randomization. rtcpBandwidth is in octets per second and avgRtcpSize in
+// octets; minTime is RTCP_MIN_TIME, halved for the initial packet.
+func RtcpDeterministicInterval(members, senders int, rtcpBandwidth float64, weSent bool, avgRtcpSize float64, minTime time.Duration) time.Duration {
+	n := members
+	// the senders get a quarter of the bandwidth when they are few
+	if float64(senders) <= float64(members)*RTCP_SENDER_BW_FRACTION {
+		if weSent {
+			rtcpBandwidth *= RTCP_SENDER_BW_FRACTION
+			n = senders
+		} else {
+			rtcpBandwidth *= RTCP_RECEIVER_BW_FRACTION
+			n -= senders
+		}
+	}
+
+	t := minTime
+	if rtcpBandwidth > 0 {
+		calculated := time.Duration(avgRtcpSize * float64(n) / rtcpBandwidth * float64(time.Second))
+		if calculated > t {
+			t = calculated
+		}
+	}
+	return t
+}
+
+// RtcpRandomizedInterval spreads td over [0.5, 1.5) with random in [0, 1) and
+// divides it by e-3/2 to compensate for the timer reconsideration.
+func RtcpRandomizedInterval(td time.Duration, random float64) time.Duration {
+	return time.Duration(float64(td) * (random + 0.5) / RTCP_COMPENSATION)
+}
+
+// UpdateAvgRtcpSize averages the size of sent and received compound packets
+// with the weight 1/16 of the new packet.
+func UpdateAvgRtcpSize(avg float64, size int) float64 {
+	return float64(size)/16 + avg*15/16
+}
@@ -0,0 +1,44 @@
+package rtcp
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/lioneagle/goutil/src/test"
+)
+
+func TestRtcpDeterministicInterval(t *testing.T) {
+	testdata := []struct {
+		members     int
+		senders     int
+		weSent      bool
+		avgRtcpSize float64
+		minTime     time.Duration
+		interval    time.Duration
+	}{
+		{2, 1, true, 100, RTCP_MIN_TIME, 5 * time.Second},
+		{4, 1, true, 200, RTCP_MIN_TIME, 8 * time.Second},
+		{4, 1, false, 200, RTCP_MIN_TIME, 8 * time.Second},
+		{4, 1, false, 100, 0, 4 * time.Second},
+		{2, 1, false, 200, 0, 4 * time.Second},
+		{4, 2, true, 200, RTCP_MIN_TIME, 8 * time.Second},
+		{100, 0, false, 100, RTCP_MIN_TIME / 2, 133333333333},
+	}
+
+	for i, v := range testdata {
+		v := v
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			t.Parallel()
+
+			interval := RtcpDeterministicInterval(v.members, v.senders, 100, v.weSent, v.avgRtcpSize, v.minTime)
+			test.EXPECT_EQ(t, interval, v.interval, "")
+		})
+	}
+}
+
+func TestRtcpRandomizedInterval(t *testing.T) {
+	test.EXPECT_EQ(t, RtcpRandomizedInterval(5*time.Second, 0.5).String(), "4.104146829s", "")
+	test.EXPECT_EQ(t, RtcpRandomizedInterval(5*time.Second, 0), RtcpRandomizedInterval(5*time.Second, 0.5)/2, "")
+	test.EXPECT_EQ(t, fmt.Sprintf("%.2f", UpdateAvgRtcpSize(100, 260)), "110.00", "")
+}
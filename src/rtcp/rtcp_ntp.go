@@ -0,0 +1,29 @@
+package rtcp
+
+import (
+	"time"
+)
+
+// seconds from 1900-01-01, the NTP epoch, to 1970-01-01
+const RTCP_NTP_EPOCH_OFFSET = 2208988800
+
+// NtpTimestamp converts t to the 64-bit NTP timestamp format used by SR and
+// the receiver reference time block.
+func NtpTimestamp(t time.Time) uint64 {
+	seconds := uint64(t.Unix() + RTCP_NTP_EPOCH_OFFSET)
+	fraction := (uint64(t.Nanosecond()) << 32) / uint64(time.Second)
+	return seconds<<32 | fraction
+}
+
+// NtpTime converts a 64-bit NTP timestamp back to a time.
+func NtpTime(ntp uint64) time.Time {
+	seconds := int64(ntp>>32) - RTCP_NTP_EPOCH_OFFSET
+	nanoseconds := int64(((ntp & 0xFFFFFFFF) * uint64(time.Second)) >> 32)
+	return time.Unix(seconds, nanoseconds)
+}
+
+// NtpMiddle returns the middle 32 bits of a NTP timestamp, the format of the
+// LSR and LRR fields.
+func NtpMiddle(ntp uint64) uint32 {
+	return uint32(ntp >> 16)
+}
@@ -0,0 +1,342 @@
+package rtcp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/lioneagle/goutil/src/buffer"
+)
+
+const (
+	RTCP_VERSION_MARSK = 0xC0
+	RTCP_PADDING_MARSK = 0x20
+	RTCP_COUNT_MARSK   = 0x1F
+
+	RTCP_HEADER_LEN = 4
+	RTCP_VERSION    = 2
+	RTCP_MAX_COUNT  = 31
+)
+
+// rtcp packet types from RFC3550, RFC3611 and RFC4585
+const (
+	RTCP_PT_SR    = 200
+	RTCP_PT_RR    = 201
+	RTCP_PT_SDES  = 202
+	RTCP_PT_BYE   = 203
+	RTCP_PT_APP   = 204
+	RTCP_PT_RTPFB = 205
+	RTCP_PT_PSFB  = 206
+	RTCP_PT_XR    = 207
+)
+
+var (
+	ErrTooShort         = errors.New("rtcp: packet too short")
+	ErrBadVersion       = errors.New("rtcp: bad version")
+	ErrBadLength        = errors.New("rtcp: length does not match packet")
+	ErrBadPadding       = errors.New("rtcp: bad padding")
+	ErrBadPacketType    = errors.New("rtcp: unexpected packet type")
+	ErrBadFirstPacket   = errors.New("rtcp: compound packet does not start with an unpadded SR or RR")
+	ErrTooManyItems     = errors.New("rtcp: too many reports, chunks or sources")
+	ErrBufferTooSmall   = errors.New("rtcp: buffer too small")
+	ErrBadAlignment     = errors.New("rtcp: data is not a multiple of 4 octets")
+	ErrEmptyCompound    = errors.New("rtcp: empty compound packet")
+	ErrPaddingNotAtLast = errors.New("rtcp: padding bit set before the last packet of a compound packet")
+)
+
+// RtcpHeader is the common header of every rtcp packet. Length is in 32-bit
+// words minus one, as on the wire.
+type RtcpHeader struct {
+	Version    byte
+	Padding    bool
+	Count      byte
+	PacketType byte
+	Length     uint16
+}
+
+// RtcpPacket is one packet of a compound rtcp packet.
+type RtcpPacket interface {
+	GetPacketType() byte
+	MarshalSize() int
+	// MarshalTo writes the packet including its header and returns the
+	// number of octets written.
+	MarshalTo(buf []byte) (int, error)
+	// Unmarshal parses exactly one packet including its header.
+	Unmarshal(data []byte) error
+	Print(w io.Writer)
+}
+
+func (this *RtcpHeader) Unmarshal(data []byte) error {
+	if len(data) < RTCP_HEADER_LEN {
+		return ErrTooShort
+	}
+	this.Version = (data[0] & RTCP_VERSION_MARSK) >> 6
+	this.Padding = data[0]&RTCP_PADDING_MARSK != 0
+	this.Count = data[0] & RTCP_COUNT_MARSK
+	this.PacketType = data[1]
+	this.Length = binary.BigEndian.Uint16(data[2:])
+	if this.Version != RTCP_VERSION {
+		return ErrBadVersion
+	}
+	return nil
+}
+
+func (this *RtcpHeader) MarshalTo(buf []byte) {
+	buf[0] = (this.Version << 6) | (this.Count & RTCP_COUNT_MARSK)
+	if this.Padding {
+		buf[0] |= RTCP_PADDING_MARSK
+	}
+	buf[1] = this.PacketType
+	binary.BigEndian.PutUint16(buf[2:], this.Length)
+}
+
+// PacketLen returns the length of the packet in octets.
+func (this *RtcpHeader) PacketLen() int {
+	return (int(this.Length) + 1) * 4
+}
+
+func (this *RtcpHeader) Print(w io.Writer, countName string) {
+	fmt.Fprintf(w, "%02b.. .... = version: %d\n", this.Version, this.Version)
+	padding := byte(0)
+	if this.Padding {
+		padding = 1
+	}
+	fmt.Fprintf(w, "..%01b. .... = Padding: %v\n", padding, this.Padding)
+	fmt.Fprintf(w, "...%01b %04b = %s: %d\n", this.Count>>4, this.Count&0x0F, countName, this.Count)
+	fmt.Fprintf(w, "Packet type: %s (%d)\n", GetPacketTypeName(this.PacketType), this.PacketType)
+	fmt.Fprintf(w, "Length: %d (%d bytes)\n", this.Length, this.PacketLen())
+}
+
+func GetPacketTypeName(packetType byte) string {
+	switch packetType {
+	case RTCP_PT_SR:
+		return "Sender Report"
+	case RTCP_PT_RR:
+		return "Receiver Report"
+	case RTCP_PT_SDES:
+		return "Source description"
+	case RTCP_PT_BYE:
+		return "Goodbye"
+	case RTCP_PT_APP:
+		return "Application specific"
+	case RTCP_PT_RTPFB:
+		return "Generic RTP Feedback"
+	case RTCP_PT_PSFB:
+		return "Payload-specific Feedback"
+	case RTCP_PT_XR:
+		return "Extended report"
+	}
+	return "unknown"
+}
+
+// parseRtcpHeader checks the common header of a single packet of the expected
+// type and returns the body after the header with any padding removed.
+func parseRtcpHeader(header *RtcpHeader, data []byte, packetType byte) ([]byte, error) {
+	err := header.Unmarshal(data)
+	if err != nil {
+		return nil, err
+	}
+	if header.PacketType != packetType {
+		return nil, ErrBadPacketType
+	}
+	if header.PacketLen() != len(data) {
+		return nil, ErrBadLength
+	}
+
+	body := data[RTCP_HEADER_LEN:]
+	if header.Padding {
+		if len(body) == 0 {
+			return nil, ErrBadPadding
+		}
+		pad := int(body[len(body)-1])
+		if pad == 0 || pad > len(body) {
+			return nil, ErrBadPadding
+		}
+		body = body[:len(body)-pad]
+	}
+	return body, nil
+}
+
+// marshalRtcpHeader writes the header of a packet of size octets. count is
+// checked before it is narrowed to the 5 bits of the header.
+func marshalRtcpHeader(buf []byte, packetType byte, count int, size int) error {
+	if len(buf) < size {
+		return ErrBufferTooSmall
+	}
+	if count < 0 || count > RTCP_MAX_COUNT {
+		return ErrTooManyItems
+	}
+	header := RtcpHeader{Version: RTCP_VERSION, Count: byte(count), PacketType: packetType, Length: uint16(size/4 - 1)}
+	header.MarshalTo(buf)
+	return nil
+}
+
+// RtcpRawPacket keeps a packet whose type is not known to this package.
+type RtcpRawPacket struct {
+	Header RtcpHeader
+	Data   []byte
+}
+
+func (this *RtcpRawPacket) GetPacketType() byte {
+	return this.Header.PacketType
+}
+
+func (this *RtcpRawPacket) MarshalSize() int {
+	return len(this.Data)
+}
+
+func (this *RtcpRawPacket) MarshalTo(buf []byte) (int, error) {
+	if len(buf) < len(this.Data) {
+		return 0, ErrBufferTooSmall
+	}
+	return copy(buf, this.Data), nil
+}
+
+func (this *RtcpRawPacket) Unmarshal(data []byte) error {
+	err := this.Header.Unmarshal(data)
+	if err != nil {
+		return err
+	}
+	if this.Header.PacketLen() != len(data) {
+		return ErrBadLength
+	}
+	this.Data = append(this.Data[:0], data...)
+	return nil
+}
+
+func (this *RtcpRawPacket) Print(w io.Writer) {
+	this.Header.Print(w, "Count")
+	if len(this.Data) > RTCP_HEADER_LEN {
+		body := this.Data[RTCP_HEADER_LEN:]
+		fmt.Fprintf(w, "Data:\n")
+		buffer.PrintAsHex(w, body, 0, len(body))
+	}
+}
+
+// rtcpPacketFactories return the packet type for a packet, or nil when it
+// should be kept as a RtcpRawPacket.
+var rtcpPacketFactories = map[byte]func(header *RtcpHeader, data []byte) RtcpPacket{
+	RTCP_PT_SR:    func(header *RtcpHeader, data []byte) RtcpPacket { return &RtcpSenderReport{} },
+	RTCP_PT_RR:    func(header *RtcpHeader, data []byte) RtcpPacket { return &RtcpReceiverReport{} },
+	RTCP_PT_SDES:  func(header *RtcpHeader, data []byte) RtcpPacket { return &RtcpSdes{} },
+	RTCP_PT_BYE:   func(header *RtcpHeader, data []byte) RtcpPacket { return &RtcpBye{} },
+	RTCP_PT_APP:   func(header *RtcpHeader, data []byte) RtcpPacket { return &RtcpApp{} },
+	RTCP_PT_RTPFB: newRtpFeedback,
+	RTCP_PT_PSFB:  newPayloadSpecificFeedback,
+	RTCP_PT_XR:    func(header *RtcpHeader, data []byte) RtcpPacket { return &RtcpXr{} },
+}
+
+func newRtcpPacket(header *RtcpHeader, data []byte) RtcpPacket {
+	factory, ok := rtcpPacketFactories[header.PacketType]
+	if !ok {
+		return &RtcpRawPacket{}
+	}
+	packet := factory(header, data)
+	if packet == nil {
+		return &RtcpRawPacket{}
+	}
+	return packet
+}
+
+// ParseRtcpPackets splits data into packets without the compound packet
+// checks, as needed for reduced-size rtcp from RFC5506.
+func ParseRtcpPackets(data []byte) ([]RtcpPacket, error) {
+	var packets []RtcpPacket
+
+	for len(data) > 0 {
+		header := RtcpHeader{}
+		err := header.Unmarshal(data)
+		if err != nil {
+			return nil, err
+		}
+		size := header.PacketLen()
+		if size > len(data) {
+			return nil, ErrBadLength
+		}
+
+		packet := newRtcpPacket(&header, data[:size])
+		err = packet.Unmarshal(data[:size])
+		if err != nil {
+			return nil, err
+		}
+		packets = append(packets, packet)
+		data = data[size:]
+	}
+
+	if len(packets) == 0 {
+		return nil, ErrEmptyCompound
+	}
+	return packets, nil
+}
+
+// ParseRtcpCompound parses a compound packet with the validity checks of
+// RFC3550 appendix A.2: every packet is version 2, the first packet is a SR
+// or RR, only the last packet may be padded and the lengths add up to the
+// length of data.
+func ParseRtcpCompound(data []byte) ([]RtcpPacket, error) {
+	if len(data) < RTCP_HEADER_LEN {
+		return nil, ErrTooShort
+	}
+	// the header check of RFC3550 appendix A.2 wants the padding bit of
+	// the first packet clear, even when it is the only one
+	if data[0]&RTCP_PADDING_MARSK != 0 || (data[1] != RTCP_PT_SR && data[1] != RTCP_PT_RR) {
+		return nil, ErrBadFirstPacket
+	}
+
+	for pos := 0; pos < len(data); {
+		header := RtcpHeader{}
+		err := header.Unmarshal(data[pos:])
+		if err != nil {
+			return nil, err
+		}
+		pos += header.PacketLen()
+		if header.Padding && pos < len(data) {
+			return nil, ErrPaddingNotAtLast
+		}
+	}
+
+	return ParseRtcpPackets(data)
+}
+
+func MarshalRtcpPacket(packet RtcpPacket) ([]byte, error) {
+	buf := make([]byte, packet.MarshalSize())
+	n, err := packet.MarshalTo(buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+// MarshalRtcpCompound concatenates packets into one compound packet.
+func MarshalRtcpCompound(packets []RtcpPacket) ([]byte, error) {
+	size := 0
+	for _, v := range packets {
+		size += v.MarshalSize()
+	}
+
+	buf := make([]byte, size)
+	pos := 0
+	for _, v := range packets {
+		n, err := v.MarshalTo(buf[pos:])
+		if err != nil {
+			return nil, err
+		}
+		pos += n
+	}
+	return buf[:pos], nil
+}
+
+func PrintRtcpCompound(w io.Writer, packets []RtcpPacket) {
+	for i, v := range packets {
+		if i > 0 {
+			fmt.Fprintf(w, "\n")
+		}
+		v.Print(w)
+	}
+}
+
+// pad4 returns n rounded up to a multiple of 4.
+func pad4(n int) int {
+	return (n + 3) &^ 0x3
+}
@@ -0,0 +1,234 @@
+package rtcp
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/lioneagle/goutil/src/buffer"
+	"github.com/lioneagle/goutil/src/test"
+)
+
+func TestRtcpPacketRoundTrip(t *testing.T) {
+	testdata := []struct {
+		packet RtcpPacket
+		data   []byte
+	}{
+		{&RtcpSenderReport{Ssrc: 0x902f9e2e, NtpTimestamp: 0xda8bd1fcdddda05a, RtpTimestamp: 0xaaf4edd5, PacketCount: 1, OctetCount: 2,
+			Reports: []RtcpReportBlock{{Ssrc: 0xbc5e9a40, FractionLost: 0x01, Lost: -2, ExtendedSequence: 0x46e1, Jitter: 0x273, LastSr: 0x9f36432, DelaySinceLastSr: 0x150137}}},
+			[]byte{0x81, 0xc8, 0x00, 0x0c, 0x90, 0x2f, 0x9e, 0x2e, 0xda, 0x8b, 0xd1, 0xfc, 0xdd, 0xdd, 0xa0, 0x5a, 0xaa, 0xf4, 0xed, 0xd5,
+				0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x02,
+				0xbc, 0x5e, 0x9a, 0x40, 0x01, 0xff, 0xff, 0xfe, 0x00, 0x00, 0x46, 0xe1, 0x00, 0x00, 0x02, 0x73, 0x09, 0xf3, 0x64, 0x32, 0x00, 0x15, 0x01, 0x37}},
+		{&RtcpReceiverReport{Ssrc: 0x902f9e2e, Reports: []RtcpReportBlock{{Ssrc: 0xbc5e9a40, Lost: 3, ExtendedSequence: 0x46e1}}, Extensions: []byte{1, 2, 3, 4}},
+			[]byte{0x81, 0xc9, 0x00, 0x08, 0x90, 0x2f, 0x9e, 0x2e,
+				0xbc, 0x5e, 0x9a, 0x40, 0x00, 0x00, 0x00, 0x03, 0x00, 0x00, 0x46, 0xe1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+				1, 2, 3, 4}},
+		{&RtcpReceiverReport{Ssrc: 1},
+			[]byte{0x80, 0xc9, 0x00, 0x01, 0x00, 0x00, 0x00, 0x01}},
+		{&RtcpSdes{Chunks: []RtcpSdesChunk{
+			{Ssrc: 0x01020304, Items: []RtcpSdesItem{{RTCP_SDES_CNAME, "ab"}}},
+			{Ssrc: 0x05060708, Items: []RtcpSdesItem{{RTCP_SDES_CNAME, "abcd"}, {RTCP_SDES_TOOL, "x"}}}}},
+			[]byte{0x82, 0xca, 0x00, 0x07,
+				0x01, 0x02, 0x03, 0x04, 0x01, 0x02, 'a', 'b', 0x00, 0x00, 0x00, 0x00,
+				0x05, 0x06, 0x07, 0x08, 0x01, 0x04, 'a', 'b', 'c', 'd', 0x06, 0x01, 'x', 0x00, 0x00, 0x00}},
+		{&RtcpBye{Sources: []uint32{0x01020304, 0x05060708}, Reason: "ciao"},
+			[]byte{0x82, 0xcb, 0x00, 0x04, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x04, 'c', 'i', 'a', 'o', 0x00, 0x00, 0x00}},
+		{&RtcpBye{Sources: []uint32{0x01020304}},
+			[]byte{0x81, 0xcb, 0x00, 0x01, 0x01, 0x02, 0x03, 0x04}},
+		{&RtcpApp{Subtype: 3, Ssrc: 0x01020304, Name: "QTSS", Data: []byte{0, 0, 0, 1}},
+			[]byte{0x83, 0xcc, 0x00, 0x03, 0x01, 0x02, 0x03, 0x04, 'Q', 'T', 'S', 'S', 0, 0, 0, 1}},
+	}
+
+	for i, v := range testdata {
+		v := v
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			t.Parallel()
+
+			data, err := MarshalRtcpPacket(v.packet)
+			test.EXPECT_EQ(t, err, nil, "")
+			test.EXPECT_EQ(t, data, v.data, "")
+
+			packets, err := ParseRtcpPackets(v.data)
+			test.EXPECT_EQ(t, err, nil, "")
+			test.EXPECT_EQ(t, packets, []RtcpPacket{v.packet}, "")
+		})
+	}
+}
+
+func TestRtcpPacketUnmarshalError(t *testing.T) {
+	testdata := []struct {
+		packet RtcpPacket
+		data   []byte
+		err    error
+	}{
+		{&RtcpReceiverReport{}, []byte{0x80, 0xc9, 0x00}, ErrTooShort},
+		{&RtcpReceiverReport{}, []byte{0x40, 0xc9, 0x00, 0x01, 0x00, 0x00, 0x00, 0x01}, ErrBadVersion},
+		{&RtcpReceiverReport{}, []byte{0x80, 0xc8, 0x00, 0x01, 0x00, 0x00, 0x00, 0x01}, ErrBadPacketType},
+		{&RtcpReceiverReport{}, []byte{0x80, 0xc9, 0x00, 0x02, 0x00, 0x00, 0x00, 0x01}, ErrBadLength},
+		{&RtcpReceiverReport{}, []byte{0x81, 0xc9, 0x00, 0x01, 0x00, 0x00, 0x00, 0x01}, ErrTooShort},
+		{&RtcpReceiverReport{}, []byte{0xa0, 0xc9, 0x00, 0x01, 0x00, 0x00, 0x00, 0x05}, ErrBadPadding},
+		{&RtcpReceiverReport{}, []byte{0xa0, 0xc9, 0x00, 0x02, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00}, ErrBadPadding},
+		{&RtcpSenderReport{}, []byte{0x80, 0xc8, 0x00, 0x01, 0x00, 0x00, 0x00, 0x01}, ErrTooShort},
+		{&RtcpSdes{}, []byte{0x81, 0xca, 0x00, 0x02, 0x00, 0x00, 0x00, 0x01, 0x01, 0x05, 'a', 'b'}, ErrBadSdesItem},
+		{&RtcpSdes{}, []byte{0x81, 0xca, 0x00, 0x02, 0x00, 0x00, 0x00, 0x01, 0x01, 0x02, 'a', 'b'}, ErrBadSdesItem},
+		{&RtcpBye{}, []byte{0x82, 0xcb, 0x00, 0x01, 0x00, 0x00, 0x00, 0x01}, ErrTooShort},
+		{&RtcpBye{}, []byte{0x81, 0xcb, 0x00, 0x02, 0x00, 0x00, 0x00, 0x01, 0x04, 'a', 'b', 'c'}, ErrBadReason},
+		{&RtcpApp{}, []byte{0x80, 0xcc, 0x00, 0x01, 0x00, 0x00, 0x00, 0x01}, ErrTooShort},
+	}
+
+	for i, v := range testdata {
+		test.EXPECT_EQ(t, v.packet.Unmarshal(v.data), v.err, "[%d]", i)
+	}
+}
+
+func TestRtcpPacketMarshalError(t *testing.T) {
+	testdata := []struct {
+		packet RtcpPacket
+		err    error
+	}{
+		{&RtcpReceiverReport{Reports: make([]RtcpReportBlock, 32)}, ErrTooManyItems},
+		// counts that wrap around the octet
+		{&RtcpReceiverReport{Reports: make([]RtcpReportBlock, 256)}, ErrTooManyItems},
+		{&RtcpSenderReport{Reports: make([]RtcpReportBlock, 257)}, ErrTooManyItems},
+		{&RtcpBye{Sources: make([]uint32, 258)}, ErrTooManyItems},
+		{&RtcpSdes{Chunks: make([]RtcpSdesChunk, 259)}, ErrTooManyItems},
+		{&RtcpSenderReport{Extensions: []byte{1}}, ErrBadAlignment},
+		{&RtcpSdes{Chunks: []RtcpSdesChunk{{Items: []RtcpSdesItem{{RTCP_SDES_CNAME, string(make([]byte, 256))}}}}}, ErrSdesTextTooBig},
+		{&RtcpBye{Reason: string(make([]byte, 256))}, ErrReasonTooBig},
+		{&RtcpApp{Name: "abc"}, ErrBadAppName},
+		{&RtcpApp{Name: "abcd", Subtype: 32}, ErrBadAppSubtype},
+		{&RtcpApp{Name: "abcd", Data: []byte{1}}, ErrBadAppDataSize},
+	}
+
+	for i, v := range testdata {
+		_, err := MarshalRtcpPacket(v.packet)
+		test.EXPECT_EQ(t, err, v.err, "[%d]", i)
+	}
+
+	_, err := (&RtcpReceiverReport{}).MarshalTo(make([]byte, 7))
+	test.EXPECT_EQ(t, err, ErrBufferTooSmall, "")
+}
+
+func TestParseRtcpCompound(t *testing.T) {
+	rr := []byte{0x80, 0xc9, 0x00, 0x01, 0x00, 0x00, 0x00, 0x01}
+	paddedRr := []byte{0xa0, 0xc9, 0x00, 0x02, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x04}
+	sdes := []byte{0x81, 0xca, 0x00, 0x02, 0x00, 0x00, 0x00, 0x01, 0x01, 0x01, 'a', 0x00}
+	paddedSdes := []byte{0xa1, 0xca, 0x00, 0x03, 0x00, 0x00, 0x00, 0x01, 0x01, 0x01, 'a', 0x00, 0x00, 0x00, 0x00, 0x04}
+	unknown := []byte{0x80, 0xd0, 0x00, 0x01, 0x01, 0x02, 0x03, 0x04}
+
+	join := func(parts ...[]byte) (ret []byte) {
+		for _, v := range parts {
+			ret = append(ret, v...)
+		}
+		return ret
+	}
+
+	// padding is dropped on unmarshal, so padded input is marshalled to unpadded
+	testdata := []struct {
+		data      []byte
+		num       int
+		err       error
+		marshaled []byte
+	}{
+		{nil, 0, ErrTooShort, nil},
+		{join(rr, sdes), 2, nil, join(rr, sdes)},
+		{join(rr, paddedSdes), 2, nil, join(rr, sdes)},
+		{join(paddedRr, sdes), 0, ErrBadFirstPacket, nil},
+		{paddedRr, 0, ErrBadFirstPacket, nil},
+		{join(rr, paddedSdes, sdes), 0, ErrPaddingNotAtLast, nil},
+		{join(sdes, rr), 0, ErrBadFirstPacket, nil},
+		{join(rr, sdes[:8]), 0, ErrBadLength, nil},
+		{join(rr, []byte{0x40}, sdes[1:]), 0, ErrBadVersion, nil},
+		{join(rr, unknown), 2, nil, join(rr, unknown)},
+	}
+
+	for i, v := range testdata {
+		v := v
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			t.Parallel()
+
+			packets, err := ParseRtcpCompound(v.data)
+			test.EXPECT_EQ(t, err, v.err, "")
+			test.EXPECT_EQ(t, len(packets), v.num, "")
+			if err != nil {
+				return
+			}
+
+			data, err := MarshalRtcpCompound(packets)
+			test.EXPECT_EQ(t, err, nil, "")
+			test.EXPECT_EQ(t, data, v.marshaled, "")
+		})
+	}
+}
+
+func TestRtcpCompoundPrint(t *testing.T) {
+	packets := []RtcpPacket{
+		&RtcpSenderReport{Ssrc: 0x01020304, NtpTimestamp: 0x0000000100000002, RtpTimestamp: 160, PacketCount: 1, OctetCount: 160,
+			Reports: []RtcpReportBlock{{Ssrc: 5, FractionLost: 2, Lost: 3, ExtendedSequence: 4, Jitter: 5, LastSr: 6, DelaySinceLastSr: 65536}}},
+		NewRtcpSdesCname(0x01020304, "user@host"),
+		&RtcpBye{Sources: []uint32{0x01020304}, Reason: "bye"},
+		&RtcpApp{Subtype: 1, Ssrc: 0x01020304, Name: "TEST", Data: []byte{1, 2, 3, 4}},
+	}
+
+	buf := buffer.NewByteBuffer(nil)
+	PrintRtcpCompound(buf, packets)
+
+	wanted := `10.. .... = version: 2
+..0. .... = Padding: false
+...0 0001 = Reception report count: 1
+Packet type: Sender Report (200)
+Length: 12 (52 bytes)
+Sender SSRC: 0x01020304 (16909060)
+Timestamp, MSW: 1 (0x00000001)
+Timestamp, LSW: 2 (0x00000002)
+RTP timestamp: 160
+Sender's packet count: 1
+Sender's octet count: 160
+Source 1
+Identifier: 0x00000005 (5)
+Fraction lost: 2 / 256
+Cumulative number of packets lost: 3
+Extended highest sequence number received: 4
+Interarrival jitter: 5
+Last SR timestamp: 6 (0x00000006)
+Delay since last SR timestamp: 65536 (1000 milliseconds)
+
+10.. .... = version: 2
+..0. .... = Padding: false
+...0 0001 = Source count: 1
+Packet type: Source description (202)
+Length: 4 (20 bytes)
+Chunk 1, SSRC/CSRC 0x01020304
+Type: CNAME (user and domain) (1)
+Length: 9
+Text: user@host
+
+10.. .... = version: 2
+..0. .... = Padding: false
+...0 0001 = Source count: 1
+Packet type: Goodbye (203)
+Length: 2 (12 bytes)
+Identifier 1: 0x01020304 (16909060)
+Length: 3
+Reason for leaving: bye
+
+10.. .... = version: 2
+..0. .... = Padding: false
+...0 0001 = Subtype: 1
+Packet type: Application specific (204)
+Length: 3 (16 bytes)
+Identifier: 0x01020304 (16909060)
+Name (ASCII): TEST
+Application specific data:
+00000000h: 01 02 03 04                                      ; ....
+`
+	test.EXPECT_EQ(t, buf.String(), wanted, "")
+}
+
+func TestRtcpRawPacketEmpty(t *testing.T) {
+	packet := &RtcpRawPacket{Header: RtcpHeader{Version: RTCP_VERSION, PacketType: 210}}
+
+	buf := buffer.NewByteBuffer(nil)
+	packet.Print(buf)
+	test.EXPECT_EQ(t, packet.MarshalSize(), 0, "")
+	test.EXPECT_EQ(t, packet.GetPacketType(), byte(210), "")
+}
@@ -0,0 +1,234 @@
+package rtcp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/lioneagle/goutil/src/buffer"
+)
+
+const (
+	RTCP_REPORT_BLOCK_LEN = 24
+	RTCP_SENDER_INFO_LEN  = 20
+)
+
+// RtcpReportBlock is one reception report block of a SR or RR. Lost is the
+// signed 24-bit cumulative number of packets lost.
+type RtcpReportBlock struct {
+	Ssrc             uint32
+	FractionLost     byte
+	Lost             int32
+	ExtendedSequence uint32
+	Jitter           uint32
+	LastSr           uint32
+	DelaySinceLastSr uint32
+}
+
+func (this *RtcpReportBlock) MarshalTo(buf []byte) {
+	binary.BigEndian.PutUint32(buf[0:], this.Ssrc)
+	lost := uint32(this.Lost)
+	if this.Lost > 0x7FFFFF {
+		lost = 0x7FFFFF
+	} else if this.Lost < -0x800000 {
+		lost = uint32(0x800000)
+	}
+	binary.BigEndian.PutUint32(buf[4:], uint32(this.FractionLost)<<24|lost&0xFFFFFF)
+	binary.BigEndian.PutUint32(buf[8:], this.ExtendedSequence)
+	binary.BigEndian.PutUint32(buf[12:], this.Jitter)
+	binary.BigEndian.PutUint32(buf[16:], this.LastSr)
+	binary.BigEndian.PutUint32(buf[20:], this.DelaySinceLastSr)
+}
+
+func (this *RtcpReportBlock) Unmarshal(data []byte) {
+	this.Ssrc = binary.BigEndian.Uint32(data[0:])
+	this.FractionLost = data[4]
+	// sign extend from 24 bits
+	this.Lost = int32(binary.BigEndian.Uint32(data[4:])<<8) >> 8
+	this.ExtendedSequence = binary.BigEndian.Uint32(data[8:])
+	this.Jitter = binary.BigEndian.Uint32(data[12:])
+	this.LastSr = binary.BigEndian.Uint32(data[16:])
+	this.DelaySinceLastSr = binary.BigEndian.Uint32(data[20:])
+}
+
+func (this *RtcpReportBlock) Print(w io.Writer) {
+	fmt.Fprintf(w, "Identifier: 0x%08x (%d)\n", this.Ssrc, this.Ssrc)
+	fmt.Fprintf(w, "Fraction lost: %d / 256\n", this.FractionLost)
+	fmt.Fprintf(w, "Cumulative number of packets lost: %d\n", this.Lost)
+	fmt.Fprintf(w, "Extended highest sequence number received: %d\n", this.ExtendedSequence)
+	fmt.Fprintf(w, "Interarrival jitter: %d\n", this.Jitter)
+	fmt.Fprintf(w, "Last SR timestamp: %d (0x%08x)\n", this.LastSr, this.LastSr)
+	fmt.Fprintf(w, "Delay since last SR timestamp: %d (%d milliseconds)\n", this.DelaySinceLastSr, uint64(this.DelaySinceLastSr)*1000/65536)
+}
+
+func unmarshalReportBlocks(count byte, data []byte) ([]RtcpReportBlock, []byte, error) {
+	if len(data) < int(count)*RTCP_REPORT_BLOCK_LEN {
+		return nil, nil, ErrTooShort
+	}
+	var reports []RtcpReportBlock
+	for i := 0; i < int(count); i++ {
+		report := RtcpReportBlock{}
+		report.Unmarshal(data[i*RTCP_REPORT_BLOCK_LEN:])
+		reports = append(reports, report)
+	}
+	return reports, data[int(count)*RTCP_REPORT_BLOCK_LEN:], nil
+}
+
+func printReportBlocks(w io.Writer, reports []RtcpReportBlock, extensions []byte) {
+	for i := range reports {
+		fmt.Fprintf(w, "Source %d\n", i+1)
+		reports[i].Print(w)
+	}
+	if len(extensions) > 0 {
+		fmt.Fprintf(w, "Profile-specific extension:\n")
+		buffer.PrintAsHex(w, extensions, 0, len(extensions))
+	}
+}
+
+type RtcpSenderReport struct {
+	Ssrc         uint32
+	NtpTimestamp uint64
+	RtpTimestamp uint32
+	PacketCount  uint32
+	OctetCount   uint32
+	Reports      []RtcpReportBlock
+	// profile-specific extensions, a multiple of 4 octets
+	Extensions []byte
+}
+
+func (this *RtcpSenderReport) GetPacketType() byte {
+	return RTCP_PT_SR
+}
+
+func (this *RtcpSenderReport) MarshalSize() int {
+	return RTCP_HEADER_LEN + 4 + RTCP_SENDER_INFO_LEN + len(this.Reports)*RTCP_REPORT_BLOCK_LEN + len(this.Extensions)
+}
+
+func (this *RtcpSenderReport) MarshalTo(buf []byte) (int, error) {
+	if len(this.Extensions)&0x3 != 0 {
+		return 0, ErrBadAlignment
+	}
+	size := this.MarshalSize()
+	err := marshalRtcpHeader(buf, RTCP_PT_SR, len(this.Reports), size)
+	if err != nil {
+		return 0, err
+	}
+
+	binary.BigEndian.PutUint32(buf[4:], this.Ssrc)
+	binary.BigEndian.PutUint64(buf[8:], this.NtpTimestamp)
+	binary.BigEndian.PutUint32(buf[16:], this.RtpTimestamp)
+	binary.BigEndian.PutUint32(buf[20:], this.PacketCount)
+	binary.BigEndian.PutUint32(buf[24:], this.OctetCount)
+	pos := 28
+	for i := range this.Reports {
+		this.Reports[i].MarshalTo(buf[pos:])
+		pos += RTCP_REPORT_BLOCK_LEN
+	}
+	pos += copy(buf[pos:], this.Extensions)
+	return pos, nil
+}
+
+func (this *RtcpSenderReport) Unmarshal(data []byte) error {
+	header := RtcpHeader{}
+	body, err := parseRtcpHeader(&header, data, RTCP_PT_SR)
+	if err != nil {
+		return err
+	}
+	if len(body) < 4+RTCP_SENDER_INFO_LEN {
+		return ErrTooShort
+	}
+
+	this.Ssrc = binary.BigEndian.Uint32(body[0:])
+	this.NtpTimestamp = binary.BigEndian.Uint64(body[4:])
+	this.RtpTimestamp = binary.BigEndian.Uint32(body[12:])
+	this.PacketCount = binary.BigEndian.Uint32(body[16:])
+	this.OctetCount = binary.BigEndian.Uint32(body[20:])
+
+	reports, rest, err := unmarshalReportBlocks(header.Count, body[24:])
+	if err != nil {
+		return err
+	}
+	this.Reports = reports
+	this.Extensions = nil
+	if len(rest) > 0 {
+		this.Extensions = append([]byte(nil), rest...)
+	}
+	return nil
+}
+
+func (this *RtcpSenderReport) Print(w io.Writer) {
+	header := RtcpHeader{Version: RTCP_VERSION, Count: byte(len(this.Reports)), PacketType: RTCP_PT_SR, Length: uint16(this.MarshalSize()/4 - 1)}
+	header.Print(w, "Reception report count")
+	fmt.Fprintf(w, "Sender SSRC: 0x%08x (%d)\n", this.Ssrc, this.Ssrc)
+	fmt.Fprintf(w, "Timestamp, MSW: %d (0x%08x)\n", this.NtpTimestamp>>32, this.NtpTimestamp>>32)
+	fmt.Fprintf(w, "Timestamp, LSW: %d (0x%08x)\n", uint32(this.NtpTimestamp), uint32(this.NtpTimestamp))
+	fmt.Fprintf(w, "RTP timestamp: %d\n", this.RtpTimestamp)
+	fmt.Fprintf(w, "Sender's packet count: %d\n", this.PacketCount)
+	fmt.Fprintf(w, "Sender's octet count: %d\n", this.OctetCount)
+	printReportBlocks(w, this.Reports, this.Extensions)
+}
+
+type RtcpReceiverReport struct {
+	Ssrc    uint32
+	Reports []RtcpReportBlock
+	// profile-specific extensions, a multiple of 4 octets
+	Extensions []byte
+}
+
+func (this *RtcpReceiverReport) GetPacketType() byte {
+	return RTCP_PT_RR
+}
+
+func (this *RtcpReceiverReport) MarshalSize() int {
+	return RTCP_HEADER_LEN + 4 + len(this.Reports)*RTCP_REPORT_BLOCK_LEN + len(this.Extensions)
+}
+
+func (this *RtcpReceiverReport) MarshalTo(buf []byte) (int, error) {
+	if len(this.Extensions)&0x3 != 0 {
+		return 0, ErrBadAlignment
+	}
+	size := this.MarshalSize()
+	err := marshalRtcpHeader(buf, RTCP_PT_RR, len(this.Reports), size)
+	if err != nil {
+		return 0, err
+	}
+
+	binary.BigEndian.PutUint32(buf[4:], this.Ssrc)
+	pos := 8
+	for i := range this.Reports {
+		this.Reports[i].MarshalTo(buf[pos:])
+		pos += RTCP_REPORT_BLOCK_LEN
+	}
+	pos += copy(buf[pos:], this.Extensions)
+	return pos, nil
+}
+
+func (this *RtcpReceiverReport) Unmarshal(data []byte) error {
+	header := RtcpHeader{}
+	body, err := parseRtcpHeader(&header, data, RTCP_PT_RR)
+	if err != nil {
+		return err
+	}
+	if len(body) < 4 {
+		return ErrTooShort
+	}
+
+	this.Ssrc = binary.BigEndian.Uint32(body[0:])
+	reports, rest, err := unmarshalReportBlocks(header.Count, body[4:])
+	if err != nil {
+		return err
+	}
+	this.Reports = reports
+	this.Extensions = nil
+	if len(rest) > 0 {
+		this.Extensions = append([]byte(nil), rest...)
+	}
+	return nil
+}
+
+func (this *RtcpReceiverReport) Print(w io.Writer) {
+	header := RtcpHeader{Version: RTCP_VERSION, Count: byte(len(this.Reports)), PacketType: RTCP_PT_RR, Length: uint16(this.MarshalSize()/4 - 1)}
+	header.Print(w, "Reception report count")
+	fmt.Fprintf(w, "Sender SSRC: 0x%08x (%d)\n", this.Ssrc, this.Ssrc)
+	printReportBlocks(w, this.Reports, this.Extensions)
+}
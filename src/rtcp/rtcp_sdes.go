@@ -0,0 +1,206 @@
+package rtcp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// sdes item types from RFC3550
+const (
+	RTCP_SDES_END   = 0
+	RTCP_SDES_CNAME = 1
+	RTCP_SDES_NAME  = 2
+	RTCP_SDES_EMAIL = 3
+	RTCP_SDES_PHONE = 4
+	RTCP_SDES_LOC   = 5
+	RTCP_SDES_TOOL  = 6
+	RTCP_SDES_NOTE  = 7
+	RTCP_SDES_PRIV  = 8
+)
+
+const RTCP_SDES_MAX_TEXT_LEN = 255
+
+var (
+	ErrBadSdesItem    = errors.New("rtcp: sdes item exceeds chunk")
+	ErrSdesTextTooBig = errors.New("rtcp: sdes item text too long")
+)
+
+type RtcpSdesItem struct {
+	Type byte
+	Text string
+}
+
+type RtcpSdesChunk struct {
+	Ssrc  uint32
+	Items []RtcpSdesItem
+}
+
+// GetItem returns the text of the first item of itemType.
+func (this *RtcpSdesChunk) GetItem(itemType byte) (string, bool) {
+	for _, v := range this.Items {
+		if v.Type == itemType {
+			return v.Text, true
+		}
+	}
+	return "", false
+}
+
+// marshalSize includes the null item and the padding to a 32-bit boundary.
+func (this *RtcpSdesChunk) marshalSize() int {
+	size := 4
+	for _, v := range this.Items {
+		size += 2 + len(v.Text)
+	}
+	return pad4(size + 1)
+}
+
+func (this *RtcpSdesChunk) marshalTo(buf []byte) (int, error) {
+	binary.BigEndian.PutUint32(buf, this.Ssrc)
+	pos := 4
+	for _, v := range this.Items {
+		if len(v.Text) > RTCP_SDES_MAX_TEXT_LEN {
+			return 0, ErrSdesTextTooBig
+		}
+		buf[pos] = v.Type
+		buf[pos+1] = byte(len(v.Text))
+		pos += 2
+		pos += copy(buf[pos:], v.Text)
+	}
+
+	end := this.marshalSize()
+	for ; pos < end; pos++ {
+		buf[pos] = RTCP_SDES_END
+	}
+	return pos, nil
+}
+
+func (this *RtcpSdesChunk) unmarshal(data []byte) (int, error) {
+	if len(data) < 4 {
+		return 0, ErrTooShort
+	}
+	this.Ssrc = binary.BigEndian.Uint32(data)
+	this.Items = nil
+
+	pos := 4
+	for {
+		if pos >= len(data) {
+			return 0, ErrBadSdesItem
+		}
+		if data[pos] == RTCP_SDES_END {
+			// the null item is followed by padding to the next 32-bit boundary
+			pos = pad4(pos + 1)
+			if pos > len(data) {
+				return 0, ErrBadSdesItem
+			}
+			return pos, nil
+		}
+		if pos+2 > len(data) {
+			return 0, ErrBadSdesItem
+		}
+		length := int(data[pos+1])
+		if pos+2+length > len(data) {
+			return 0, ErrBadSdesItem
+		}
+		this.Items = append(this.Items, RtcpSdesItem{Type: data[pos], Text: string(data[pos+2 : pos+2+length])})
+		pos += 2 + length
+	}
+}
+
+type RtcpSdes struct {
+	Chunks []RtcpSdesChunk
+}
+
+// NewRtcpSdesCname returns a SDES packet holding only the CNAME of ssrc, as
+// needed in every compound packet.
+func NewRtcpSdesCname(ssrc uint32, cname string) *RtcpSdes {
+	return &RtcpSdes{Chunks: []RtcpSdesChunk{{Ssrc: ssrc, Items: []RtcpSdesItem{{Type: RTCP_SDES_CNAME, Text: cname}}}}}
+}
+
+func (this *RtcpSdes) GetPacketType() byte {
+	return RTCP_PT_SDES
+}
+
+func (this *RtcpSdes) MarshalSize() int {
+	size := RTCP_HEADER_LEN
+	for i := range this.Chunks {
+		size += this.Chunks[i].marshalSize()
+	}
+	return size
+}
+
+func (this *RtcpSdes) MarshalTo(buf []byte) (int, error) {
+	size := this.MarshalSize()
+	err := marshalRtcpHeader(buf, RTCP_PT_SDES, len(this.Chunks), size)
+	if err != nil {
+		return 0, err
+	}
+
+	pos := RTCP_HEADER_LEN
+	for i := range this.Chunks {
+		n, err := this.Chunks[i].marshalTo(buf[pos:])
+		if err != nil {
+			return 0, err
+		}
+		pos += n
+	}
+	return pos, nil
+}
+
+func (this *RtcpSdes) Unmarshal(data []byte) error {
+	header := RtcpHeader{}
+	body, err := parseRtcpHeader(&header, data, RTCP_PT_SDES)
+	if err != nil {
+		return err
+	}
+
+	this.Chunks = nil
+	for i := 0; i < int(header.Count); i++ {
+		chunk := RtcpSdesChunk{}
+		n, err := chunk.unmarshal(body)
+		if err != nil {
+			return err
+		}
+		this.Chunks = append(this.Chunks, chunk)
+		body = body[n:]
+	}
+	return nil
+}
+
+func GetSdesItemTypeName(itemType byte) string {
+	switch itemType {
+	case RTCP_SDES_END:
+		return "END"
+	case RTCP_SDES_CNAME:
+		return "CNAME (user and domain)"
+	case RTCP_SDES_NAME:
+		return "NAME (common name)"
+	case RTCP_SDES_EMAIL:
+		return "EMAIL (e-mail address)"
+	case RTCP_SDES_PHONE:
+		return "PHONE (phone number)"
+	case RTCP_SDES_LOC:
+		return "LOC (geographic location)"
+	case RTCP_SDES_TOOL:
+		return "TOOL (name/version of source app)"
+	case RTCP_SDES_NOTE:
+		return "NOTE (note about source)"
+	case RTCP_SDES_PRIV:
+		return "PRIV (private extensions)"
+	}
+	return "unknown"
+}
+
+func (this *RtcpSdes) Print(w io.Writer) {
+	header := RtcpHeader{Version: RTCP_VERSION, Count: byte(len(this.Chunks)), PacketType: RTCP_PT_SDES, Length: uint16(this.MarshalSize()/4 - 1)}
+	header.Print(w, "Source count")
+	for i, chunk := range this.Chunks {
+		fmt.Fprintf(w, "Chunk %d, SSRC/CSRC 0x%08X\n", i+1, chunk.Ssrc)
+		for _, v := range chunk.Items {
+			fmt.Fprintf(w, "Type: %s (%d)\n", GetSdesItemTypeName(v.Type), v.Type)
+			fmt.Fprintf(w, "Length: %d\n", len(v.Text))
+			fmt.Fprintf(w, "Text: %s\n", v.Text)
+		}
+	}
+}
@@ -0,0 +1,323 @@
+package rtcp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// transport-wide congestion control feedback from
+// draft-holmer-rmcat-transport-wide-cc-extensions-01
+const (
+	RTCP_TWCC_HEADER_LEN = RTCP_FEEDBACK_HEADER_LEN + 8
+
+	RTCP_TWCC_SYMBOL_NOT_RECEIVED = 0
+	RTCP_TWCC_SYMBOL_SMALL_DELTA  = 1
+	RTCP_TWCC_SYMBOL_LARGE_DELTA  = 2
+
+	RTCP_TWCC_MAX_RUN_LENGTH       = 0x1FFF
+	RTCP_TWCC_ONE_BIT_VECTOR_SIZE  = 14
+	RTCP_TWCC_TWO_BIT_VECTOR_SIZE  = 7
+	RTCP_TWCC_MAX_STATUS_COUNT     = 0xFFFF
+	RTCP_TWCC_REFERENCE_TIME_MARSK = 0xFFFFFF
+
+	RTCP_TWCC_REFERENCE_TIME_UNIT = 64 * time.Millisecond
+	RTCP_TWCC_DELTA_UNIT          = 250 * time.Microsecond
+)
+
+var (
+	ErrBadTwccChunk = errors.New("rtcp: bad transport-cc packet chunk")
+	ErrBadTwccDelta = errors.New("rtcp: transport-cc receive delta out of range")
+)
+
+// RtcpTwccPacket is the status of one transport sequence number. Delta is
+// the receive delta in 250us units, from the reference time for the first
+// received packet and from the previous received packet otherwise.
+type RtcpTwccPacket struct {
+	Received bool
+	Delta    int32
+}
+
+func (this *RtcpTwccPacket) symbol() (byte, error) {
+	if !this.Received {
+		return RTCP_TWCC_SYMBOL_NOT_RECEIVED, nil
+	}
+	if this.Delta >= 0 && this.Delta <= 0xFF {
+		return RTCP_TWCC_SYMBOL_SMALL_DELTA, nil
+	}
+	if this.Delta >= -0x8000 && this.Delta <= 0x7FFF {
+		return RTCP_TWCC_SYMBOL_LARGE_DELTA, nil
+	}
+	return 0, ErrBadTwccDelta
+}
+
+// RtcpTwccResult is the arrival of one transport sequence number, Arrival is
+// relative to the time base of the reference time.
+type RtcpTwccResult struct {
+	Sequence uint16
+	Received bool
+	Arrival  time.Duration
+}
+
+// RtcpTransportCc is the transport-cc feedback message, Packets holds one
+// status per sequence number starting at BaseSequence.
+type RtcpTransportCc struct {
+	SenderSsrc    uint32
+	MediaSsrc     uint32
+	BaseSequence  uint16
+	ReferenceTime uint32
+	FeedbackCount byte
+	Packets       []RtcpTwccPacket
+}
+
+// Results returns the arrival time of every sequence number in the packet.
+func (this *RtcpTransportCc) Results() []RtcpTwccResult {
+	results := make([]RtcpTwccResult, len(this.Packets))
+	arrival := time.Duration(this.ReferenceTime) * RTCP_TWCC_REFERENCE_TIME_UNIT
+	for i, v := range this.Packets {
+		results[i].Sequence = this.BaseSequence + uint16(i)
+		results[i].Received = v.Received
+		if v.Received {
+			arrival += time.Duration(v.Delta) * RTCP_TWCC_DELTA_UNIT
+			results[i].Arrival = arrival
+		}
+	}
+	return results
+}
+
+func (this *RtcpTransportCc) GetPacketType() byte {
+	return RTCP_PT_RTPFB
+}
+
+func (this *RtcpTransportCc) symbols() ([]byte, int, error) {
+	symbols := make([]byte, len(this.Packets))
+	deltaLen := 0
+	for i := range this.Packets {
+		symbol, err := this.Packets[i].symbol()
+		if err != nil {
+			return nil, 0, err
+		}
+		symbols[i] = symbol
+		deltaLen += int(symbol)
+	}
+	return symbols, deltaLen, nil
+}
+
+// encodeTwccChunks packs symbols into run length and status vector chunks.
+func encodeTwccChunks(symbols []byte) []uint16 {
+	var chunks []uint16
+
+	for pos := 0; pos < len(symbols); {
+		run := 1
+		for pos+run < len(symbols) && symbols[pos+run] == symbols[pos] && run < RTCP_TWCC_MAX_RUN_LENGTH {
+			run++
+		}
+
+		if run >= RTCP_TWCC_ONE_BIT_VECTOR_SIZE {
+			chunks = append(chunks, uint16(symbols[pos])<<13|uint16(run))
+			pos += run
+			continue
+		}
+
+		n := len(symbols) - pos
+		if n > RTCP_TWCC_ONE_BIT_VECTOR_SIZE {
+			n = RTCP_TWCC_ONE_BIT_VECTOR_SIZE
+		}
+		oneBit := true
+		for i := 0; i < n; i++ {
+			if symbols[pos+i] > RTCP_TWCC_SYMBOL_SMALL_DELTA {
+				oneBit = false
+				break
+			}
+		}
+		if oneBit {
+			chunk := uint16(0x8000)
+			for i := 0; i < n; i++ {
+				chunk |= uint16(symbols[pos+i]) << uint(13-i)
+			}
+			chunks = append(chunks, chunk)
+			pos += n
+			continue
+		}
+
+		if run >= RTCP_TWCC_TWO_BIT_VECTOR_SIZE {
+			chunks = append(chunks, uint16(symbols[pos])<<13|uint16(run))
+			pos += run
+			continue
+		}
+
+		if n > RTCP_TWCC_TWO_BIT_VECTOR_SIZE {
+			n = RTCP_TWCC_TWO_BIT_VECTOR_SIZE
+		}
+		chunk := uint16(0xC000)
+		for i := 0; i < n; i++ {
+			chunk |= uint16(symbols[pos+i]) << uint(12-i*2)
+		}
+		chunks = append(chunks, chunk)
+		pos += n
+	}
+
+	return chunks
+}
+
+// decodeTwccChunks reads chunks until count symbols are known and returns
+// the symbols and the number of octets used.
+func decodeTwccChunks(data []byte, count int) ([]byte, int, error) {
+	symbols := make([]byte, 0, count)
+	pos := 0
+
+	for len(symbols) < count {
+		if pos+2 > len(data) {
+			return nil, 0, ErrTooShort
+		}
+		chunk := binary.BigEndian.Uint16(data[pos:])
+		pos += 2
+		left := count - len(symbols)
+
+		switch {
+		case chunk&0x8000 == 0:
+			symbol := byte(chunk>>13) & 0x3
+			run := int(chunk & RTCP_TWCC_MAX_RUN_LENGTH)
+			if symbol == 3 || run == 0 || run > left {
+				return nil, 0, ErrBadTwccChunk
+			}
+			for i := 0; i < run; i++ {
+				symbols = append(symbols, symbol)
+			}
+		case chunk&0x4000 == 0:
+			for i := 0; i < RTCP_TWCC_ONE_BIT_VECTOR_SIZE && i < left; i++ {
+				symbols = append(symbols, byte(chunk>>uint(13-i))&0x1)
+			}
+		default:
+			for i := 0; i < RTCP_TWCC_TWO_BIT_VECTOR_SIZE && i < left; i++ {
+				symbol := byte(chunk>>uint(12-i*2)) & 0x3
+				if symbol == 3 {
+					return nil, 0, ErrBadTwccChunk
+				}
+				symbols = append(symbols, symbol)
+			}
+		}
+	}
+
+	return symbols, pos, nil
+}
+
+func (this *RtcpTransportCc) marshalSize() (int, []uint16, []byte, error) {
+	symbols, deltaLen, err := this.symbols()
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	chunks := encodeTwccChunks(symbols)
+	return pad4(RTCP_TWCC_HEADER_LEN + len(chunks)*2 + deltaLen), chunks, symbols, nil
+}
+
+func (this *RtcpTransportCc) MarshalSize() int {
+	size, _, _, _ := this.marshalSize()
+	return size
+}
+
+// MarshalTo pads the packet to a 32-bit boundary with zero octets after the
+// last delta. The P bit is not used so the packet may appear anywhere in a
+// compound packet.
+func (this *RtcpTransportCc) MarshalTo(buf []byte) (int, error) {
+	if len(this.Packets) == 0 || len(this.Packets) > RTCP_TWCC_MAX_STATUS_COUNT {
+		return 0, ErrBadFci
+	}
+	size, chunks, symbols, err := this.marshalSize()
+	if err != nil {
+		return 0, err
+	}
+	err = marshalFeedbackHeader(buf, RTCP_PT_RTPFB, RTCP_RTPFB_FMT_TWCC, size, this.SenderSsrc, this.MediaSsrc)
+	if err != nil {
+		return 0, err
+	}
+
+	pos := RTCP_FEEDBACK_HEADER_LEN
+	binary.BigEndian.PutUint16(buf[pos:], this.BaseSequence)
+	binary.BigEndian.PutUint16(buf[pos+2:], uint16(len(this.Packets)))
+	binary.BigEndian.PutUint32(buf[pos+4:], (this.ReferenceTime&RTCP_TWCC_REFERENCE_TIME_MARSK)<<8|uint32(this.FeedbackCount))
+	pos += 8
+
+	for _, v := range chunks {
+		binary.BigEndian.PutUint16(buf[pos:], v)
+		pos += 2
+	}
+
+	for i, v := range symbols {
+		switch v {
+		case RTCP_TWCC_SYMBOL_SMALL_DELTA:
+			buf[pos] = byte(this.Packets[i].Delta)
+			pos++
+		case RTCP_TWCC_SYMBOL_LARGE_DELTA:
+			binary.BigEndian.PutUint16(buf[pos:], uint16(int16(this.Packets[i].Delta)))
+			pos += 2
+		}
+	}
+
+	for ; pos < size; pos++ {
+		buf[pos] = 0
+	}
+	return size, nil
+}
+
+func (this *RtcpTransportCc) Unmarshal(data []byte) error {
+	header := RtcpHeader{}
+	senderSsrc, mediaSsrc, fci, err := parseFeedbackHeader(&header, data, RTCP_PT_RTPFB, RTCP_RTPFB_FMT_TWCC)
+	if err != nil {
+		return err
+	}
+	if len(fci) < 8 {
+		return ErrTooShort
+	}
+
+	count := int(binary.BigEndian.Uint16(fci[2:]))
+	symbols, n, err := decodeTwccChunks(fci[8:], count)
+	if err != nil {
+		return err
+	}
+
+	packets := make([]RtcpTwccPacket, count)
+	pos := 8 + n
+	for i, v := range symbols {
+		switch v {
+		case RTCP_TWCC_SYMBOL_SMALL_DELTA:
+			if pos+1 > len(fci) {
+				return ErrTooShort
+			}
+			packets[i] = RtcpTwccPacket{Received: true, Delta: int32(fci[pos])}
+			pos++
+		case RTCP_TWCC_SYMBOL_LARGE_DELTA:
+			if pos+2 > len(fci) {
+				return ErrTooShort
+			}
+			packets[i] = RtcpTwccPacket{Received: true, Delta: int32(int16(binary.BigEndian.Uint16(fci[pos:])))}
+			pos += 2
+		}
+	}
+
+	this.SenderSsrc = senderSsrc
+	this.MediaSsrc = mediaSsrc
+	this.BaseSequence = binary.BigEndian.Uint16(fci)
+	val := binary.BigEndian.Uint32(fci[4:])
+	this.ReferenceTime = val >> 8
+	this.FeedbackCount = byte(val)
+	this.Packets = packets
+	return nil
+}
+
+func (this *RtcpTransportCc) Print(w io.Writer) {
+	printFeedbackHeader(w, RTCP_PT_RTPFB, RTCP_RTPFB_FMT_TWCC, this.MarshalSize(), this.SenderSsrc, this.MediaSsrc)
+	fmt.Fprintf(w, "Base Sequence Number: %d\n", this.BaseSequence)
+	fmt.Fprintf(w, "Packet Status Count: %d\n", len(this.Packets))
+	fmt.Fprintf(w, "Reference Time: %d\n", this.ReferenceTime)
+	fmt.Fprintf(w, "Feedback Packets Count: %d\n", this.FeedbackCount)
+	for _, v := range this.Results() {
+		if v.Received {
+			fmt.Fprintf(w, "Sequence: %d, received, arrival: %v\n", v.Sequence, v.Arrival)
+		} else {
+			fmt.Fprintf(w, "Sequence: %d, not received\n", v.Sequence)
+		}
+	}
+}
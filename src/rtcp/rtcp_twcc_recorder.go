@@ -0,0 +1,157 @@
+package rtcp
+
+import (
+	"sort"
+	"time"
+)
+
+const RTCP_TWCC_DEFAULT_INTERVAL = 100 * time.Millisecond
+
+// TwccRecorder collects the transport sequence numbers and arrival times of
+// received rtp packets and turns them into transport-cc feedback. It holds no
+// timer: the owner calls Poll from its own clock, so tests stay deterministic.
+type TwccRecorder struct {
+	SenderSsrc uint32
+	MediaSsrc  uint32
+	Interval   time.Duration
+
+	arrivals      map[int64]time.Time
+	started       bool
+	maxSequence   int64
+	nextSequence  int64
+	feedbackCount byte
+	lastFeedback  time.Time
+}
+
+func NewTwccRecorder(senderSsrc, mediaSsrc uint32, interval time.Duration) *TwccRecorder {
+	if interval <= 0 {
+		interval = RTCP_TWCC_DEFAULT_INTERVAL
+	}
+	return &TwccRecorder{
+		SenderSsrc: senderSsrc,
+		MediaSsrc:  mediaSsrc,
+		Interval:   interval,
+		arrivals:   make(map[int64]time.Time),
+	}
+}
+
+// unwrap extends seq to 64 bits around the highest sequence seen so far.
+func (this *TwccRecorder) unwrap(seq uint16) int64 {
+	if !this.started {
+		return int64(seq)
+	}
+	return this.maxSequence + int64(int16(seq-uint16(this.maxSequence)))
+}
+
+// Record adds a packet carrying transport sequence seq that arrived at
+// arrival. Packets older than the last feedback sent are ignored.
+func (this *TwccRecorder) Record(seq uint16, arrival time.Time) {
+	extended := this.unwrap(seq)
+	if !this.started {
+		this.started = true
+		this.maxSequence = extended
+		this.nextSequence = extended
+	}
+	if extended < this.nextSequence {
+		return
+	}
+	if extended > this.maxSequence {
+		this.maxSequence = extended
+	}
+	if _, ok := this.arrivals[extended]; !ok {
+		this.arrivals[extended] = arrival
+	}
+}
+
+// Poll returns the feedback to send when Interval has elapsed since the last
+// feedback and packets have been recorded, nil otherwise.
+func (this *TwccRecorder) Poll(now time.Time) []RtcpPacket {
+	if len(this.arrivals) == 0 {
+		return nil
+	}
+	if !this.lastFeedback.IsZero() && now.Sub(this.lastFeedback) < this.Interval {
+		return nil
+	}
+	this.lastFeedback = now
+
+	var packets []RtcpPacket
+	for _, v := range this.BuildFeedback() {
+		packets = append(packets, v)
+	}
+	return packets
+}
+
+// BuildFeedback reports every sequence number from the end of the previous
+// feedback up to the highest one recorded, and forgets them. A new message is
+// started when a receive delta does not fit 16 bits.
+func (this *TwccRecorder) BuildFeedback() []*RtcpTransportCc {
+	if len(this.arrivals) == 0 {
+		return nil
+	}
+
+	seqs := make([]int64, 0, len(this.arrivals))
+	for seq := range this.arrivals {
+		seqs = append(seqs, seq)
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+
+	var feedbacks []*RtcpTransportCc
+	var current *RtcpTransportCc
+	var last int64
+
+	for _, seq := range seqs {
+		arrival := this.arrivals[seq].UnixNano()
+
+		if current != nil {
+			delta := roundTwccDelta(arrival - last)
+			if delta < -0x8000 || delta > 0x7FFF || seq-this.nextSequence+int64(len(current.Packets)) >= RTCP_TWCC_MAX_STATUS_COUNT {
+				current = nil
+			} else {
+				for i := this.nextSequence; i < seq; i++ {
+					current.Packets = append(current.Packets, RtcpTwccPacket{})
+				}
+				current.Packets = append(current.Packets, RtcpTwccPacket{Received: true, Delta: int32(delta)})
+				last += delta * int64(RTCP_TWCC_DELTA_UNIT)
+				this.nextSequence = seq + 1
+				continue
+			}
+		}
+
+		// start a new message, a gap too long to report is skipped
+		if seq-this.nextSequence >= RTCP_TWCC_MAX_STATUS_COUNT {
+			this.nextSequence = seq
+		}
+
+		reference := arrival / int64(RTCP_TWCC_REFERENCE_TIME_UNIT)
+		last = reference * int64(RTCP_TWCC_REFERENCE_TIME_UNIT)
+		current = &RtcpTransportCc{
+			SenderSsrc:    this.SenderSsrc,
+			MediaSsrc:     this.MediaSsrc,
+			BaseSequence:  uint16(this.nextSequence),
+			ReferenceTime: uint32(reference) & RTCP_TWCC_REFERENCE_TIME_MARSK,
+			FeedbackCount: this.feedbackCount,
+		}
+		this.feedbackCount++
+		feedbacks = append(feedbacks, current)
+
+		for i := this.nextSequence; i < seq; i++ {
+			current.Packets = append(current.Packets, RtcpTwccPacket{})
+		}
+		delta := roundTwccDelta(arrival - last)
+		current.Packets = append(current.Packets, RtcpTwccPacket{Received: true, Delta: int32(delta)})
+		last += delta * int64(RTCP_TWCC_DELTA_UNIT)
+		this.nextSequence = seq + 1
+	}
+
+	this.arrivals = make(map[int64]time.Time)
+	return feedbacks
+}
+
+// roundTwccDelta converts nanoseconds to 250us units, rounding to nearest.
+func roundTwccDelta(ns int64) int64 {
+	unit := int64(RTCP_TWCC_DELTA_UNIT)
+	if ns >= 0 {
+		return (ns + unit/2) / unit
+	}
+	return (ns - unit/2) / unit
+}
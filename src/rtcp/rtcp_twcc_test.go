@@ -0,0 +1,156 @@
+package rtcp
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/lioneagle/goutil/src/buffer"
+	"github.com/lioneagle/goutil/src/test"
+)
+
+func TestEncodeTwccChunks(t *testing.T) {
+	testdata := []struct {
+		symbols []byte
+		chunks  []uint16
+	}{
+		{[]byte{1}, []uint16{0xa000}},
+		{[]byte{1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1}, []uint16{0x200e}},
+		{[]byte{1, 0, 1, 0, 1, 0, 1, 0, 1, 0, 1, 0, 1, 0, 1}, []uint16{0xaaaa, 0xa000}},
+		{[]byte{1, 2, 0, 1, 2}, []uint16{0xd860}},
+		{[]byte{2, 2, 2, 2, 2, 2, 2, 2, 1}, []uint16{0x4008, 0xa000}},
+		{[]byte{0, 0, 1, 2, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}, []uint16{0xc180, 0x8000}},
+	}
+
+	for i, v := range testdata {
+		chunks := encodeTwccChunks(v.symbols)
+		test.EXPECT_EQ(t, chunks, v.chunks, "[%d]", i)
+
+		data := make([]byte, len(chunks)*2)
+		for j, chunk := range chunks {
+			data[j*2] = byte(chunk >> 8)
+			data[j*2+1] = byte(chunk)
+		}
+		symbols, n, err := decodeTwccChunks(data, len(v.symbols))
+		test.EXPECT_EQ(t, err, nil, "[%d]", i)
+		test.EXPECT_EQ(t, n, len(data), "[%d]", i)
+		test.EXPECT_EQ(t, symbols, v.symbols, "[%d]", i)
+	}
+}
+
+func TestRtcpTransportCcRoundTrip(t *testing.T) {
+	testdata := []struct {
+		packet *RtcpTransportCc
+		data   []byte
+	}{
+		{&RtcpTransportCc{SenderSsrc: 1, MediaSsrc: 2, BaseSequence: 153, ReferenceTime: 0x123456, FeedbackCount: 7,
+			Packets: []RtcpTwccPacket{{true, 4}, {false, 0}, {true, 300}, {true, -4}}},
+			[]byte{0x8f, 0xcd, 0x00, 0x06, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x02,
+				0x00, 0x99, 0x00, 0x04, 0x12, 0x34, 0x56, 0x07,
+				0xd2, 0x80, 0x04, 0x01, 0x2c, 0xff, 0xfc, 0x00}},
+		{&RtcpTransportCc{SenderSsrc: 1, MediaSsrc: 2, BaseSequence: 0xfffe, ReferenceTime: 1, FeedbackCount: 0,
+			Packets: []RtcpTwccPacket{{true, 1}, {true, 2}, {true, 3}}},
+			[]byte{0x8f, 0xcd, 0x00, 0x06, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x02,
+				0xff, 0xfe, 0x00, 0x03, 0x00, 0x00, 0x01, 0x00,
+				0xb8, 0x00, 0x01, 0x02, 0x03, 0x00, 0x00, 0x00}},
+	}
+
+	for i, v := range testdata {
+		v := v
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			t.Parallel()
+
+			data, err := MarshalRtcpPacket(v.packet)
+			test.EXPECT_EQ(t, err, nil, "")
+			test.EXPECT_EQ(t, data, v.data, "")
+
+			packets, err := ParseRtcpPackets(v.data)
+			test.EXPECT_EQ(t, err, nil, "")
+			test.EXPECT_EQ(t, packets, []RtcpPacket{v.packet}, "")
+		})
+	}
+}
+
+func TestRtcpTransportCcResults(t *testing.T) {
+	packet := &RtcpTransportCc{BaseSequence: 0xffff, ReferenceTime: 2,
+		Packets: []RtcpTwccPacket{{true, 4}, {false, 0}, {true, -2}}}
+
+	wanted := []RtcpTwccResult{
+		{Sequence: 0xffff, Received: true, Arrival: 128*time.Millisecond + time.Millisecond},
+		{Sequence: 0, Received: false},
+		{Sequence: 1, Received: true, Arrival: 128*time.Millisecond + 500*time.Microsecond},
+	}
+	test.EXPECT_EQ(t, packet.Results(), wanted, "")
+
+	buf := buffer.NewByteBuffer(nil)
+	packet.Print(buf)
+	test.EXPECT_EQ(t, buf.String(), `10.. .... = version: 2
+..0. .... = Padding: false
+...0 1111 = RTCP Feedback message type (FMT): 15
+Packet type: Generic RTP Feedback (205)
+Length: 6 (28 bytes)
+Sender SSRC: 0x00000000 (0)
+Media source SSRC: 0x00000000 (0)
+Base Sequence Number: 65535
+Packet Status Count: 3
+Reference Time: 2
+Feedback Packets Count: 0
+Sequence: 65535, received, arrival: 129ms
+Sequence: 0, not received
+Sequence: 1, received, arrival: 128.5ms
+`, "")
+}
+
+func TestRtcpTransportCcMarshalError(t *testing.T) {
+	_, err := MarshalRtcpPacket(&RtcpTransportCc{})
+	test.EXPECT_EQ(t, err, ErrBadFci, "")
+
+	_, err = MarshalRtcpPacket(&RtcpTransportCc{Packets: []RtcpTwccPacket{{true, 0x8000}}})
+	test.EXPECT_EQ(t, err, ErrBadTwccDelta, "")
+}
+
+func TestTwccRecorder(t *testing.T) {
+	base := time.Unix(1000, 0)
+	recorder := NewTwccRecorder(1, 2, 100*time.Millisecond)
+
+	test.EXPECT_EQ(t, len(recorder.Poll(base)), 0, "")
+
+	recorder.Record(65534, base.Add(10*time.Millisecond))
+	recorder.Record(1, base.Add(30*time.Millisecond))
+	recorder.Record(65535, base.Add(20*time.Millisecond))
+	recorder.Record(65535, base.Add(25*time.Millisecond))
+
+	packets := recorder.Poll(base.Add(40 * time.Millisecond))
+	reference := uint32(base.Add(10*time.Millisecond).UnixNano()/int64(64*time.Millisecond)) & 0xFFFFFF
+	offset := int32(base.Add(10*time.Millisecond).UnixNano()%int64(64*time.Millisecond)) / int32(250*time.Microsecond)
+	test.EXPECT_EQ(t, packets, []RtcpPacket{&RtcpTransportCc{SenderSsrc: 1, MediaSsrc: 2, BaseSequence: 65534, ReferenceTime: reference, FeedbackCount: 0,
+		Packets: []RtcpTwccPacket{{true, offset}, {true, 40}, {false, 0}, {true, 40}}}}, "")
+
+	// nothing new, and the interval has not elapsed
+	recorder.Record(1, base.Add(50*time.Millisecond))
+	recorder.Record(2, base.Add(60*time.Millisecond))
+	test.EXPECT_EQ(t, len(recorder.Poll(base.Add(100*time.Millisecond))), 0, "")
+
+	packets = recorder.Poll(base.Add(140 * time.Millisecond))
+	test.EXPECT_EQ(t, len(packets), 1, "")
+	feedback := packets[0].(*RtcpTransportCc)
+	test.EXPECT_EQ(t, feedback.BaseSequence, uint16(2), "")
+	test.EXPECT_EQ(t, feedback.FeedbackCount, byte(1), "")
+	test.EXPECT_EQ(t, len(feedback.Packets), 1, "")
+
+	// a delta above 8 seconds starts a new message
+	recorder.Record(3, base.Add(time.Second))
+	recorder.Record(5, base.Add(10*time.Second))
+	feedbacks := recorder.BuildFeedback()
+	test.EXPECT_EQ(t, len(feedbacks), 2, "")
+	test.EXPECT_EQ(t, feedbacks[0].BaseSequence, uint16(3), "")
+	test.EXPECT_EQ(t, len(feedbacks[0].Packets), 1, "")
+	test.EXPECT_EQ(t, feedbacks[1].BaseSequence, uint16(4), "")
+	test.EXPECT_EQ(t, feedbacks[1].Packets[0].Received, false, "")
+	test.EXPECT_EQ(t, feedbacks[1].FeedbackCount, byte(3), "")
+
+	for _, v := range feedbacks {
+		_, err := MarshalRtcpPacket(v)
+		test.EXPECT_EQ(t, err, nil, "")
+	}
+}
@@ -0,0 +1,826 @@
+package rtcp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/lioneagle/goutil/src/buffer"
+)
+
+// report block types from RFC3611
+const (
+	RTCP_XR_LOSS_RLE                = 1
+	RTCP_XR_DUPLICATE_RLE           = 2
+	RTCP_XR_PACKET_RECEIPT_TIMES    = 3
+	RTCP_XR_RECEIVER_REFERENCE_TIME = 4
+	RTCP_XR_DLRR                    = 5
+	RTCP_XR_STATISTICS_SUMMARY      = 6
+	RTCP_XR_VOIP_METRICS            = 7
+)
+
+const (
+	RTCP_XR_BLOCK_HEADER_LEN = 4
+	RTCP_XR_THINNING_MARSK   = 0x0F
+	RTCP_XR_MAX_THINNING     = 15
+
+	RTCP_XR_RLE_BIT_VECTOR_MARSK = 0x8000
+	RTCP_XR_RLE_RUN_TYPE_MARSK   = 0x4000
+	RTCP_XR_RLE_RUN_LENGTH_MARSK = 0x3FFF
+	RTCP_XR_RLE_BIT_VECTOR_SIZE  = 15
+
+	RTCP_XR_DLRR_ITEM_LEN          = 12
+	RTCP_XR_STATISTICS_SUMMARY_LEN = 36
+	RTCP_XR_VOIP_METRICS_LEN       = 32
+
+	RTCP_XR_LOSS_REPORT_MARSK      = 0x80
+	RTCP_XR_DUPLICATE_REPORT_MARSK = 0x40
+	RTCP_XR_JITTER_REPORT_MARSK    = 0x20
+	RTCP_XR_TTL_OR_HOP_LIMIT_MARSK = 0x18
+
+	RTCP_XR_TTL_NONE      = 0
+	RTCP_XR_TTL_IPV4      = 1
+	RTCP_XR_TTL_IPV6_HOPS = 2
+
+	// value of a VoIP metric that is not available
+	RTCP_XR_UNAVAILABLE = 127
+)
+
+var (
+	ErrBadXrBlockType = errors.New("rtcp: unexpected xr block type")
+	ErrBadThinning    = errors.New("rtcp: xr thinning out of range")
+)
+
+// RtcpXrBlock is one report block of an extended report.
+type RtcpXrBlock interface {
+	GetBlockType() byte
+	MarshalSize() int
+	// MarshalTo writes the block including its header and returns the number
+	// of octets written.
+	MarshalTo(buf []byte) (int, error)
+	// Unmarshal parses exactly one block including its header.
+	Unmarshal(data []byte) error
+	Print(w io.Writer)
+}
+
+func GetXrBlockTypeName(blockType byte) string {
+	switch blockType {
+	case RTCP_XR_LOSS_RLE:
+		return "Loss Run Length Encoding Report Block"
+	case RTCP_XR_DUPLICATE_RLE:
+		return "Duplicate Run Length Encoding Report Block"
+	case RTCP_XR_PACKET_RECEIPT_TIMES:
+		return "Packet Receipt Times Report Block"
+	case RTCP_XR_RECEIVER_REFERENCE_TIME:
+		return "Receiver Reference Time Report Block"
+	case RTCP_XR_DLRR:
+		return "DLRR Report Block"
+	case RTCP_XR_STATISTICS_SUMMARY:
+		return "Statistics Summary Report Block"
+	case RTCP_XR_VOIP_METRICS:
+		return "VoIP Metrics Report Block"
+	}
+	return "unknown"
+}
+
+// parseXrBlockHeader checks the header of a single block of the expected
+// type and returns the type-specific octet and the body after the header.
+func parseXrBlockHeader(data []byte, blockType byte) (byte, []byte, error) {
+	if len(data) < RTCP_XR_BLOCK_HEADER_LEN {
+		return 0, nil, ErrTooShort
+	}
+	if data[0] != blockType {
+		return 0, nil, ErrBadXrBlockType
+	}
+	if (int(binary.BigEndian.Uint16(data[2:]))+1)*4 != len(data) {
+		return 0, nil, ErrBadLength
+	}
+	return data[1], data[RTCP_XR_BLOCK_HEADER_LEN:], nil
+}
+
+// marshalXrBlockHeader writes the header of a block of size octets.
+func marshalXrBlockHeader(buf []byte, blockType, typeSpecific byte, size int) error {
+	if len(buf) < size {
+		return ErrBufferTooSmall
+	}
+	buf[0] = blockType
+	buf[1] = typeSpecific
+	binary.BigEndian.PutUint16(buf[2:], uint16(size/4-1))
+	return nil
+}
+
+func printXrBlockHeader(w io.Writer, blockType byte, size int) {
+	fmt.Fprintf(w, "Type: %s (%d)\n", GetXrBlockTypeName(blockType), blockType)
+	fmt.Fprintf(w, "Length: %d\n", size/4-1)
+}
+
+// RtcpXrRawBlock keeps a block whose type is not known to this package.
+type RtcpXrRawBlock struct {
+	Data []byte
+}
+
+// GetBlockType returns the type in the block header, 0 for an empty block.
+func (this *RtcpXrRawBlock) GetBlockType() byte {
+	if len(this.Data) == 0 {
+		return 0
+	}
+	return this.Data[0]
+}
+
+func (this *RtcpXrRawBlock) MarshalSize() int {
+	return len(this.Data)
+}
+
+func (this *RtcpXrRawBlock) MarshalTo(buf []byte) (int, error) {
+	if len(buf) < len(this.Data) {
+		return 0, ErrBufferTooSmall
+	}
+	return copy(buf, this.Data), nil
+}
+
+func (this *RtcpXrRawBlock) Unmarshal(data []byte) error {
+	if len(data) < RTCP_XR_BLOCK_HEADER_LEN {
+		return ErrTooShort
+	}
+	_, _, err := parseXrBlockHeader(data, data[0])
+	if err != nil {
+		return err
+	}
+	this.Data = append(this.Data[:0], data...)
+	return nil
+}
+
+func (this *RtcpXrRawBlock) Print(w io.Writer) {
+	printXrBlockHeader(w, this.GetBlockType(), len(this.Data))
+	if len(this.Data) > RTCP_XR_BLOCK_HEADER_LEN {
+		body := this.Data[RTCP_XR_BLOCK_HEADER_LEN:]
+		fmt.Fprintf(w, "Data:\n")
+		buffer.PrintAsHex(w, body, 0, len(body))
+	}
+}
+
+var rtcpXrBlockFactories = map[byte]func() RtcpXrBlock{
+	RTCP_XR_LOSS_RLE:                func() RtcpXrBlock { return &RtcpXrRle{} },
+	RTCP_XR_DUPLICATE_RLE:           func() RtcpXrBlock { return &RtcpXrRle{} },
+	RTCP_XR_PACKET_RECEIPT_TIMES:    func() RtcpXrBlock { return &RtcpXrPacketReceiptTimes{} },
+	RTCP_XR_RECEIVER_REFERENCE_TIME: func() RtcpXrBlock { return &RtcpXrReceiverReferenceTime{} },
+	RTCP_XR_DLRR:                    func() RtcpXrBlock { return &RtcpXrDlrr{} },
+	RTCP_XR_STATISTICS_SUMMARY:      func() RtcpXrBlock { return &RtcpXrStatisticsSummary{} },
+	RTCP_XR_VOIP_METRICS:            func() RtcpXrBlock { return &RtcpXrVoipMetrics{} },
+}
+
+func newRtcpXrBlock(blockType byte) RtcpXrBlock {
+	factory, ok := rtcpXrBlockFactories[blockType]
+	if !ok {
+		return &RtcpXrRawBlock{}
+	}
+	return factory()
+}
+
+// RtcpXr is the extended report packet, Ssrc is the originator of the report.
+type RtcpXr struct {
+	Ssrc   uint32
+	Blocks []RtcpXrBlock
+}
+
+func (this *RtcpXr) GetPacketType() byte {
+	return RTCP_PT_XR
+}
+
+func (this *RtcpXr) MarshalSize() int {
+	size := RTCP_HEADER_LEN + 4
+	for _, v := range this.Blocks {
+		size += v.MarshalSize()
+	}
+	return size
+}
+
+func (this *RtcpXr) MarshalTo(buf []byte) (int, error) {
+	size := this.MarshalSize()
+	err := marshalRtcpHeader(buf, RTCP_PT_XR, 0, size)
+	if err != nil {
+		return 0, err
+	}
+
+	binary.BigEndian.PutUint32(buf[4:], this.Ssrc)
+	pos := 8
+	for _, v := range this.Blocks {
+		n, err := v.MarshalTo(buf[pos:])
+		if err != nil {
+			return 0, err
+		}
+		pos += n
+	}
+	return pos, nil
+}
+
+func (this *RtcpXr) Unmarshal(data []byte) error {
+	header := RtcpHeader{}
+	body, err := parseRtcpHeader(&header, data, RTCP_PT_XR)
+	if err != nil {
+		return err
+	}
+	if len(body) < 4 {
+		return ErrTooShort
+	}
+
+	var blocks []RtcpXrBlock
+	for pos := 4; pos < len(body); {
+		if pos+RTCP_XR_BLOCK_HEADER_LEN > len(body) {
+			return ErrTooShort
+		}
+		size := (int(binary.BigEndian.Uint16(body[pos+2:])) + 1) * 4
+		if pos+size > len(body) {
+			return ErrBadLength
+		}
+		block := newRtcpXrBlock(body[pos])
+		err = block.Unmarshal(body[pos : pos+size])
+		if err != nil {
+			return err
+		}
+		blocks = append(blocks, block)
+		pos += size
+	}
+
+	this.Ssrc = binary.BigEndian.Uint32(body)
+	this.Blocks = blocks
+	return nil
+}
+
+func (this *RtcpXr) Print(w io.Writer) {
+	header := RtcpHeader{Version: RTCP_VERSION, PacketType: RTCP_PT_XR, Length: uint16(this.MarshalSize()/4 - 1)}
+	header.Print(w, "Reserved")
+	fmt.Fprintf(w, "Sender SSRC: 0x%08x (%d)\n", this.Ssrc, this.Ssrc)
+	for _, v := range this.Blocks {
+		v.Print(w)
+	}
+}
+
+// RtcpXrRle is a Loss RLE or a Duplicate RLE block, selected by Type. Chunks
+// are the run length and bit vector chunks without the trailing null chunk.
+type RtcpXrRle struct {
+	Type     byte
+	Thinning byte
+	Ssrc     uint32
+	BeginSeq uint16
+	EndSeq   uint16
+	Chunks   []uint16
+}
+
+// EncodeRtcpXrRleChunks packs one flag per reported sequence number into
+// chunks: the flag is true for a received packet in a Loss RLE block and for
+// a duplicated packet in a Duplicate RLE block.
+func EncodeRtcpXrRleChunks(flags []bool) []uint16 {
+	var chunks []uint16
+
+	for pos := 0; pos < len(flags); {
+		run := 1
+		for pos+run < len(flags) && flags[pos+run] == flags[pos] && run < RTCP_XR_RLE_RUN_LENGTH_MARSK {
+			run++
+		}
+
+		if run > RTCP_XR_RLE_BIT_VECTOR_SIZE || pos+run == len(flags) && run > 1 {
+			chunk := uint16(run)
+			if flags[pos] {
+				chunk |= RTCP_XR_RLE_RUN_TYPE_MARSK
+			}
+			chunks = append(chunks, chunk)
+			pos += run
+			continue
+		}
+
+		chunk := uint16(RTCP_XR_RLE_BIT_VECTOR_MARSK)
+		for i := 0; i < RTCP_XR_RLE_BIT_VECTOR_SIZE && pos < len(flags); i++ {
+			if flags[pos] {
+				chunk |= 1 << uint(14-i)
+			}
+			pos++
+		}
+		chunks = append(chunks, chunk)
+	}
+
+	return chunks
+}
+
+// Flags expands the chunks to one flag per reported sequence number. A bit
+// vector always yields 15 flags, the caller trims them with the sequence
+// range of the block.
+func (this *RtcpXrRle) Flags() []bool {
+	var flags []bool
+	for _, chunk := range this.Chunks {
+		if chunk&RTCP_XR_RLE_BIT_VECTOR_MARSK != 0 {
+			for i := 0; i < RTCP_XR_RLE_BIT_VECTOR_SIZE; i++ {
+				flags = append(flags, chunk&(1<<uint(14-i)) != 0)
+			}
+			continue
+		}
+		run := int(chunk & RTCP_XR_RLE_RUN_LENGTH_MARSK)
+		for i := 0; i < run; i++ {
+			flags = append(flags, chunk&RTCP_XR_RLE_RUN_TYPE_MARSK != 0)
+		}
+	}
+	return flags
+}
+
+func (this *RtcpXrRle) GetBlockType() byte {
+	return this.Type
+}
+
+func (this *RtcpXrRle) MarshalSize() int {
+	return RTCP_XR_BLOCK_HEADER_LEN + 8 + pad4(len(this.Chunks)*2)
+}
+
+func (this *RtcpXrRle) MarshalTo(buf []byte) (int, error) {
+	if this.Type != RTCP_XR_LOSS_RLE && this.Type != RTCP_XR_DUPLICATE_RLE {
+		return 0, ErrBadXrBlockType
+	}
+	if this.Thinning > RTCP_XR_MAX_THINNING {
+		return 0, ErrBadThinning
+	}
+	size := this.MarshalSize()
+	err := marshalXrBlockHeader(buf, this.Type, this.Thinning, size)
+	if err != nil {
+		return 0, err
+	}
+
+	binary.BigEndian.PutUint32(buf[4:], this.Ssrc)
+	binary.BigEndian.PutUint16(buf[8:], this.BeginSeq)
+	binary.BigEndian.PutUint16(buf[10:], this.EndSeq)
+	pos := 12
+	for _, v := range this.Chunks {
+		binary.BigEndian.PutUint16(buf[pos:], v)
+		pos += 2
+	}
+	// null chunk
+	for ; pos < size; pos++ {
+		buf[pos] = 0
+	}
+	return size, nil
+}
+
+func (this *RtcpXrRle) Unmarshal(data []byte) error {
+	if len(data) == 0 {
+		return ErrTooShort
+	}
+	if data[0] != RTCP_XR_LOSS_RLE && data[0] != RTCP_XR_DUPLICATE_RLE {
+		return ErrBadXrBlockType
+	}
+	typeSpecific, body, err := parseXrBlockHeader(data, data[0])
+	if err != nil {
+		return err
+	}
+	if len(body) < 8 {
+		return ErrTooShort
+	}
+
+	var chunks []uint16
+	for pos := 8; pos+2 <= len(body); pos += 2 {
+		chunk := binary.BigEndian.Uint16(body[pos:])
+		if chunk == 0 {
+			break
+		}
+		chunks = append(chunks, chunk)
+	}
+
+	this.Type = data[0]
+	this.Thinning = typeSpecific & RTCP_XR_THINNING_MARSK
+	this.Ssrc = binary.BigEndian.Uint32(body)
+	this.BeginSeq = binary.BigEndian.Uint16(body[4:])
+	this.EndSeq = binary.BigEndian.Uint16(body[6:])
+	this.Chunks = chunks
+	return nil
+}
+
+func (this *RtcpXrRle) Print(w io.Writer) {
+	printXrBlockHeader(w, this.Type, this.MarshalSize())
+	fmt.Fprintf(w, "Thinning factor: %d\n", this.Thinning)
+	fmt.Fprintf(w, "Source SSRC: 0x%08x (%d)\n", this.Ssrc, this.Ssrc)
+	fmt.Fprintf(w, "Begin Sequence Number: %d\n", this.BeginSeq)
+	fmt.Fprintf(w, "End Sequence Number: %d\n", this.EndSeq)
+	for _, v := range this.Chunks {
+		if v&RTCP_XR_RLE_BIT_VECTOR_MARSK != 0 {
+			fmt.Fprintf(w, "Bit Vector: %015b\n", v&0x7FFF)
+		} else {
+			fmt.Fprintf(w, "Run Length: %d of %d\n", v&RTCP_XR_RLE_RUN_LENGTH_MARSK, (v&RTCP_XR_RLE_RUN_TYPE_MARSK)>>14)
+		}
+	}
+}
+
+// RtcpXrPacketReceiptTimes has the arrival time of every reported packet in
+// the rtp timestamp clock, 0 for a packet not received.
+type RtcpXrPacketReceiptTimes struct {
+	Thinning     byte
+	Ssrc         uint32
+	BeginSeq     uint16
+	EndSeq       uint16
+	ReceiptTimes []uint32
+}
+
+func (this *RtcpXrPacketReceiptTimes) GetBlockType() byte {
+	return RTCP_XR_PACKET_RECEIPT_TIMES
+}
+
+func (this *RtcpXrPacketReceiptTimes) MarshalSize() int {
+	return RTCP_XR_BLOCK_HEADER_LEN + 8 + len(this.ReceiptTimes)*4
+}
+
+func (this *RtcpXrPacketReceiptTimes) MarshalTo(buf []byte) (int, error) {
+	if this.Thinning > RTCP_XR_MAX_THINNING {
+		return 0, ErrBadThinning
+	}
+	size := this.MarshalSize()
+	err := marshalXrBlockHeader(buf, RTCP_XR_PACKET_RECEIPT_TIMES, this.Thinning, size)
+	if err != nil {
+		return 0, err
+	}
+
+	binary.BigEndian.PutUint32(buf[4:], this.Ssrc)
+	binary.BigEndian.PutUint16(buf[8:], this.BeginSeq)
+	binary.BigEndian.PutUint16(buf[10:], this.EndSeq)
+	pos := 12
+	for _, v := range this.ReceiptTimes {
+		binary.BigEndian.PutUint32(buf[pos:], v)
+		pos += 4
+	}
+	return size, nil
+}
+
+func (this *RtcpXrPacketReceiptTimes) Unmarshal(data []byte) error {
+	typeSpecific, body, err := parseXrBlockHeader(data, RTCP_XR_PACKET_RECEIPT_TIMES)
+	if err != nil {
+		return err
+	}
+	if len(body) < 8 {
+		return ErrTooShort
+	}
+
+	this.Thinning = typeSpecific & RTCP_XR_THINNING_MARSK
+	this.Ssrc = binary.BigEndian.Uint32(body)
+	this.BeginSeq = binary.BigEndian.Uint16(body[4:])
+	this.EndSeq = binary.BigEndian.Uint16(body[6:])
+	this.ReceiptTimes = nil
+	for pos := 8; pos < len(body); pos += 4 {
+		this.ReceiptTimes = append(this.ReceiptTimes, binary.BigEndian.Uint32(body[pos:]))
+	}
+	return nil
+}
+
+func (this *RtcpXrPacketReceiptTimes) Print(w io.Writer) {
+	printXrBlockHeader(w, RTCP_XR_PACKET_RECEIPT_TIMES, this.MarshalSize())
+	fmt.Fprintf(w, "Thinning factor: %d\n", this.Thinning)
+	fmt.Fprintf(w, "Source SSRC: 0x%08x (%d)\n", this.Ssrc, this.Ssrc)
+	fmt.Fprintf(w, "Begin Sequence Number: %d\n", this.BeginSeq)
+	fmt.Fprintf(w, "End Sequence Number: %d\n", this.EndSeq)
+	for _, v := range this.ReceiptTimes {
+		fmt.Fprintf(w, "Receipt time: %d\n", v)
+	}
+}
+
+// RtcpXrReceiverReferenceTime lets a receiver that does not send take part
+// in round trip time measurement with DLRR blocks.
+type RtcpXrReceiverReferenceTime struct {
+	NtpTimestamp uint64
+}
+
+func (this *RtcpXrReceiverReferenceTime) GetBlockType() byte {
+	return RTCP_XR_RECEIVER_REFERENCE_TIME
+}
+
+func (this *RtcpXrReceiverReferenceTime) MarshalSize() int {
+	return RTCP_XR_BLOCK_HEADER_LEN + 8
+}
+
+func (this *RtcpXrReceiverReferenceTime) MarshalTo(buf []byte) (int, error) {
+	size := this.MarshalSize()
+	err := marshalXrBlockHeader(buf, RTCP_XR_RECEIVER_REFERENCE_TIME, 0, size)
+	if err != nil {
+		return 0, err
+	}
+	binary.BigEndian.PutUint64(buf[4:], this.NtpTimestamp)
+	return size, nil
+}
+
+func (this *RtcpXrReceiverReferenceTime) Unmarshal(data []byte) error {
+	_, body, err := parseXrBlockHeader(data, RTCP_XR_RECEIVER_REFERENCE_TIME)
+	if err != nil {
+		return err
+	}
+	if len(body) != 8 {
+		return ErrBadLength
+	}
+	this.NtpTimestamp = binary.BigEndian.Uint64(body)
+	return nil
+}
+
+func (this *RtcpXrReceiverReferenceTime) Print(w io.Writer) {
+	printXrBlockHeader(w, RTCP_XR_RECEIVER_REFERENCE_TIME, this.MarshalSize())
+	fmt.Fprintf(w, "Timestamp, MSW: %d (0x%08x)\n", this.NtpTimestamp>>32, this.NtpTimestamp>>32)
+	fmt.Fprintf(w, "Timestamp, LSW: %d (0x%08x)\n", uint32(this.NtpTimestamp), uint32(this.NtpTimestamp))
+}
+
+// RtcpXrDlrrItem answers the receiver reference time of Ssrc, in the same
+// units as LastSr and DelaySinceLastSr of a report block.
+type RtcpXrDlrrItem struct {
+	Ssrc             uint32
+	LastRr           uint32
+	DelaySinceLastRr uint32
+}
+
+type RtcpXrDlrr struct {
+	Items []RtcpXrDlrrItem
+}
+
+func (this *RtcpXrDlrr) GetBlockType() byte {
+	return RTCP_XR_DLRR
+}
+
+func (this *RtcpXrDlrr) MarshalSize() int {
+	return RTCP_XR_BLOCK_HEADER_LEN + len(this.Items)*RTCP_XR_DLRR_ITEM_LEN
+}
+
+func (this *RtcpXrDlrr) MarshalTo(buf []byte) (int, error) {
+	size := this.MarshalSize()
+	err := marshalXrBlockHeader(buf, RTCP_XR_DLRR, 0, size)
+	if err != nil {
+		return 0, err
+	}
+
+	pos := RTCP_XR_BLOCK_HEADER_LEN
+	for _, v := range this.Items {
+		binary.BigEndian.PutUint32(buf[pos:], v.Ssrc)
+		binary.BigEndian.PutUint32(buf[pos+4:], v.LastRr)
+		binary.BigEndian.PutUint32(buf[pos+8:], v.DelaySinceLastRr)
+		pos += RTCP_XR_DLRR_ITEM_LEN
+	}
+	return size, nil
+}
+
+func (this *RtcpXrDlrr) Unmarshal(data []byte) error {
+	_, body, err := parseXrBlockHeader(data, RTCP_XR_DLRR)
+	if err != nil {
+		return err
+	}
+	if len(body)%RTCP_XR_DLRR_ITEM_LEN != 0 {
+		return ErrBadLength
+	}
+
+	this.Items = nil
+	for pos := 0; pos < len(body); pos += RTCP_XR_DLRR_ITEM_LEN {
+		this.Items = append(this.Items, RtcpXrDlrrItem{
+			Ssrc:             binary.BigEndian.Uint32(body[pos:]),
+			LastRr:           binary.BigEndian.Uint32(body[pos+4:]),
+			DelaySinceLastRr: binary.BigEndian.Uint32(body[pos+8:]),
+		})
+	}
+	return nil
+}
+
+func (this *RtcpXrDlrr) Print(w io.Writer) {
+	printXrBlockHeader(w, RTCP_XR_DLRR, this.MarshalSize())
+	for _, v := range this.Items {
+		fmt.Fprintf(w, "Identifier: 0x%08x (%d)\n", v.Ssrc, v.Ssrc)
+		fmt.Fprintf(w, "Last RR timestamp: %d (0x%08x)\n", v.LastRr, v.LastRr)
+		fmt.Fprintf(w, "Delay since last RR timestamp: %d (%d milliseconds)\n", v.DelaySinceLastRr, uint64(v.DelaySinceLastRr)*1000/65536)
+	}
+}
+
+// RtcpXrStatisticsSummary reports loss, duplicates, jitter and ttl over a
+// sequence range. The flags tell which of the fields are valid.
+type RtcpXrStatisticsSummary struct {
+	LossReport      bool
+	DuplicateReport bool
+	JitterReport    bool
+	TtlOrHopLimit   byte
+	Ssrc            uint32
+	BeginSeq        uint16
+	EndSeq          uint16
+	LostPackets     uint32
+	DupPackets      uint32
+	MinJitter       uint32
+	MaxJitter       uint32
+	MeanJitter      uint32
+	DevJitter       uint32
+	MinTtl          byte
+	MaxTtl          byte
+	MeanTtl         byte
+	DevTtl          byte
+}
+
+func (this *RtcpXrStatisticsSummary) GetBlockType() byte {
+	return RTCP_XR_STATISTICS_SUMMARY
+}
+
+func (this *RtcpXrStatisticsSummary) MarshalSize() int {
+	return RTCP_XR_BLOCK_HEADER_LEN + RTCP_XR_STATISTICS_SUMMARY_LEN
+}
+
+func (this *RtcpXrStatisticsSummary) MarshalTo(buf []byte) (int, error) {
+	flags := (this.TtlOrHopLimit << 3) & RTCP_XR_TTL_OR_HOP_LIMIT_MARSK
+	if this.LossReport {
+		flags |= RTCP_XR_LOSS_REPORT_MARSK
+	}
+	if this.DuplicateReport {
+		flags |= RTCP_XR_DUPLICATE_REPORT_MARSK
+	}
+	if this.JitterReport {
+		flags |= RTCP_XR_JITTER_REPORT_MARSK
+	}
+	size := this.MarshalSize()
+	err := marshalXrBlockHeader(buf, RTCP_XR_STATISTICS_SUMMARY, flags, size)
+	if err != nil {
+		return 0, err
+	}
+
+	binary.BigEndian.PutUint32(buf[4:], this.Ssrc)
+	binary.BigEndian.PutUint16(buf[8:], this.BeginSeq)
+	binary.BigEndian.PutUint16(buf[10:], this.EndSeq)
+	binary.BigEndian.PutUint32(buf[12:], this.LostPackets)
+	binary.BigEndian.PutUint32(buf[16:], this.DupPackets)
+	binary.BigEndian.PutUint32(buf[20:], this.MinJitter)
+	binary.BigEndian.PutUint32(buf[24:], this.MaxJitter)
+	binary.BigEndian.PutUint32(buf[28:], this.MeanJitter)
+	binary.BigEndian.PutUint32(buf[32:], this.DevJitter)
+	buf[36] = this.MinTtl
+	buf[37] = this.MaxTtl
+	buf[38] = this.MeanTtl
+	buf[39] = this.DevTtl
+	return size, nil
+}
+
+func (this *RtcpXrStatisticsSummary) Unmarshal(data []byte) error {
+	flags, body, err := parseXrBlockHeader(data, RTCP_XR_STATISTICS_SUMMARY)
+	if err != nil {
+		return err
+	}
+	if len(body) != RTCP_XR_STATISTICS_SUMMARY_LEN {
+		return ErrBadLength
+	}
+
+	this.LossReport = flags&RTCP_XR_LOSS_REPORT_MARSK != 0
+	this.DuplicateReport = flags&RTCP_XR_DUPLICATE_REPORT_MARSK != 0
+	this.JitterReport = flags&RTCP_XR_JITTER_REPORT_MARSK != 0
+	this.TtlOrHopLimit = (flags & RTCP_XR_TTL_OR_HOP_LIMIT_MARSK) >> 3
+	this.Ssrc = binary.BigEndian.Uint32(body)
+	this.BeginSeq = binary.BigEndian.Uint16(body[4:])
+	this.EndSeq = binary.BigEndian.Uint16(body[6:])
+	this.LostPackets = binary.BigEndian.Uint32(body[8:])
+	this.DupPackets = binary.BigEndian.Uint32(body[12:])
+	this.MinJitter = binary.BigEndian.Uint32(body[16:])
+	this.MaxJitter = binary.BigEndian.Uint32(body[20:])
+	this.MeanJitter = binary.BigEndian.Uint32(body[24:])
+	this.DevJitter = binary.BigEndian.Uint32(body[28:])
+	this.MinTtl = body[32]
+	this.MaxTtl = body[33]
+	this.MeanTtl = body[34]
+	this.DevTtl = body[35]
+	return nil
+}
+
+func (this *RtcpXrStatisticsSummary) Print(w io.Writer) {
+	printXrBlockHeader(w, RTCP_XR_STATISTICS_SUMMARY, this.MarshalSize())
+	fmt.Fprintf(w, "Loss Report Flag: %v\n", this.LossReport)
+	fmt.Fprintf(w, "Duplicates Report Flag: %v\n", this.DuplicateReport)
+	fmt.Fprintf(w, "Jitter Report Flag: %v\n", this.JitterReport)
+	fmt.Fprintf(w, "TTL or Hop Limit Flag: %d\n", this.TtlOrHopLimit)
+	fmt.Fprintf(w, "Source SSRC: 0x%08x (%d)\n", this.Ssrc, this.Ssrc)
+	fmt.Fprintf(w, "Begin Sequence Number: %d\n", this.BeginSeq)
+	fmt.Fprintf(w, "End Sequence Number: %d\n", this.EndSeq)
+	fmt.Fprintf(w, "Lost Packets: %d\n", this.LostPackets)
+	fmt.Fprintf(w, "Duplicate Packets: %d\n", this.DupPackets)
+	fmt.Fprintf(w, "Jitter: min %d, max %d, mean %d, deviation %d\n", this.MinJitter, this.MaxJitter, this.MeanJitter, this.DevJitter)
+	fmt.Fprintf(w, "TTL or Hop Limit: min %d, max %d, mean %d, deviation %d\n", this.MinTtl, this.MaxTtl, this.MeanTtl, this.DevTtl)
+}
+
+// RtcpXrVoipMetrics is the VoIP metrics block. Rates and densities are in
+// 1/256, durations and delays in milliseconds, levels in dBm, MOS values
+// multiplied by 10; RTCP_XR_UNAVAILABLE marks a metric that is not known.
+type RtcpXrVoipMetrics struct {
+	Ssrc           uint32
+	LossRate       byte
+	DiscardRate    byte
+	BurstDensity   byte
+	GapDensity     byte
+	BurstDuration  uint16
+	GapDuration    uint16
+	RoundTripDelay uint16
+	EndSystemDelay uint16
+	SignalLevel    int8
+	NoiseLevel     int8
+	Rerl           byte
+	Gmin           byte
+	RFactor        byte
+	ExtRFactor     byte
+	MosLq          byte
+	MosCq          byte
+	RxConfig       byte
+	JbNominal      uint16
+	JbMaximum      uint16
+	JbAbsMax       uint16
+}
+
+func (this *RtcpXrVoipMetrics) GetBlockType() byte {
+	return RTCP_XR_VOIP_METRICS
+}
+
+func (this *RtcpXrVoipMetrics) MarshalSize() int {
+	return RTCP_XR_BLOCK_HEADER_LEN + RTCP_XR_VOIP_METRICS_LEN
+}
+
+func (this *RtcpXrVoipMetrics) MarshalTo(buf []byte) (int, error) {
+	size := this.MarshalSize()
+	err := marshalXrBlockHeader(buf, RTCP_XR_VOIP_METRICS, 0, size)
+	if err != nil {
+		return 0, err
+	}
+
+	binary.BigEndian.PutUint32(buf[4:], this.Ssrc)
+	buf[8] = this.LossRate
+	buf[9] = this.DiscardRate
+	buf[10] = this.BurstDensity
+	buf[11] = this.GapDensity
+	binary.BigEndian.PutUint16(buf[12:], this.BurstDuration)
+	binary.BigEndian.PutUint16(buf[14:], this.GapDuration)
+	binary.BigEndian.PutUint16(buf[16:], this.RoundTripDelay)
+	binary.BigEndian.PutUint16(buf[18:], this.EndSystemDelay)
+	buf[20] = byte(this.SignalLevel)
+	buf[21] = byte(this.NoiseLevel)
+	buf[22] = this.Rerl
+	buf[23] = this.Gmin
+	buf[24] = this.RFactor
+	buf[25] = this.ExtRFactor
+	buf[26] = this.MosLq
+	buf[27] = this.MosCq
+	buf[28] = this.RxConfig
+	buf[29] = 0
+	binary.BigEndian.PutUint16(buf[30:], this.JbNominal)
+	binary.BigEndian.PutUint16(buf[32:], this.JbMaximum)
+	binary.BigEndian.PutUint16(buf[34:], this.JbAbsMax)
+	return size, nil
+}
+
+func (this *RtcpXrVoipMetrics) Unmarshal(data []byte) error {
+	_, body, err := parseXrBlockHeader(data, RTCP_XR_VOIP_METRICS)
+	if err != nil {
+		return err
+	}
+	if len(body) != RTCP_XR_VOIP_METRICS_LEN {
+		return ErrBadLength
+	}
+
+	this.Ssrc = binary.BigEndian.Uint32(body)
+	this.LossRate = body[4]
+	this.DiscardRate = body[5]
+	this.BurstDensity = body[6]
+	this.GapDensity = body[7]
+	this.BurstDuration = binary.BigEndian.Uint16(body[8:])
+	this.GapDuration = binary.BigEndian.Uint16(body[10:])
+	this.RoundTripDelay = binary.BigEndian.Uint16(body[12:])
+	this.EndSystemDelay = binary.BigEndian.Uint16(body[14:])
+	this.SignalLevel = int8(body[16])
+	this.NoiseLevel = int8(body[17])
+	this.Rerl = body[18]
+	this.Gmin = body[19]
+	this.RFactor = body[20]
+	this.ExtRFactor = body[21]
+	this.MosLq = body[22]
+	this.MosCq = body[23]
+	this.RxConfig = body[24]
+	this.JbNominal = binary.BigEndian.Uint16(body[26:])
+	this.JbMaximum = binary.BigEndian.Uint16(body[28:])
+	this.JbAbsMax = binary.BigEndian.Uint16(body[30:])
+	return nil
+}
+
+func printXrMos(w io.Writer, name string, mos byte) {
+	if mos == RTCP_XR_UNAVAILABLE {
+		fmt.Fprintf(w, "%s: unavailable\n", name)
+		return
+	}
+	fmt.Fprintf(w, "%s: %d.%d\n", name, mos/10, mos%10)
+}
+
+func (this *RtcpXrVoipMetrics) Print(w io.Writer) {
+	printXrBlockHeader(w, RTCP_XR_VOIP_METRICS, this.MarshalSize())
+	fmt.Fprintf(w, "Source SSRC: 0x%08x (%d)\n", this.Ssrc, this.Ssrc)
+	fmt.Fprintf(w, "Loss Rate: %d / 256\n", this.LossRate)
+	fmt.Fprintf(w, "Discard Rate: %d / 256\n", this.DiscardRate)
+	fmt.Fprintf(w, "Burst Density: %d / 256\n", this.BurstDensity)
+	fmt.Fprintf(w, "Gap Density: %d / 256\n", this.GapDensity)
+	fmt.Fprintf(w, "Burst Duration: %d ms\n", this.BurstDuration)
+	fmt.Fprintf(w, "Gap Duration: %d ms\n", this.GapDuration)
+	fmt.Fprintf(w, "Round Trip Delay: %d ms\n", this.RoundTripDelay)
+	fmt.Fprintf(w, "End System Delay: %d ms\n", this.EndSystemDelay)
+	fmt.Fprintf(w, "Signal Level: %d dBm\n", this.SignalLevel)
+	fmt.Fprintf(w, "Noise Level: %d dBm\n", this.NoiseLevel)
+	fmt.Fprintf(w, "Residual Echo Return Loss: %d dB\n", this.Rerl)
+	fmt.Fprintf(w, "Gmin: %d\n", this.Gmin)
+	fmt.Fprintf(w, "R Factor: %d\n", this.RFactor)
+	fmt.Fprintf(w, "External R Factor: %d\n", this.ExtRFactor)
+	printXrMos(w, "MOS - Listening Quality", this.MosLq)
+	printXrMos(w, "MOS - Conversational Quality", this.MosCq)
+	fmt.Fprintf(w, "Receiver Configuration: 0x%02x\n", this.RxConfig)
+	fmt.Fprintf(w, "Nominal Jitter Buffer Delay: %d ms\n", this.JbNominal)
+	fmt.Fprintf(w, "Maximum Jitter Buffer Delay: %d ms\n", this.JbMaximum)
+	fmt.Fprintf(w, "Absolute Maximum Jitter Buffer Delay: %d ms\n", this.JbAbsMax)
+}
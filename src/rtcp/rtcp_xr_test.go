@@ -0,0 +1,160 @@
+package rtcp
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/lioneagle/goutil/src/buffer"
+	"github.com/lioneagle/goutil/src/test"
+)
+
+func TestRtcpXrRoundTrip(t *testing.T) {
+	packet := &RtcpXr{Ssrc: 0x01020304, Blocks: []RtcpXrBlock{
+		&RtcpXrRle{Type: RTCP_XR_LOSS_RLE, Thinning: 2, Ssrc: 0x05060708, BeginSeq: 10, EndSeq: 40, Chunks: []uint16{0x4010, 0x8001}},
+		&RtcpXrRle{Type: RTCP_XR_DUPLICATE_RLE, Ssrc: 0x05060708, BeginSeq: 10, EndSeq: 26, Chunks: []uint16{0x0010}},
+		&RtcpXrPacketReceiptTimes{Ssrc: 5, BeginSeq: 1, EndSeq: 3, ReceiptTimes: []uint32{100, 0}},
+		&RtcpXrReceiverReferenceTime{NtpTimestamp: 0x0102030405060708},
+		&RtcpXrDlrr{Items: []RtcpXrDlrrItem{{1, 2, 3}}},
+		&RtcpXrStatisticsSummary{LossReport: true, JitterReport: true, TtlOrHopLimit: RTCP_XR_TTL_IPV4, Ssrc: 9, BeginSeq: 1, EndSeq: 100,
+			LostPackets: 2, MinJitter: 1, MaxJitter: 5, MeanJitter: 3, DevJitter: 1, MinTtl: 64, MaxTtl: 64, MeanTtl: 64},
+		&RtcpXrVoipMetrics{Ssrc: 9, LossRate: 1, DiscardRate: 2, BurstDensity: 3, GapDensity: 4, BurstDuration: 5, GapDuration: 6,
+			RoundTripDelay: 7, EndSystemDelay: 8, SignalLevel: -20, NoiseLevel: -60, Rerl: 127, Gmin: 16, RFactor: 93, ExtRFactor: 127,
+			MosLq: 44, MosCq: 43, RxConfig: 0x80, JbNominal: 40, JbMaximum: 80, JbAbsMax: 200},
+		&RtcpXrRawBlock{Data: []byte{0x2a, 0x01, 0x00, 0x01, 1, 2, 3, 4}},
+	}}
+	data := []byte{0x80, 0xcf, 0x00, 0x2a, 0x01, 0x02, 0x03, 0x04,
+		0x01, 0x02, 0x00, 0x03, 0x05, 0x06, 0x07, 0x08, 0x00, 0x0a, 0x00, 0x28, 0x40, 0x10, 0x80, 0x01,
+		0x02, 0x00, 0x00, 0x03, 0x05, 0x06, 0x07, 0x08, 0x00, 0x0a, 0x00, 0x1a, 0x00, 0x10, 0x00, 0x00,
+		0x03, 0x00, 0x00, 0x04, 0x00, 0x00, 0x00, 0x05, 0x00, 0x01, 0x00, 0x03, 0x00, 0x00, 0x00, 0x64, 0x00, 0x00, 0x00, 0x00,
+		0x04, 0x00, 0x00, 0x02, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08,
+		0x05, 0x00, 0x00, 0x03, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x03,
+		0x06, 0xa8, 0x00, 0x09, 0x00, 0x00, 0x00, 0x09, 0x00, 0x01, 0x00, 0x64, 0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x05, 0x00, 0x00, 0x00, 0x03, 0x00, 0x00, 0x00, 0x01, 0x40, 0x40, 0x40, 0x00,
+		0x07, 0x00, 0x00, 0x08, 0x00, 0x00, 0x00, 0x09, 0x01, 0x02, 0x03, 0x04, 0x00, 0x05, 0x00, 0x06, 0x00, 0x07, 0x00, 0x08,
+		0xec, 0xc4, 0x7f, 0x10, 0x5d, 0x7f, 0x2c, 0x2b, 0x80, 0x00, 0x00, 0x28, 0x00, 0x50, 0x00, 0xc8,
+		0x2a, 0x01, 0x00, 0x01, 0x01, 0x02, 0x03, 0x04}
+
+	encoded, err := MarshalRtcpPacket(packet)
+	test.EXPECT_EQ(t, err, nil, "")
+	test.EXPECT_EQ(t, encoded, data, "")
+
+	packets, err := ParseRtcpPackets(data)
+	test.EXPECT_EQ(t, err, nil, "")
+	test.EXPECT_EQ(t, packets, []RtcpPacket{packet}, "")
+}
+
+func TestRtcpXrUnmarshalError(t *testing.T) {
+	testdata := []struct {
+		data []byte
+		err  error
+	}{
+		{[]byte{0x80, 0xcf, 0x00, 0x00}, ErrTooShort},
+		{[]byte{0x80, 0xcf, 0x00, 0x02, 0x00, 0x00, 0x00, 0x01, 0x04, 0x00, 0x00, 0x02}, ErrBadLength},
+		{[]byte{0x80, 0xcf, 0x00, 0x03, 0x00, 0x00, 0x00, 0x01, 0x04, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00}, ErrBadLength},
+		{[]byte{0x80, 0xcf, 0x00, 0x03, 0x00, 0x00, 0x00, 0x01, 0x01, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00}, ErrTooShort},
+		{[]byte{0x80, 0xcf, 0x00, 0x03, 0x00, 0x00, 0x00, 0x01, 0x05, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00}, ErrBadLength},
+	}
+
+	for i, v := range testdata {
+		packet := &RtcpXr{}
+		test.EXPECT_EQ(t, packet.Unmarshal(v.data), v.err, "[%d]", i)
+	}
+}
+
+func TestEncodeRtcpXrRleChunks(t *testing.T) {
+	repeat := func(val bool, n int) []bool {
+		flags := make([]bool, n)
+		for i := range flags {
+			flags[i] = val
+		}
+		return flags
+	}
+
+	testdata := []struct {
+		flags  []bool
+		chunks []uint16
+	}{
+		{repeat(true, 20), []uint16{0x4014}},
+		{[]bool{true, false, true}, []uint16{0xd000}},
+		{repeat(false, 3), []uint16{0x0003}},
+		{append(repeat(true, 5), repeat(false, 20)...), []uint16{0xfc00, 0x000a}},
+	}
+
+	for i, v := range testdata {
+		v := v
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			t.Parallel()
+
+			chunks := EncodeRtcpXrRleChunks(v.flags)
+			test.EXPECT_EQ(t, chunks, v.chunks, "")
+
+			block := RtcpXrRle{Type: RTCP_XR_LOSS_RLE, Chunks: chunks}
+			test.EXPECT_EQ(t, block.Flags()[:len(v.flags)], v.flags, "")
+		})
+	}
+}
+
+func TestRtcpXrPrint(t *testing.T) {
+	packet := &RtcpXr{Ssrc: 1, Blocks: []RtcpXrBlock{
+		&RtcpXrReceiverReferenceTime{NtpTimestamp: 0x0000000100000002},
+		&RtcpXrDlrr{Items: []RtcpXrDlrrItem{{Ssrc: 2, LastRr: 3, DelaySinceLastRr: 65536}}},
+	}}
+
+	buf := buffer.NewByteBuffer(nil)
+	packet.Print(buf)
+	test.EXPECT_EQ(t, buf.String(), `10.. .... = version: 2
+..0. .... = Padding: false
+...0 0000 = Reserved: 0
+Packet type: Extended report (207)
+Length: 8 (36 bytes)
+Sender SSRC: 0x00000001 (1)
+Type: Receiver Reference Time Report Block (4)
+Length: 2
+Timestamp, MSW: 1 (0x00000001)
+Timestamp, LSW: 2 (0x00000002)
+Type: DLRR Report Block (5)
+Length: 3
+Identifier: 0x00000002 (2)
+Last RR timestamp: 3 (0x00000003)
+Delay since last RR timestamp: 65536 (1000 milliseconds)
+`, "")
+}
+
+func TestRtcpEModel(t *testing.T) {
+	model := RtcpEModelG711
+
+	test.EXPECT_EQ(t, fmt.Sprintf("%.2f", model.RFactor(0, 0, 1)), "93.20", "")
+	test.EXPECT_EQ(t, fmt.Sprintf("%.3f", RtcpDelayImpairment(200*time.Millisecond)), "7.297", "")
+	test.EXPECT_EQ(t, fmt.Sprintf("%.3f", model.EffectiveIe(1, 1)), "3.640", "")
+	test.EXPECT_EQ(t, fmt.Sprintf("%.2f", RtcpMos(93.2)), "4.41", "")
+	test.EXPECT_EQ(t, RtcpMos(-1), 1.0, "")
+	test.EXPECT_EQ(t, RtcpMos(120), 4.5, "")
+
+	block := &RtcpXrVoipMetrics{}
+	block.SetQuality(&model)
+	test.EXPECT_EQ(t, block.RFactor, byte(93), "")
+	test.EXPECT_EQ(t, block.ExtRFactor, byte(RTCP_XR_UNAVAILABLE), "")
+	test.EXPECT_EQ(t, block.MosLq, byte(44), "")
+	test.EXPECT_EQ(t, block.MosCq, byte(44), "")
+
+	block = &RtcpXrVoipMetrics{LossRate: 26, RoundTripDelay: 400, EndSystemDelay: 100}
+	block.SetQuality(&model)
+	test.EXPECT_EQ(t, block.RFactor < 60, true, "R factor = %d", block.RFactor)
+	test.EXPECT_EQ(t, block.MosCq < block.MosLq, true, "MOS-CQ = %d, MOS-LQ = %d", block.MosCq, block.MosLq)
+
+	// the sum of large delays does not wrap around to a small one
+	block = &RtcpXrVoipMetrics{RoundTripDelay: 4, EndSystemDelay: 0xfffe}
+	block.SetQuality(&model)
+	test.EXPECT_EQ(t, block.RFactor, byte(0), "")
+	test.EXPECT_EQ(t, block.MosCq, byte(10), "")
+}
+
+func TestRtcpXrRawBlockEmpty(t *testing.T) {
+	block := &RtcpXrRawBlock{}
+	test.EXPECT_EQ(t, block.GetBlockType(), byte(0), "")
+
+	buf := buffer.NewByteBuffer(nil)
+	block.Print(buf)
+	(&RtcpXr{Ssrc: 1, Blocks: []RtcpXrBlock{block}}).Print(buf)
+}
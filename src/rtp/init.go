@@ -4,4 +4,11 @@ func init() {
 	for i := 0; i < len(StaticRtpProfiles); i++ {
 		StaticRtpProfiles[i].PayloadType = byte(i)
 	}
+
+	RegisterRtpExtension(RTP_EXTENSION_URI_AUDIO_LEVEL, func() RtpExtension { return &RtpAudioLevel{} })
+	RegisterRtpExtension(RTP_EXTENSION_URI_ABS_SEND_TIME, func() RtpExtension { return &RtpAbsSendTime{} })
+	RegisterRtpExtension(RTP_EXTENSION_URI_TRANSPORT_CC, func() RtpExtension { return &RtpTransportSequence{} })
+	RegisterRtpExtension(RTP_EXTENSION_URI_SDES_MID, func() RtpExtension { return &RtpMid{} })
+	RegisterRtpExtension(RTP_EXTENSION_URI_TOFFSET, func() RtpExtension { return &RtpTransmissionOffset{} })
+	RegisterRtpExtension(RTP_EXTENSION_URI_VIDEO_ORIENTATION, func() RtpExtension { return &RtpVideoOrientation{} })
 }
@@ -0,0 +1,330 @@
+package rtp
+
+import (
+	"encoding/binary"
+	"errors"
+	"sync"
+	"time"
+)
+
+// header extension uris used by webrtc and RFC6464, RFC5450, RFC8843, 3GPP TS 26.114
+const (
+	RTP_EXTENSION_URI_AUDIO_LEVEL       = "urn:ietf:params:rtp-hdrext:ssrc-audio-level"
+	RTP_EXTENSION_URI_ABS_SEND_TIME     = "http://www.webrtc.org/experiments/rtp-hdrext/abs-send-time"
+	RTP_EXTENSION_URI_TRANSPORT_CC      = "http://www.ietf.org/id/draft-holmer-rmcat-transport-wide-cc-extensions-01"
+	RTP_EXTENSION_URI_SDES_MID          = "urn:ietf:params:rtp-hdrext:sdes:mid"
+	RTP_EXTENSION_URI_TOFFSET           = "urn:ietf:params:rtp-hdrext:toffset"
+	RTP_EXTENSION_URI_VIDEO_ORIENTATION = "urn:3gpp:video-orientation"
+)
+
+const RTP_ABS_SEND_TIME_FRAC_BITS = 18
+
+var (
+	ErrExtensionNotMapped     = errors.New("rtp: header extension uri is not mapped to an id")
+	ErrExtensionIdInUse       = errors.New("rtp: header extension id is mapped to another uri")
+	ErrBadExtensionValue      = errors.New("rtp: bad header extension value")
+	ErrExtensionUriRegistered = errors.New("rtp: header extension uri already registered")
+)
+
+// RtpExtension is a typed header extension element identified by its uri.
+type RtpExtension interface {
+	Uri() string
+	Marshal() ([]byte, error)
+	Unmarshal(data []byte) error
+}
+
+var rtpExtensions = struct {
+	sync.RWMutex
+	factories map[string]func() RtpExtension
+}{factories: make(map[string]func() RtpExtension)}
+
+// RegisterRtpExtension makes a typed extension decodable by uri.
+func RegisterRtpExtension(uri string, factory func() RtpExtension) error {
+	rtpExtensions.Lock()
+	defer rtpExtensions.Unlock()
+
+	if _, ok := rtpExtensions.factories[uri]; ok {
+		return ErrExtensionUriRegistered
+	}
+	rtpExtensions.factories[uri] = factory
+	return nil
+}
+
+// NewRtpExtension returns an empty typed extension for uri, or nil when the
+// uri is not registered.
+func NewRtpExtension(uri string) RtpExtension {
+	rtpExtensions.RLock()
+	factory, ok := rtpExtensions.factories[uri]
+	rtpExtensions.RUnlock()
+
+	if !ok {
+		return nil
+	}
+	return factory()
+}
+
+// RtpExtensionMap is the id to uri mapping negotiated for one session,
+// as carried by a=extmap.
+type RtpExtensionMap struct {
+	idToUri map[byte]string
+	uriToId map[string]byte
+}
+
+func NewRtpExtensionMap() *RtpExtensionMap {
+	return &RtpExtensionMap{
+		idToUri: make(map[byte]string),
+		uriToId: make(map[string]byte),
+	}
+}
+
+// Register maps id to uri, replacing any previous id of uri.
+func (this *RtpExtensionMap) Register(id byte, uri string) error {
+	if id == 0 {
+		return ErrBadExtensionElementId
+	}
+	if old, ok := this.idToUri[id]; ok && old != uri {
+		return ErrExtensionIdInUse
+	}
+	if old, ok := this.uriToId[uri]; ok {
+		delete(this.idToUri, old)
+	}
+	this.idToUri[id] = uri
+	this.uriToId[uri] = id
+	return nil
+}
+
+func (this *RtpExtensionMap) Unregister(uri string) {
+	if id, ok := this.uriToId[uri]; ok {
+		delete(this.idToUri, id)
+		delete(this.uriToId, uri)
+	}
+}
+
+func (this *RtpExtensionMap) GetId(uri string) (byte, bool) {
+	id, ok := this.uriToId[uri]
+	return id, ok
+}
+
+func (this *RtpExtensionMap) GetUri(id byte) (string, bool) {
+	uri, ok := this.idToUri[id]
+	return uri, ok
+}
+
+// Get decodes the element mapped to ext.Uri() into ext and returns whether
+// the packet carries it.
+func (this *RtpExtensionMap) Get(packet *RtpPacket, ext RtpExtension) (bool, error) {
+	id, ok := this.uriToId[ext.Uri()]
+	if !ok {
+		return false, ErrExtensionNotMapped
+	}
+
+	data := packet.GetExtensionElement(id)
+	if data == nil {
+		return false, nil
+	}
+	return true, ext.Unmarshal(data)
+}
+
+// Set encodes ext into the element mapped to ext.Uri().
+func (this *RtpExtensionMap) Set(packet *RtpPacket, ext RtpExtension) error {
+	id, ok := this.uriToId[ext.Uri()]
+	if !ok {
+		return ErrExtensionNotMapped
+	}
+
+	data, err := ext.Marshal()
+	if err != nil {
+		return err
+	}
+	return packet.SetExtensionElement(id, data)
+}
+
+// Decode returns every element of the packet whose id is mapped to a
+// registered uri. Unmapped and unknown elements are skipped.
+func (this *RtpExtensionMap) Decode(packet *RtpPacket) ([]RtpExtension, error) {
+	elements, err := packet.GetExtensionElements()
+	if err != nil {
+		return nil, err
+	}
+
+	var exts []RtpExtension
+	for _, v := range elements {
+		uri, ok := this.idToUri[v.Id]
+		if !ok {
+			continue
+		}
+		ext := NewRtpExtension(uri)
+		if ext == nil {
+			continue
+		}
+		err = ext.Unmarshal(v.Data)
+		if err != nil {
+			return nil, err
+		}
+		exts = append(exts, ext)
+	}
+	return exts, nil
+}
+
+// RtpAudioLevel is the client-to-mixer audio level from RFC6464, Level is
+// in -dBov from 0 to 127.
+type RtpAudioLevel struct {
+	Voice bool
+	Level byte
+}
+
+func (this *RtpAudioLevel) Uri() string { return RTP_EXTENSION_URI_AUDIO_LEVEL }
+
+func (this *RtpAudioLevel) Marshal() ([]byte, error) {
+	if this.Level > 127 {
+		return nil, ErrBadExtensionValue
+	}
+	val := this.Level
+	if this.Voice {
+		val |= 0x80
+	}
+	return []byte{val}, nil
+}
+
+func (this *RtpAudioLevel) Unmarshal(data []byte) error {
+	if len(data) < 1 {
+		return ErrBadExtensionValue
+	}
+	this.Voice = data[0]&0x80 != 0
+	this.Level = data[0] & 0x7F
+	return nil
+}
+
+// RtpAbsSendTime is the 24 bit 6.18 fixed point NTP send time in seconds.
+type RtpAbsSendTime struct {
+	Timestamp uint32
+}
+
+// NewRtpAbsSendTime converts t to abs-send-time. The NTP and unix epochs are
+// a multiple of 64 seconds apart, so unix seconds can be used directly.
+func NewRtpAbsSendTime(t time.Time) *RtpAbsSendTime {
+	seconds := uint64(t.Unix()) & 0x3F
+	frac := uint64(t.Nanosecond()) << RTP_ABS_SEND_TIME_FRAC_BITS / uint64(time.Second)
+	return &RtpAbsSendTime{Timestamp: uint32(seconds<<RTP_ABS_SEND_TIME_FRAC_BITS | frac)}
+}
+
+func (this *RtpAbsSendTime) Uri() string { return RTP_EXTENSION_URI_ABS_SEND_TIME }
+
+func (this *RtpAbsSendTime) Marshal() ([]byte, error) {
+	if this.Timestamp > 0xFFFFFF {
+		return nil, ErrBadExtensionValue
+	}
+	return []byte{byte(this.Timestamp >> 16), byte(this.Timestamp >> 8), byte(this.Timestamp)}, nil
+}
+
+func (this *RtpAbsSendTime) Unmarshal(data []byte) error {
+	if len(data) < 3 {
+		return ErrBadExtensionValue
+	}
+	this.Timestamp = uint32(data[0])<<16 | uint32(data[1])<<8 | uint32(data[2])
+	return nil
+}
+
+// RtpTransportSequence is the transport-wide sequence number used by
+// transport-cc feedback.
+type RtpTransportSequence struct {
+	Sequence uint16
+}
+
+func (this *RtpTransportSequence) Uri() string { return RTP_EXTENSION_URI_TRANSPORT_CC }
+
+func (this *RtpTransportSequence) Marshal() ([]byte, error) {
+	data := make([]byte, 2)
+	binary.BigEndian.PutUint16(data, this.Sequence)
+	return data, nil
+}
+
+func (this *RtpTransportSequence) Unmarshal(data []byte) error {
+	if len(data) < 2 {
+		return ErrBadExtensionValue
+	}
+	this.Sequence = binary.BigEndian.Uint16(data)
+	return nil
+}
+
+// RtpMid is the media identification from RFC8843.
+type RtpMid struct {
+	Mid string
+}
+
+func (this *RtpMid) Uri() string { return RTP_EXTENSION_URI_SDES_MID }
+
+func (this *RtpMid) Marshal() ([]byte, error) {
+	if len(this.Mid) == 0 || len(this.Mid) > RTP_TWO_BYTE_EXTENSION_MAX_LEN {
+		return nil, ErrBadExtensionValue
+	}
+	return []byte(this.Mid), nil
+}
+
+func (this *RtpMid) Unmarshal(data []byte) error {
+	if len(data) == 0 {
+		return ErrBadExtensionValue
+	}
+	this.Mid = string(data)
+	return nil
+}
+
+// RtpTransmissionOffset is the signed 24 bit offset in rtp timestamp units
+// from RFC5450.
+type RtpTransmissionOffset struct {
+	Offset int32
+}
+
+func (this *RtpTransmissionOffset) Uri() string { return RTP_EXTENSION_URI_TOFFSET }
+
+func (this *RtpTransmissionOffset) Marshal() ([]byte, error) {
+	if this.Offset < -0x800000 || this.Offset > 0x7FFFFF {
+		return nil, ErrBadExtensionValue
+	}
+	val := uint32(this.Offset)
+	return []byte{byte(val >> 16), byte(val >> 8), byte(val)}, nil
+}
+
+func (this *RtpTransmissionOffset) Unmarshal(data []byte) error {
+	if len(data) < 3 {
+		return ErrBadExtensionValue
+	}
+	val := uint32(data[0])<<16 | uint32(data[1])<<8 | uint32(data[2])
+	// sign extend from 24 bits
+	this.Offset = int32(val<<8) >> 8
+	return nil
+}
+
+// RtpVideoOrientation is the coordination of video orientation from
+// 3GPP TS 26.114, Rotation is in degrees clockwise.
+type RtpVideoOrientation struct {
+	BackCamera bool
+	Flip       bool
+	Rotation   uint16
+}
+
+func (this *RtpVideoOrientation) Uri() string { return RTP_EXTENSION_URI_VIDEO_ORIENTATION }
+
+func (this *RtpVideoOrientation) Marshal() ([]byte, error) {
+	if this.Rotation%90 != 0 || this.Rotation >= 360 {
+		return nil, ErrBadExtensionValue
+	}
+	val := byte(this.Rotation / 90)
+	if this.Flip {
+		val |= 0x04
+	}
+	if this.BackCamera {
+		val |= 0x08
+	}
+	return []byte{val}, nil
+}
+
+func (this *RtpVideoOrientation) Unmarshal(data []byte) error {
+	if len(data) < 1 {
+		return ErrBadExtensionValue
+	}
+	this.BackCamera = data[0]&0x08 != 0
+	this.Flip = data[0]&0x04 != 0
+	this.Rotation = uint16(data[0]&0x03) * 90
+	return nil
+}
@@ -0,0 +1,107 @@
+package rtp
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/lioneagle/goutil/src/test"
+)
+
+func TestRtpExtensionCodec(t *testing.T) {
+	testdata := []struct {
+		ext  RtpExtension
+		data []byte
+	}{
+		{&RtpAudioLevel{Voice: true, Level: 42}, []byte{0xaa}},
+		{&RtpAudioLevel{Voice: false, Level: 127}, []byte{0x7f}},
+		{&RtpAbsSendTime{Timestamp: 0x123456}, []byte{0x12, 0x34, 0x56}},
+		{&RtpTransportSequence{Sequence: 0xfffe}, []byte{0xff, 0xfe}},
+		{&RtpMid{Mid: "audio"}, []byte("audio")},
+		{&RtpTransmissionOffset{Offset: 1000}, []byte{0x00, 0x03, 0xe8}},
+		{&RtpTransmissionOffset{Offset: -2}, []byte{0xff, 0xff, 0xfe}},
+		{&RtpVideoOrientation{BackCamera: true, Flip: false, Rotation: 270}, []byte{0x0b}},
+		{&RtpVideoOrientation{BackCamera: false, Flip: true, Rotation: 90}, []byte{0x05}},
+	}
+
+	for i, v := range testdata {
+		v := v
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			t.Parallel()
+
+			data, err := v.ext.Marshal()
+			test.EXPECT_EQ(t, err, nil, "")
+			test.EXPECT_EQ(t, data, v.data, "")
+
+			ext := NewRtpExtension(v.ext.Uri())
+			err = ext.Unmarshal(data)
+			test.EXPECT_EQ(t, err, nil, "")
+			test.EXPECT_EQ(t, ext, v.ext, "")
+		})
+	}
+}
+
+func TestRtpExtensionCodecBadValue(t *testing.T) {
+	testdata := []RtpExtension{
+		&RtpAudioLevel{Level: 128},
+		&RtpAbsSendTime{Timestamp: 0x1000000},
+		&RtpMid{},
+		&RtpTransmissionOffset{Offset: 0x800000},
+		&RtpVideoOrientation{Rotation: 45},
+	}
+
+	for i, v := range testdata {
+		_, err := v.Marshal()
+		test.EXPECT_EQ(t, err, ErrBadExtensionValue, "[%d]", i)
+	}
+}
+
+func TestNewRtpAbsSendTime(t *testing.T) {
+	now := time.Unix(64*1000+3, int64(time.Second/4))
+	test.EXPECT_EQ(t, NewRtpAbsSendTime(now).Timestamp, uint32(3<<18|1<<16), "")
+}
+
+func TestRtpExtensionMap(t *testing.T) {
+	extmap := NewRtpExtensionMap()
+	test.EXPECT_EQ(t, extmap.Register(1, RTP_EXTENSION_URI_AUDIO_LEVEL), nil, "")
+	test.EXPECT_EQ(t, extmap.Register(3, RTP_EXTENSION_URI_TRANSPORT_CC), nil, "")
+	test.EXPECT_EQ(t, extmap.Register(4, "urn:example:unknown"), nil, "")
+	test.EXPECT_EQ(t, extmap.Register(3, RTP_EXTENSION_URI_SDES_MID), ErrExtensionIdInUse, "")
+	test.EXPECT_EQ(t, extmap.Register(0, RTP_EXTENSION_URI_SDES_MID), ErrBadExtensionElementId, "")
+
+	data := []byte{0x80, 0x08, 0x00, 0x01, 0x00, 0x00, 0x00, 0xa0, 0x00, 0x00, 0x00, 0x0a, 1, 2, 3}
+	rtp := NewRtpPacket()
+	err := rtp.Parse(data)
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+
+	test.EXPECT_EQ(t, extmap.Set(rtp, &RtpAudioLevel{Voice: true, Level: 30}), nil, "")
+	test.EXPECT_EQ(t, extmap.Set(rtp, &RtpTransportSequence{Sequence: 513}), nil, "")
+	test.EXPECT_EQ(t, extmap.Set(rtp, &RtpMid{Mid: "0"}), ErrExtensionNotMapped, "")
+	rtp.SetExtensionElement(4, []byte{0xff})
+	rtp.SetExtensionElement(5, []byte{0xff})
+	test.EXPECT_EQ(t, rtp.GetExtension(), []byte{0x10, 0x9e, 0x31, 0x02, 0x01, 0x40, 0xff, 0x50, 0xff, 0x00, 0x00, 0x00}, "")
+
+	level := &RtpAudioLevel{}
+	ok, err := extmap.Get(rtp, level)
+	test.EXPECT_EQ(t, ok, true, "")
+	test.EXPECT_EQ(t, err, nil, "")
+	test.EXPECT_EQ(t, level, &RtpAudioLevel{Voice: true, Level: 30}, "")
+
+	exts, err := extmap.Decode(rtp)
+	test.EXPECT_EQ(t, err, nil, "")
+	test.EXPECT_EQ(t, exts, []RtpExtension{&RtpAudioLevel{Voice: true, Level: 30}, &RtpTransportSequence{Sequence: 513}}, "")
+
+	// remapping a uri frees its old id
+	test.EXPECT_EQ(t, extmap.Register(2, RTP_EXTENSION_URI_AUDIO_LEVEL), nil, "")
+	_, ok = extmap.GetUri(1)
+	test.EXPECT_EQ(t, ok, false, "")
+	ok, err = extmap.Get(rtp, level)
+	test.EXPECT_EQ(t, ok, false, "")
+	test.EXPECT_EQ(t, err, nil, "")
+
+	extmap.Unregister(RTP_EXTENSION_URI_AUDIO_LEVEL)
+	_, ok = extmap.GetId(RTP_EXTENSION_URI_AUDIO_LEVEL)
+	test.EXPECT_EQ(t, ok, false, "")
+}
@@ -0,0 +1,209 @@
+package rtp
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+const RTP_MAX_CSRC_COUNT = 15
+
+var (
+	ErrBufferTooSmall  = errors.New("rtp: buffer too small")
+	ErrTooManyCsrc     = errors.New("rtp: too many csrc")
+	ErrExtensionTooBig = errors.New("rtp: header extension too big")
+)
+
+// Header holds every field of an rtp packet except the payload, so that a
+// packet can be laid out in one pass instead of by CalcLen, Alloc and the
+// setters of RtpPacket. The P bit follows PaddingLen and the X bit is set
+// when Extension is true or ExtensionElements is not empty.
+type Header struct {
+	Version          byte
+	Marker           bool
+	PayloadType      byte
+	Sequence         uint16
+	Timestamp        uint32
+	Ssrc             uint32
+	Csrc             []uint32
+	Extension        bool
+	ExtensionProfile uint16
+	// RFC8285 elements, used instead of ExtensionData when not empty
+	ExtensionElements []RtpExtensionElement
+	ExtensionData     []byte
+	PaddingLen        int
+}
+
+func NewHeader() *Header {
+	return &Header{Version: RTP_VERSION}
+}
+
+func (this *Header) hasExtension() bool {
+	return this.Extension || len(this.ExtensionElements) > 0
+}
+
+func (this *Header) extensionBodyLen() int {
+	if len(this.ExtensionElements) > 0 {
+		length, _ := rtpExtensionElementsLen(this.ExtensionElements, IsTwoByteExtensionProfile(this.ExtensionProfile))
+		return length
+	}
+	return (len(this.ExtensionData) + 3) &^ 0x3
+}
+
+// HeaderLen returns the length of the fixed header, csrc list and header
+// extension.
+func (this *Header) HeaderLen() int {
+	length := RTP_HEADER_LEN + len(this.Csrc)*4
+	if this.hasExtension() {
+		length += 4 + this.extensionBodyLen()
+	}
+	return length
+}
+
+// MarshalSize returns the packet length for a payload of payloadLen octets.
+func (this *Header) MarshalSize(payloadLen int) int {
+	return this.HeaderLen() + payloadLen + this.PaddingLen
+}
+
+// Marshal returns a new packet holding the header, payload and padding.
+func (this *Header) Marshal(payload []byte) ([]byte, error) {
+	buf := make([]byte, this.MarshalSize(len(payload)))
+	n, err := this.MarshalTo(buf, payload)
+	if err != nil {
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+// MarshalTo lays out the header, payload and padding into buf and returns the
+// number of octets written.
+func (this *Header) MarshalTo(buf []byte, payload []byte) (int, error) {
+	if len(this.Csrc) > RTP_MAX_CSRC_COUNT {
+		return 0, ErrTooManyCsrc
+	}
+	if this.PaddingLen < 0 || this.PaddingLen > 255 {
+		return 0, ErrBadPadding
+	}
+
+	size := this.MarshalSize(len(payload))
+	if len(buf) < size {
+		return 0, ErrBufferTooSmall
+	}
+
+	buf[0] = (this.Version << 6) | byte(len(this.Csrc))
+	if this.PaddingLen > 0 {
+		buf[0] |= RTP_PADDING_MARSK
+	}
+	if this.hasExtension() {
+		buf[0] |= RTP_EXTENSION_MARSK
+	}
+	buf[RTP_PAYLOAD_TYPE_OFFSET] = this.PayloadType & RTP_PAYLOAD_TYPE_MARSK
+	if this.Marker {
+		buf[RTP_MARKER_OFFSET] |= RTP_MARKER_MARSK
+	}
+	binary.BigEndian.PutUint16(buf[RTP_SEQUENCE_OFFSET:], this.Sequence)
+	binary.BigEndian.PutUint32(buf[RTP_TIMESTAMP_OFFSET:], this.Timestamp)
+	binary.BigEndian.PutUint32(buf[RTP_SSRC_OFFSET:], this.Ssrc)
+
+	offset := RTP_HEADER_LEN
+	for _, v := range this.Csrc {
+		binary.BigEndian.PutUint32(buf[offset:], v)
+		offset += 4
+	}
+
+	if this.hasExtension() {
+		n, err := this.marshalExtension(buf[offset:])
+		if err != nil {
+			return 0, err
+		}
+		offset += n
+	}
+
+	offset += copy(buf[offset:], payload)
+
+	if this.PaddingLen > 0 {
+		for i := 0; i < this.PaddingLen-1; i++ {
+			buf[offset] = 0
+			offset++
+		}
+		buf[offset] = byte(this.PaddingLen)
+		offset++
+	}
+
+	return offset, nil
+}
+
+func (this *Header) marshalExtension(buf []byte) (int, error) {
+	profile := this.ExtensionProfile
+	var body []byte
+
+	if len(this.ExtensionElements) > 0 {
+		var err error
+		twoByte := IsTwoByteExtensionProfile(this.ExtensionProfile)
+		profile, body, err = appendRtpExtensionElements(buf[4:4], this.ExtensionElements, twoByte)
+		if err != nil {
+			return 0, err
+		}
+	} else {
+		body = buf[4 : 4+this.extensionBodyLen()]
+		n := copy(body, this.ExtensionData)
+		for i := n; i < len(body); i++ {
+			body[i] = 0
+		}
+	}
+
+	if len(body)/4 > 0xFFFF {
+		return 0, ErrExtensionTooBig
+	}
+
+	binary.BigEndian.PutUint16(buf[0:], profile)
+	binary.BigEndian.PutUint16(buf[2:], uint16(len(body)/4))
+	return 4 + len(body), nil
+}
+
+// Unmarshal validates data and fills the header from it. The returned payload,
+// ExtensionData and ExtensionElements refer to data.
+func (this *Header) Unmarshal(data []byte) (payload []byte, err error) {
+	packet := RtpPacket{data: data}
+	err = packet.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	this.Version = packet.GetVersion()
+	this.Marker = packet.GetMarker() == 1
+	this.PayloadType = packet.GetPayloadType()
+	this.Sequence = packet.GetSequence()
+	this.Timestamp = packet.GetTimestamp()
+	this.Ssrc = packet.GetSsrc()
+	this.Csrc = packet.GetCsrc()
+	this.Extension = packet.GetExtensionBit() == 1
+	this.ExtensionProfile = packet.GetExtensionProfile()
+	this.ExtensionData = packet.GetExtension()
+	this.ExtensionElements = nil
+	if this.Extension && IsGeneralExtensionProfile(this.ExtensionProfile) {
+		this.ExtensionElements, err = ParseRtpExtensionElements(this.ExtensionProfile, this.ExtensionData)
+		if err != nil {
+			return nil, err
+		}
+	}
+	this.PaddingLen = packet.PaddingLen()
+
+	return packet.GetPayload(), nil
+}
+
+// SetHeader replaces the content of the packet with header and payload,
+// allocating a buffer of the exact size.
+func (this *RtpPacket) SetHeader(header *Header, payload []byte) error {
+	data, err := header.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	this.data = data
+	return nil
+}
+
+// GetHeader fills header from a packet that has been parsed or built, and
+// returns the payload.
+func (this *RtpPacket) GetHeader(header *Header) (payload []byte, err error) {
+	return header.Unmarshal(this.data)
+}
@@ -0,0 +1,274 @@
+package rtp
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// general header extension profiles from RFC8285
+const (
+	RTP_ONE_BYTE_EXTENSION_PROFILE       = 0xBEDE
+	RTP_TWO_BYTE_EXTENSION_PROFILE       = 0x1000
+	RTP_TWO_BYTE_EXTENSION_PROFILE_MARSK = 0xFFF0
+
+	RTP_ONE_BYTE_EXTENSION_MAX_ID  = 14
+	RTP_ONE_BYTE_EXTENSION_MAX_LEN = 16
+	RTP_ONE_BYTE_EXTENSION_STOP_ID = 15
+	RTP_TWO_BYTE_EXTENSION_MAX_ID  = 255
+	RTP_TWO_BYTE_EXTENSION_MAX_LEN = 255
+)
+
+var (
+	ErrNotGeneralExtension    = errors.New("rtp: header extension is not a RFC8285 general extension")
+	ErrBadExtensionElement    = errors.New("rtp: header extension element exceeds extension length")
+	ErrBadExtensionElementId  = errors.New("rtp: bad header extension element id")
+	ErrBadExtensionElementLen = errors.New("rtp: bad header extension element length")
+)
+
+type RtpExtensionElement struct {
+	Id   byte
+	Data []byte
+}
+
+func IsOneByteExtensionProfile(profile uint16) bool {
+	return profile == RTP_ONE_BYTE_EXTENSION_PROFILE
+}
+
+func IsTwoByteExtensionProfile(profile uint16) bool {
+	return profile&RTP_TWO_BYTE_EXTENSION_PROFILE_MARSK == RTP_TWO_BYTE_EXTENSION_PROFILE
+}
+
+func IsGeneralExtensionProfile(profile uint16) bool {
+	return IsOneByteExtensionProfile(profile) || IsTwoByteExtensionProfile(profile)
+}
+
+// ParseRtpExtensionElements splits the body of a one-byte or two-byte header
+// extension into elements. Element data refers to the input buffer.
+func ParseRtpExtensionElements(profile uint16, data []byte) ([]RtpExtensionElement, error) {
+	var elements []RtpExtensionElement
+
+	if IsOneByteExtensionProfile(profile) {
+		for pos := 0; pos < len(data); {
+			id := data[pos] >> 4
+			if id == 0 {
+				// padding octet
+				pos++
+				continue
+			}
+			if id == RTP_ONE_BYTE_EXTENSION_STOP_ID {
+				break
+			}
+			length := int(data[pos]&0x0F) + 1
+			pos++
+			if pos+length > len(data) {
+				return nil, ErrBadExtensionElement
+			}
+			elements = append(elements, RtpExtensionElement{Id: id, Data: data[pos : pos+length]})
+			pos += length
+		}
+		return elements, nil
+	}
+
+	if IsTwoByteExtensionProfile(profile) {
+		for pos := 0; pos < len(data); {
+			id := data[pos]
+			if id == 0 {
+				pos++
+				continue
+			}
+			if pos+2 > len(data) {
+				return nil, ErrBadExtensionElement
+			}
+			length := int(data[pos+1])
+			pos += 2
+			if pos+length > len(data) {
+				return nil, ErrBadExtensionElement
+			}
+			elements = append(elements, RtpExtensionElement{Id: id, Data: data[pos : pos+length]})
+			pos += length
+		}
+		return elements, nil
+	}
+
+	return nil, ErrNotGeneralExtension
+}
+
+// MarshalRtpExtensionElements encodes elements in the one-byte form when
+// every element fits it and twoByte is false, otherwise in the two-byte form.
+// The returned body is zero padded to a multiple of 4 octets.
+func MarshalRtpExtensionElements(elements []RtpExtensionElement, twoByte bool) (profile uint16, data []byte, err error) {
+	return appendRtpExtensionElements(nil, elements, twoByte)
+}
+
+func appendRtpExtensionElements(dst []byte, elements []RtpExtensionElement, twoByte bool) (profile uint16, data []byte, err error) {
+	twoByte, err = needTwoByteExtension(elements, twoByte)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	start := len(dst)
+	data = dst
+	if twoByte {
+		profile = RTP_TWO_BYTE_EXTENSION_PROFILE
+		for _, v := range elements {
+			data = append(data, v.Id, byte(len(v.Data)))
+			data = append(data, v.Data...)
+		}
+	} else {
+		profile = RTP_ONE_BYTE_EXTENSION_PROFILE
+		for _, v := range elements {
+			data = append(data, v.Id<<4|byte(len(v.Data)-1))
+			data = append(data, v.Data...)
+		}
+	}
+
+	for (len(data)-start)&0x3 != 0 {
+		data = append(data, 0)
+	}
+
+	return profile, data, nil
+}
+
+// rtpExtensionElementsLen returns the padded body length that
+// MarshalRtpExtensionElements produces.
+func rtpExtensionElementsLen(elements []RtpExtensionElement, twoByte bool) (int, error) {
+	twoByte, err := needTwoByteExtension(elements, twoByte)
+	if err != nil {
+		return 0, err
+	}
+
+	length := 0
+	for _, v := range elements {
+		length += 1 + len(v.Data)
+		if twoByte {
+			length++
+		}
+	}
+	return (length + 3) &^ 0x3, nil
+}
+
+func needTwoByteExtension(elements []RtpExtensionElement, twoByte bool) (bool, error) {
+	for _, v := range elements {
+		if v.Id == 0 {
+			return false, ErrBadExtensionElementId
+		}
+		if len(v.Data) > RTP_TWO_BYTE_EXTENSION_MAX_LEN {
+			return false, ErrBadExtensionElementLen
+		}
+		if v.Id > RTP_ONE_BYTE_EXTENSION_MAX_ID || len(v.Data) == 0 || len(v.Data) > RTP_ONE_BYTE_EXTENSION_MAX_LEN {
+			twoByte = true
+		}
+	}
+	return twoByte, nil
+}
+
+// GetExtensionElements returns the RFC8285 elements of the header extension,
+// or nil when the packet has no extension.
+func (this *RtpPacket) GetExtensionElements() ([]RtpExtensionElement, error) {
+	if this.GetExtensionBit() == 0 {
+		return nil, nil
+	}
+	return ParseRtpExtensionElements(this.GetExtensionProfile(), this.GetExtension())
+}
+
+// GetExtensionElement returns the data of element id, or nil when absent.
+func (this *RtpPacket) GetExtensionElement(id byte) []byte {
+	elements, err := this.GetExtensionElements()
+	if err != nil {
+		return nil
+	}
+	for _, v := range elements {
+		if v.Id == id {
+			return v.Data
+		}
+	}
+	return nil
+}
+
+// SetExtensionElement adds element id or replaces its data, growing or
+// shrinking the packet as needed. The one-byte form is kept unless the
+// element or an existing two-byte extension requires the two-byte form.
+func (this *RtpPacket) SetExtensionElement(id byte, data []byte) error {
+	if id == 0 {
+		return ErrBadExtensionElementId
+	}
+
+	elements, err := this.GetExtensionElements()
+	if err != nil {
+		return err
+	}
+
+	value := append([]byte(nil), data...)
+	found := false
+	for i := range elements {
+		if elements[i].Id == id {
+			elements[i].Data = value
+			found = true
+			break
+		}
+	}
+	if !found {
+		elements = append(elements, RtpExtensionElement{Id: id, Data: value})
+	}
+
+	twoByte := this.GetExtensionBit() == 1 && IsTwoByteExtensionProfile(this.GetExtensionProfile())
+	return this.setExtensionElements(elements, twoByte)
+}
+
+// RemoveExtensionElement removes element id and returns whether it was
+// present. The extension is removed entirely with its last element.
+func (this *RtpPacket) RemoveExtensionElement(id byte) bool {
+	elements, err := this.GetExtensionElements()
+	if err != nil {
+		return false
+	}
+
+	for i, v := range elements {
+		if v.Id == id {
+			elements = append(elements[:i:i], elements[i+1:]...)
+			twoByte := IsTwoByteExtensionProfile(this.GetExtensionProfile())
+			return this.setExtensionElements(elements, twoByte) == nil
+		}
+	}
+	return false
+}
+
+func (this *RtpPacket) setExtensionElements(elements []RtpExtensionElement, twoByte bool) error {
+	if len(elements) == 0 {
+		this.replaceExtension(0, nil)
+		return nil
+	}
+
+	profile, data, err := MarshalRtpExtensionElements(elements, twoByte)
+	if err != nil {
+		return err
+	}
+	this.replaceExtension(profile, data)
+	return nil
+}
+
+// replaceExtension rewrites the header extension with a body that is already
+// a multiple of 4 octets, moving the payload and padding after it. A nil body
+// removes the extension.
+func (this *RtpPacket) replaceExtension(profile uint16, body []byte) {
+	offset := RTP_HEADER_LEN + int(this.GetCsrcCount())*4
+	rest := this.data[this.HeaderLen():]
+
+	data := make([]byte, 0, offset+4+len(body)+len(rest))
+	data = append(data, this.data[:offset]...)
+	if body != nil {
+		var header [4]byte
+		binary.BigEndian.PutUint16(header[0:], profile)
+		binary.BigEndian.PutUint16(header[2:], uint16(len(body)/4))
+		data = append(data, header[:]...)
+		data = append(data, body...)
+	}
+	data = append(data, rest...)
+	this.data = data
+
+	if body != nil {
+		this.SetExtensionBit()
+	} else {
+		this.ClearExtensionBit()
+	}
+}
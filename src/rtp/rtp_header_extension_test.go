@@ -0,0 +1,147 @@
+package rtp
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/lioneagle/goutil/src/buffer"
+	"github.com/lioneagle/goutil/src/test"
+)
+
+func TestParseRtpExtensionElements(t *testing.T) {
+	testdata := []struct {
+		profile  uint16
+		data     []byte
+		elements []RtpExtensionElement
+		err      error
+	}{
+		{0xBEDE, []byte{0x10, 0xff, 0x00, 0x00}, []RtpExtensionElement{{1, []byte{0xff}}}, nil},
+		{0xBEDE, []byte{0x00, 0x21, 0xaa, 0xbb, 0x30, 0x01, 0x00, 0x00}, []RtpExtensionElement{{2, []byte{0xaa, 0xbb}}, {3, []byte{0x01}}}, nil},
+		{0xBEDE, []byte{0x10, 0xff, 0xf0, 0x00, 0x20, 0x01, 0x00, 0x00}, []RtpExtensionElement{{1, []byte{0xff}}}, nil},
+		{0xBEDE, []byte{0x13, 0xff, 0x00, 0x00}, nil, ErrBadExtensionElement},
+		{0x1000, []byte{0x01, 0x00, 0x02, 0x01, 0xaa, 0x00, 0x00, 0x00}, []RtpExtensionElement{{1, []byte{}}, {2, []byte{0xaa}}}, nil},
+		{0x100f, []byte{0xf0, 0x02, 0xaa, 0xbb}, []RtpExtensionElement{{0xf0, []byte{0xaa, 0xbb}}}, nil},
+		{0x1000, []byte{0x00, 0x00, 0x00, 0x05}, nil, ErrBadExtensionElement},
+		{0x1000, []byte{0x05, 0x03, 0x00, 0x00}, nil, ErrBadExtensionElement},
+		{0x1234, []byte{0x10, 0xff, 0x00, 0x00}, nil, ErrNotGeneralExtension},
+	}
+
+	for i, v := range testdata {
+		v := v
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			t.Parallel()
+
+			elements, err := ParseRtpExtensionElements(v.profile, v.data)
+			test.EXPECT_EQ(t, err, v.err, "")
+			test.EXPECT_EQ(t, elements, v.elements, "")
+		})
+	}
+}
+
+func TestMarshalRtpExtensionElements(t *testing.T) {
+	testdata := []struct {
+		elements []RtpExtensionElement
+		twoByte  bool
+		profile  uint16
+		data     []byte
+		err      error
+	}{
+		{[]RtpExtensionElement{{1, []byte{0xff}}}, false, 0xBEDE, []byte{0x10, 0xff, 0x00, 0x00}, nil},
+		{[]RtpExtensionElement{{1, []byte{0xff}}}, true, 0x1000, []byte{0x01, 0x01, 0xff, 0x00}, nil},
+		{[]RtpExtensionElement{{1, []byte{0xff}}, {15, []byte{1, 2}}}, false, 0x1000, []byte{0x01, 0x01, 0xff, 0x0f, 0x02, 0x01, 0x02, 0x00}, nil},
+		{[]RtpExtensionElement{{1, []byte{}}}, false, 0x1000, []byte{0x01, 0x00, 0x00, 0x00}, nil},
+		{[]RtpExtensionElement{{1, make([]byte, 17)}}, false, 0x1000, append([]byte{0x01, 17}, make([]byte, 18)...), nil},
+		{[]RtpExtensionElement{{0, []byte{0xff}}}, false, 0, nil, ErrBadExtensionElementId},
+		{[]RtpExtensionElement{{1, make([]byte, 256)}}, false, 0, nil, ErrBadExtensionElementLen},
+	}
+
+	for i, v := range testdata {
+		v := v
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			t.Parallel()
+
+			profile, data, err := MarshalRtpExtensionElements(v.elements, v.twoByte)
+			test.EXPECT_EQ(t, err, v.err, "")
+			test.EXPECT_EQ(t, profile, v.profile, "")
+			test.EXPECT_EQ(t, data, v.data, "")
+		})
+	}
+}
+
+func TestRtpPacketExtensionElements(t *testing.T) {
+	data := []byte{0xa1, 0x08, 0x00, 0x01, 0x00, 0x00, 0x00, 0xa0, 0x00, 0x00, 0x00, 0x0a, 0x00, 0x00, 0x00, 0x07, 1, 2, 3, 0, 2}
+
+	rtp := NewRtpPacket()
+	err := rtp.Parse(data)
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+
+	test.EXPECT_EQ(t, rtp.SetExtensionElement(1, []byte{0x80}), nil, "")
+	test.EXPECT_EQ(t, rtp.SetExtensionElement(3, []byte{0x01, 0x02, 0x03}), nil, "")
+	test.EXPECT_EQ(t, rtp.GetExtensionProfile(), uint16(0xBEDE), "")
+	test.EXPECT_EQ(t, rtp.GetExtension(), []byte{0x10, 0x80, 0x32, 0x01, 0x02, 0x03, 0x00, 0x00}, "")
+	test.EXPECT_EQ(t, rtp.GetExtensionElement(3), []byte{0x01, 0x02, 0x03}, "")
+	test.EXPECT_EQ(t, rtp.GetExtensionElement(2), []byte(nil), "")
+	test.EXPECT_EQ(t, rtp.GetCsrc(), []uint32{7}, "")
+	test.EXPECT_EQ(t, rtp.GetPayload(), []byte{1, 2, 3}, "")
+	test.EXPECT_EQ(t, rtp.PaddingLen(), 2, "")
+	test.EXPECT_EQ(t, rtp.Validate(), nil, "")
+
+	// a longer value still fits the one-byte form
+	test.EXPECT_EQ(t, rtp.SetExtensionElement(1, []byte{0x81, 0x82}), nil, "")
+	test.EXPECT_EQ(t, rtp.GetExtension(), []byte{0x11, 0x81, 0x82, 0x32, 0x01, 0x02, 0x03, 0x00}, "")
+
+	// an id above 14 needs the two-byte form
+	test.EXPECT_EQ(t, rtp.SetExtensionElement(20, []byte{0x55}), nil, "")
+	test.EXPECT_EQ(t, rtp.GetExtensionProfile(), uint16(0x1000), "")
+	test.EXPECT_EQ(t, rtp.GetExtension(), []byte{0x01, 0x02, 0x81, 0x82, 0x03, 0x03, 0x01, 0x02, 0x03, 0x14, 0x01, 0x55}, "")
+	test.EXPECT_EQ(t, rtp.Validate(), nil, "")
+
+	test.EXPECT_EQ(t, rtp.RemoveExtensionElement(20), true, "")
+	test.EXPECT_EQ(t, rtp.RemoveExtensionElement(20), false, "")
+	test.EXPECT_EQ(t, rtp.GetExtensionProfile(), uint16(0x1000), "")
+	test.EXPECT_EQ(t, rtp.RemoveExtensionElement(1), true, "")
+	test.EXPECT_EQ(t, rtp.RemoveExtensionElement(3), true, "")
+	test.EXPECT_EQ(t, rtp.GetExtensionBit(), byte(0), "")
+	test.EXPECT_EQ(t, rtp.data, data, "")
+
+	test.EXPECT_EQ(t, rtp.SetExtensionElement(0, []byte{1}), ErrBadExtensionElementId, "")
+
+	// an empty extension is replaced, not kept in front of the payload
+	empty := []byte{0x90, 0x08, 0x00, 0x01, 0x00, 0x00, 0x00, 0xa0, 0x00, 0x00, 0x00, 0x0a, 0xbe, 0xde, 0x00, 0x00, 1, 2, 3}
+	test.EXPECT_EQ(t, rtp.Parse(empty), nil, "")
+	test.EXPECT_EQ(t, rtp.SetExtensionElement(1, []byte{0x80}), nil, "")
+	test.EXPECT_EQ(t, rtp.GetExtension(), []byte{0x10, 0x80, 0x00, 0x00}, "")
+	test.EXPECT_EQ(t, rtp.GetPayload(), []byte{1, 2, 3}, "")
+}
+
+func TestRtpPacketExtensionElementsPrint(t *testing.T) {
+	data := []byte{0x90, 0x08, 0x00, 0x01, 0x00, 0x00, 0x00, 0xa0, 0x00, 0x00, 0x00, 0x0a, 0xbe, 0xde, 0x00, 0x01, 0x10, 0x80, 0x00, 0x00}
+
+	rtp := NewRtpPacket()
+	err := rtp.Parse(data)
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+
+	buf := buffer.NewByteBuffer(nil)
+	rtp.Print(buf)
+
+	wanted := `10.. .... = version: 2
+..0. .... = Padding: false
+...1 .... = Extension: true
+.... 0000 = CSRC count: 0
+.0.. .... = Marker: false
+Payload type: PCMA (8)
+Sequence number: 1
+Timestamp: 160
+SSRC: 0x0000000a (10)
+Extension:
+profile:0xbede (48862)
+00000000h: 10 80 00 00                                      ; ....
+ID: 1, length: 1
+00000000h: 80                                               ; .
+`
+	test.EXPECT_EQ(t, buf.String(), wanted, "")
+}
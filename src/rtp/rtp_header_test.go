@@ -0,0 +1,121 @@
+package rtp
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/lioneagle/goutil/src/test"
+)
+
+func TestHeaderMarshal(t *testing.T) {
+	testdata := []struct {
+		header  *Header
+		payload []byte
+		data    []byte
+	}{
+		{&Header{Version: 2, PayloadType: 8, Sequence: 1, Timestamp: 160, Ssrc: 10},
+			[]byte{1, 2, 3},
+			[]byte{0x80, 0x08, 0x00, 0x01, 0x00, 0x00, 0x00, 0xa0, 0x00, 0x00, 0x00, 0x0a, 1, 2, 3}},
+		{&Header{Version: 2, Marker: true, PayloadType: 96, Sequence: 0xffff, Timestamp: 1, Ssrc: 2, Csrc: []uint32{3, 4}, PaddingLen: 3},
+			[]byte{1},
+			[]byte{0xa2, 0xe0, 0xff, 0xff, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x03, 0x00, 0x00, 0x00, 0x04, 1, 0, 0, 3}},
+		{&Header{Version: 2, PayloadType: 0, Extension: true, ExtensionProfile: 0x1234, ExtensionData: []byte{1, 2, 3, 4, 5}},
+			nil,
+			[]byte{0x90, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x12, 0x34, 0x00, 0x02, 1, 2, 3, 4, 5, 0, 0, 0}},
+		{&Header{Version: 2, PayloadType: 0, Extension: true, ExtensionProfile: 0xBEDE},
+			[]byte{7, 8},
+			[]byte{0x90, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xbe, 0xde, 0x00, 0x00, 7, 8}},
+		{&Header{Version: 2, PayloadType: 0, ExtensionElements: []RtpExtensionElement{{1, []byte{0xaa}}, {2, []byte{0xbb, 0xcc}}}},
+			[]byte{9},
+			[]byte{0x90, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xbe, 0xde, 0x00, 0x02, 0x10, 0xaa, 0x21, 0xbb, 0xcc, 0, 0, 0, 9}},
+		{&Header{Version: 2, PayloadType: 0, ExtensionProfile: 0x1000, ExtensionElements: []RtpExtensionElement{{1, []byte{0xaa}}}},
+			[]byte{9},
+			[]byte{0x90, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x10, 0x00, 0x00, 0x01, 0x01, 0x01, 0xaa, 0x00, 9}},
+	}
+
+	for i, v := range testdata {
+		v := v
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			t.Parallel()
+
+			test.EXPECT_EQ(t, v.header.MarshalSize(len(v.payload)), len(v.data), "")
+
+			data, err := v.header.Marshal(v.payload)
+			test.EXPECT_EQ(t, err, nil, "")
+			test.EXPECT_EQ(t, data, v.data, "")
+
+			// MarshalTo must not depend on the old content of buf
+			buf := make([]byte, len(v.data)+10)
+			for j := range buf {
+				buf[j] = 0xff
+			}
+			n, err := v.header.MarshalTo(buf, v.payload)
+			test.EXPECT_EQ(t, err, nil, "")
+			test.EXPECT_EQ(t, buf[:n], v.data, "")
+
+			rtp := NewRtpPacket()
+			test.EXPECT_EQ(t, rtp.Parse(data), nil, "")
+			test.EXPECT_EQ(t, rtp.GetVersion(), v.header.Version, "")
+			test.EXPECT_EQ(t, rtp.GetMarker() == 1, v.header.Marker, "")
+			test.EXPECT_EQ(t, rtp.GetPayloadType(), v.header.PayloadType, "")
+			test.EXPECT_EQ(t, rtp.GetSequence(), v.header.Sequence, "")
+			test.EXPECT_EQ(t, rtp.GetTimestamp(), v.header.Timestamp, "")
+			test.EXPECT_EQ(t, rtp.GetSsrc(), v.header.Ssrc, "")
+			test.EXPECT_EQ(t, rtp.GetCsrc(), v.header.Csrc, "")
+			test.EXPECT_EQ(t, rtp.PaddingLen(), v.header.PaddingLen, "")
+			test.EXPECT_EQ(t, rtp.PayloadLen(), len(v.payload), "")
+
+			header := &Header{}
+			payload, err := header.Unmarshal(data)
+			test.EXPECT_EQ(t, err, nil, "")
+			test.EXPECT_EQ(t, payload, rtp.GetPayload(), "")
+			test.EXPECT_EQ(t, header.HeaderLen(), rtp.HeaderLen(), "")
+
+			again, err := header.Marshal(payload)
+			test.EXPECT_EQ(t, err, nil, "")
+			test.EXPECT_EQ(t, again, v.data, "")
+		})
+	}
+}
+
+func TestHeaderMarshalError(t *testing.T) {
+	testdata := []struct {
+		header *Header
+		buf    []byte
+		err    error
+	}{
+		{&Header{Version: 2}, make([]byte, 11), ErrBufferTooSmall},
+		{&Header{Version: 2, Csrc: make([]uint32, 16)}, make([]byte, 100), ErrTooManyCsrc},
+		{&Header{Version: 2, PaddingLen: 256}, make([]byte, 300), ErrBadPadding},
+		{&Header{Version: 2, ExtensionElements: []RtpExtensionElement{{0, []byte{1}}}}, make([]byte, 100), ErrBadExtensionElementId},
+	}
+
+	for i, v := range testdata {
+		_, err := v.header.MarshalTo(v.buf, nil)
+		test.EXPECT_EQ(t, err, v.err, "[%d]", i)
+	}
+}
+
+func TestRtpPacketSetHeader(t *testing.T) {
+	header := NewHeader()
+	header.PayloadType = 9
+	header.Sequence = 20
+	header.Timestamp = 160
+	header.Csrc = []uint32{5}
+
+	rtp := NewRtpPacket()
+	test.EXPECT_EQ(t, rtp.SetHeader(header, []byte{1, 2, 3, 4}), nil, "")
+	test.EXPECT_EQ(t, rtp.Validate(), nil, "")
+	test.EXPECT_EQ(t, rtp.GetCsrc(), []uint32{5}, "")
+
+	// setters keep working on a built packet
+	rtp.SetSequence(21)
+	test.EXPECT_EQ(t, rtp.SetExtensionElement(1, []byte{0x80}), nil, "")
+
+	ret := &Header{}
+	payload, err := rtp.GetHeader(ret)
+	test.EXPECT_EQ(t, err, nil, "")
+	test.EXPECT_EQ(t, payload, []byte{1, 2, 3, 4}, "")
+	test.EXPECT_EQ(t, ret.Sequence, uint16(21), "")
+	test.EXPECT_EQ(t, ret.ExtensionElements, []RtpExtensionElement{{1, []byte{0x80}}}, "")
+}
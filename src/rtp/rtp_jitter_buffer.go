@@ -0,0 +1,310 @@
+package rtp
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+type JitterMode int
+
+const (
+	// the playout delay is Delay
+	RTP_JITTER_MODE_FIXED JitterMode = iota
+	// the playout delay follows a percentile of the measured transit
+	// variation, between MinDelay and MaxDelay
+	RTP_JITTER_MODE_ADAPTIVE
+)
+
+func (this JitterMode) String() string {
+	switch this {
+	case RTP_JITTER_MODE_FIXED:
+		return "fixed"
+	case RTP_JITTER_MODE_ADAPTIVE:
+		return "adaptive"
+	}
+	return "unknown"
+}
+
+const (
+	RTP_JITTER_DEFAULT_DELAY      = 60 * time.Millisecond
+	RTP_JITTER_DEFAULT_MIN_DELAY  = 20 * time.Millisecond
+	RTP_JITTER_DEFAULT_MAX_DELAY  = 500 * time.Millisecond
+	RTP_JITTER_DEFAULT_MAX_LEN    = 500
+	RTP_JITTER_DEFAULT_PERCENTILE = 0.95
+	// transit samples the adaptive delay is measured over
+	RTP_JITTER_HISTORY_SIZE = 200
+)
+
+var (
+	ErrNoClockRate     = errors.New("rtp: jitter buffer without clock rate")
+	ErrLatePacket      = errors.New("rtp: packet arrived after its playout")
+	ErrDuplicatePacket = errors.New("rtp: duplicate packet")
+	ErrStrayPacket     = errors.New("rtp: packet out of the stream")
+)
+
+// JitterFrame is the packets of one timestamp in sequence order. Lost is the
+// number of packets missing since the previous frame.
+type JitterFrame struct {
+	Timestamp   uint32
+	PlayoutTime time.Time
+	Packets     []*RtpPacket
+	Lost        int
+}
+
+type JitterBufferStats struct {
+	Received   uint64
+	Released   uint64
+	Lost       uint64
+	Late       uint64
+	Duplicates uint64
+	// packets dropped by the size bound, as stray or by a restart of the
+	// stream
+	Discarded uint64
+	// buffer level in packets and in media time
+	Packets  int
+	Duration time.Duration
+	// playout delay in use
+	Delay time.Duration
+}
+
+type jitterEntry struct {
+	seq       int64
+	timestamp int64
+	packet    *RtpPacket
+}
+
+// JitterBuffer reorders the packets of one source and releases them as
+// frames at their playout time: the arrival time the frame would have with
+// the smallest transit seen, plus the playout delay.
+//
+// The buffer has no clock of its own, every call is passed the current time.
+// Calls must not run concurrently.
+type JitterBuffer struct {
+	ClockRate uint32
+	Mode      JitterMode
+	// playout delay of the fixed mode and initial one of the adaptive mode
+	Delay      time.Duration
+	MinDelay   time.Duration
+	MaxDelay   time.Duration
+	Percentile float64
+	// most packets held, the oldest frames are discarded beyond
+	MaxLen int
+
+	started     bool
+	badSeq      uint16
+	badSeqValid bool
+	seqs        SequenceUnwrapper
+	timestamps  TimestampUnwrapper
+	baseTs      int64
+	baseArrival time.Time
+	released    bool
+	releasedSeq int64
+	releasedTs  int64
+
+	entries    []*jitterEntry
+	transits   []time.Duration
+	next       int
+	minTransit time.Duration
+	delay      time.Duration
+	stats      JitterBufferStats
+}
+
+func NewJitterBuffer(clockRate uint32, mode JitterMode) *JitterBuffer {
+	return &JitterBuffer{
+		ClockRate:  clockRate,
+		Mode:       mode,
+		Delay:      RTP_JITTER_DEFAULT_DELAY,
+		MinDelay:   RTP_JITTER_DEFAULT_MIN_DELAY,
+		MaxDelay:   RTP_JITTER_DEFAULT_MAX_DELAY,
+		Percentile: RTP_JITTER_DEFAULT_PERCENTILE,
+		MaxLen:     RTP_JITTER_DEFAULT_MAX_LEN,
+	}
+}
+
+// Reset drops the packets and the timing, as for a new source.
+func (this *JitterBuffer) Reset() {
+	this.stats.Discarded += uint64(len(this.entries))
+	this.started = false
+	this.badSeqValid = false
+	this.seqs.Reset()
+	this.timestamps.Reset()
+	this.released = false
+	this.entries = nil
+	this.transits = nil
+	this.next = 0
+}
+
+// mediaTime splits the seconds from the remainder, units*time.Second would
+// overflow after a day or two of media.
+func (this *JitterBuffer) mediaTime(units int64) time.Duration {
+	rate := int64(this.ClockRate)
+	return time.Duration(units/rate)*time.Second + time.Duration(units%rate)*time.Second/time.Duration(rate)
+}
+
+// Push adds a packet that arrived at arrival. Late and duplicate packets are
+// dropped with ErrLatePacket and ErrDuplicatePacket.
+//
+// A packet far from the sequence, or going back behind the frames released
+// with a newer sequence number, is dropped with ErrStrayPacket. When the next
+// packet follows it the stream is taken as restarted, as with bad_seq in
+// RFC3550 appendix A.1.
+func (this *JitterBuffer) Push(packet *RtpPacket, arrival time.Time) error {
+	if this.ClockRate == 0 {
+		return ErrNoClockRate
+	}
+
+	if this.isJump(packet) {
+		if !this.badSeqValid || packet.GetSequence() != this.badSeq {
+			this.badSeq = packet.GetSequence() + 1
+			this.badSeqValid = true
+			this.stats.Received++
+			this.stats.Discarded++
+			return ErrStrayPacket
+		}
+		// the stream restarted
+		this.Reset()
+	}
+	this.badSeqValid = false
+
+	seq := this.seqs.Unwrap(packet.GetSequence())
+	ts := this.timestamps.Unwrap(packet.GetTimestamp())
+	if !this.started {
+		this.started = true
+		this.baseTs = ts
+		this.baseArrival = arrival
+		this.delay = this.Delay
+	}
+
+	this.stats.Received++
+	if this.released && (seq <= this.releasedSeq || ts < this.releasedTs) {
+		this.stats.Late++
+		return ErrLatePacket
+	}
+
+	pos := sort.Search(len(this.entries), func(i int) bool { return this.entries[i].seq >= seq })
+	if pos < len(this.entries) && this.entries[pos].seq == seq {
+		this.stats.Duplicates++
+		return ErrDuplicatePacket
+	}
+
+	this.entries = append(this.entries, nil)
+	copy(this.entries[pos+1:], this.entries[pos:])
+	this.entries[pos] = &jitterEntry{seq: seq, timestamp: ts, packet: packet}
+
+	this.updateDelay(arrival.Sub(this.baseArrival) - this.mediaTime(ts-this.baseTs))
+
+	for this.MaxLen > 0 && len(this.entries) > this.MaxLen {
+		frame := this.popFrame()
+		this.stats.Discarded += uint64(len(frame.Packets))
+	}
+	return nil
+}
+
+// isJump tells whether packet is out of the sequence of the stream or has a
+// timestamp before the frames released.
+func (this *JitterBuffer) isJump(packet *RtpPacket) bool {
+	highest, ok := this.seqs.GetHighest()
+	if !ok {
+		return false
+	}
+	seq := this.seqs.Peek(packet.GetSequence())
+	if seq-highest > RTP_MAX_DROPOUT || highest-seq > RTP_MAX_DROPOUT {
+		return true
+	}
+	return this.released && seq > this.releasedSeq && this.timestamps.Peek(packet.GetTimestamp()) < this.releasedTs
+}
+
+// updateDelay adds the transit of a packet relative to the first one.
+func (this *JitterBuffer) updateDelay(transit time.Duration) {
+	if len(this.transits) < RTP_JITTER_HISTORY_SIZE {
+		this.transits = append(this.transits, transit)
+	} else {
+		this.transits[this.next] = transit
+		this.next = (this.next + 1) % RTP_JITTER_HISTORY_SIZE
+	}
+
+	sorted := make([]time.Duration, len(this.transits))
+	copy(sorted, this.transits)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	this.minTransit = sorted[0]
+
+	if this.Mode != RTP_JITTER_MODE_ADAPTIVE {
+		this.delay = this.Delay
+		return
+	}
+
+	pos := int(float64(len(sorted)-1) * this.Percentile)
+	delay := sorted[pos] - this.minTransit
+	if delay < this.MinDelay {
+		delay = this.MinDelay
+	}
+	if delay > this.MaxDelay {
+		delay = this.MaxDelay
+	}
+	this.delay = delay
+}
+
+func (this *JitterBuffer) playoutTime(ts int64) time.Time {
+	return this.baseArrival.Add(this.mediaTime(ts-this.baseTs) + this.minTransit + this.delay)
+}
+
+// NextPlayoutTime returns when the next frame is due, false when the buffer
+// is empty.
+func (this *JitterBuffer) NextPlayoutTime() (time.Time, bool) {
+	if len(this.entries) == 0 {
+		return time.Time{}, false
+	}
+	return this.playoutTime(this.entries[0].timestamp), true
+}
+
+// Pop returns the next frame when its playout time has come at now, nil
+// otherwise.
+func (this *JitterBuffer) Pop(now time.Time) *JitterFrame {
+	playout, ok := this.NextPlayoutTime()
+	if !ok || playout.After(now) {
+		return nil
+	}
+	frame := this.popFrame()
+	this.stats.Released++
+	this.stats.Lost += uint64(frame.Lost)
+	return frame
+}
+
+func (this *JitterBuffer) popFrame() *JitterFrame {
+	first := this.entries[0]
+	frame := &JitterFrame{
+		Timestamp:   uint32(first.timestamp),
+		PlayoutTime: this.playoutTime(first.timestamp),
+	}
+	if this.released {
+		frame.Lost = int(first.seq - this.releasedSeq - 1)
+	}
+
+	n := 0
+	for n < len(this.entries) && this.entries[n].timestamp == first.timestamp {
+		frame.Packets = append(frame.Packets, this.entries[n].packet)
+		n++
+	}
+
+	this.released = true
+	this.releasedSeq = this.entries[n-1].seq
+	this.releasedTs = first.timestamp
+	this.entries = this.entries[n:]
+	return frame
+}
+
+func (this *JitterBuffer) GetStats() JitterBufferStats {
+	stats := this.stats
+	stats.Packets = len(this.entries)
+	if len(this.entries) > 0 {
+		stats.Duration = this.mediaTime(this.entries[len(this.entries)-1].timestamp - this.entries[0].timestamp)
+	}
+	stats.Delay = this.delay
+	return stats
+}
+
+// GetDelay returns the playout delay in use.
+func (this *JitterBuffer) GetDelay() time.Duration {
+	return this.delay
+}
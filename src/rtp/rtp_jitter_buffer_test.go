@@ -0,0 +1,265 @@
+package rtp
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/lioneagle/goutil/src/test"
+)
+
+func TestJitterBufferFixedPlayout(t *testing.T) {
+	base := time.Unix(1000, 0)
+	jb := NewJitterBuffer(8000, RTP_JITTER_MODE_FIXED)
+
+	for i := 0; i < 3; i++ {
+		err := jb.Push(newTestRtpPacket(0, uint16(i+1), uint32(i*160)), base.Add(time.Duration(i)*20*time.Millisecond))
+		test.EXPECT_EQ(t, err, nil, "packet %d", i)
+	}
+
+	playout, ok := jb.NextPlayoutTime()
+	test.EXPECT_EQ(t, ok, true, "")
+	test.EXPECT_EQ(t, playout, base.Add(60*time.Millisecond), "")
+
+	test.EXPECT_EQ(t, jb.Pop(base.Add(59*time.Millisecond)) == nil, true, "")
+	frame := jb.Pop(base.Add(60 * time.Millisecond))
+	test.EXPECT_EQ(t, frame != nil, true, "")
+	test.EXPECT_EQ(t, frame.Timestamp, uint32(0), "")
+	test.EXPECT_EQ(t, frame.PlayoutTime, base.Add(60*time.Millisecond), "")
+	test.EXPECT_EQ(t, len(frame.Packets), 1, "")
+
+	test.EXPECT_EQ(t, jb.Pop(base.Add(79*time.Millisecond)) == nil, true, "")
+	frame = jb.Pop(base.Add(80 * time.Millisecond))
+	test.EXPECT_EQ(t, frame.Packets[0].GetSequence(), uint16(2), "")
+}
+
+func TestJitterBufferMediaTime(t *testing.T) {
+	testdata := []struct {
+		clockRate uint32
+		units     int64
+		wanted    time.Duration
+	}{
+		{8000, 160, 20 * time.Millisecond},
+		{8000, -160, -20 * time.Millisecond},
+		{90000, 3000, 33333333 * time.Nanosecond},
+		// two days at 90 kHz overflow units*time.Second
+		{90000, 48 * 3600 * 90000, 48 * time.Hour},
+		{48000, 100*3600*48000 + 960, 100*time.Hour + 20*time.Millisecond},
+	}
+
+	for i, v := range testdata {
+		jb := NewJitterBuffer(v.clockRate, RTP_JITTER_MODE_FIXED)
+		test.EXPECT_EQ(t, jb.mediaTime(v.units), v.wanted, "[%d]", i)
+	}
+}
+
+func TestJitterBufferOrder(t *testing.T) {
+	testdata := []struct {
+		seqs       []uint16
+		timestamps []uint32
+		wanted     []uint16
+		lost       []int
+	}{
+		// in order
+		{[]uint16{1, 2, 3}, []uint32{0, 160, 320}, []uint16{1, 2, 3}, []int{0, 0, 0}},
+		// reordered
+		{[]uint16{2, 1, 4, 3}, []uint32{160, 0, 480, 320}, []uint16{1, 2, 3, 4}, []int{0, 0, 0, 0}},
+		// sequence and timestamp wrap around
+		{[]uint16{65534, 0, 65535, 1}, []uint32{0xFFFFFF00, 0x40, 0xFFFFFFA0, 0xE0}, []uint16{65534, 65535, 0, 1}, []int{0, 0, 0, 0}},
+		// loss
+		{[]uint16{1, 2, 4, 7}, []uint32{0, 160, 480, 960}, []uint16{1, 2, 4, 7}, []int{0, 0, 1, 2}},
+		// loss across the wrap around
+		{[]uint16{65535, 1}, []uint32{0, 320}, []uint16{65535, 1}, []int{0, 1}},
+	}
+
+	for i, v := range testdata {
+		v := v
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			t.Parallel()
+
+			base := time.Unix(1000, 0)
+			jb := NewJitterBuffer(8000, RTP_JITTER_MODE_FIXED)
+			for j, seq := range v.seqs {
+				err := jb.Push(newTestRtpPacket(0, seq, v.timestamps[j]), base.Add(time.Duration(j)*20*time.Millisecond))
+				test.EXPECT_EQ(t, err, nil, "packet %d", j)
+			}
+
+			now := base.Add(time.Second)
+			lost := 0
+			for j, seq := range v.wanted {
+				frame := jb.Pop(now)
+				if frame == nil {
+					t.Fatalf("frame %d not released", j)
+				}
+				test.EXPECT_EQ(t, frame.Packets[0].GetSequence(), seq, "frame %d", j)
+				test.EXPECT_EQ(t, frame.Lost, v.lost[j], "frame %d", j)
+				lost += v.lost[j]
+			}
+			test.EXPECT_EQ(t, jb.Pop(now) == nil, true, "")
+			test.EXPECT_EQ(t, jb.GetStats().Lost, uint64(lost), "")
+			test.EXPECT_EQ(t, jb.GetStats().Released, uint64(len(v.wanted)), "")
+		})
+	}
+}
+
+func TestJitterBufferFrame(t *testing.T) {
+	base := time.Unix(1000, 0)
+	jb := NewJitterBuffer(90000, RTP_JITTER_MODE_FIXED)
+
+	jb.Push(newTestRtpPacket(96, 2, 0), base)
+	jb.Push(newTestRtpPacket(96, 1, 0), base)
+	jb.Push(newTestRtpPacket(96, 3, 3000), base.Add(33*time.Millisecond))
+
+	frame := jb.Pop(base.Add(time.Second))
+	test.EXPECT_EQ(t, len(frame.Packets), 2, "")
+	test.EXPECT_EQ(t, frame.Packets[0].GetSequence(), uint16(1), "")
+	test.EXPECT_EQ(t, frame.Packets[1].GetSequence(), uint16(2), "")
+
+	frame = jb.Pop(base.Add(time.Second))
+	test.EXPECT_EQ(t, len(frame.Packets), 1, "")
+	test.EXPECT_EQ(t, frame.Timestamp, uint32(3000), "")
+}
+
+func TestJitterBufferLateAndDuplicate(t *testing.T) {
+	base := time.Unix(1000, 0)
+	jb := NewJitterBuffer(8000, RTP_JITTER_MODE_FIXED)
+
+	jb.Push(newTestRtpPacket(0, 1, 0), base)
+	jb.Push(newTestRtpPacket(0, 2, 160), base.Add(20*time.Millisecond))
+	jb.Pop(base.Add(time.Second))
+	jb.Pop(base.Add(time.Second))
+
+	test.EXPECT_EQ(t, jb.Push(newTestRtpPacket(0, 1, 0), base.Add(time.Second)), ErrLatePacket, "")
+	test.EXPECT_EQ(t, jb.Push(newTestRtpPacket(0, 3, 320), base.Add(time.Second)), nil, "")
+	test.EXPECT_EQ(t, jb.Push(newTestRtpPacket(0, 3, 320), base.Add(time.Second)), ErrDuplicatePacket, "")
+
+	stats := jb.GetStats()
+	test.EXPECT_EQ(t, stats.Received, uint64(5), "")
+	test.EXPECT_EQ(t, stats.Released, uint64(2), "")
+	test.EXPECT_EQ(t, stats.Late, uint64(1), "")
+	test.EXPECT_EQ(t, stats.Duplicates, uint64(1), "")
+	test.EXPECT_EQ(t, stats.Packets, 1, "")
+}
+
+func TestJitterBufferMaxLen(t *testing.T) {
+	base := time.Unix(1000, 0)
+	jb := NewJitterBuffer(8000, RTP_JITTER_MODE_FIXED)
+	jb.MaxLen = 2
+
+	for i := 0; i < 4; i++ {
+		jb.Push(newTestRtpPacket(0, uint16(i+1), uint32(i*160)), base.Add(time.Duration(i)*20*time.Millisecond))
+	}
+
+	stats := jb.GetStats()
+	test.EXPECT_EQ(t, stats.Discarded, uint64(2), "")
+	test.EXPECT_EQ(t, stats.Packets, 2, "")
+	test.EXPECT_EQ(t, stats.Duration, 20*time.Millisecond, "")
+
+	frame := jb.Pop(base.Add(time.Second))
+	test.EXPECT_EQ(t, frame.Packets[0].GetSequence(), uint16(3), "")
+	test.EXPECT_EQ(t, frame.Lost, 0, "")
+	test.EXPECT_EQ(t, jb.Push(newTestRtpPacket(0, 2, 160), base.Add(time.Second)), ErrLatePacket, "")
+}
+
+func TestJitterBufferAdaptiveDelay(t *testing.T) {
+	testdata := []struct {
+		jitter time.Duration
+		delay  time.Duration
+	}{
+		{0, RTP_JITTER_DEFAULT_MIN_DELAY},
+		{40 * time.Millisecond, 40 * time.Millisecond},
+		{100 * time.Millisecond, 100 * time.Millisecond},
+		{time.Second, RTP_JITTER_DEFAULT_MAX_DELAY},
+	}
+
+	for i, v := range testdata {
+		v := v
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			t.Parallel()
+
+			base := time.Unix(1000, 0)
+			jb := NewJitterBuffer(8000, RTP_JITTER_MODE_ADAPTIVE)
+			test.EXPECT_EQ(t, jb.GetDelay(), time.Duration(0), "")
+
+			for j := 0; j < 50; j++ {
+				arrival := base.Add(time.Duration(j) * 20 * time.Millisecond)
+				if j%2 == 1 {
+					arrival = arrival.Add(v.jitter)
+				}
+				jb.Push(newTestRtpPacket(0, uint16(j), uint32(j*160)), arrival)
+			}
+			test.EXPECT_EQ(t, jb.GetDelay(), v.delay, "")
+			test.EXPECT_EQ(t, jb.GetStats().Delay, v.delay, "")
+
+			playout, _ := jb.NextPlayoutTime()
+			test.EXPECT_EQ(t, playout, base.Add(v.delay), "")
+		})
+	}
+}
+
+func TestJitterBufferRestart(t *testing.T) {
+	base := time.Unix(1000, 0)
+	jb := NewJitterBuffer(8000, RTP_JITTER_MODE_FIXED)
+
+	jb.Push(newTestRtpPacket(0, 1, 0), base)
+	jb.Push(newTestRtpPacket(0, 2, 160), base.Add(20*time.Millisecond))
+	jb.Pop(base.Add(time.Second))
+
+	test.EXPECT_EQ(t, jb.Push(newTestRtpPacket(0, 10000, 80000), base.Add(time.Second)), ErrStrayPacket, "")
+	test.EXPECT_EQ(t, jb.Push(newTestRtpPacket(0, 10001, 80160), base.Add(time.Second)), nil, "")
+
+	stats := jb.GetStats()
+	test.EXPECT_EQ(t, stats.Discarded, uint64(2), "")
+	test.EXPECT_EQ(t, stats.Packets, 1, "")
+
+	frame := jb.Pop(base.Add(time.Second + 60*time.Millisecond))
+	test.EXPECT_EQ(t, frame.Packets[0].GetSequence(), uint16(10001), "")
+	test.EXPECT_EQ(t, frame.Lost, 0, "")
+}
+
+func TestJitterBufferStrayPacket(t *testing.T) {
+	base := time.Unix(1000, 0)
+	jb := NewJitterBuffer(8000, RTP_JITTER_MODE_FIXED)
+
+	for i := 0; i < 5; i++ {
+		jb.Push(newTestRtpPacket(0, uint16(i+1), uint32(i*160)), base.Add(time.Duration(i)*20*time.Millisecond))
+	}
+	test.EXPECT_EQ(t, jb.Push(newTestRtpPacket(0, 40000, 0), base.Add(100*time.Millisecond)), ErrStrayPacket, "")
+	test.EXPECT_EQ(t, jb.Push(newTestRtpPacket(0, 6, 800), base.Add(100*time.Millisecond)), nil, "")
+
+	stats := jb.GetStats()
+	test.EXPECT_EQ(t, stats.Discarded, uint64(1), "")
+	test.EXPECT_EQ(t, stats.Packets, 6, "")
+	for i := 0; i < 6; i++ {
+		frame := jb.Pop(base.Add(time.Second))
+		test.EXPECT_EQ(t, frame.Packets[0].GetSequence(), uint16(i+1), "frame %d", i)
+	}
+}
+
+func TestJitterBufferTimestampReset(t *testing.T) {
+	base := time.Unix(1000, 0)
+	jb := NewJitterBuffer(8000, RTP_JITTER_MODE_FIXED)
+
+	jb.Push(newTestRtpPacket(0, 1, 100000), base)
+	jb.Push(newTestRtpPacket(0, 2, 100160), base.Add(20*time.Millisecond))
+	jb.Pop(base.Add(time.Second))
+	jb.Pop(base.Add(time.Second))
+
+	// the timestamps go back with the sequence numbers going on
+	now := base.Add(time.Second)
+	test.EXPECT_EQ(t, jb.Push(newTestRtpPacket(0, 3, 0), now), ErrStrayPacket, "")
+	test.EXPECT_EQ(t, jb.Push(newTestRtpPacket(0, 4, 160), now), nil, "")
+	test.EXPECT_EQ(t, jb.Push(newTestRtpPacket(0, 5, 320), now.Add(20*time.Millisecond)), nil, "")
+
+	frame := jb.Pop(now.Add(60 * time.Millisecond))
+	test.EXPECT_EQ(t, frame.Packets[0].GetSequence(), uint16(4), "")
+	frame = jb.Pop(now.Add(80 * time.Millisecond))
+	test.EXPECT_EQ(t, frame.Packets[0].GetSequence(), uint16(5), "")
+	test.EXPECT_EQ(t, jb.GetStats().Late, uint64(0), "")
+}
+
+func TestJitterBufferNoClockRate(t *testing.T) {
+	jb := NewJitterBuffer(0, RTP_JITTER_MODE_FIXED)
+	test.EXPECT_EQ(t, jb.Push(newTestRtpPacket(0, 1, 0), time.Unix(1000, 0)), ErrNoClockRate, "")
+	test.EXPECT_EQ(t, jb.Pop(time.Unix(1000, 0)) == nil, true, "")
+}
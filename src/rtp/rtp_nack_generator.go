@@ -0,0 +1,224 @@
+package rtp
+
+import (
+	"sort"
+	"time"
+
+	"rtcp"
+
+	"github.com/lioneagle/goutil/src/algorithm/timewheel"
+)
+
+const (
+	RTP_NACK_TIMER_TICK = 10 * time.Millisecond
+	// packets newer than a missing one that may arrive before it is
+	// requested
+	RTP_NACK_DEFAULT_REORDER_TOLERANCE = 0
+	// interval between requests of a packet until the rtt is known
+	RTP_NACK_DEFAULT_RTT         = 100 * time.Millisecond
+	RTP_NACK_DEFAULT_MAX_RETRIES = 10
+	RTP_NACK_DEFAULT_MAX_AGE     = time.Second
+	RTP_NACK_DEFAULT_MAX_LIST    = 1000
+)
+
+type NackGeneratorStats struct {
+	// packets in the missing list
+	Missing int
+	// sequence numbers sent in NACKs, retries included
+	Requested uint64
+	Recovered uint64
+	// packets given up after MaxRetries or MaxAge, or dropped with the
+	// missing list when it grew beyond MaxList
+	Abandoned        uint64
+	KeyFrameRequests uint64
+}
+
+type nackEntry struct {
+	detected time.Time
+	sent     time.Time
+	retries  int
+}
+
+// NackGenerator watches the sequence numbers of one source and requests the
+// missing packets with generic NACKs of RFC4585 section 6.2.1. A packet is
+// requested again every rtt until it arrives, MaxRetries requests went
+// unanswered or it is missing for MaxAge. The packets given up are signalled
+// with a PLI, the others are still requested. A loss too large for MaxList
+// drops the whole missing list and asks for a key frame as well.
+//
+// The generator has no clock of its own, OnTimer is called with the current
+// time, usually from a timewheel with AttachTimeWheel. Calls must not run
+// concurrently.
+type NackGenerator struct {
+	SenderSsrc       uint32
+	MediaSsrc        uint32
+	ReorderTolerance int
+	MaxRetries       int
+	MaxAge           time.Duration
+	// most packets in the missing list, a larger loss asks for a key frame
+	MaxList int
+	// SendNack transmits a generic NACK
+	SendNack func(nack *rtcp.RtcpNack)
+	// RequestKeyFrame transmits a PLI
+	RequestKeyFrame func(pli *rtcp.RtcpPli)
+
+	seqs    SequenceUnwrapper
+	missing map[int64]*nackEntry
+	rtt     time.Duration
+	stats   NackGeneratorStats
+}
+
+func NewNackGenerator(senderSsrc, mediaSsrc uint32, sendNack func(nack *rtcp.RtcpNack), requestKeyFrame func(pli *rtcp.RtcpPli)) *NackGenerator {
+	return &NackGenerator{
+		SenderSsrc:       senderSsrc,
+		MediaSsrc:        mediaSsrc,
+		ReorderTolerance: RTP_NACK_DEFAULT_REORDER_TOLERANCE,
+		MaxRetries:       RTP_NACK_DEFAULT_MAX_RETRIES,
+		MaxAge:           RTP_NACK_DEFAULT_MAX_AGE,
+		MaxList:          RTP_NACK_DEFAULT_MAX_LIST,
+		SendNack:         sendNack,
+		RequestKeyFrame:  requestKeyFrame,
+		missing:          make(map[int64]*nackEntry),
+		rtt:              RTP_NACK_DEFAULT_RTT,
+	}
+}
+
+// AttachTimeWheel sends the retries from tw. They go out at most one tick
+// after they are due, so the tick should be well below the rtt.
+func (this *NackGenerator) AttachTimeWheel(tw *timewheel.TimeWheel, tick time.Duration, clock func() time.Time) bool {
+	return attachTimeWheel(tw, tick, clock, this)
+}
+
+// SetRtt sets the round trip time pacing the requests of a packet.
+func (this *NackGenerator) SetRtt(rtt time.Duration) {
+	this.rtt = rtt
+}
+
+func (this *NackGenerator) GetRtt() time.Duration {
+	return this.rtt
+}
+
+// OnRtpPacket records a received packet and requests the packets found
+// missing at once.
+func (this *NackGenerator) OnRtpPacket(packet *RtpPacket, now time.Time) {
+	highest, ok := this.seqs.GetHighest()
+	seq := this.seqs.Unwrap(packet.GetSequence())
+	if !ok {
+		return
+	}
+
+	if seq <= highest {
+		if _, ok := this.missing[seq]; ok {
+			delete(this.missing, seq)
+			this.stats.Recovered++
+		}
+		return
+	}
+
+	if seq-highest-1 > int64(this.MaxList) {
+		this.dropMissing()
+		return
+	}
+	for i := highest + 1; i < seq; i++ {
+		this.missing[i] = &nackEntry{detected: now}
+	}
+	if len(this.missing) > this.MaxList {
+		this.dropMissing()
+		return
+	}
+	this.process(now)
+}
+
+// OnKeyFrame drops the packets missing before a key frame starting at seq,
+// they are not needed any more.
+func (this *NackGenerator) OnKeyFrame(seq uint16) {
+	extended := this.seqs.Peek(seq)
+	for k := range this.missing {
+		if k < extended {
+			delete(this.missing, k)
+		}
+	}
+}
+
+// OnTimer sends the requests due at now.
+func (this *NackGenerator) OnTimer(now time.Time) {
+	this.process(now)
+}
+
+func (this *NackGenerator) sortedMissing() []int64 {
+	seqs := make([]int64, 0, len(this.missing))
+	for k := range this.missing {
+		seqs = append(seqs, k)
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+	return seqs
+}
+
+func (this *NackGenerator) process(now time.Time) {
+	highest, _ := this.seqs.GetHighest()
+
+	var lost []uint16
+	abandoned := 0
+	for _, seq := range this.sortedMissing() {
+		entry := this.missing[seq]
+		if highest-seq <= int64(this.ReorderTolerance) {
+			break
+		}
+		due := entry.retries == 0 || now.Sub(entry.sent) >= this.rtt
+		if now.Sub(entry.detected) >= this.MaxAge || (due && entry.retries >= this.MaxRetries) {
+			delete(this.missing, seq)
+			abandoned++
+			continue
+		}
+		if !due {
+			continue
+		}
+		entry.retries++
+		entry.sent = now
+		lost = append(lost, uint16(seq))
+	}
+
+	if len(lost) > 0 {
+		this.stats.Requested += uint64(len(lost))
+		if this.SendNack != nil {
+			this.SendNack(&rtcp.RtcpNack{
+				SenderSsrc: this.SenderSsrc,
+				MediaSsrc:  this.MediaSsrc,
+				Nacks:      rtcp.NewRtcpNackPairs(lost),
+			})
+		}
+	}
+	if abandoned > 0 {
+		this.stats.Abandoned += uint64(abandoned)
+		this.requestKeyFrame()
+	}
+}
+
+// dropMissing gives up all the missing packets, the key frame replaces them.
+func (this *NackGenerator) dropMissing() {
+	this.stats.Abandoned += uint64(len(this.missing))
+	this.missing = make(map[int64]*nackEntry)
+	this.requestKeyFrame()
+}
+
+func (this *NackGenerator) requestKeyFrame() {
+	this.stats.KeyFrameRequests++
+	if this.RequestKeyFrame != nil {
+		this.RequestKeyFrame(&rtcp.RtcpPli{SenderSsrc: this.SenderSsrc, MediaSsrc: this.MediaSsrc})
+	}
+}
+
+// GetMissing returns the missing sequence numbers in order.
+func (this *NackGenerator) GetMissing() []uint16 {
+	var list []uint16
+	for _, v := range this.sortedMissing() {
+		list = append(list, uint16(v))
+	}
+	return list
+}
+
+func (this *NackGenerator) GetStats() NackGeneratorStats {
+	stats := this.stats
+	stats.Missing = len(this.missing)
+	return stats
+}
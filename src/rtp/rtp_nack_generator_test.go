@@ -0,0 +1,200 @@
+package rtp
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"rtcp"
+
+	"github.com/lioneagle/goutil/src/test"
+)
+
+type testNack struct {
+	at   time.Duration
+	seqs []uint16
+}
+
+type testNackGenerator struct {
+	*testTimeWheel
+	generator *NackGenerator
+	nacks     []testNack
+	plis      []time.Duration
+}
+
+func newTestNackGenerator() *testNackGenerator {
+	this := &testNackGenerator{testTimeWheel: newTestTimeWheel()}
+	this.generator = NewNackGenerator(1, 2, func(nack *rtcp.RtcpNack) {
+		this.nacks = append(this.nacks, testNack{this.elapsed(), nack.PacketList()})
+	}, func(pli *rtcp.RtcpPli) {
+		this.plis = append(this.plis, this.elapsed())
+	})
+	this.generator.AttachTimeWheel(this.tw, RTP_NACK_TIMER_TICK, this.clock)
+	return this
+}
+
+func (this *testNackGenerator) receive(seqs ...uint16) {
+	for _, v := range seqs {
+		this.generator.OnRtpPacket(newTestRtpPacket(96, v, 0), this.now)
+	}
+}
+
+func (this *testNackGenerator) runUntil(offset time.Duration) {
+	this.testTimeWheel.runUntil(offset, RTP_NACK_TIMER_TICK)
+}
+
+func TestNackGeneratorRequest(t *testing.T) {
+	g := newTestNackGenerator()
+
+	g.receive(1, 2, 5)
+	test.EXPECT_EQ(t, g.nacks, []testNack{{0, []uint16{3, 4}}}, "")
+	test.EXPECT_EQ(t, g.generator.GetMissing(), []uint16{3, 4}, "")
+
+	// requested again after one rtt
+	g.runUntil(90 * time.Millisecond)
+	test.EXPECT_EQ(t, len(g.nacks), 1, "")
+	g.runUntil(100 * time.Millisecond)
+	test.EXPECT_EQ(t, g.nacks[1], testNack{100 * time.Millisecond, []uint16{3, 4}}, "")
+
+	g.receive(3)
+	g.generator.SetRtt(50 * time.Millisecond)
+	g.runUntil(150 * time.Millisecond)
+	test.EXPECT_EQ(t, g.nacks[2], testNack{150 * time.Millisecond, []uint16{4}}, "")
+
+	stats := g.generator.GetStats()
+	test.EXPECT_EQ(t, stats.Missing, 1, "")
+	test.EXPECT_EQ(t, stats.Requested, uint64(5), "")
+	test.EXPECT_EQ(t, stats.Recovered, uint64(1), "")
+	test.EXPECT_EQ(t, len(g.plis), 0, "")
+}
+
+func TestNackGeneratorReorderTolerance(t *testing.T) {
+	testdata := []struct {
+		tolerance int
+		seqs      []uint16
+		nacks     [][]uint16
+		missing   []uint16
+	}{
+		{0, []uint16{1, 3, 2}, [][]uint16{{2}}, nil},
+		{2, []uint16{1, 3, 2, 4}, nil, nil},
+		{2, []uint16{1, 3, 4}, nil, []uint16{2}},
+		{2, []uint16{1, 3, 4, 5}, [][]uint16{{2}}, []uint16{2}},
+		{2, []uint16{1, 4, 5, 6, 7}, [][]uint16{{2}, {3}}, []uint16{2, 3}},
+		// wrap around
+		{0, []uint16{65534, 1}, [][]uint16{{65535, 0}}, []uint16{65535, 0}},
+	}
+
+	for i, v := range testdata {
+		v := v
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			t.Parallel()
+
+			g := newTestNackGenerator()
+			g.generator.ReorderTolerance = v.tolerance
+			g.receive(v.seqs...)
+
+			var nacks [][]uint16
+			for _, nack := range g.nacks {
+				nacks = append(nacks, nack.seqs)
+			}
+			test.EXPECT_EQ(t, nacks, v.nacks, "")
+			test.EXPECT_EQ(t, g.generator.GetMissing(), v.missing, "")
+		})
+	}
+}
+
+func TestNackGeneratorGiveUp(t *testing.T) {
+	testdata := []struct {
+		maxRetries int
+		maxAge     time.Duration
+		nacks      []time.Duration
+		pli        time.Duration
+	}{
+		{2, time.Second, []time.Duration{0, 20 * time.Millisecond}, 40 * time.Millisecond},
+		{100, 50 * time.Millisecond, []time.Duration{0, 20 * time.Millisecond, 40 * time.Millisecond}, 50 * time.Millisecond},
+	}
+
+	for i, v := range testdata {
+		v := v
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			t.Parallel()
+
+			g := newTestNackGenerator()
+			g.generator.MaxRetries = v.maxRetries
+			g.generator.MaxAge = v.maxAge
+			g.generator.SetRtt(20 * time.Millisecond)
+
+			g.receive(1, 3)
+			g.runUntil(200 * time.Millisecond)
+
+			var nacks []time.Duration
+			for _, nack := range g.nacks {
+				nacks = append(nacks, nack.at)
+			}
+			test.EXPECT_EQ(t, nacks, v.nacks, "")
+			test.EXPECT_EQ(t, g.plis, []time.Duration{v.pli}, "")
+
+			stats := g.generator.GetStats()
+			test.EXPECT_EQ(t, stats.Missing, 0, "")
+			test.EXPECT_EQ(t, stats.Abandoned, uint64(1), "")
+			test.EXPECT_EQ(t, stats.KeyFrameRequests, uint64(1), "")
+		})
+	}
+}
+
+func TestNackGeneratorPartialGiveUp(t *testing.T) {
+	g := newTestNackGenerator()
+	g.generator.MaxRetries = 100
+	g.generator.MaxAge = 50 * time.Millisecond
+	g.generator.SetRtt(20 * time.Millisecond)
+
+	g.receive(1, 4)
+	g.runUntil(30 * time.Millisecond)
+	g.receive(6)
+	g.runUntil(200 * time.Millisecond)
+
+	// 2 and 3 expire together, 5 is still requested
+	ms := time.Millisecond
+	test.EXPECT_EQ(t, g.nacks, []testNack{
+		{0, []uint16{2, 3}},
+		{20 * ms, []uint16{2, 3}},
+		{30 * ms, []uint16{5}},
+		{40 * ms, []uint16{2, 3}},
+		{50 * ms, []uint16{5}},
+		{70 * ms, []uint16{5}},
+	}, "")
+	test.EXPECT_EQ(t, g.plis, []time.Duration{50 * ms, 80 * ms}, "")
+
+	stats := g.generator.GetStats()
+	test.EXPECT_EQ(t, stats.Missing, 0, "")
+	test.EXPECT_EQ(t, stats.Abandoned, uint64(3), "")
+	test.EXPECT_EQ(t, stats.KeyFrameRequests, uint64(2), "")
+}
+
+func TestNackGeneratorMaxList(t *testing.T) {
+	g := newTestNackGenerator()
+	g.generator.MaxList = 10
+
+	g.receive(1, 20)
+	test.EXPECT_EQ(t, len(g.nacks), 0, "")
+	test.EXPECT_EQ(t, len(g.plis), 1, "")
+
+	g.receive(25, 32)
+	test.EXPECT_EQ(t, len(g.nacks), 2, "")
+	test.EXPECT_EQ(t, g.generator.GetStats().Missing, 10, "")
+	g.receive(34)
+	test.EXPECT_EQ(t, len(g.plis), 2, "")
+	test.EXPECT_EQ(t, len(g.generator.GetMissing()), 0, "")
+	test.EXPECT_EQ(t, g.generator.GetStats().Abandoned, uint64(11), "")
+}
+
+func TestNackGeneratorOnKeyFrame(t *testing.T) {
+	g := newTestNackGenerator()
+
+	g.receive(1, 5)
+	g.generator.OnKeyFrame(4)
+	test.EXPECT_EQ(t, g.generator.GetMissing(), []uint16{4}, "")
+
+	g.runUntil(100 * time.Millisecond)
+	test.EXPECT_EQ(t, g.nacks[len(g.nacks)-1], testNack{100 * time.Millisecond, []uint16{4}}, "")
+}
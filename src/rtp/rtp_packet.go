@@ -2,6 +2,7 @@ package rtp
 
 import (
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	//"os"
@@ -18,6 +19,8 @@ const (
 	RTP_PAYLOAD_TYPE_MARSK = 0x7F
 
 	RTP_HEADER_LEN = 12
+
+	RTP_VERSION = 2
 )
 
 const (
@@ -28,6 +31,14 @@ const (
 	RTP_SSRC_OFFSET         = 8
 )
 
+var (
+	ErrTooShort     = errors.New("rtp: packet too short")
+	ErrBadVersion   = errors.New("rtp: bad version")
+	ErrBadCsrcCount = errors.New("rtp: csrc list exceeds packet length")
+	ErrBadExtension = errors.New("rtp: header extension exceeds packet length")
+	ErrBadPadding   = errors.New("rtp: bad padding length")
+)
+
 type RtpPacket struct {
 	data []byte
 }
@@ -54,26 +65,72 @@ func (this *RtpPacket) CalcHeaderLen(csrcNum, extensionNum int) int {
 		length += csrcNum * 4
 	}
 	if extensionNum > 0 {
-		length += 2 + extensionNum*4
+		length += 4 + extensionNum*4
 	}
 	return length
 }
 
+// HeaderLen returns the length of the fixed header, csrc list and header
+// extension. The extension is present with the X bit set, even when its
+// length is 0.
 func (this *RtpPacket) HeaderLen() int {
 	csrcNum := int(this.GetCsrcCount())
-	extensionNum := 0
+	length := this.CalcHeaderLen(csrcNum, 0)
 	if this.GetExtensionBit() == 1 {
-		extensionNum = this.GetExtensionNum(csrcNum)
+		length += 4 + this.GetExtensionNum(csrcNum)*4
 	}
-	return this.CalcHeaderLen(csrcNum, extensionNum)
+	return length
 }
 
+// PayloadLen returns the length of the payload without padding.
 func (this *RtpPacket) PayloadLen() int {
-	return len(this.data) - this.HeaderLen()
+	return len(this.data) - this.HeaderLen() - this.PaddingLen()
 }
 
+// GetPayload returns the payload without padding.
 func (this *RtpPacket) GetPayload() []byte {
-	return this.data[this.HeaderLen():]
+	return this.data[this.HeaderLen() : len(this.data)-this.PaddingLen()]
+}
+
+// PaddingLen returns the number of padding octets at the end of the packet,
+// including the trailing count octet, or 0 when the P bit is clear.
+func (this *RtpPacket) PaddingLen() int {
+	if this.GetPadding() == 0 {
+		return 0
+	}
+
+	avail := len(this.data) - this.HeaderLen()
+	if avail <= 0 {
+		return 0
+	}
+
+	pad := int(this.data[len(this.data)-1])
+	if pad > avail {
+		return avail
+	}
+	return pad
+}
+
+// SetPaddingLen replaces any existing padding with n octets of padding as
+// described in RFC 3550 section 5.1, the last of which holds the count n.
+// Passing 0 removes the padding. It returns false when n is not in [0, 255].
+func (this *RtpPacket) SetPaddingLen(n int) bool {
+	if n < 0 || n > 255 {
+		return false
+	}
+
+	this.data = this.data[:len(this.data)-this.PaddingLen()]
+	if n == 0 {
+		this.ClearPadding()
+		return true
+	}
+
+	for i := 0; i < n-1; i++ {
+		this.data = append(this.data, 0)
+	}
+	this.data = append(this.data, byte(n))
+	this.SetPadding()
+	return true
 }
 
 func (this *RtpPacket) GetVersion() byte {
@@ -89,6 +146,7 @@ func (this *RtpPacket) GetPadding() byte {
 	return (this.data[0] & RTP_PADDING_MARSK) >> 5
 }
 
+// SetPadding only sets the P bit, use SetPaddingLen to add padding octets.
 func (this *RtpPacket) SetPadding() {
 	this.data[0] |= RTP_PADDING_MARSK
 }
@@ -241,11 +299,73 @@ func (this *RtpPacket) SetExtension(profile uint16, val []byte) bool {
 	return true
 }
 
+// Parse validates data as an RTP packet and copies it into this packet. After
+// a successful Parse all accessors are safe to call.
+func (this *RtpPacket) Parse(data []byte) error {
+	this.CopyFromBytes(data)
+	err := this.Validate()
+	if err != nil {
+		this.Reset()
+	}
+	return err
+}
+
+// Validate checks the fixed header, csrc list, header extension and padding
+// of the packet against its length.
+func (this *RtpPacket) Validate() error {
+	size := len(this.data)
+	if size < RTP_HEADER_LEN {
+		return ErrTooShort
+	}
+
+	if this.GetVersion() != RTP_VERSION {
+		return ErrBadVersion
+	}
+
+	offset := RTP_HEADER_LEN + int(this.GetCsrcCount())*4
+	if offset > size {
+		return ErrBadCsrcCount
+	}
+
+	if this.GetExtensionBit() == 1 {
+		if offset+4 > size {
+			return ErrBadExtension
+		}
+		offset += 4 + int(binary.BigEndian.Uint16(this.data[offset+2:]))*4
+		if offset > size {
+			return ErrBadExtension
+		}
+	}
+
+	if this.GetPadding() == 1 {
+		if offset >= size {
+			return ErrBadPadding
+		}
+		// the last octet counts itself, so zero is never valid
+		pad := int(this.data[size-1])
+		if pad == 0 || offset+pad > size {
+			return ErrBadPadding
+		}
+	}
+
+	return nil
+}
+
 func (this *RtpPacket) CopyFromBytes(data []byte) {
 	this.Reset()
 	this.data = append(this.data, data...)
 }
 
+// Bytes returns the packet as sent on the wire, without copying.
+func (this *RtpPacket) Bytes() []byte {
+	return this.data
+}
+
+// SetBytes makes data the packet, without copying or validating it.
+func (this *RtpPacket) SetBytes(data []byte) {
+	this.data = data
+}
+
 func (this *RtpPacket) CopyToBytes(data []byte) {
 	data = append(data, this.data...)
 }
@@ -256,12 +376,18 @@ func (this *RtpPacket) CopyToByteBuffer(buf *buffer.ByteBuffer) {
 }
 
 func (this *RtpPacket) Print(w io.Writer) {
+	this.PrintWithPayloads(w, nil)
+}
+
+// PrintWithPayloads prints the packet with the payload type names of
+// payloads, nil for the static ones only.
+func (this *RtpPacket) PrintWithPayloads(w io.Writer, payloads *PayloadRegistry) {
 	fmt.Fprintf(w, "%02b.. .... = version: %d\n", this.GetVersion(), this.GetVersion())
 	fmt.Fprintf(w, "..%01b. .... = Padding: %v\n", this.GetPadding(), this.GetPadding() == 1)
 	fmt.Fprintf(w, "...%01b .... = Extension: %v\n", this.GetExtensionBit(), this.GetExtensionBit() == 1)
 	fmt.Fprintf(w, ".... %04b = CSRC count: %v\n", this.GetCsrcCount(), this.GetCsrcCount())
 	fmt.Fprintf(w, ".%01b.. .... = Marker: %v\n", this.GetMarker(), this.GetMarker() == 1)
-	fmt.Fprintf(w, "Payload type: %s (%v)\n", GetStaticPayloadTypeName(this.GetPayloadType()), this.GetPayloadType())
+	fmt.Fprintf(w, "Payload type: %s (%v)\n", payloads.GetName(this.GetPayloadType()), this.GetPayloadType())
 	fmt.Fprintf(w, "Sequence number: %v\n", this.GetSequence())
 	fmt.Fprintf(w, "Timestamp: %v\n", this.GetTimestamp())
 	fmt.Fprintf(w, "SSRC: 0x%08x (%d)\n", this.GetSsrc(), this.GetSsrc())
@@ -278,10 +404,28 @@ func (this *RtpPacket) Print(w io.Writer) {
 		fmt.Fprintf(w, "Extension:\n")
 		fmt.Fprintf(w, "profile:0x%04x (%d)\n", profile, profile)
 		buffer.PrintAsHex(w, extension, 0, len(extension))
+		if IsGeneralExtensionProfile(profile) {
+			elements, err := ParseRtpExtensionElements(profile, extension)
+			if err != nil {
+				fmt.Fprintf(w, "Extension elements: %v\n", err)
+			}
+			for _, v := range elements {
+				fmt.Fprintf(w, "ID: %d, length: %d\n", v.Id, len(v.Data))
+				buffer.PrintAsHex(w, v.Data, 0, len(v.Data))
+			}
+		}
 	}
 	payload := this.GetPayload()
 	if len(payload) > 0 {
 		fmt.Fprintf(w, "Payload:\n")
 		buffer.PrintAsHex(w, payload, 0, len(payload))
 	}
+	if this.GetPadding() == 1 {
+		padding := this.data[len(this.data)-this.PaddingLen():]
+		if len(padding) > 1 {
+			fmt.Fprintf(w, "Padding data:\n")
+			buffer.PrintAsHex(w, padding, 0, len(padding)-1)
+		}
+		fmt.Fprintf(w, "Padding count: %d\n", this.data[len(this.data)-1])
+	}
 }
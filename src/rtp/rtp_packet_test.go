@@ -143,13 +143,130 @@ Extension:
 profile:0x11d7 (4567)
 00000000h: 01 02 03 04 05 06 07 00                          ; ........
 Payload:
-00000000h: 07 00 00 00 00 00 00 00  00 00 00 00 00 00 00 00 ; ................
+00000000h: 00 00 00 00 00 00 00 00  00 00 00 00 00 00 00 00 ; ................
 00000010h: 00 00 00 00                                      ; ....
+Padding count: 0
 `
 	test.EXPECT_EQ(t, buf.String(), wanted, "")
 
 }
 
+func TestRtpPacketParse(t *testing.T) {
+	header := []byte{0x80, 0x08, 0x00, 0x01, 0x00, 0x00, 0x00, 0xa0, 0x00, 0x00, 0x00, 0x0a}
+
+	join := func(parts ...[]byte) (ret []byte) {
+		for _, v := range parts {
+			ret = append(ret, v...)
+		}
+		return ret
+	}
+
+	testdata := []struct {
+		data    []byte
+		err     error
+		payload []byte
+	}{
+		{nil, ErrTooShort, nil},
+		{header[:11], ErrTooShort, nil},
+		{header, nil, []byte{}},
+		{join(header, []byte{1, 2, 3}), nil, []byte{1, 2, 3}},
+		{join([]byte{0x40}, header[1:]), ErrBadVersion, nil},
+		{join([]byte{0xc0}, header[1:]), ErrBadVersion, nil},
+		{join([]byte{0x81}, header[1:]), ErrBadCsrcCount, nil},
+		{join([]byte{0x81}, header[1:], []byte{0, 0, 0, 1}), nil, []byte{}},
+		{join([]byte{0x8f}, header[1:], make([]byte, 56)), ErrBadCsrcCount, nil},
+		{join([]byte{0x90}, header[1:]), ErrBadExtension, nil},
+		{join([]byte{0x90}, header[1:], []byte{0xbe, 0xde, 0x00}), ErrBadExtension, nil},
+		{join([]byte{0x90}, header[1:], []byte{0xbe, 0xde, 0x00, 0x00}), nil, []byte{}},
+		{join([]byte{0x90}, header[1:], []byte{0xbe, 0xde, 0x00, 0x00, 7, 8}), nil, []byte{7, 8}},
+		{join([]byte{0x90}, header[1:], []byte{0xbe, 0xde, 0x00, 0x01, 0x10, 0xff, 0x00}), ErrBadExtension, nil},
+		{join([]byte{0x90}, header[1:], []byte{0xbe, 0xde, 0x00, 0x01, 0x10, 0xff, 0x00, 0x00}), nil, []byte{}},
+		{join([]byte{0x91}, header[1:], []byte{0xbe, 0xde, 0x00, 0x00}), ErrBadExtension, nil},
+		{join([]byte{0xa0}, header[1:]), ErrBadPadding, nil},
+		{join([]byte{0xa0}, header[1:], []byte{1, 2, 0}), ErrBadPadding, nil},
+		{join([]byte{0xa0}, header[1:], []byte{1, 2, 4}), ErrBadPadding, nil},
+		{join([]byte{0xa0}, header[1:], []byte{1, 2, 3}), nil, []byte{}},
+		{join([]byte{0xa0}, header[1:], []byte{1}), nil, []byte{}},
+	}
+
+	for i, v := range testdata {
+		v := v
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			t.Parallel()
+
+			rtp := NewRtpPacket()
+			err := rtp.Parse(v.data)
+			if err != v.err {
+				t.Fatalf("err = %v, wanted = %v", err, v.err)
+			}
+			if err != nil {
+				return
+			}
+
+			// accessors must not panic on a parsed packet
+			show := buffer.NewByteBuffer(nil)
+			rtp.Print(show)
+			test.EXPECT_EQ(t, rtp.GetPayload(), v.payload, "")
+			test.EXPECT_EQ(t, rtp.PayloadLen(), len(v.payload), "")
+		})
+	}
+}
+
+func TestRtpPacketPadding(t *testing.T) {
+	data := []byte{0x80, 0x08, 0x00, 0x01, 0x00, 0x00, 0x00, 0xa0, 0x00, 0x00, 0x00, 0x0a, 1, 2, 3}
+
+	testdata := []struct {
+		padding    int
+		ok         bool
+		paddingBit byte
+		length     int
+	}{
+		{4, true, 1, 19},
+		{1, true, 1, 16},
+		{255, true, 1, 270},
+		{256, false, 1, 270},
+		{-1, false, 1, 270},
+		{0, true, 0, 15},
+	}
+
+	rtp := NewRtpPacket()
+	err := rtp.Parse(data)
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+
+	for i, v := range testdata {
+		ok := rtp.SetPaddingLen(v.padding)
+		test.EXPECT_EQ(t, ok, v.ok, "[%d]", i)
+		test.EXPECT_EQ(t, rtp.GetPadding(), v.paddingBit, "[%d]", i)
+		test.EXPECT_EQ(t, len(rtp.data), v.length, "[%d]", i)
+		test.EXPECT_EQ(t, rtp.GetPayload(), []byte{1, 2, 3}, "[%d]", i)
+		test.EXPECT_EQ(t, rtp.PayloadLen(), 3, "[%d]", i)
+		test.EXPECT_EQ(t, rtp.Validate(), nil, "[%d]", i)
+	}
+
+	rtp.SetPaddingLen(3)
+	buf := buffer.NewByteBuffer(nil)
+	rtp.Print(buf)
+
+	wanted := `10.. .... = version: 2
+..1. .... = Padding: true
+...0 .... = Extension: false
+.... 0000 = CSRC count: 0
+.0.. .... = Marker: false
+Payload type: PCMA (8)
+Sequence number: 1
+Timestamp: 160
+SSRC: 0x0000000a (10)
+Payload:
+00000000h: 01 02 03                                         ; ...
+Padding data:
+00000000h: 00 00                                            ; ..
+Padding count: 3
+`
+	test.EXPECT_EQ(t, buf.String(), wanted, "")
+}
+
 /*
 func Test2(t *testing.T) {
 	type result struct {
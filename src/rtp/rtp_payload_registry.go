@@ -0,0 +1,195 @@
+package rtp
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	RTP_MAX_PAYLOAD_TYPE         = 127
+	RTP_DYNAMIC_PAYLOAD_TYPE_MIN = 96
+	RTP_DYNAMIC_PAYLOAD_TYPE_MAX = 127
+)
+
+var (
+	ErrBadPayloadType           = errors.New("rtp: payload type out of range")
+	ErrDepacketizerRegistered   = errors.New("rtp: depacketizer already registered")
+	ErrNoDepacketizer           = errors.New("rtp: no depacketizer for payload type")
+	ErrPayloadTypeNotRegistered = errors.New("rtp: payload type not registered")
+)
+
+// RtpDepacketizer turns the payloads of one codec back into media frames.
+type RtpDepacketizer interface {
+	// Depacketize returns the media carried by payload, nil while a frame
+	// is still incomplete.
+	Depacketize(payload []byte, marker bool) ([]byte, error)
+}
+
+var rtpDepacketizers = struct {
+	sync.RWMutex
+	factories map[string]func(profile *RtpProfile) RtpDepacketizer
+}{factories: make(map[string]func(profile *RtpProfile) RtpDepacketizer)}
+
+// RegisterRtpDepacketizer makes a depacketizer selectable by codec name,
+// names are matched case insensitively.
+func RegisterRtpDepacketizer(name string, factory func(profile *RtpProfile) RtpDepacketizer) error {
+	name = strings.ToUpper(name)
+
+	rtpDepacketizers.Lock()
+	defer rtpDepacketizers.Unlock()
+
+	if _, ok := rtpDepacketizers.factories[name]; ok {
+		return ErrDepacketizerRegistered
+	}
+	rtpDepacketizers.factories[name] = factory
+	return nil
+}
+
+// UnregisterRtpDepacketizer removes the depacketizer of a codec name.
+func UnregisterRtpDepacketizer(name string) {
+	rtpDepacketizers.Lock()
+	defer rtpDepacketizers.Unlock()
+	delete(rtpDepacketizers.factories, strings.ToUpper(name))
+}
+
+// PayloadRegistry maps the payload types of one session to their codecs,
+// as carried by a=rtpmap and a=fmtp. Payload types not registered fall back
+// to StaticRtpProfiles. A nil registry holds the static table only.
+type PayloadRegistry struct {
+	mutex    sync.RWMutex
+	profiles map[byte]*RtpProfile
+}
+
+func NewPayloadRegistry() *PayloadRegistry {
+	return &PayloadRegistry{profiles: make(map[byte]*RtpProfile)}
+}
+
+// Register adds a dynamic payload type or overrides a static one.
+func (this *PayloadRegistry) Register(profile RtpProfile) error {
+	if profile.PayloadType > RTP_MAX_PAYLOAD_TYPE {
+		return ErrBadPayloadType
+	}
+	profile.Used = true
+
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.profiles[profile.PayloadType] = &profile
+	return nil
+}
+
+// Replace makes the registry hold the payload types of other, as after a
+// renegotiation. Receiver statistics sharing the registry see the change.
+func (this *PayloadRegistry) Replace(other *PayloadRegistry) {
+	profiles := make(map[byte]*RtpProfile)
+	if other != nil {
+		other.mutex.RLock()
+		for k, v := range other.profiles {
+			profiles[k] = v
+		}
+		other.mutex.RUnlock()
+	}
+
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.profiles = profiles
+}
+
+func (this *PayloadRegistry) Unregister(payloadType byte) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	delete(this.profiles, payloadType)
+}
+
+// Lookup returns the profile of payloadType, or nil when neither the
+// registry nor the static table knows it. The profile must not be modified.
+func (this *PayloadRegistry) Lookup(payloadType byte) *RtpProfile {
+	if this != nil {
+		this.mutex.RLock()
+		profile, ok := this.profiles[payloadType]
+		this.mutex.RUnlock()
+		if ok {
+			return profile
+		}
+	}
+
+	if int(payloadType) < len(StaticRtpProfiles) && StaticRtpProfiles[payloadType].Used {
+		return &StaticRtpProfiles[payloadType]
+	}
+	return nil
+}
+
+// GetName returns the codec name, falling back to GetStaticPayloadTypeName.
+func (this *PayloadRegistry) GetName(payloadType byte) string {
+	profile := this.Lookup(payloadType)
+	if profile == nil {
+		return GetStaticPayloadTypeName(payloadType)
+	}
+	return profile.Name
+}
+
+// GetClockRate returns the clock rate of payloadType, 0 when unknown.
+func (this *PayloadRegistry) GetClockRate(payloadType byte) uint32 {
+	profile := this.Lookup(payloadType)
+	if profile == nil || !profile.HasClockRate {
+		return 0
+	}
+	return profile.ClockRate
+}
+
+// FindPayloadType returns the lowest payload type of codec name with
+// clockRate, 0 matching any clock rate. Registered payload types are
+// preferred to static ones.
+func (this *PayloadRegistry) FindPayloadType(name string, clockRate uint32) (byte, bool) {
+	match := func(profile *RtpProfile) bool {
+		return strings.EqualFold(profile.Name, name) && (clockRate == 0 || profile.ClockRate == clockRate)
+	}
+
+	for _, v := range this.GetPayloadTypes() {
+		if match(this.Lookup(v)) {
+			return v, true
+		}
+	}
+	for i := range StaticRtpProfiles {
+		if StaticRtpProfiles[i].Used && match(&StaticRtpProfiles[i]) {
+			return byte(i), true
+		}
+	}
+	return 0, false
+}
+
+// GetPayloadTypes returns the registered payload types in ascending order.
+func (this *PayloadRegistry) GetPayloadTypes() []byte {
+	if this == nil {
+		return nil
+	}
+
+	this.mutex.RLock()
+	defer this.mutex.RUnlock()
+
+	types := make([]byte, 0, len(this.profiles))
+	for k := range this.profiles {
+		types = append(types, k)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	return types
+}
+
+// NewDepacketizer selects the depacketizer registered for the codec of
+// payloadType.
+func (this *PayloadRegistry) NewDepacketizer(payloadType byte) (RtpDepacketizer, error) {
+	profile := this.Lookup(payloadType)
+	if profile == nil {
+		return nil, ErrPayloadTypeNotRegistered
+	}
+
+	rtpDepacketizers.RLock()
+	factory, ok := rtpDepacketizers.factories[strings.ToUpper(profile.Name)]
+	rtpDepacketizers.RUnlock()
+
+	if !ok {
+		return nil, ErrNoDepacketizer
+	}
+	return factory(profile), nil
+}
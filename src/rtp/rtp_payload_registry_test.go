@@ -0,0 +1,177 @@
+package rtp
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/lioneagle/goutil/src/test"
+)
+
+type testDepacketizer struct {
+	profile *RtpProfile
+}
+
+func (this *testDepacketizer) Depacketize(payload []byte, marker bool) ([]byte, error) {
+	return payload, nil
+}
+
+func newTestPayloadRegistry() *PayloadRegistry {
+	registry := NewPayloadRegistry()
+	registry.Register(RtpProfile{PayloadType: 96, Name: "opus", MediaType: "A", HasClockRate: true, ClockRate: 48000, HasChannels: true, Channels: 2, Fmtp: "minptime=10; useinbandfec=1"})
+	registry.Register(RtpProfile{PayloadType: 101, Name: "telephone-event", MediaType: "A", HasClockRate: true, ClockRate: 8000, Fmtp: "0-16"})
+	registry.Register(RtpProfile{PayloadType: 8, Name: "PCMA", MediaType: "A", HasClockRate: true, ClockRate: 16000})
+	return registry
+}
+
+func TestGetStaticPayloadTypeName(t *testing.T) {
+	testdata := []struct {
+		payloadType byte
+		name        string
+	}{
+		{0, "PCMU"},
+		{1, "unknown"},
+		{34, "H263"},
+		{94, "unknown"},
+		{95, "dynamic"},
+		{127, "dynamic"},
+	}
+
+	for i, v := range testdata {
+		test.EXPECT_EQ(t, GetStaticPayloadTypeName(v.payloadType), v.name, "[%d]", i)
+	}
+}
+
+func TestPayloadRegistryLookup(t *testing.T) {
+	registry := newTestPayloadRegistry()
+
+	testdata := []struct {
+		payloadType byte
+		name        string
+		clockRate   uint32
+	}{
+		{0, "PCMU", 8000},
+		{8, "PCMA", 16000},
+		{9, "G722", 8000},
+		{2, "unknown", 0},
+		{96, "opus", 48000},
+		{101, "telephone-event", 8000},
+		{110, "dynamic", 0},
+	}
+
+	for i, v := range testdata {
+		v := v
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			t.Parallel()
+
+			test.EXPECT_EQ(t, registry.GetName(v.payloadType), v.name, "")
+			test.EXPECT_EQ(t, registry.GetClockRate(v.payloadType), v.clockRate, "")
+		})
+	}
+}
+
+func TestPayloadRegistryNil(t *testing.T) {
+	var registry *PayloadRegistry
+
+	test.EXPECT_EQ(t, registry.GetName(0), "PCMU", "")
+	test.EXPECT_EQ(t, registry.GetName(96), "dynamic", "")
+	test.EXPECT_EQ(t, registry.GetClockRate(34), uint32(90000), "")
+	test.EXPECT_EQ(t, len(registry.GetPayloadTypes()), 0, "")
+}
+
+func TestPayloadRegistryRegister(t *testing.T) {
+	registry := newTestPayloadRegistry()
+
+	test.EXPECT_EQ(t, registry.Register(RtpProfile{PayloadType: 128}), ErrBadPayloadType, "")
+	test.EXPECT_EQ(t, registry.GetPayloadTypes(), []byte{8, 96, 101}, "")
+
+	profile := registry.Lookup(96)
+	test.EXPECT_EQ(t, profile.Used, true, "")
+	test.EXPECT_EQ(t, profile.Channels, byte(2), "")
+	test.EXPECT_EQ(t, profile.FmtpParameters(), map[string]string{"minptime": "10", "useinbandfec": "1"}, "")
+	test.EXPECT_EQ(t, registry.Lookup(101).FmtpParameters(), map[string]string{"0-16": ""}, "")
+
+	registry.Unregister(8)
+	test.EXPECT_EQ(t, registry.GetClockRate(8), uint32(8000), "")
+	test.EXPECT_EQ(t, registry.GetPayloadTypes(), []byte{96, 101}, "")
+}
+
+func TestPayloadRegistryReplace(t *testing.T) {
+	registry := newTestPayloadRegistry()
+	stats := NewReceiverStats(1)
+	stats.Payloads = registry
+
+	other := NewPayloadRegistry()
+	other.Register(RtpProfile{PayloadType: 97, Name: "G7221", HasClockRate: true, ClockRate: 32000})
+	registry.Replace(other)
+
+	test.EXPECT_EQ(t, registry.GetPayloadTypes(), []byte{97}, "")
+	test.EXPECT_EQ(t, stats.GetClockRate(97), uint32(32000), "")
+	test.EXPECT_EQ(t, stats.GetClockRate(96), uint32(0), "")
+	test.EXPECT_EQ(t, stats.GetClockRate(8), uint32(8000), "")
+
+	registry.Replace(nil)
+	test.EXPECT_EQ(t, len(registry.GetPayloadTypes()), 0, "")
+}
+
+func TestPayloadRegistryFindPayloadType(t *testing.T) {
+	registry := newTestPayloadRegistry()
+
+	testdata := []struct {
+		name        string
+		clockRate   uint32
+		payloadType byte
+		ok          bool
+	}{
+		{"OPUS", 48000, 96, true},
+		{"opus", 0, 96, true},
+		{"opus", 8000, 0, false},
+		{"PCMA", 16000, 8, true},
+		{"DVI4", 16000, 6, true},
+		{"H264", 90000, 0, false},
+	}
+
+	for i, v := range testdata {
+		payloadType, ok := registry.FindPayloadType(v.name, v.clockRate)
+		test.EXPECT_EQ(t, ok, v.ok, "[%d]", i)
+		test.EXPECT_EQ(t, payloadType, v.payloadType, "[%d]", i)
+	}
+}
+
+func TestPayloadRegistryNewDepacketizer(t *testing.T) {
+	registry := newTestPayloadRegistry()
+	registry.Register(RtpProfile{PayloadType: 100, Name: "x-test-codec", HasClockRate: true, ClockRate: 90000})
+
+	err := RegisterRtpDepacketizer("X-TEST-CODEC", func(profile *RtpProfile) RtpDepacketizer {
+		return &testDepacketizer{profile: profile}
+	})
+	test.EXPECT_EQ(t, err, nil, "")
+	defer UnregisterRtpDepacketizer("x-test-codec")
+	test.EXPECT_EQ(t, RegisterRtpDepacketizer("x-test-codec", nil), ErrDepacketizerRegistered, "")
+
+	depacketizer, err := registry.NewDepacketizer(100)
+	test.EXPECT_EQ(t, err, nil, "")
+	test.EXPECT_EQ(t, depacketizer.(*testDepacketizer).profile.ClockRate, uint32(90000), "")
+
+	_, err = registry.NewDepacketizer(96)
+	test.EXPECT_EQ(t, err, ErrNoDepacketizer, "")
+
+	_, err = registry.NewDepacketizer(110)
+	test.EXPECT_EQ(t, err, ErrPayloadTypeNotRegistered, "")
+}
+
+func TestRtpPacketPrintWithPayloads(t *testing.T) {
+	packet := NewRtpPacket()
+	packet.Alloc(RTP_HEADER_LEN)
+	packet.SetVersion(RTP_VERSION)
+	packet.SetPayloadType(96)
+
+	w := &bytes.Buffer{}
+	packet.Print(w)
+	test.EXPECT_EQ(t, strings.Contains(w.String(), "Payload type: dynamic (96)"), true, "")
+
+	w.Reset()
+	packet.PrintWithPayloads(w, newTestPayloadRegistry())
+	test.EXPECT_EQ(t, strings.Contains(w.String(), "Payload type: opus (96)"), true, "")
+}
@@ -1,5 +1,9 @@
 package rtp
 
+import (
+	"strings"
+)
+
 type RtpProfile struct {
 	Used         bool
 	PayloadType  byte
@@ -9,6 +13,8 @@ type RtpProfile struct {
 	ClockRate    uint32
 	HasChannels  bool
 	Channels     byte
+	// format parameters of a=fmtp, "name=value" pairs separated by ';'
+	Fmtp string
 }
 
 // static rtp profiles from RFC3551
@@ -43,7 +49,7 @@ var StaticRtpProfiles = [95]RtpProfile{
 }
 
 func GetStaticPayloadTypeName(payloadType byte) string {
-	if payloadType > byte(len(StaticRtpProfiles)) {
+	if int(payloadType) >= len(StaticRtpProfiles) {
 		return "dynamic"
 	}
 	if !StaticRtpProfiles[payloadType].Used {
@@ -51,3 +57,21 @@ func GetStaticPayloadTypeName(payloadType byte) string {
 	}
 	return StaticRtpProfiles[payloadType].Name
 }
+
+// FmtpParameters splits Fmtp into its parameters, names in lower case.
+// Parameters without a value map to "".
+func (this *RtpProfile) FmtpParameters() map[string]string {
+	params := make(map[string]string)
+	for _, v := range strings.Split(this.Fmtp, ";") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		name, value := v, ""
+		if pos := strings.IndexByte(v, '='); pos >= 0 {
+			name, value = strings.TrimSpace(v[:pos]), strings.TrimSpace(v[pos+1:])
+		}
+		params[strings.ToLower(name)] = value
+	}
+	return params
+}
@@ -0,0 +1,234 @@
+package rtp
+
+import (
+	"time"
+
+	"rtcp"
+)
+
+// sequence number validation constants from RFC3550 appendix A.1
+const (
+	RTP_MIN_SEQUENTIAL = 2
+	RTP_MAX_DROPOUT    = 3000
+	RTP_MAX_MISORDER   = 100
+	RTP_SEQ_MOD        = 1 << 16
+)
+
+// ReceiverStats keeps the reception statistics of one source as in RFC3550
+// appendix A: sequence number validation (A.1), loss (A.3) and interarrival
+// jitter (A.8), and the timing of the last SR for LSR/DLSR.
+type ReceiverStats struct {
+	Ssrc uint32
+	// payload types of the session, nil for the static ones only
+	Payloads *PayloadRegistry
+
+	started       bool
+	maxSeq        uint16
+	cycles        uint32
+	baseSeq       uint32
+	badSeq        uint32
+	probation     int
+	received      uint32
+	expectedPrior uint32
+	receivedPrior uint32
+
+	payloadType  byte
+	clockRate    uint32
+	hasTransit   bool
+	transit      uint32
+	jitter       uint32
+	firstArrival time.Time
+
+	lastSr        uint32
+	lastSrArrival time.Time
+}
+
+func NewReceiverStats(ssrc uint32) *ReceiverStats {
+	return &ReceiverStats{Ssrc: ssrc}
+}
+
+func (this *ReceiverStats) initSeq(seq uint16) {
+	this.baseSeq = uint32(seq)
+	this.maxSeq = seq
+	this.badSeq = RTP_SEQ_MOD + 1
+	this.cycles = 0
+	this.received = 0
+	this.receivedPrior = 0
+	this.expectedPrior = 0
+}
+
+// updateSeq is update_seq of RFC3550 appendix A.1, it returns false for a
+// packet that is not valid.
+func (this *ReceiverStats) updateSeq(seq uint16) bool {
+	udelta := seq - this.maxSeq
+
+	if this.probation > 0 {
+		// packet is in sequence
+		if seq == this.maxSeq+1 {
+			this.probation--
+			this.maxSeq = seq
+			if this.probation == 0 {
+				this.initSeq(seq)
+				this.received++
+				return true
+			}
+		} else {
+			this.probation = RTP_MIN_SEQUENTIAL - 1
+			this.maxSeq = seq
+		}
+		return false
+	} else if udelta < RTP_MAX_DROPOUT {
+		// in order, with permissible gap
+		if seq < this.maxSeq {
+			this.cycles += RTP_SEQ_MOD
+		}
+		this.maxSeq = seq
+	} else if uint32(udelta) <= RTP_SEQ_MOD-RTP_MAX_MISORDER {
+		// the sequence number made a very large jump
+		if uint32(seq) == this.badSeq {
+			// two sequential packets, assume the other side restarted
+			// without telling us so just re-sync
+			this.initSeq(seq)
+		} else {
+			this.badSeq = (uint32(seq) + 1) & (RTP_SEQ_MOD - 1)
+			return false
+		}
+	} else {
+		// duplicate or reordered packet
+	}
+	this.received++
+	return true
+}
+
+// GetClockRate returns the clock rate of payloadType, 0 when it is unknown.
+func (this *ReceiverStats) GetClockRate(payloadType byte) uint32 {
+	return this.Payloads.GetClockRate(payloadType)
+}
+
+// Update accounts a packet of this source that arrived at arrival and
+// returns false while the source is on probation or the packet is rejected
+// by the sequence number validation.
+func (this *ReceiverStats) Update(packet *RtpPacket, arrival time.Time) bool {
+	seq := packet.GetSequence()
+	if !this.started {
+		this.started = true
+		this.initSeq(seq)
+		this.maxSeq = seq - 1
+		this.probation = RTP_MIN_SEQUENTIAL
+		this.firstArrival = arrival
+	}
+
+	if !this.updateSeq(seq) {
+		return false
+	}
+	this.updateJitter(packet.GetPayloadType(), packet.GetTimestamp(), arrival)
+	return true
+}
+
+// updateJitter is the interarrival jitter estimator of RFC3550 appendix A.8,
+// jitter is kept multiplied by 16.
+func (this *ReceiverStats) updateJitter(payloadType byte, timestamp uint32, arrival time.Time) {
+	if !this.hasTransit || payloadType != this.payloadType {
+		this.payloadType = payloadType
+		this.clockRate = this.GetClockRate(payloadType)
+		this.hasTransit = false
+	}
+	if this.clockRate == 0 {
+		return
+	}
+
+	elapsed := arrival.Sub(this.firstArrival)
+	seconds := int64(elapsed / time.Second)
+	nanoseconds := int64(elapsed % time.Second)
+	units := seconds*int64(this.clockRate) + nanoseconds*int64(this.clockRate)/int64(time.Second)
+
+	transit := uint32(units) - timestamp
+	if !this.hasTransit {
+		this.hasTransit = true
+		this.transit = transit
+		return
+	}
+
+	d := int32(transit - this.transit)
+	this.transit = transit
+	if d < 0 {
+		d = -d
+	}
+	this.jitter += uint32(d) - ((this.jitter + 8) >> 4)
+}
+
+// Valid reports whether the source has passed probation.
+func (this *ReceiverStats) Valid() bool {
+	return this.started && this.probation == 0
+}
+
+func (this *ReceiverStats) GetReceived() uint32 {
+	return this.received
+}
+
+func (this *ReceiverStats) GetExtendedHighestSequence() uint32 {
+	return this.cycles + uint32(this.maxSeq)
+}
+
+func (this *ReceiverStats) GetExpected() uint32 {
+	return this.GetExtendedHighestSequence() - this.baseSeq + 1
+}
+
+// GetCumulativeLost returns the packets expected minus the packets received,
+// negative when duplicates were received.
+func (this *ReceiverStats) GetCumulativeLost() int32 {
+	return int32(int64(this.GetExpected()) - int64(this.received))
+}
+
+// GetJitter returns the interarrival jitter in timestamp units.
+func (this *ReceiverStats) GetJitter() uint32 {
+	return this.jitter >> 4
+}
+
+// UpdateSenderReport records a SR of this source that arrived at arrival.
+func (this *ReceiverStats) UpdateSenderReport(sr *rtcp.RtcpSenderReport, arrival time.Time) {
+	this.lastSr = rtcp.NtpMiddle(sr.NtpTimestamp)
+	this.lastSrArrival = arrival
+}
+
+// ReportBlock returns the reception report block for this source at now and
+// starts a new interval for the fraction lost. It returns false while the
+// source is not valid.
+func (this *ReceiverStats) ReportBlock(now time.Time) (rtcp.RtcpReportBlock, bool) {
+	if !this.Valid() {
+		return rtcp.RtcpReportBlock{}, false
+	}
+
+	expected := this.GetExpected()
+	expectedInterval := expected - this.expectedPrior
+	this.expectedPrior = expected
+	receivedInterval := this.received - this.receivedPrior
+	this.receivedPrior = this.received
+	lostInterval := int64(expectedInterval) - int64(receivedInterval)
+
+	fraction := byte(0)
+	if expectedInterval != 0 && lostInterval > 0 {
+		fraction = byte((lostInterval << 8) / int64(expectedInterval))
+	}
+
+	lost := this.GetCumulativeLost()
+	if lost > 0x7FFFFF {
+		lost = 0x7FFFFF
+	} else if lost < -0x800000 {
+		lost = -0x800000
+	}
+
+	block := rtcp.RtcpReportBlock{
+		Ssrc:             this.Ssrc,
+		FractionLost:     fraction,
+		Lost:             lost,
+		ExtendedSequence: this.GetExtendedHighestSequence(),
+		Jitter:           this.GetJitter(),
+		LastSr:           this.lastSr,
+	}
+	if !this.lastSrArrival.IsZero() && now.After(this.lastSrArrival) {
+		// in units of 1/65536 seconds
+		block.DelaySinceLastSr = uint32(int64(now.Sub(this.lastSrArrival)/time.Microsecond) * 65536 / 1000000)
+	}
+	return block, true
+}
@@ -0,0 +1,124 @@
+package rtp
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"rtcp"
+
+	"github.com/lioneagle/goutil/src/test"
+)
+
+func newTestRtpPacket(payloadType byte, seq uint16, timestamp uint32) *RtpPacket {
+	packet := NewRtpPacket()
+	packet.Alloc(packet.CalcLen(0, 0, 0))
+	packet.SetVersion(RTP_VERSION)
+	packet.SetPayloadType(payloadType)
+	packet.SetSequence(seq)
+	packet.SetTimestamp(timestamp)
+	return packet
+}
+
+func TestReceiverStatsSequence(t *testing.T) {
+	testdata := []struct {
+		seqs     []uint16
+		valid    []bool
+		extended uint32
+		expected uint32
+		received uint32
+	}{
+		// probation
+		{[]uint16{10, 11, 12}, []bool{false, true, true}, 12, 2, 2},
+		{[]uint16{10, 20, 21, 22}, []bool{false, false, true, true}, 22, 2, 2},
+		// wrap around
+		{[]uint16{65534, 65535, 0, 1}, []bool{false, true, true, true}, 65537, 3, 3},
+		// loss and duplicate
+		{[]uint16{1, 2, 5, 5, 4}, []bool{false, true, true, true, true}, 5, 4, 4},
+		// a big jump is dropped, two sequential packets restart
+		{[]uint16{1, 2, 3, 10000, 4, 20000, 20001}, []bool{false, true, true, false, true, false, true}, 20001, 1, 1},
+	}
+
+	for i, v := range testdata {
+		v := v
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			t.Parallel()
+
+			stats := NewReceiverStats(1)
+			arrival := time.Unix(1000, 0)
+			for j, seq := range v.seqs {
+				valid := stats.Update(newTestRtpPacket(0, seq, 0), arrival)
+				test.EXPECT_EQ(t, valid, v.valid[j], "packet %d", j)
+			}
+			test.EXPECT_EQ(t, stats.GetExtendedHighestSequence(), v.extended, "")
+			test.EXPECT_EQ(t, stats.GetExpected(), v.expected, "")
+			test.EXPECT_EQ(t, stats.GetReceived(), v.received, "")
+		})
+	}
+}
+
+func TestReceiverStatsJitter(t *testing.T) {
+	stats := NewReceiverStats(1)
+	base := time.Unix(1000, 0)
+
+	// 20ms packets at 8000Hz, the fourth one 10ms late
+	delays := []time.Duration{0, 0, 0, 10 * time.Millisecond, 0}
+	for i, delay := range delays {
+		arrival := base.Add(time.Duration(i)*20*time.Millisecond + delay)
+		stats.Update(newTestRtpPacket(0, uint16(i), uint32(i*160)), arrival)
+	}
+	test.EXPECT_EQ(t, stats.GetJitter(), uint32(9), "")
+
+	// dynamic payload type without a clock rate is skipped
+	stats = NewReceiverStats(1)
+	for i, delay := range delays {
+		arrival := base.Add(time.Duration(i)*20*time.Millisecond + delay)
+		stats.Update(newTestRtpPacket(96, uint16(i), uint32(i*160)), arrival)
+	}
+	test.EXPECT_EQ(t, stats.GetJitter(), uint32(0), "")
+
+	stats = NewReceiverStats(1)
+	stats.Payloads = NewPayloadRegistry()
+	stats.Payloads.Register(RtpProfile{PayloadType: 96, Name: "G7221", MediaType: "A", HasClockRate: true, ClockRate: 16000})
+	for i, delay := range delays {
+		arrival := base.Add(time.Duration(i)*20*time.Millisecond + delay)
+		stats.Update(newTestRtpPacket(96, uint16(i), uint32(i*320)), arrival)
+	}
+	test.EXPECT_EQ(t, stats.GetJitter(), uint32(19), "")
+}
+
+func TestReceiverStatsReportBlock(t *testing.T) {
+	stats := NewReceiverStats(0x1234)
+	base := time.Unix(1000, 0)
+
+	_, ok := stats.ReportBlock(base)
+	test.EXPECT_EQ(t, ok, false, "")
+
+	for seq := uint16(0); seq < 10; seq++ {
+		if seq == 5 || seq == 6 {
+			continue
+		}
+		stats.Update(newTestRtpPacket(0, seq, uint32(seq)*160), base.Add(time.Duration(seq)*20*time.Millisecond))
+	}
+	stats.UpdateSenderReport(&rtcp.RtcpSenderReport{NtpTimestamp: 0x0102030405060708}, base)
+
+	block, ok := stats.ReportBlock(base.Add(1500 * time.Millisecond))
+	test.EXPECT_EQ(t, ok, true, "")
+	test.EXPECT_EQ(t, block, rtcp.RtcpReportBlock{
+		Ssrc:             0x1234,
+		FractionLost:     56,
+		Lost:             2,
+		ExtendedSequence: 9,
+		LastSr:           0x03040506,
+		DelaySinceLastSr: 98304,
+	}, "")
+
+	// a new interval without loss
+	for seq := uint16(10); seq < 20; seq++ {
+		stats.Update(newTestRtpPacket(0, seq, uint32(seq)*160), base.Add(time.Duration(seq)*20*time.Millisecond))
+	}
+	block, _ = stats.ReportBlock(base.Add(2 * time.Second))
+	test.EXPECT_EQ(t, block.FractionLost, byte(0), "")
+	test.EXPECT_EQ(t, block.Lost, int32(2), "")
+	test.EXPECT_EQ(t, block.ExtendedSequence, uint32(19), "")
+}
@@ -0,0 +1,352 @@
+package rtp
+
+import (
+	"math/rand"
+	"time"
+
+	"rtcp"
+
+	"github.com/lioneagle/goutil/src/algorithm/timewheel"
+)
+
+const (
+	RTP_RTCP_TIMER_TICK = 10 * time.Millisecond
+	// a BYE may be sent at once when there are fewer members, RFC3550
+	// section 6.3.7
+	RTP_RTCP_IMMEDIATE_BYE_MEMBERS = 50
+	// l of the early feedback dither in RFC4585 section 3.5.2
+	RTP_AVPF_DITHER_FACTOR = 0.5
+)
+
+// RtcpScheduler paces the rtcp packets of a Session with the transmission
+// interval rules of RFC3550 section 6.3: reverse reconsideration when members
+// leave, timer reconsideration at expiry and BYE reconsideration when leaving.
+// With Avpf set it also follows RFC4585 section 3.5: early feedback once per
+// regular interval and the T_rr_interval lower bound for regular reports.
+//
+// The scheduler has no clock of its own, OnTimer is called with the current
+// time, usually from a timewheel with AttachTimeWheel. Calls must not run
+// concurrently with each other or with the Session.
+type RtcpScheduler struct {
+	Session *Session
+	// octets per second for rtcp
+	RtcpBandwidth float64
+	Avpf          bool
+	// minimal interval between regular rtcp packets with AVPF, 0 for none
+	TrrInterval time.Duration
+	// Random returns a value in [0, 1), it may be replaced by tests
+	Random func() float64
+	// Send transmits one compound rtcp packet
+	Send func(packets []rtcp.RtcpPacket)
+
+	started     bool
+	initial     bool
+	tp          time.Time
+	tn          time.Time
+	tpPrev      time.Time
+	pmembers    int
+	avgRtcpSize float64
+	sentReports bool
+
+	// last randomized interval, T_rr of RFC4585
+	trr        time.Duration
+	allowEarly bool
+	earlyTime  time.Time
+	feedback   []rtcp.RtcpPacket
+	trrLast    time.Time
+	trrCurrent time.Duration
+
+	leaving    bool
+	byeMembers int
+	reason     string
+	done       bool
+}
+
+// NewRtcpScheduler uses 5% of sessionBandwidth, in bits per second, for rtcp.
+func NewRtcpScheduler(session *Session, sessionBandwidth float64, send func(packets []rtcp.RtcpPacket)) *RtcpScheduler {
+	return &RtcpScheduler{
+		Session:       session,
+		RtcpBandwidth: sessionBandwidth * rtcp.RTCP_BANDWIDTH_FRACTION / 8,
+		Random:        rand.Float64,
+		Send:          send,
+	}
+}
+
+// AttachTimeWheel runs the report timers from tw, checking them every tick
+// at the time of clock. RTP_RTCP_TIMER_TICK is fine enough for the intervals
+// of RFC3550.
+func (this *RtcpScheduler) AttachTimeWheel(tw *timewheel.TimeWheel, tick time.Duration, clock func() time.Time) bool {
+	return attachTimeWheel(tw, tick, clock, this)
+}
+
+func (this *RtcpScheduler) minTime() time.Duration {
+	if this.Avpf {
+		if this.initial {
+			return rtcp.RTCP_AVPF_INITIAL_MIN_TIME
+		}
+		return 0
+	}
+	if this.initial {
+		return rtcp.RTCP_MIN_TIME / 2
+	}
+	return rtcp.RTCP_MIN_TIME
+}
+
+func (this *RtcpScheduler) interval() time.Duration {
+	td := rtcp.RtcpDeterministicInterval(this.Session.MemberCount(), this.Session.SenderCount(), this.RtcpBandwidth,
+		this.Session.Sender.HasSent(), this.avgRtcpSize, this.minTime())
+	return rtcp.RtcpRandomizedInterval(td, this.Random())
+}
+
+// DeterministicInterval is the interval Td used for the member and sender
+// timeouts, always with the minimum of RFC3550.
+func (this *RtcpScheduler) DeterministicInterval() time.Duration {
+	return rtcp.RtcpDeterministicInterval(this.Session.MemberCount(), this.Session.SenderCount(), this.RtcpBandwidth,
+		this.Session.Sender.HasSent(), this.avgRtcpSize, rtcp.RTCP_MIN_TIME)
+}
+
+func (this *RtcpScheduler) randomTrrInterval() time.Duration {
+	return time.Duration(float64(this.TrrInterval) * (this.Random() + 0.5))
+}
+
+// Start schedules the first report, the average size starts with the size of
+// that report.
+func (this *RtcpScheduler) Start(now time.Time) {
+	this.started = true
+	this.initial = true
+	this.allowEarly = true
+	this.tp = now
+	this.pmembers = this.Session.MemberCount()
+	this.avgRtcpSize = float64(this.estimateSize())
+	this.trr = this.interval()
+	this.tn = now.Add(this.trr)
+}
+
+// Done reports whether the BYE has been sent after Leave.
+func (this *RtcpScheduler) Done() bool {
+	return this.done
+}
+
+// NextReportTime returns when the next regular report is due.
+func (this *RtcpScheduler) NextReportTime() time.Time {
+	return this.tn
+}
+
+func rtcpCompoundSize(packets []rtcp.RtcpPacket) int {
+	size := rtcp.RTCP_UDP_IP_OVERHEAD
+	for _, v := range packets {
+		size += v.MarshalSize()
+	}
+	return size
+}
+
+// estimateSize returns the size of a report without report blocks.
+func (this *RtcpScheduler) estimateSize() int {
+	ssrc := this.Session.GetLocalSsrc()
+	return rtcpCompoundSize([]rtcp.RtcpPacket{&rtcp.RtcpReceiverReport{Ssrc: ssrc}, rtcp.NewRtcpSdesCname(ssrc, this.Session.Cname)})
+}
+
+// buildReport returns a SR or RR with the report blocks, more RR when there
+// are over 31 blocks, the SDES CNAME, a BYE for SSRCs left after a collision
+// and, with takeFeedback, the queued feedback.
+func (this *RtcpScheduler) buildReport(now time.Time, takeFeedback bool) []rtcp.RtcpPacket {
+	session := this.Session
+	blocks := session.ReportBlocks(now)
+	first := blocks
+	if len(first) > rtcp.RTCP_MAX_COUNT {
+		first = first[:rtcp.RTCP_MAX_COUNT]
+	}
+	blocks = blocks[len(first):]
+
+	var packets []rtcp.RtcpPacket
+	if sr := session.Sender.SenderReport(now, first); sr != nil {
+		packets = append(packets, sr)
+	} else {
+		packets = append(packets, &rtcp.RtcpReceiverReport{Ssrc: session.GetLocalSsrc(), Reports: first})
+	}
+	for len(blocks) > 0 {
+		n := len(blocks)
+		if n > rtcp.RTCP_MAX_COUNT {
+			n = rtcp.RTCP_MAX_COUNT
+		}
+		packets = append(packets, &rtcp.RtcpReceiverReport{Ssrc: session.GetLocalSsrc(), Reports: blocks[:n]})
+		blocks = blocks[n:]
+	}
+
+	packets = append(packets, rtcp.NewRtcpSdesCname(session.GetLocalSsrc(), session.Cname))
+	if takeFeedback {
+		if bye := session.ByePacket(); bye != nil {
+			packets = append(packets, bye)
+		}
+		packets = append(packets, this.feedback...)
+		this.feedback = nil
+	}
+	return packets
+}
+
+func (this *RtcpScheduler) send(packets []rtcp.RtcpPacket) {
+	this.avgRtcpSize = rtcp.UpdateAvgRtcpSize(this.avgRtcpSize, rtcpCompoundSize(packets))
+	this.Send(packets)
+}
+
+// OnTimer runs the expiry of the scheduled report, BYE or early feedback.
+func (this *RtcpScheduler) OnTimer(now time.Time) {
+	if !this.started || this.done {
+		return
+	}
+	if this.leaving {
+		this.onByeTimer(now)
+		return
+	}
+
+	// reverse reconsideration
+	members := this.Session.MemberCount()
+	if members < this.pmembers {
+		ratio := float64(members) / float64(this.pmembers)
+		this.tn = now.Add(time.Duration(float64(this.tn.Sub(now)) * ratio))
+		this.tp = now.Add(-time.Duration(float64(now.Sub(this.tp)) * ratio))
+		this.pmembers = members
+	}
+
+	if !this.earlyTime.IsZero() && !now.Before(this.earlyTime) {
+		this.sendEarly(now)
+	}
+
+	if now.Before(this.tn) {
+		return
+	}
+	// timer reconsideration
+	tn := this.tp.Add(this.interval())
+	if tn.After(now) {
+		this.tn = tn
+		this.pmembers = members
+		return
+	}
+	this.sendRegular(now)
+}
+
+func (this *RtcpScheduler) sendRegular(now time.Time) {
+	// a source silent for the last two intervals is no longer a sender
+	if !this.tpPrev.IsZero() {
+		this.Session.Sender.Timeout(this.tpPrev)
+	}
+
+	suppress := this.Avpf && this.TrrInterval > 0 && !this.trrLast.IsZero() && now.Before(this.trrLast.Add(this.trrCurrent))
+	if !suppress {
+		this.send(this.buildReport(now, true))
+		this.sentReports = true
+		this.trrLast = now
+		this.trrCurrent = this.randomTrrInterval()
+	} else if len(this.feedback) > 0 {
+		// only the feedback goes out in place of the suppressed report
+		this.send(this.buildReport(now, true))
+	}
+
+	this.tpPrev, this.tp = this.tp, now
+	this.Session.Timeout(now, this.DeterministicInterval())
+	this.trr = this.interval()
+	this.tn = now.Add(this.trr)
+	this.initial = false
+	this.allowEarly = true
+	this.earlyTime = time.Time{}
+	this.pmembers = this.Session.MemberCount()
+}
+
+func (this *RtcpScheduler) sendEarly(now time.Time) {
+	this.send(this.buildReport(now, true))
+	this.earlyTime = time.Time{}
+	this.allowEarly = false
+	// the next regular report is pushed out by one interval
+	this.tn = this.tp.Add(2 * this.trr)
+}
+
+// SendFeedback queues feedback packets. With AVPF they go in an early packet
+// when one is allowed and it comes before the next regular report, otherwise
+// with the next regular report.
+func (this *RtcpScheduler) SendFeedback(now time.Time, packets []rtcp.RtcpPacket) {
+	this.feedback = append(this.feedback, packets...)
+	if !this.Avpf || !this.allowEarly || !this.earlyTime.IsZero() {
+		return
+	}
+
+	// no dither between two participants
+	dither := time.Duration(0)
+	if this.Session.MemberCount() > 2 {
+		dither = time.Duration(this.Random() * RTP_AVPF_DITHER_FACTOR * float64(this.trr))
+	}
+	te := now.Add(dither)
+	if !te.Before(this.tn) {
+		return
+	}
+	this.earlyTime = te
+}
+
+// OnRtcpReceived accounts a received compound packet of size octets without
+// the udp and ip headers.
+func (this *RtcpScheduler) OnRtcpReceived(packets []rtcp.RtcpPacket, size int) {
+	if !this.leaving {
+		this.avgRtcpSize = rtcp.UpdateAvgRtcpSize(this.avgRtcpSize, size+rtcp.RTCP_UDP_IP_OVERHEAD)
+		return
+	}
+
+	// while leaving only BYE packets are counted
+	bye := false
+	for _, v := range packets {
+		if v.GetPacketType() == rtcp.RTCP_PT_BYE {
+			this.byeMembers++
+			bye = true
+		}
+	}
+	if bye {
+		this.avgRtcpSize = rtcp.UpdateAvgRtcpSize(this.avgRtcpSize, size+rtcp.RTCP_UDP_IP_OVERHEAD)
+	}
+}
+
+func (this *RtcpScheduler) buildBye(now time.Time, reason string) []rtcp.RtcpPacket {
+	packets := this.buildReport(now, false)
+	return append(packets, &rtcp.RtcpBye{Sources: []uint32{this.Session.GetLocalSsrc()}, Reason: reason})
+}
+
+// Leave sends a BYE, at once in a small session and after BYE
+// reconsideration otherwise. Nothing is sent when no report has been sent.
+func (this *RtcpScheduler) Leave(now time.Time, reason string) {
+	if this.done || this.leaving {
+		return
+	}
+	if !this.sentReports {
+		this.done = true
+		return
+	}
+	if this.Session.MemberCount() <= RTP_RTCP_IMMEDIATE_BYE_MEMBERS {
+		this.send(this.buildBye(now, reason))
+		this.done = true
+		return
+	}
+
+	this.leaving = true
+	this.reason = reason
+	this.tp = now
+	this.byeMembers = 1
+	this.pmembers = 1
+	this.initial = true
+	this.avgRtcpSize = float64(this.estimateSize() + (&rtcp.RtcpBye{Sources: []uint32{0}, Reason: reason}).MarshalSize())
+	this.tn = now.Add(this.byeInterval())
+}
+
+func (this *RtcpScheduler) byeInterval() time.Duration {
+	td := rtcp.RtcpDeterministicInterval(this.byeMembers, 0, this.RtcpBandwidth, false, this.avgRtcpSize, this.minTime())
+	return rtcp.RtcpRandomizedInterval(td, this.Random())
+}
+
+func (this *RtcpScheduler) onByeTimer(now time.Time) {
+	if now.Before(this.tn) {
+		return
+	}
+	tn := this.tp.Add(this.byeInterval())
+	if tn.After(now) {
+		this.tn = tn
+		return
+	}
+	this.send(this.buildBye(now, this.reason))
+	this.done = true
+}
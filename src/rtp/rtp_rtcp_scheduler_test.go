@@ -0,0 +1,207 @@
+package rtp
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"rtcp"
+
+	"github.com/lioneagle/goutil/src/test"
+)
+
+type testRtcpSend struct {
+	at      time.Duration
+	packets []rtcp.RtcpPacket
+}
+
+type testRtcpScheduler struct {
+	*testTimeWheel
+	scheduler *RtcpScheduler
+	sends     []testRtcpSend
+}
+
+func newTestRtcpScheduler(session *Session) *testRtcpScheduler {
+	this := &testRtcpScheduler{testTimeWheel: newTestTimeWheel()}
+	this.scheduler = NewRtcpScheduler(session, 64000, func(packets []rtcp.RtcpPacket) {
+		this.sends = append(this.sends, testRtcpSend{this.elapsed(), packets})
+	})
+	this.scheduler.Random = func() float64 { return 0.5 }
+	this.scheduler.AttachTimeWheel(this.tw, RTP_RTCP_TIMER_TICK, this.clock)
+	return this
+}
+
+func (this *testRtcpScheduler) runUntil(offset time.Duration) {
+	this.testTimeWheel.runUntil(offset, RTP_RTCP_TIMER_TICK)
+}
+
+func packetTypes(packets []rtcp.RtcpPacket) string {
+	types := ""
+	for _, v := range packets {
+		types += fmt.Sprintf("%d ", v.GetPacketType())
+	}
+	return types
+}
+
+func addTestMembers(session *Session, n int, now time.Time) {
+	for i := 0; i < n; i++ {
+		addr := fmt.Sprintf("10.0.0.%d:5001", i+1)
+		session.OnRtcpPackets([]rtcp.RtcpPacket{&rtcp.RtcpReceiverReport{Ssrc: uint32(i + 1)}}, addr, now)
+	}
+}
+
+func TestRtcpSchedulerRegular(t *testing.T) {
+	runner := newTestRtcpScheduler(newTestSession(100))
+	runner.scheduler.Start(runner.now)
+	runner.runUntil(7 * time.Second)
+
+	// 2.5s then 5s, randomized with 0.5 and divided by e-3/2
+	test.EXPECT_EQ(t, len(runner.sends), 2, "")
+	test.EXPECT_EQ(t, runner.sends[0].at, 2060*time.Millisecond, "")
+	test.EXPECT_EQ(t, runner.sends[1].at, 6170*time.Millisecond, "")
+	test.EXPECT_EQ(t, packetTypes(runner.sends[0].packets), "201 202 ", "")
+	test.EXPECT_EQ(t, runner.sends[0].packets[1], rtcp.NewRtcpSdesCname(100, "local@example.com"), "")
+}
+
+func TestRtcpSchedulerSenderReport(t *testing.T) {
+	session := newTestSession(100)
+	runner := newTestRtcpScheduler(session)
+	runner.scheduler.Start(runner.now)
+
+	session.OnRtpPacket(newTestSessionPacket(1, 0), "10.0.0.1:5000", runner.now)
+	session.OnRtpPacket(newTestSessionPacket(1, 1), "10.0.0.1:5000", runner.now)
+	session.OnRtpSent(newTestSessionPacket(0, 0), runner.now)
+	runner.runUntil(3 * time.Second)
+
+	test.EXPECT_EQ(t, len(runner.sends), 1, "")
+	sr := runner.sends[0].packets[0].(*rtcp.RtcpSenderReport)
+	test.EXPECT_EQ(t, sr.Ssrc, uint32(100), "")
+	test.EXPECT_EQ(t, len(sr.Reports), 1, "")
+	test.EXPECT_EQ(t, sr.Reports[0].Ssrc, uint32(1), "")
+}
+
+func TestRtcpSchedulerSenderTimeout(t *testing.T) {
+	session := newTestSession(100)
+	runner := newTestRtcpScheduler(session)
+	runner.scheduler.Start(runner.now)
+	session.OnRtpSent(newTestSessionPacket(0, 0), runner.now)
+	runner.runUntil(20 * time.Second)
+
+	// SR until nothing has been sent for two intervals
+	types := ""
+	for _, v := range runner.sends {
+		types += fmt.Sprintf("%d ", v.packets[0].GetPacketType())
+	}
+	test.EXPECT_EQ(t, types, "200 200 201 201 201 ", "")
+	test.EXPECT_EQ(t, session.Sender.HasSent(), false, "")
+	test.EXPECT_EQ(t, session.SenderCount(), 0, "")
+
+	session.OnRtpSent(newTestSessionPacket(0, 1), runner.now)
+	test.EXPECT_EQ(t, session.SenderCount(), 1, "")
+	runner.runUntil(26 * time.Second)
+	sr := runner.sends[len(runner.sends)-1].packets[0].(*rtcp.RtcpSenderReport)
+	test.EXPECT_EQ(t, sr.PacketCount, uint32(2), "")
+}
+
+func TestRtcpSchedulerReverseReconsideration(t *testing.T) {
+	session := newTestSession(100)
+	runner := newTestRtcpScheduler(session)
+	addTestMembers(session, 3, runner.now)
+	runner.scheduler.Start(runner.now)
+
+	runner.runUntil(time.Second)
+	before := runner.scheduler.NextReportTime()
+	for i := 0; i < 3; i++ {
+		addr := fmt.Sprintf("10.0.0.%d:5001", i+1)
+		session.OnRtcpPackets([]rtcp.RtcpPacket{&rtcp.RtcpBye{Sources: []uint32{uint32(i + 1)}}}, addr, runner.now)
+	}
+	runner.scheduler.OnTimer(runner.now)
+
+	wanted := runner.now.Add(time.Duration(float64(before.Sub(runner.now)) / 4))
+	test.EXPECT_EQ(t, runner.scheduler.NextReportTime(), wanted, "")
+}
+
+func TestRtcpSchedulerBye(t *testing.T) {
+	// nothing is sent before the first report
+	runner := newTestRtcpScheduler(newTestSession(100))
+	runner.scheduler.Start(runner.now)
+	runner.scheduler.Leave(runner.now, "bye")
+	test.EXPECT_EQ(t, runner.scheduler.Done(), true, "")
+	test.EXPECT_EQ(t, len(runner.sends), 0, "")
+
+	// a small session sends the BYE at once
+	runner = newTestRtcpScheduler(newTestSession(100))
+	runner.scheduler.Start(runner.now)
+	runner.runUntil(3 * time.Second)
+	runner.scheduler.Leave(runner.now, "bye")
+	test.EXPECT_EQ(t, runner.scheduler.Done(), true, "")
+	test.EXPECT_EQ(t, len(runner.sends), 2, "")
+	test.EXPECT_EQ(t, packetTypes(runner.sends[1].packets), "201 202 203 ", "")
+	test.EXPECT_EQ(t, runner.sends[1].packets[2], &rtcp.RtcpBye{Sources: []uint32{100}, Reason: "bye"}, "")
+}
+
+func TestRtcpSchedulerByeReconsideration(t *testing.T) {
+	session := newTestSession(100)
+	runner := newTestRtcpScheduler(session)
+	addTestMembers(session, 60, runner.now)
+	runner.scheduler.Start(runner.now)
+	runner.runUntil(12 * time.Second)
+	test.EXPECT_EQ(t, len(runner.sends), 1, "")
+
+	runner.scheduler.Leave(runner.now, "")
+	test.EXPECT_EQ(t, len(runner.sends), 1, "")
+	leave := runner.now.Sub(runner.base)
+
+	runner.scheduler.OnRtcpReceived([]rtcp.RtcpPacket{&rtcp.RtcpReceiverReport{Ssrc: 1}, &rtcp.RtcpBye{Sources: []uint32{1}}}, 16)
+	runner.runUntil(leave + 3*time.Second)
+
+	test.EXPECT_EQ(t, runner.scheduler.Done(), true, "")
+	test.EXPECT_EQ(t, len(runner.sends), 2, "")
+	test.EXPECT_EQ(t, runner.sends[1].at, leave+2060*time.Millisecond, "")
+	test.EXPECT_EQ(t, packetTypes(runner.sends[1].packets), "201 202 203 ", "")
+}
+
+func TestRtcpSchedulerAvpfEarlyFeedback(t *testing.T) {
+	session := newTestSession(100)
+	runner := newTestRtcpScheduler(session)
+	runner.scheduler.Avpf = true
+	addTestMembers(session, 1, runner.now)
+	runner.scheduler.Start(runner.now)
+
+	// the initial minimum is 1s
+	runner.runUntil(900 * time.Millisecond)
+	test.EXPECT_EQ(t, len(runner.sends), 1, "")
+	test.EXPECT_EQ(t, runner.sends[0].at, 830*time.Millisecond, "")
+
+	pli := &rtcp.RtcpPli{SenderSsrc: 100, MediaSsrc: 1}
+	runner.scheduler.SendFeedback(runner.now, []rtcp.RtcpPacket{pli})
+	runner.runUntil(910 * time.Millisecond)
+	test.EXPECT_EQ(t, len(runner.sends), 2, "")
+	test.EXPECT_EQ(t, runner.sends[1].at, 910*time.Millisecond, "")
+	test.EXPECT_EQ(t, packetTypes(runner.sends[1].packets), "201 202 206 ", "")
+
+	// one early packet per regular interval, the next regular report is
+	// pushed out and carries the feedback
+	next := runner.scheduler.NextReportTime()
+	runner.scheduler.SendFeedback(runner.now, []rtcp.RtcpPacket{pli})
+	runner.runUntil(next.Sub(runner.base) + RTP_RTCP_TIMER_TICK)
+	test.EXPECT_EQ(t, len(runner.sends), 3, "")
+	test.EXPECT_EQ(t, runner.sends[2].at > 1500*time.Millisecond, true, "at = %v", runner.sends[2].at)
+	test.EXPECT_EQ(t, packetTypes(runner.sends[2].packets), "201 202 206 ", "")
+}
+
+func TestRtcpSchedulerTrrInterval(t *testing.T) {
+	count := func(trrInterval time.Duration) int {
+		session := newTestSession(100)
+		runner := newTestRtcpScheduler(session)
+		runner.scheduler.Avpf = true
+		runner.scheduler.TrrInterval = trrInterval
+		addTestMembers(session, 1, runner.now)
+		runner.scheduler.Start(runner.now)
+		runner.runUntil(10 * time.Second)
+		return len(runner.sends)
+	}
+
+	test.EXPECT_EQ(t, count(0) > 20, true, "")
+	test.EXPECT_EQ(t, count(3*time.Second), 3, "")
+}
@@ -0,0 +1,87 @@
+package rtp
+
+import (
+	"time"
+
+	"rtcp"
+)
+
+// SenderStats counts the packets sent by one source and builds its sender
+// reports. Octets are payload octets only, as defined for the SR.
+type SenderStats struct {
+	Ssrc      uint32
+	ClockRate uint32
+
+	sent          bool
+	packetCount   uint32
+	octetCount    uint32
+	lastTimestamp uint32
+	lastSendTime  time.Time
+}
+
+func NewSenderStats(ssrc, clockRate uint32) *SenderStats {
+	return &SenderStats{Ssrc: ssrc, ClockRate: clockRate}
+}
+
+// Update accounts a packet sent at now.
+func (this *SenderStats) Update(packet *RtpPacket, now time.Time) {
+	this.sent = true
+	this.packetCount++
+	this.octetCount += uint32(packet.PayloadLen())
+	this.lastTimestamp = packet.GetTimestamp()
+	this.lastSendTime = now
+}
+
+// Reset clears the counters, as needed when the source changes its SSRC.
+func (this *SenderStats) Reset(ssrc uint32) {
+	*this = SenderStats{Ssrc: ssrc, ClockRate: this.ClockRate}
+}
+
+// HasSent reports whether the source is a sender, we_sent of RFC3550: a
+// packet has been sent since the last Reset and the sender has not timed
+// out.
+func (this *SenderStats) HasSent() bool {
+	return this.sent
+}
+
+// Timeout clears we_sent when no packet has been sent since since, RFC3550
+// section 6.3.8. The counters are kept for when the source sends again.
+func (this *SenderStats) Timeout(since time.Time) {
+	if this.sent && this.lastSendTime.Before(since) {
+		this.sent = false
+	}
+}
+
+func (this *SenderStats) GetPacketCount() uint32 {
+	return this.packetCount
+}
+
+func (this *SenderStats) GetOctetCount() uint32 {
+	return this.octetCount
+}
+
+// GetRtpTimestamp returns the rtp timestamp that corresponds to now,
+// extrapolated from the last packet sent with the clock rate.
+func (this *SenderStats) GetRtpTimestamp(now time.Time) uint32 {
+	elapsed := now.Sub(this.lastSendTime)
+	seconds := int64(elapsed / time.Second)
+	nanoseconds := int64(elapsed % time.Second)
+	units := seconds*int64(this.ClockRate) + nanoseconds*int64(this.ClockRate)/int64(time.Second)
+	return this.lastTimestamp + uint32(units)
+}
+
+// SenderReport returns the SR for now carrying reports, or nil when nothing
+// has been sent and a RR should be used instead.
+func (this *SenderStats) SenderReport(now time.Time, reports []rtcp.RtcpReportBlock) *rtcp.RtcpSenderReport {
+	if !this.sent {
+		return nil
+	}
+	return &rtcp.RtcpSenderReport{
+		Ssrc:         this.Ssrc,
+		NtpTimestamp: rtcp.NtpTimestamp(now),
+		RtpTimestamp: this.GetRtpTimestamp(now),
+		PacketCount:  this.packetCount,
+		OctetCount:   this.octetCount,
+		Reports:      reports,
+	}
+}
@@ -0,0 +1,46 @@
+package rtp
+
+import (
+	"testing"
+	"time"
+
+	"rtcp"
+
+	"github.com/lioneagle/goutil/src/test"
+)
+
+func TestSenderStats(t *testing.T) {
+	stats := NewSenderStats(0x1234, 8000)
+	base := time.Unix(1500000000, 250000000)
+
+	test.EXPECT_EQ(t, stats.SenderReport(base, nil) == nil, true, "")
+
+	for i := 0; i < 3; i++ {
+		packet := NewRtpPacket()
+		packet.Alloc(packet.CalcLen(0, 0, 160))
+		packet.SetVersion(RTP_VERSION)
+		packet.SetSequence(uint16(i))
+		packet.SetTimestamp(uint32(1000 + i*160))
+		// padding is not counted
+		packet.SetPaddingLen(4)
+		stats.Update(packet, base.Add(time.Duration(i)*20*time.Millisecond))
+	}
+
+	// 50ms after the last packet
+	now := base.Add(90 * time.Millisecond)
+	sr := stats.SenderReport(now, []rtcp.RtcpReportBlock{{Ssrc: 1}})
+	test.EXPECT_EQ(t, sr, &rtcp.RtcpSenderReport{
+		Ssrc:         0x1234,
+		NtpTimestamp: uint64(1500000000+rtcp.RTCP_NTP_EPOCH_OFFSET)<<32 | 0x570a3d70,
+		RtpTimestamp: 1000 + 2*160 + 400,
+		PacketCount:  3,
+		OctetCount:   3 * 160,
+		Reports:      []rtcp.RtcpReportBlock{{Ssrc: 1}},
+	}, "")
+	test.EXPECT_EQ(t, rtcp.NtpTime(sr.NtpTimestamp).Sub(now) < time.Microsecond, true, "")
+
+	stats.Reset(0x5678)
+	test.EXPECT_EQ(t, stats.HasSent(), false, "")
+	test.EXPECT_EQ(t, stats.GetPacketCount(), uint32(0), "")
+	test.EXPECT_EQ(t, stats.ClockRate, uint32(8000), "")
+}
@@ -0,0 +1,105 @@
+package rtp
+
+// Sequence numbers and timestamps are compared in serial number arithmetic,
+// RFC1982: a is less than b when b follows a by less than half the number
+// space. Two values exactly half the space apart are not ordered.
+
+const (
+	RTP_SEQ_HALF       = 1 << 15
+	RTP_TIMESTAMP_HALF = 1 << 31
+)
+
+// SeqLess tells whether sequence number a precedes b.
+func SeqLess(a, b uint16) bool {
+	return a != b && b-a < RTP_SEQ_HALF
+}
+
+// SeqDiff returns how far a is ahead of b, negative when a precedes b.
+// Values half the space apart give -32768.
+func SeqDiff(a, b uint16) int {
+	return int(int16(a - b))
+}
+
+// TimestampLess tells whether RTP timestamp a precedes b.
+func TimestampLess(a, b uint32) bool {
+	return a != b && b-a < RTP_TIMESTAMP_HALF
+}
+
+// TimestampDiff returns how far a is ahead of b, negative when a precedes b.
+func TimestampDiff(a, b uint32) int64 {
+	return int64(int32(a - b))
+}
+
+// SequenceUnwrapper extends 16 bit sequence numbers to 64 bits. Each value is
+// placed next to the highest one seen, so packets reordered across the wrap
+// around keep their place. Packets preceding the first one across the wrap
+// around get negative values.
+type SequenceUnwrapper struct {
+	started bool
+	highest int64
+}
+
+// Unwrap returns the extended value of seq and moves the highest value
+// forward.
+func (this *SequenceUnwrapper) Unwrap(seq uint16) int64 {
+	extended := this.Peek(seq)
+	if !this.started || extended > this.highest {
+		this.started = true
+		this.highest = extended
+	}
+	return extended
+}
+
+// Peek returns the extended value of seq without changing the state.
+func (this *SequenceUnwrapper) Peek(seq uint16) int64 {
+	if !this.started {
+		return int64(seq)
+	}
+	return this.highest + int64(SeqDiff(seq, uint16(this.highest)))
+}
+
+// GetHighest returns the highest extended value, false before the first one.
+func (this *SequenceUnwrapper) GetHighest() (int64, bool) {
+	return this.highest, this.started
+}
+
+func (this *SequenceUnwrapper) Reset() {
+	this.started = false
+	this.highest = 0
+}
+
+// TimestampUnwrapper extends 32 bit RTP timestamps to 64 bits, as
+// SequenceUnwrapper does for sequence numbers.
+type TimestampUnwrapper struct {
+	started bool
+	highest int64
+}
+
+// Unwrap returns the extended value of timestamp and moves the highest value
+// forward.
+func (this *TimestampUnwrapper) Unwrap(timestamp uint32) int64 {
+	extended := this.Peek(timestamp)
+	if !this.started || extended > this.highest {
+		this.started = true
+		this.highest = extended
+	}
+	return extended
+}
+
+// Peek returns the extended value of timestamp without changing the state.
+func (this *TimestampUnwrapper) Peek(timestamp uint32) int64 {
+	if !this.started {
+		return int64(timestamp)
+	}
+	return this.highest + TimestampDiff(timestamp, uint32(this.highest))
+}
+
+// GetHighest returns the highest extended value, false before the first one.
+func (this *TimestampUnwrapper) GetHighest() (int64, bool) {
+	return this.highest, this.started
+}
+
+func (this *TimestampUnwrapper) Reset() {
+	this.started = false
+	this.highest = 0
+}
@@ -0,0 +1,122 @@
+package rtp
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/lioneagle/goutil/src/test"
+)
+
+func TestSeqLess(t *testing.T) {
+	testdata := []struct {
+		a    uint16
+		b    uint16
+		less bool
+		diff int
+	}{
+		{1, 2, true, -1},
+		{2, 1, false, 1},
+		{5, 5, false, 0},
+		{65535, 0, true, -1},
+		{0, 65535, false, 1},
+		{65000, 100, true, -636},
+		{0, 32767, true, -32767},
+		{32767, 0, false, 32767},
+		// half the space apart
+		{0, 32768, false, -32768},
+		{32768, 0, false, -32768},
+	}
+
+	for i, v := range testdata {
+		test.EXPECT_EQ(t, SeqLess(v.a, v.b), v.less, "[%d]", i)
+		test.EXPECT_EQ(t, SeqDiff(v.a, v.b), v.diff, "[%d]", i)
+	}
+}
+
+func TestTimestampLess(t *testing.T) {
+	testdata := []struct {
+		a    uint32
+		b    uint32
+		less bool
+		diff int64
+	}{
+		{0, 160, true, -160},
+		{160, 0, false, 160},
+		{0xFFFFFF00, 0x40, true, -0x140},
+		{0x40, 0xFFFFFF00, false, 0x140},
+		{0, 0x80000000, false, -0x80000000},
+		{0x80000000, 0, false, -0x80000000},
+	}
+
+	for i, v := range testdata {
+		test.EXPECT_EQ(t, TimestampLess(v.a, v.b), v.less, "[%d]", i)
+		test.EXPECT_EQ(t, TimestampDiff(v.a, v.b), v.diff, "[%d]", i)
+	}
+}
+
+func TestSequenceUnwrapper(t *testing.T) {
+	testdata := []struct {
+		seqs     []uint16
+		extended []int64
+		highest  int64
+	}{
+		{[]uint16{1, 2, 3}, []int64{1, 2, 3}, 3},
+		// wrap around
+		{[]uint16{65534, 65535, 0, 1}, []int64{65534, 65535, 65536, 65537}, 65537},
+		// reordered across the wrap around
+		{[]uint16{65535, 1, 0, 65534, 2}, []int64{65535, 65537, 65536, 65534, 65538}, 65538},
+		// preceding the first packet across the wrap around
+		{[]uint16{0, 65535, 1}, []int64{0, -1, 1}, 1},
+		// several cycles
+		{[]uint16{0, 30000, 60000, 24464, 54464, 18928}, []int64{0, 30000, 60000, 90000, 120000, 150000}, 150000},
+	}
+
+	for i, v := range testdata {
+		v := v
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			t.Parallel()
+
+			unwrapper := &SequenceUnwrapper{}
+			_, ok := unwrapper.GetHighest()
+			test.EXPECT_EQ(t, ok, false, "")
+
+			for j, seq := range v.seqs {
+				test.EXPECT_EQ(t, unwrapper.Peek(seq), v.extended[j], "packet %d", j)
+				test.EXPECT_EQ(t, unwrapper.Unwrap(seq), v.extended[j], "packet %d", j)
+			}
+			highest, ok := unwrapper.GetHighest()
+			test.EXPECT_EQ(t, ok, true, "")
+			test.EXPECT_EQ(t, highest, v.highest, "")
+
+			unwrapper.Reset()
+			test.EXPECT_EQ(t, unwrapper.Unwrap(100), int64(100), "")
+		})
+	}
+}
+
+func TestTimestampUnwrapper(t *testing.T) {
+	testdata := []struct {
+		timestamps []uint32
+		extended   []int64
+	}{
+		{[]uint32{0, 160, 320}, []int64{0, 160, 320}},
+		// wrap around
+		{[]uint32{0xFFFFFF00, 0xFFFFFFA0, 0x40}, []int64{0xFFFFFF00, 0xFFFFFFA0, 0x100000040}},
+		// reordered across the wrap around
+		{[]uint32{0xFFFFFFA0, 0x40, 0xFFFFFF00, 0xE0}, []int64{0xFFFFFFA0, 0x100000040, 0xFFFFFF00, 0x1000000E0}},
+		// preceding the first packet across the wrap around
+		{[]uint32{0x40, 0xFFFFFFA0}, []int64{0x40, -0x60}},
+	}
+
+	for i, v := range testdata {
+		v := v
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			t.Parallel()
+
+			unwrapper := &TimestampUnwrapper{}
+			for j, timestamp := range v.timestamps {
+				test.EXPECT_EQ(t, unwrapper.Unwrap(timestamp), v.extended[j], "packet %d", j)
+			}
+		})
+	}
+}
@@ -0,0 +1,376 @@
+package rtp
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"sort"
+	"time"
+
+	"rtcp"
+)
+
+// timeouts of RFC3550 section 6.3.5 in multiples of the report interval
+const (
+	RTP_SENDER_TIMEOUT_INTERVALS   = 2
+	RTP_MEMBER_TIMEOUT_INTERVALS   = 5
+	RTP_CONFLICT_TIMEOUT_INTERVALS = 10
+)
+
+// RTP_BYE_TIMEOUT_INTERVALS is how long a member that sent BYE is kept, so
+// that its reordered packets do not re-create it (RFC3550 section 6.3.4)
+const RTP_BYE_TIMEOUT_INTERVALS = 1
+
+var (
+	ErrThirdPartyCollision = errors.New("rtp: ssrc collision between other participants")
+	ErrThirdPartyLoop      = errors.New("rtp: loop of another participant's packets")
+	ErrLocalSsrcCollision  = errors.New("rtp: ssrc collision with the local source")
+	ErrOwnTrafficLooped    = errors.New("rtp: own packets looped back")
+	ErrSourceLeft          = errors.New("rtp: packet of a source that sent BYE")
+)
+
+// SessionMember is one entry of the source identifier table. The transport
+// addresses are those the first data and control packets came from.
+type SessionMember struct {
+	Ssrc  uint32
+	Cname string
+	// Sender is set while the member sends rtp packets
+	Sender   bool
+	RtpAddr  string
+	RtcpAddr string
+	Stats    *ReceiverStats
+
+	LastRtpTime  time.Time
+	LastRtcpTime time.Time
+	// ByeTime is when the member sent BYE, zero while it has not
+	ByeTime time.Time
+}
+
+// Left reports whether the member has sent BYE. It stays in the table until
+// a later Timeout but is no longer counted.
+func (this *SessionMember) Left() bool {
+	return !this.ByeTime.IsZero()
+}
+
+func (this *SessionMember) lastActivity() time.Time {
+	if this.LastRtcpTime.After(this.LastRtpTime) {
+		return this.LastRtcpTime
+	}
+	return this.LastRtpTime
+}
+
+// Session is a participant in a rtp session: it owns the local SSRC and keeps
+// the table of the other members. It has no clock and no socket, every event
+// is passed with its time and the transport address, as "host:port", it came
+// from.
+type Session struct {
+	Cname  string
+	Sender *SenderStats
+	// payload types of the session, shared with the receiver statistics
+	Payloads *PayloadRegistry
+	// NewSsrc chooses a random SSRC, it may be replaced by tests
+	NewSsrc func() uint32
+
+	localSsrc   uint32
+	members     map[uint32]*SessionMember
+	conflicts   map[string]time.Time
+	pendingByes []uint32
+}
+
+func randomSsrc() uint32 {
+	buf := make([]byte, 4)
+	rand.Read(buf)
+	return binary.BigEndian.Uint32(buf)
+}
+
+// NewSession creates a session whose local source sends with clockRate.
+func NewSession(cname string, clockRate uint32) *Session {
+	session := &Session{
+		Cname:     cname,
+		Payloads:  NewPayloadRegistry(),
+		NewSsrc:   randomSsrc,
+		members:   make(map[uint32]*SessionMember),
+		conflicts: make(map[string]time.Time),
+	}
+	session.localSsrc = session.chooseSsrc()
+	session.Sender = NewSenderStats(session.localSsrc, clockRate)
+	return session
+}
+
+func (this *Session) chooseSsrc() uint32 {
+	for {
+		ssrc := this.NewSsrc()
+		if _, ok := this.members[ssrc]; !ok && ssrc != this.localSsrc {
+			return ssrc
+		}
+	}
+}
+
+func (this *Session) GetLocalSsrc() uint32 {
+	return this.localSsrc
+}
+
+// SetLocalSsrc replaces the local SSRC, as used when it is signalled.
+func (this *Session) SetLocalSsrc(ssrc uint32) {
+	this.localSsrc = ssrc
+	this.Sender.Reset(ssrc)
+}
+
+// GetMember returns nil for an unknown member or one that has left.
+func (this *Session) GetMember(ssrc uint32) *SessionMember {
+	member, ok := this.members[ssrc]
+	if !ok || member.Left() {
+		return nil
+	}
+	return member
+}
+
+// GetMembers returns the other members ordered by SSRC.
+func (this *Session) GetMembers() []*SessionMember {
+	members := make([]*SessionMember, 0, len(this.members))
+	for _, v := range this.members {
+		if !v.Left() {
+			members = append(members, v)
+		}
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].Ssrc < members[j].Ssrc })
+	return members
+}
+
+// MemberCount includes the local participant.
+func (this *Session) MemberCount() int {
+	count := 1
+	for _, v := range this.members {
+		if !v.Left() {
+			count++
+		}
+	}
+	return count
+}
+
+// SenderCount includes the local participant when it has sent.
+func (this *Session) SenderCount() int {
+	count := 0
+	if this.Sender.HasSent() {
+		count++
+	}
+	for _, v := range this.members {
+		if v.Sender && !v.Left() {
+			count++
+		}
+	}
+	return count
+}
+
+// GetMembersByCname returns the members bound to cname, ordered by SSRC. The
+// streams of one participant share its CNAME.
+func (this *Session) GetMembersByCname(cname string) []*SessionMember {
+	var members []*SessionMember
+	for _, v := range this.GetMembers() {
+		if v.Cname == cname {
+			members = append(members, v)
+		}
+	}
+	return members
+}
+
+func (this *Session) newMember(ssrc uint32) *SessionMember {
+	stats := NewReceiverStats(ssrc)
+	stats.Payloads = this.Payloads
+	member := &SessionMember{Ssrc: ssrc, Stats: stats}
+	this.members[ssrc] = member
+	return member
+}
+
+// checkSource is the collision and loop detection of RFC3550 section 8.2 for
+// a packet or SDES chunk of ssrc from addr. cname is the CNAME carried with
+// the identifier, empty when there is none. It returns the member to update.
+func (this *Session) checkSource(ssrc uint32, addr string, control bool, cname string, now time.Time) (*SessionMember, error) {
+	if ssrc != this.localSsrc {
+		member, ok := this.members[ssrc]
+		if !ok {
+			member = this.newMember(ssrc)
+		}
+		if member.Left() {
+			return nil, ErrSourceLeft
+		}
+
+		saved := &member.RtpAddr
+		if control {
+			saved = &member.RtcpAddr
+		}
+		if *saved == "" {
+			*saved = addr
+			return member, nil
+		}
+		if *saved == addr {
+			return member, nil
+		}
+		if cname != "" && member.Cname != "" && cname != member.Cname {
+			return nil, ErrThirdPartyCollision
+		}
+		return nil, ErrThirdPartyLoop
+	}
+
+	// a collision or loop of the local source, an address already in the
+	// conflict list only has its time refreshed and the packet is dropped
+	if _, ok := this.conflicts[addr]; ok {
+		this.conflicts[addr] = now
+		if cname == "" || cname == this.Cname {
+			return nil, ErrOwnTrafficLooped
+		}
+		return nil, ErrThirdPartyLoop
+	}
+
+	this.conflicts[addr] = now
+	this.pendingByes = append(this.pendingByes, this.localSsrc)
+	this.SetLocalSsrc(this.chooseSsrc())
+
+	member := this.newMember(ssrc)
+	if control {
+		member.RtcpAddr = addr
+	} else {
+		member.RtpAddr = addr
+	}
+	return nil, ErrLocalSsrcCollision
+}
+
+// OnRtpPacket accounts a received rtp packet. An error means the packet has
+// to be dropped, after ErrLocalSsrcCollision the local SSRC has changed.
+func (this *Session) OnRtpPacket(packet *RtpPacket, addr string, now time.Time) (*SessionMember, error) {
+	member, err := this.checkSource(packet.GetSsrc(), addr, false, "", now)
+	if err != nil {
+		return nil, err
+	}
+
+	// contributing sources are members too, without a transport address
+	for _, v := range packet.GetCsrc() {
+		if v == this.localSsrc {
+			continue
+		}
+		contributor, ok := this.members[v]
+		if !ok {
+			contributor = this.newMember(v)
+		}
+		if !contributor.Left() {
+			contributor.LastRtpTime = now
+		}
+	}
+
+	if member.Stats.Update(packet, now) {
+		member.Sender = true
+	}
+	member.LastRtpTime = now
+	return member, nil
+}
+
+// OnRtcpPackets accounts the packets of a received compound rtcp packet.
+// Packets that fail the collision and loop detection are skipped and the
+// first such error is returned.
+func (this *Session) OnRtcpPackets(packets []rtcp.RtcpPacket, addr string, now time.Time) error {
+	var firstErr error
+	check := func(ssrc uint32, cname string) *SessionMember {
+		member, err := this.checkSource(ssrc, addr, true, cname, now)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			return nil
+		}
+		member.LastRtcpTime = now
+		return member
+	}
+
+	for _, packet := range packets {
+		switch v := packet.(type) {
+		case *rtcp.RtcpSenderReport:
+			if member := check(v.Ssrc, ""); member != nil {
+				member.Stats.UpdateSenderReport(v, now)
+			}
+		case *rtcp.RtcpReceiverReport:
+			check(v.Ssrc, "")
+		case *rtcp.RtcpSdes:
+			for i := range v.Chunks {
+				cname, _ := v.Chunks[i].GetItem(rtcp.RTCP_SDES_CNAME)
+				if member := check(v.Chunks[i].Ssrc, cname); member != nil && cname != "" {
+					member.Cname = cname
+				}
+			}
+		case *rtcp.RtcpBye:
+			for _, ssrc := range v.Sources {
+				if ssrc == this.localSsrc {
+					continue
+				}
+				member, ok := this.members[ssrc]
+				if ok && !member.Left() && (member.RtcpAddr == "" || member.RtcpAddr == addr) {
+					member.ByeTime = now
+					member.Sender = false
+				}
+			}
+		}
+	}
+	return firstErr
+}
+
+// Timeout applies the timeouts of RFC3550 section 6.3.5 with the
+// deterministic report interval and returns the SSRCs removed, including the
+// members that sent BYE.
+func (this *Session) Timeout(now time.Time, interval time.Duration) []uint32 {
+	var removed []uint32
+
+	for ssrc, v := range this.members {
+		if v.Left() {
+			if now.Sub(v.ByeTime) > RTP_BYE_TIMEOUT_INTERVALS*interval {
+				delete(this.members, ssrc)
+				removed = append(removed, ssrc)
+			}
+			continue
+		}
+		if v.Sender && now.Sub(v.LastRtpTime) > RTP_SENDER_TIMEOUT_INTERVALS*interval {
+			v.Sender = false
+		}
+		if now.Sub(v.lastActivity()) > RTP_MEMBER_TIMEOUT_INTERVALS*interval {
+			delete(this.members, ssrc)
+			removed = append(removed, ssrc)
+		}
+	}
+	sort.Slice(removed, func(i, j int) bool { return removed[i] < removed[j] })
+
+	for addr, v := range this.conflicts {
+		if now.Sub(v) > RTP_CONFLICT_TIMEOUT_INTERVALS*interval {
+			delete(this.conflicts, addr)
+		}
+	}
+	return removed
+}
+
+// OnRtpSent stamps packet with the local SSRC and accounts it as sent.
+func (this *Session) OnRtpSent(packet *RtpPacket, now time.Time) {
+	packet.SetSsrc(this.localSsrc)
+	this.Sender.Update(packet, now)
+}
+
+// ReportBlocks returns the reception report blocks of the valid senders.
+func (this *Session) ReportBlocks(now time.Time) []rtcp.RtcpReportBlock {
+	var blocks []rtcp.RtcpReportBlock
+	for _, v := range this.GetMembers() {
+		if !v.Sender {
+			continue
+		}
+		if block, ok := v.Stats.ReportBlock(now); ok {
+			blocks = append(blocks, block)
+		}
+	}
+	return blocks
+}
+
+// ByePacket returns the BYE for the pending SSRCs left because of a
+// collision, or nil when there is none.
+func (this *Session) ByePacket() *rtcp.RtcpBye {
+	if len(this.pendingByes) == 0 {
+		return nil
+	}
+	bye := &rtcp.RtcpBye{Sources: this.pendingByes}
+	this.pendingByes = nil
+	return bye
+}
@@ -0,0 +1,188 @@
+package rtp
+
+import (
+	"testing"
+	"time"
+
+	"rtcp"
+
+	"github.com/lioneagle/goutil/src/test"
+)
+
+func newTestSession(ssrcs ...uint32) *Session {
+	session := NewSession("local@example.com", 8000)
+	session.NewSsrc = func() uint32 {
+		ssrc := ssrcs[0]
+		ssrcs = ssrcs[1:]
+		return ssrc
+	}
+	session.SetLocalSsrc(session.NewSsrc())
+	return session
+}
+
+func newTestSessionPacket(ssrc uint32, seq uint16) *RtpPacket {
+	packet := newTestRtpPacket(0, seq, uint32(seq)*160)
+	packet.SetSsrc(ssrc)
+	return packet
+}
+
+func TestSessionMembers(t *testing.T) {
+	session := newTestSession(100)
+	now := time.Unix(1000, 0)
+
+	test.EXPECT_EQ(t, session.GetLocalSsrc(), uint32(100), "")
+	test.EXPECT_EQ(t, session.MemberCount(), 1, "")
+	test.EXPECT_EQ(t, session.SenderCount(), 0, "")
+
+	for seq := uint16(0); seq < 3; seq++ {
+		_, err := session.OnRtpPacket(newTestSessionPacket(1, seq), "10.0.0.1:5000", now)
+		test.EXPECT_EQ(t, err, nil, "")
+	}
+	err := session.OnRtcpPackets([]rtcp.RtcpPacket{
+		&rtcp.RtcpReceiverReport{Ssrc: 2},
+		rtcp.NewRtcpSdesCname(2, "bob@example.com"),
+		rtcp.NewRtcpSdesCname(1, "alice@example.com"),
+	}, "10.0.0.2:5001", now)
+	test.EXPECT_EQ(t, err, nil, "")
+
+	test.EXPECT_EQ(t, session.MemberCount(), 3, "")
+	test.EXPECT_EQ(t, session.SenderCount(), 1, "")
+	member := session.GetMember(1)
+	test.EXPECT_EQ(t, member.RtpAddr, "10.0.0.1:5000", "")
+	test.EXPECT_EQ(t, member.RtcpAddr, "10.0.0.2:5001", "")
+	test.EXPECT_EQ(t, member.Cname, "alice@example.com", "")
+	test.EXPECT_EQ(t, len(session.GetMembersByCname("bob@example.com")), 1, "")
+
+	blocks := session.ReportBlocks(now)
+	test.EXPECT_EQ(t, len(blocks), 1, "")
+	test.EXPECT_EQ(t, blocks[0].Ssrc, uint32(1), "")
+
+	packet := newTestSessionPacket(0, 0)
+	session.OnRtpSent(packet, now)
+	test.EXPECT_EQ(t, packet.GetSsrc(), uint32(100), "")
+	test.EXPECT_EQ(t, session.SenderCount(), 2, "")
+
+	// BYE from the wrong address is ignored
+	session.OnRtcpPackets([]rtcp.RtcpPacket{&rtcp.RtcpBye{Sources: []uint32{1}}}, "10.0.0.9:5001", now)
+	test.EXPECT_EQ(t, session.MemberCount(), 3, "")
+	session.OnRtcpPackets([]rtcp.RtcpPacket{&rtcp.RtcpBye{Sources: []uint32{1, 100}}}, "10.0.0.2:5001", now)
+	test.EXPECT_EQ(t, session.MemberCount(), 2, "")
+	test.EXPECT_EQ(t, session.GetMember(1) == nil, true, "")
+}
+
+func TestSessionTimeout(t *testing.T) {
+	session := newTestSession(100)
+	base := time.Unix(1000, 0)
+	interval := 5 * time.Second
+
+	session.OnRtpPacket(newTestSessionPacket(1, 0), "10.0.0.1:5000", base)
+	session.OnRtpPacket(newTestSessionPacket(1, 1), "10.0.0.1:5000", base)
+	session.OnRtcpPackets([]rtcp.RtcpPacket{&rtcp.RtcpReceiverReport{Ssrc: 1}}, "10.0.0.1:5001", base.Add(15*time.Second))
+	session.OnRtcpPackets([]rtcp.RtcpPacket{&rtcp.RtcpReceiverReport{Ssrc: 2}}, "10.0.0.2:5001", base)
+
+	test.EXPECT_EQ(t, session.Timeout(base.Add(11*time.Second), interval), []uint32(nil), "")
+	test.EXPECT_EQ(t, session.GetMember(1).Sender, false, "")
+
+	test.EXPECT_EQ(t, session.Timeout(base.Add(26*time.Second), interval), []uint32{2}, "")
+	test.EXPECT_EQ(t, session.Timeout(base.Add(41*time.Second), interval), []uint32{1}, "")
+	test.EXPECT_EQ(t, session.MemberCount(), 1, "")
+}
+
+func TestSessionCsrcTimeout(t *testing.T) {
+	session := newTestSession(100)
+	base := time.Unix(1000, 0)
+	interval := 5 * time.Second
+
+	// the mixer keeps listing 2, which sends no packets of its own
+	for i := 0; i < 10; i++ {
+		now := base.Add(time.Duration(i) * 5 * time.Second)
+		packet := NewRtpPacket()
+		header := &Header{Version: 2, Sequence: uint16(i), Ssrc: 1, Csrc: []uint32{2}}
+		test.EXPECT_EQ(t, packet.SetHeader(header, []byte{1}), nil, "[%d]", i)
+		_, err := session.OnRtpPacket(packet, "10.0.0.1:5000", now)
+		test.EXPECT_EQ(t, err, nil, "[%d]", i)
+		test.EXPECT_EQ(t, session.Timeout(now, interval), []uint32(nil), "[%d]", i)
+		test.EXPECT_EQ(t, session.MemberCount(), 3, "[%d]", i)
+	}
+	test.EXPECT_EQ(t, session.GetMember(2).LastRtpTime, base.Add(45*time.Second), "")
+}
+
+func TestSessionByeDelay(t *testing.T) {
+	session := newTestSession(100)
+	base := time.Unix(1000, 0)
+	interval := 5 * time.Second
+
+	session.OnRtpPacket(newTestSessionPacket(1, 0), "10.0.0.1:5000", base)
+	session.OnRtcpPackets([]rtcp.RtcpPacket{&rtcp.RtcpBye{Sources: []uint32{1}}}, "10.0.0.1:5001", base)
+	test.EXPECT_EQ(t, session.MemberCount(), 1, "")
+	test.EXPECT_EQ(t, session.SenderCount(), 0, "")
+
+	// packets reordered behind the BYE do not bring the member back
+	_, err := session.OnRtpPacket(newTestSessionPacket(1, 1), "10.0.0.1:5000", base.Add(time.Second))
+	test.EXPECT_EQ(t, err, ErrSourceLeft, "")
+	err = session.OnRtcpPackets([]rtcp.RtcpPacket{&rtcp.RtcpReceiverReport{Ssrc: 1}}, "10.0.0.1:5001", base.Add(time.Second))
+	test.EXPECT_EQ(t, err, ErrSourceLeft, "")
+	test.EXPECT_EQ(t, session.GetMember(1) == nil, true, "")
+	test.EXPECT_EQ(t, session.MemberCount(), 1, "")
+
+	test.EXPECT_EQ(t, session.Timeout(base.Add(interval), interval), []uint32(nil), "")
+	test.EXPECT_EQ(t, session.Timeout(base.Add(interval+time.Second), interval), []uint32{1}, "")
+
+	// after the removal the SSRC is a new member again
+	_, err = session.OnRtpPacket(newTestSessionPacket(1, 2), "10.0.0.1:5000", base.Add(7*time.Second))
+	test.EXPECT_EQ(t, err, nil, "")
+	test.EXPECT_EQ(t, session.MemberCount(), 2, "")
+}
+
+func TestSessionThirdPartyCollision(t *testing.T) {
+	session := newTestSession(100)
+	now := time.Unix(1000, 0)
+
+	session.OnRtcpPackets([]rtcp.RtcpPacket{rtcp.NewRtcpSdesCname(1, "alice@example.com")}, "10.0.0.1:5001", now)
+
+	err := session.OnRtcpPackets([]rtcp.RtcpPacket{rtcp.NewRtcpSdesCname(1, "bob@example.com")}, "10.0.0.2:5001", now)
+	test.EXPECT_EQ(t, err, ErrThirdPartyCollision, "")
+
+	err = session.OnRtcpPackets([]rtcp.RtcpPacket{&rtcp.RtcpReceiverReport{Ssrc: 1}}, "10.0.0.3:5001", now)
+	test.EXPECT_EQ(t, err, ErrThirdPartyLoop, "")
+	test.EXPECT_EQ(t, session.GetMember(1).Cname, "alice@example.com", "")
+
+	// the first data packet stores the data address
+	_, err = session.OnRtpPacket(newTestSessionPacket(1, 0), "10.0.0.1:5000", now)
+	test.EXPECT_EQ(t, err, nil, "")
+	_, err = session.OnRtpPacket(newTestSessionPacket(1, 1), "10.0.0.4:5000", now)
+	test.EXPECT_EQ(t, err, ErrThirdPartyLoop, "")
+}
+
+func TestSessionLocalCollision(t *testing.T) {
+	session := newTestSession(100, 100, 1, 200)
+	now := time.Unix(1000, 0)
+
+	session.OnRtpPacket(newTestSessionPacket(1, 0), "10.0.0.1:5000", now)
+	session.OnRtpSent(newTestSessionPacket(0, 0), now)
+
+	_, err := session.OnRtpPacket(newTestSessionPacket(100, 0), "10.0.0.2:5000", now)
+	test.EXPECT_EQ(t, err, ErrLocalSsrcCollision, "")
+	// 100 is in use and 1 is a member, so 200 is chosen
+	test.EXPECT_EQ(t, session.GetLocalSsrc(), uint32(200), "")
+	test.EXPECT_EQ(t, session.Sender.HasSent(), false, "")
+	test.EXPECT_EQ(t, session.GetMember(100).RtpAddr, "10.0.0.2:5000", "")
+	test.EXPECT_EQ(t, session.ByePacket(), &rtcp.RtcpBye{Sources: []uint32{100}}, "")
+	test.EXPECT_EQ(t, session.ByePacket() == nil, true, "")
+
+	// the new SSRC arriving from a conflicting address is our own loop
+	_, err = session.OnRtpPacket(newTestSessionPacket(200, 1), "10.0.0.2:5000", now)
+	test.EXPECT_EQ(t, err, ErrOwnTrafficLooped, "")
+	// a foreign CNAME from a conflicting address keeps the local SSRC
+	err = session.OnRtcpPackets([]rtcp.RtcpPacket{rtcp.NewRtcpSdesCname(200, "other@example.com")}, "10.0.0.2:5000", now)
+	test.EXPECT_EQ(t, err, ErrThirdPartyLoop, "")
+	test.EXPECT_EQ(t, session.GetLocalSsrc(), uint32(200), "")
+	test.EXPECT_EQ(t, session.ByePacket() == nil, true, "")
+
+	// the conflict entry expires after 10 intervals
+	session.Timeout(now.Add(11*time.Second), time.Second)
+	session.NewSsrc = func() uint32 { return 300 }
+	_, err = session.OnRtpPacket(newTestSessionPacket(200, 2), "10.0.0.2:5000", now.Add(11*time.Second))
+	test.EXPECT_EQ(t, err, ErrLocalSsrcCollision, "")
+	test.EXPECT_EQ(t, session.GetLocalSsrc(), uint32(300), "")
+}
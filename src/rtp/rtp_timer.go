@@ -0,0 +1,22 @@
+package rtp
+
+import (
+	"time"
+
+	"github.com/lioneagle/goutil/src/algorithm/timewheel"
+)
+
+// rtpTimer is a component driven by the current time instead of a clock of
+// its own.
+type rtpTimer interface {
+	OnTimer(now time.Time)
+}
+
+// attachTimeWheel calls the OnTimer of timer with the time of clock every
+// tick of tw.
+func attachTimeWheel(tw *timewheel.TimeWheel, tick time.Duration, clock func() time.Time, timer rtpTimer) bool {
+	_, ok := tw.AddCycle(int64(tick), timer, func(data interface{}) {
+		data.(rtpTimer).OnTimer(clock())
+	})
+	return ok
+}
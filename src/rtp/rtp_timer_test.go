@@ -0,0 +1,58 @@
+package rtp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lioneagle/goutil/src/algorithm/timewheel"
+	"github.com/lioneagle/goutil/src/test"
+)
+
+// testTimeWheel is a timewheel stepped by a fake clock.
+type testTimeWheel struct {
+	base time.Time
+	now  time.Time
+	tw   *timewheel.TimeWheel
+}
+
+func newTestTimeWheel() *testTimeWheel {
+	this := &testTimeWheel{base: time.Unix(1000, 0)}
+	this.now = this.base
+	this.tw = timewheel.NewTimeWheel(3, []int{10000, 600, 600}, int64(time.Millisecond), this.base.UnixNano(), 1000)
+	return this
+}
+
+func (this *testTimeWheel) clock() time.Time {
+	return this.now
+}
+
+// elapsed returns the fake time since base.
+func (this *testTimeWheel) elapsed() time.Duration {
+	return this.now.Sub(this.base)
+}
+
+// runUntil steps the clock by tick up to offset from base.
+func (this *testTimeWheel) runUntil(offset, tick time.Duration) {
+	for end := this.base.Add(offset); this.now.Before(end); {
+		this.now = this.now.Add(tick)
+		this.tw.Step(this.now.UnixNano())
+	}
+}
+
+type testTimer struct {
+	times []time.Duration
+	wheel *testTimeWheel
+}
+
+func (this *testTimer) OnTimer(now time.Time) {
+	this.times = append(this.times, now.Sub(this.wheel.base))
+}
+
+func TestAttachTimeWheel(t *testing.T) {
+	wheel := newTestTimeWheel()
+	timer := &testTimer{wheel: wheel}
+	test.EXPECT_EQ(t, attachTimeWheel(wheel.tw, 20*time.Millisecond, wheel.clock, timer), true, "")
+
+	wheel.runUntil(70*time.Millisecond, 10*time.Millisecond)
+	test.EXPECT_EQ(t, timer.times, []time.Duration{20 * time.Millisecond, 40 * time.Millisecond, 60 * time.Millisecond}, "")
+}
@@ -0,0 +1,185 @@
+package rtp
+
+import (
+	"time"
+
+	"rtcp"
+)
+
+// minimum number of received packets between two losses for the losses to
+// belong to different bursts, RFC3611 section 4.7.2
+const RTP_VOIP_METRICS_DEFAULT_GMIN = 16
+
+// RtpVoipMetrics computes the loss, discard and burst metrics of the VoIP
+// metrics block over the packets received from one source, with the Markov
+// model of RFC3611 appendix A.2. Losses are found from gaps in the sequence
+// numbers; late and duplicate packets are ignored.
+type RtpVoipMetrics struct {
+	Ssrc uint32
+	// media duration of one packet, used for the burst and gap durations
+	PacketDuration time.Duration
+	Gmin           int
+	RoundTripDelay time.Duration
+	EndSystemDelay time.Duration
+
+	started      bool
+	maxSequence  int64
+	lossCount    int
+	discardCount int
+
+	// state and transition counts of the Markov model
+	pkt  int
+	lost int
+	c11  int
+	c13  int
+	c14  int
+	c22  int
+	c23  int
+	c33  int
+}
+
+func NewRtpVoipMetrics(ssrc uint32, packetDuration time.Duration) *RtpVoipMetrics {
+	return &RtpVoipMetrics{Ssrc: ssrc, PacketDuration: packetDuration, Gmin: RTP_VOIP_METRICS_DEFAULT_GMIN}
+}
+
+// Update counts a received packet and the packets lost before it, discarded
+// tells that the packet arrived but was dropped by the jitter buffer.
+func (this *RtpVoipMetrics) Update(packet *RtpPacket, discarded bool) {
+	seq := packet.GetSequence()
+	if !this.started {
+		this.started = true
+		this.maxSequence = int64(seq)
+		this.update(false, discarded)
+		return
+	}
+
+	extended := this.maxSequence + int64(SeqDiff(seq, uint16(this.maxSequence)))
+	if extended <= this.maxSequence {
+		return
+	}
+	for i := this.maxSequence + 1; i < extended; i++ {
+		this.update(true, false)
+	}
+	this.update(false, discarded)
+	this.maxSequence = extended
+}
+
+func (this *RtpVoipMetrics) update(lost, discarded bool) {
+	if lost {
+		this.lossCount++
+	}
+	if discarded {
+		this.discardCount++
+	}
+	if !lost && !discarded {
+		this.pkt++
+		return
+	}
+
+	if this.pkt >= this.Gmin {
+		if this.lost == 1 {
+			this.c14++
+		} else {
+			this.c13++
+		}
+		this.lost = 1
+		this.c11 += this.pkt
+	} else {
+		this.lost++
+		if this.pkt == 0 {
+			this.c33++
+		} else {
+			this.c23++
+			this.c22 += this.pkt - 1
+		}
+	}
+	this.pkt = 0
+}
+
+// BuildVoipMetrics returns the VoIP metrics block for the packets seen so
+// far, with R factor and MOS rated by model.
+func (this *RtpVoipMetrics) BuildVoipMetrics(model *rtcp.RtcpEModel) *rtcp.RtcpXrVoipMetrics {
+	block := &rtcp.RtcpXrVoipMetrics{
+		Ssrc:           this.Ssrc,
+		RoundTripDelay: durationToMilliseconds(this.RoundTripDelay),
+		EndSystemDelay: durationToMilliseconds(this.EndSystemDelay),
+		SignalLevel:    rtcp.RTCP_XR_UNAVAILABLE,
+		NoiseLevel:     rtcp.RTCP_XR_UNAVAILABLE,
+		Rerl:           rtcp.RTCP_XR_UNAVAILABLE,
+		Gmin:           byte(this.Gmin),
+	}
+
+	// the packets received since the last loss are still open
+	c11, c22 := this.c11, this.c22
+	if this.pkt >= this.Gmin || this.lossCount+this.discardCount == 0 {
+		c11 += this.pkt
+	} else {
+		c22 += this.pkt
+	}
+	c13, c14, c23, c33 := this.c13, this.c14, this.c23, this.c33
+	c31, c32 := c13, c23
+
+	total := c11 + c14 + c13 + c22 + c23 + c31 + c32 + c33
+	if total == 0 {
+		block.SetQuality(model)
+		return block
+	}
+
+	block.LossRate = scaleTo256(float64(this.lossCount) / float64(total))
+	block.DiscardRate = scaleTo256(float64(this.discardCount) / float64(total))
+
+	m := float64(this.PacketDuration) / float64(time.Millisecond)
+	if this.lossCount+this.discardCount == 0 {
+		block.GapDuration = clampUint16(float64(total) * m)
+		block.SetQuality(model)
+		return block
+	}
+
+	p32 := 0.0
+	if c31+c32+c33 > 0 {
+		p32 = float64(c32) / float64(c31+c32+c33)
+	}
+	p23 := 1.0
+	if c22+c23 > 0 {
+		p23 = 1 - float64(c22)/float64(c22+c23)
+	}
+	block.BurstDensity = scaleTo256(p23 / (p23 + p32))
+	if c11+c14 > 0 {
+		block.GapDensity = scaleTo256(float64(c14) / float64(c11+c14))
+	}
+
+	if c13 > 0 {
+		gap := float64(c11+c14+c13) * m / float64(c13)
+		block.GapDuration = clampUint16(gap)
+		block.BurstDuration = clampUint16(float64(total)*m/float64(c13) - gap)
+	} else {
+		block.GapDuration = clampUint16(float64(c11+c14) * m)
+		block.BurstDuration = clampUint16(float64(total-c11-c14) * m)
+	}
+
+	block.SetQuality(model)
+	return block
+}
+
+// scaleTo256 converts a fraction to the 1/256 units of the metrics block.
+func scaleTo256(val float64) byte {
+	val *= 256
+	if val >= 255 {
+		return 255
+	}
+	return byte(val)
+}
+
+func clampUint16(val float64) uint16 {
+	if val >= 0xFFFF {
+		return 0xFFFF
+	}
+	if val <= 0 {
+		return 0
+	}
+	return uint16(val + 0.5)
+}
+
+func durationToMilliseconds(d time.Duration) uint16 {
+	return clampUint16(float64(d) / float64(time.Millisecond))
+}
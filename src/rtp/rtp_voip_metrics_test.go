@@ -0,0 +1,72 @@
+package rtp
+
+import (
+	"testing"
+	"time"
+
+	"rtcp"
+
+	"github.com/lioneagle/goutil/src/test"
+)
+
+func TestRtpVoipMetrics(t *testing.T) {
+	metrics := NewRtpVoipMetrics(0x1234, 20*time.Millisecond)
+	metrics.RoundTripDelay = 100 * time.Millisecond
+	metrics.EndSystemDelay = 40 * time.Millisecond
+
+	packet := NewRtpPacket()
+	packet.Alloc(packet.CalcLen(0, 0, 0))
+	packet.SetVersion(RTP_VERSION)
+
+	// two isolated losses, a late and a duplicate packet, wrapping around
+	for i := 0; i < 100; i++ {
+		if i == 20 || i == 60 {
+			continue
+		}
+		packet.SetSequence(uint16(65500 + i))
+		metrics.Update(packet, false)
+		if i == 30 {
+			packet.SetSequence(uint16(65500 + 25))
+			metrics.Update(packet, false)
+			packet.SetSequence(uint16(65500 + 19))
+			metrics.Update(packet, false)
+		}
+	}
+
+	block := metrics.BuildVoipMetrics(&rtcp.RtcpEModelG711)
+	test.EXPECT_EQ(t, block, &rtcp.RtcpXrVoipMetrics{
+		Ssrc:           0x1234,
+		LossRate:       5,
+		BurstDensity:   255,
+		GapDensity:     2,
+		BurstDuration:  20,
+		GapDuration:    2000,
+		RoundTripDelay: 100,
+		EndSystemDelay: 40,
+		SignalLevel:    rtcp.RTCP_XR_UNAVAILABLE,
+		NoiseLevel:     rtcp.RTCP_XR_UNAVAILABLE,
+		Rerl:           rtcp.RTCP_XR_UNAVAILABLE,
+		Gmin:           RTP_VOIP_METRICS_DEFAULT_GMIN,
+		RFactor:        87,
+		ExtRFactor:     rtcp.RTCP_XR_UNAVAILABLE,
+		MosLq:          43,
+		MosCq:          43,
+	}, "")
+}
+
+func TestRtpVoipMetricsNoLoss(t *testing.T) {
+	metrics := NewRtpVoipMetrics(1, 20*time.Millisecond)
+	packet := NewRtpPacket()
+	packet.Alloc(packet.CalcLen(0, 0, 0))
+
+	for i := 0; i < 50; i++ {
+		packet.SetSequence(uint16(i))
+		metrics.Update(packet, false)
+	}
+
+	block := metrics.BuildVoipMetrics(&rtcp.RtcpEModelG711)
+	test.EXPECT_EQ(t, block.LossRate, byte(0), "")
+	test.EXPECT_EQ(t, block.BurstDensity, byte(0), "")
+	test.EXPECT_EQ(t, block.GapDuration, uint16(1000), "")
+	test.EXPECT_EQ(t, block.RFactor, byte(93), "")
+}
@@ -0,0 +1,412 @@
+package sdp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	SDP_VERSION = 0
+
+	SDP_NET_TYPE_IN    = "IN"
+	SDP_ADDR_TYPE_IP4  = "IP4"
+	SDP_ADDR_TYPE_IP6  = "IP6"
+	SDP_LINE_SEPARATOR = "\r\n"
+)
+
+var (
+	ErrBadLine     = errors.New("sdp: malformed line")
+	ErrBadVersion  = errors.New("sdp: unsupported version")
+	ErrBadOrder    = errors.New("sdp: line out of order")
+	ErrUnknownType = errors.New("sdp: unknown line type")
+	ErrMissingLine = errors.New("sdp: missing mandatory line")
+)
+
+// ParseError reports the line a session description failed to parse at,
+// Line counting from 1.
+type ParseError struct {
+	Line int
+	Text string
+	Err  error
+}
+
+func (this *ParseError) Error() string {
+	return fmt.Sprintf("%s at line %d: %q", this.Err.Error(), this.Line, this.Text)
+}
+
+// Origin is the "o=" line.
+type Origin struct {
+	Username       string
+	SessionId      uint64
+	SessionVersion uint64
+	NetType        string
+	AddrType       string
+	Address        string
+}
+
+// Connection is the "c=" line. Ttl and Range are 0 when not given, IP6
+// addresses carry no ttl.
+type Connection struct {
+	NetType  string
+	AddrType string
+	Address  string
+	Ttl      int
+	Range    int
+}
+
+// Bandwidth is the "b=" line, Bandwidth in kilobits per second for the
+// CT and AS types.
+type Bandwidth struct {
+	Type      string
+	Bandwidth uint64
+}
+
+// Timing is the "t=" line with the "r=" lines following it kept as they
+// were received.
+type Timing struct {
+	Start   uint64
+	Stop    uint64
+	Repeats []string
+}
+
+// SessionDescription is a session description of RFC8866. Attributes keep
+// their order, unknown ones included, so that a parsed description is
+// written back unchanged.
+type SessionDescription struct {
+	Origin        Origin
+	SessionName   string
+	Information   string
+	Uri           string
+	Emails        []string
+	Phones        []string
+	Connection    *Connection
+	Bandwidths    []Bandwidth
+	Timings       []Timing
+	TimeZones     string
+	EncryptionKey string
+	Attributes    Attributes
+	Media         []*MediaDescription
+}
+
+// NewSessionDescription creates a description with the mandatory lines
+// filled in for an originator at address.
+func NewSessionDescription(sessionId uint64, address string) *SessionDescription {
+	addrType := SDP_ADDR_TYPE_IP4
+	if strings.IndexByte(address, ':') >= 0 {
+		addrType = SDP_ADDR_TYPE_IP6
+	}
+	return &SessionDescription{
+		Origin: Origin{
+			Username:       "-",
+			SessionId:      sessionId,
+			SessionVersion: sessionId,
+			NetType:        SDP_NET_TYPE_IN,
+			AddrType:       addrType,
+			Address:        address,
+		},
+		SessionName: "-",
+		Connection:  &Connection{NetType: SDP_NET_TYPE_IN, AddrType: addrType, Address: address},
+		Timings:     []Timing{{}},
+	}
+}
+
+// session level line types in the order of RFC8866 section 5
+const sdpSessionOrder = "vosiuepcbtrzka"
+
+// media level line types in the order of RFC8866 section 5
+const sdpMediaOrder = "micbka"
+
+// ParseSessionDescription parses data whose lines end with CRLF or LF.
+func ParseSessionDescription(data string) (*SessionDescription, error) {
+	sdp := &SessionDescription{}
+	var media *MediaDescription
+	order := strings.IndexByte(sdpSessionOrder, 'v')
+	seen := ""
+
+	lines := strings.Split(data, "\n")
+	for i, v := range lines {
+		line := strings.TrimSuffix(v, "\r")
+		if line == "" {
+			continue
+		}
+		if len(line) < 2 || line[1] != '=' {
+			return nil, &ParseError{Line: i + 1, Text: line, Err: ErrBadLine}
+		}
+		typ, value := line[0], line[2:]
+
+		var err error
+		if typ == 'm' {
+			media, err = parseMediaLine(value)
+			if err == nil {
+				sdp.Media = append(sdp.Media, media)
+				order = 0
+			}
+		} else if media == nil {
+			pos := strings.IndexByte(sdpSessionOrder, typ)
+			if pos < 0 {
+				err = ErrUnknownType
+			} else if pos < order && !(typ == 't' && seen[len(seen)-1] == 'r') {
+				// a "t=" may follow the "r=" of the previous one
+				err = ErrBadOrder
+			} else if len(seen) == 0 && typ != 'v' {
+				err = ErrMissingLine
+			} else {
+				order = pos
+				err = sdp.parseLine(typ, value)
+			}
+			seen += string(typ)
+		} else {
+			pos := strings.IndexByte(sdpMediaOrder, typ)
+			if pos < 0 {
+				err = ErrUnknownType
+			} else if pos < order {
+				err = ErrBadOrder
+			} else {
+				order = pos
+				err = media.parseLine(typ, value)
+			}
+		}
+
+		if err != nil {
+			return nil, &ParseError{Line: i + 1, Text: line, Err: err}
+		}
+	}
+
+	if strings.IndexByte(seen, 'o') < 0 || strings.IndexByte(seen, 's') < 0 {
+		return nil, &ParseError{Line: len(lines), Err: ErrMissingLine}
+	}
+	return sdp, nil
+}
+
+func (this *SessionDescription) parseLine(typ byte, value string) (err error) {
+	switch typ {
+	case 'v':
+		if value != strconv.Itoa(SDP_VERSION) {
+			return ErrBadVersion
+		}
+	case 'o':
+		this.Origin, err = parseOrigin(value)
+	case 's':
+		this.SessionName = value
+	case 'i':
+		this.Information = value
+	case 'u':
+		this.Uri = value
+	case 'e':
+		this.Emails = append(this.Emails, value)
+	case 'p':
+		this.Phones = append(this.Phones, value)
+	case 'c':
+		this.Connection, err = parseConnection(value)
+	case 'b':
+		var bandwidth Bandwidth
+		bandwidth, err = parseBandwidth(value)
+		this.Bandwidths = append(this.Bandwidths, bandwidth)
+	case 't':
+		var timing Timing
+		timing, err = parseTiming(value)
+		this.Timings = append(this.Timings, timing)
+	case 'r':
+		if len(this.Timings) == 0 {
+			return ErrBadOrder
+		}
+		last := &this.Timings[len(this.Timings)-1]
+		last.Repeats = append(last.Repeats, value)
+	case 'z':
+		this.TimeZones = value
+	case 'k':
+		this.EncryptionKey = value
+	case 'a':
+		this.Attributes.Add(parseAttribute(value))
+	}
+	return err
+}
+
+func parseOrigin(value string) (Origin, error) {
+	fields := strings.Fields(value)
+	if len(fields) != 6 {
+		return Origin{}, ErrBadLine
+	}
+	id, err1 := strconv.ParseUint(fields[1], 10, 64)
+	version, err2 := strconv.ParseUint(fields[2], 10, 64)
+	if err1 != nil || err2 != nil {
+		return Origin{}, ErrBadLine
+	}
+	return Origin{
+		Username:       fields[0],
+		SessionId:      id,
+		SessionVersion: version,
+		NetType:        fields[3],
+		AddrType:       fields[4],
+		Address:        fields[5],
+	}, nil
+}
+
+func parseConnection(value string) (*Connection, error) {
+	fields := strings.Fields(value)
+	if len(fields) != 3 {
+		return nil, ErrBadLine
+	}
+	conn := &Connection{NetType: fields[0], AddrType: fields[1]}
+
+	parts := strings.Split(fields[2], "/")
+	conn.Address = parts[0]
+	numbers := make([]int, len(parts)-1)
+	for i, v := range parts[1:] {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, ErrBadLine
+		}
+		numbers[i] = n
+	}
+
+	switch {
+	case len(numbers) == 0:
+	case conn.AddrType == SDP_ADDR_TYPE_IP6 && len(numbers) == 1:
+		conn.Range = numbers[0]
+	case conn.AddrType != SDP_ADDR_TYPE_IP6 && len(numbers) <= 2:
+		conn.Ttl = numbers[0]
+		if len(numbers) == 2 {
+			conn.Range = numbers[1]
+		}
+	default:
+		return nil, ErrBadLine
+	}
+	return conn, nil
+}
+
+func parseBandwidth(value string) (Bandwidth, error) {
+	pos := strings.IndexByte(value, ':')
+	if pos <= 0 {
+		return Bandwidth{}, ErrBadLine
+	}
+	bandwidth, err := strconv.ParseUint(value[pos+1:], 10, 64)
+	if err != nil {
+		return Bandwidth{}, ErrBadLine
+	}
+	return Bandwidth{Type: value[:pos], Bandwidth: bandwidth}, nil
+}
+
+func parseTiming(value string) (Timing, error) {
+	fields := strings.Fields(value)
+	if len(fields) != 2 {
+		return Timing{}, ErrBadLine
+	}
+	start, err1 := strconv.ParseUint(fields[0], 10, 64)
+	stop, err2 := strconv.ParseUint(fields[1], 10, 64)
+	if err1 != nil || err2 != nil {
+		return Timing{}, ErrBadLine
+	}
+	return Timing{Start: start, Stop: stop}, nil
+}
+
+func (this *Origin) String() string {
+	return fmt.Sprintf("%s %d %d %s %s %s", this.Username, this.SessionId, this.SessionVersion,
+		this.NetType, this.AddrType, this.Address)
+}
+
+func (this *Connection) String() string {
+	s := this.NetType + " " + this.AddrType + " " + this.Address
+	if this.Ttl != 0 {
+		s += "/" + strconv.Itoa(this.Ttl)
+	}
+	if this.Range != 0 {
+		s += "/" + strconv.Itoa(this.Range)
+	}
+	return s
+}
+
+func (this *Bandwidth) String() string {
+	return this.Type + ":" + strconv.FormatUint(this.Bandwidth, 10)
+}
+
+// String writes the description with CRLF line endings. A description
+// without timing gets "t=0 0" as the line is mandatory.
+func (this *SessionDescription) String() string {
+	w := &sdpWriter{}
+	w.line('v', strconv.Itoa(SDP_VERSION))
+	w.line('o', this.Origin.String())
+	if this.SessionName == "" {
+		w.line('s', "-")
+	} else {
+		w.line('s', this.SessionName)
+	}
+	w.optional('i', this.Information)
+	w.optional('u', this.Uri)
+	w.lines('e', this.Emails)
+	w.lines('p', this.Phones)
+	if this.Connection != nil {
+		w.line('c', this.Connection.String())
+	}
+	for i := range this.Bandwidths {
+		w.line('b', this.Bandwidths[i].String())
+	}
+	if len(this.Timings) == 0 {
+		w.line('t', "0 0")
+	}
+	for _, v := range this.Timings {
+		w.line('t', strconv.FormatUint(v.Start, 10)+" "+strconv.FormatUint(v.Stop, 10))
+		w.lines('r', v.Repeats)
+	}
+	w.optional('z', this.TimeZones)
+	w.optional('k', this.EncryptionKey)
+	w.attributes(this.Attributes)
+
+	for _, v := range this.Media {
+		v.write(w)
+	}
+	return w.String()
+}
+
+type sdpWriter struct {
+	bytes.Buffer
+}
+
+func (this *sdpWriter) line(typ byte, value string) {
+	this.WriteByte(typ)
+	this.WriteByte('=')
+	this.WriteString(value)
+	this.WriteString(SDP_LINE_SEPARATOR)
+}
+
+func (this *sdpWriter) optional(typ byte, value string) {
+	if value != "" {
+		this.line(typ, value)
+	}
+}
+
+func (this *sdpWriter) lines(typ byte, values []string) {
+	for _, v := range values {
+		this.line(typ, v)
+	}
+}
+
+func (this *sdpWriter) attributes(attrs Attributes) {
+	for i := range attrs {
+		this.line('a', attrs[i].String())
+	}
+}
+
+// GetDirection returns the direction of media, falling back to the session
+// level and then to sendrecv.
+func (this *SessionDescription) GetDirection(media *MediaDescription) string {
+	if dir := media.GetDirection(); dir != "" {
+		return dir
+	}
+	if dir := this.Attributes.getDirection(); dir != "" {
+		return dir
+	}
+	return SDP_DIRECTION_SENDRECV
+}
+
+// GetConnection returns the connection of media, falling back to the
+// session level.
+func (this *SessionDescription) GetConnection(media *MediaDescription) *Connection {
+	if len(media.Connections) > 0 {
+		return media.Connections[0]
+	}
+	return this.Connection
+}
@@ -0,0 +1,349 @@
+package sdp
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"rtp"
+)
+
+// attribute names of RFC8866, RFC3605, RFC5761, RFC4585, RFC8285, RFC5576,
+// RFC5888 and RFC4568
+const (
+	SDP_ATTR_RTPMAP   = "rtpmap"
+	SDP_ATTR_FMTP     = "fmtp"
+	SDP_ATTR_RTCP     = "rtcp"
+	SDP_ATTR_RTCP_MUX = "rtcp-mux"
+	SDP_ATTR_RTCP_FB  = "rtcp-fb"
+	SDP_ATTR_EXTMAP   = "extmap"
+	SDP_ATTR_SSRC     = "ssrc"
+	SDP_ATTR_MID      = "mid"
+	SDP_ATTR_PTIME    = "ptime"
+	SDP_ATTR_CRYPTO   = "crypto"
+)
+
+const (
+	SDP_DIRECTION_SENDRECV = "sendrecv"
+	SDP_DIRECTION_SENDONLY = "sendonly"
+	SDP_DIRECTION_RECVONLY = "recvonly"
+	SDP_DIRECTION_INACTIVE = "inactive"
+)
+
+// rtcp-fb types and parameters of RFC4585
+const (
+	SDP_RTCP_FB_WILDCARD = "*"
+	SDP_RTCP_FB_NACK     = "nack"
+	SDP_RTCP_FB_PLI      = "pli"
+	SDP_RTCP_FB_CCM      = "ccm"
+	SDP_RTCP_FB_FIR      = "fir"
+)
+
+var ErrBadAttribute = errors.New("sdp: malformed attribute")
+
+// Attribute is an "a=" line, Value is empty for property attributes.
+type Attribute struct {
+	Key   string
+	Value string
+}
+
+func parseAttribute(value string) (string, string) {
+	if pos := strings.IndexByte(value, ':'); pos >= 0 {
+		return value[:pos], value[pos+1:]
+	}
+	return value, ""
+}
+
+func (this *Attribute) String() string {
+	if this.Value == "" {
+		return this.Key
+	}
+	return this.Key + ":" + this.Value
+}
+
+// Attributes are the attributes of one level in the order received.
+type Attributes []Attribute
+
+func (this *Attributes) Add(key, value string) {
+	*this = append(*this, Attribute{Key: key, Value: value})
+}
+
+// Set replaces every attribute key with a single one.
+func (this *Attributes) Set(key, value string) {
+	this.Remove(key)
+	this.Add(key, value)
+}
+
+func (this *Attributes) Remove(key string) {
+	attrs := (*this)[:0]
+	for _, v := range *this {
+		if v.Key != key {
+			attrs = append(attrs, v)
+		}
+	}
+	*this = attrs
+}
+
+// Get returns the value of the first attribute key.
+func (this Attributes) Get(key string) (string, bool) {
+	for _, v := range this {
+		if v.Key == key {
+			return v.Value, true
+		}
+	}
+	return "", false
+}
+
+func (this Attributes) GetAll(key string) []string {
+	var values []string
+	for _, v := range this {
+		if v.Key == key {
+			values = append(values, v.Value)
+		}
+	}
+	return values
+}
+
+func (this Attributes) Has(key string) bool {
+	_, ok := this.Get(key)
+	return ok
+}
+
+func (this Attributes) getDirection() string {
+	for _, v := range this {
+		switch v.Key {
+		case SDP_DIRECTION_SENDRECV, SDP_DIRECTION_SENDONLY, SDP_DIRECTION_RECVONLY, SDP_DIRECTION_INACTIVE:
+			return v.Key
+		}
+	}
+	return ""
+}
+
+func (this *Attributes) setDirection(dir string) {
+	this.Remove(SDP_DIRECTION_SENDRECV)
+	this.Remove(SDP_DIRECTION_SENDONLY)
+	this.Remove(SDP_DIRECTION_RECVONLY)
+	this.Remove(SDP_DIRECTION_INACTIVE)
+	this.Add(dir, "")
+}
+
+// RtpMap is "a=rtpmap:<payload type> <encoding name>/<clock rate>[/<channels>]",
+// Channels is 0 when not given.
+type RtpMap struct {
+	PayloadType  byte
+	EncodingName string
+	ClockRate    uint32
+	Channels     byte
+}
+
+func ParseRtpMap(value string) (*RtpMap, error) {
+	fields := strings.Fields(value)
+	if len(fields) != 2 {
+		return nil, ErrBadAttribute
+	}
+	pt, err := parsePayloadType(fields[0])
+	if err != nil {
+		return nil, err
+	}
+
+	parts := strings.Split(fields[1], "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" {
+		return nil, ErrBadAttribute
+	}
+	rate, err := strconv.ParseUint(parts[1], 10, 32)
+	if err != nil {
+		return nil, ErrBadAttribute
+	}
+
+	rtpmap := &RtpMap{PayloadType: pt, EncodingName: parts[0], ClockRate: uint32(rate)}
+	if len(parts) == 3 {
+		channels, err := strconv.ParseUint(parts[2], 10, 8)
+		if err != nil {
+			return nil, ErrBadAttribute
+		}
+		rtpmap.Channels = byte(channels)
+	}
+	return rtpmap, nil
+}
+
+func (this *RtpMap) String() string {
+	s := strconv.Itoa(int(this.PayloadType)) + " " + this.EncodingName + "/" + strconv.FormatUint(uint64(this.ClockRate), 10)
+	if this.Channels != 0 {
+		s += "/" + strconv.Itoa(int(this.Channels))
+	}
+	return s
+}
+
+// Fmtp is "a=fmtp:<format> <format specific parameters>".
+type Fmtp struct {
+	Format string
+	Params string
+}
+
+func ParseFmtp(value string) (*Fmtp, error) {
+	pos := strings.IndexByte(value, ' ')
+	if pos <= 0 {
+		return nil, ErrBadAttribute
+	}
+	return &Fmtp{Format: value[:pos], Params: strings.TrimSpace(value[pos+1:])}, nil
+}
+
+func (this *Fmtp) String() string {
+	return this.Format + " " + this.Params
+}
+
+// RtcpAttribute is "a=rtcp:<port> [<nettype> <addrtype> <address>]" of
+// RFC3605, the address fields are empty when not given.
+type RtcpAttribute struct {
+	Port     uint16
+	NetType  string
+	AddrType string
+	Address  string
+}
+
+func ParseRtcpAttribute(value string) (*RtcpAttribute, error) {
+	fields := strings.Fields(value)
+	if len(fields) != 1 && len(fields) != 4 {
+		return nil, ErrBadAttribute
+	}
+	port, err := strconv.ParseUint(fields[0], 10, 16)
+	if err != nil {
+		return nil, ErrBadAttribute
+	}
+
+	rtcp := &RtcpAttribute{Port: uint16(port)}
+	if len(fields) == 4 {
+		rtcp.NetType, rtcp.AddrType, rtcp.Address = fields[1], fields[2], fields[3]
+	}
+	return rtcp, nil
+}
+
+func (this *RtcpAttribute) String() string {
+	s := strconv.Itoa(int(this.Port))
+	if this.Address != "" {
+		s += " " + this.NetType + " " + this.AddrType + " " + this.Address
+	}
+	return s
+}
+
+// RtcpFb is "a=rtcp-fb:<format> <type> [<param>]" of RFC4585, Format is
+// "*" for every payload type.
+type RtcpFb struct {
+	Format string
+	Type   string
+	Param  string
+}
+
+func ParseRtcpFb(value string) (*RtcpFb, error) {
+	fields := strings.SplitN(strings.TrimSpace(value), " ", 3)
+	if len(fields) < 2 || fields[0] == "" || fields[1] == "" {
+		return nil, ErrBadAttribute
+	}
+	if fields[0] != SDP_RTCP_FB_WILDCARD {
+		if _, err := parsePayloadType(fields[0]); err != nil {
+			return nil, err
+		}
+	}
+
+	fb := &RtcpFb{Format: fields[0], Type: fields[1]}
+	if len(fields) == 3 {
+		fb.Param = strings.TrimSpace(fields[2])
+	}
+	return fb, nil
+}
+
+// Matches returns whether the feedback applies to payloadType.
+func (this *RtcpFb) Matches(payloadType byte) bool {
+	return this.Format == SDP_RTCP_FB_WILDCARD || this.Format == strconv.Itoa(int(payloadType))
+}
+
+func (this *RtcpFb) String() string {
+	s := this.Format + " " + this.Type
+	if this.Param != "" {
+		s += " " + this.Param
+	}
+	return s
+}
+
+// ExtMap is "a=extmap:<id>[/<direction>] <uri> [<attributes>]" of RFC8285.
+type ExtMap struct {
+	Id         byte
+	Direction  string
+	Uri        string
+	Attributes string
+}
+
+func ParseExtMap(value string) (*ExtMap, error) {
+	fields := strings.SplitN(strings.TrimSpace(value), " ", 3)
+	if len(fields) < 2 || fields[1] == "" {
+		return nil, ErrBadAttribute
+	}
+
+	ext := &ExtMap{Uri: fields[1]}
+	id := fields[0]
+	if pos := strings.IndexByte(id, '/'); pos >= 0 {
+		id, ext.Direction = id[:pos], id[pos+1:]
+	}
+	n, err := strconv.ParseUint(id, 10, 8)
+	if err != nil || n == 0 {
+		return nil, ErrBadAttribute
+	}
+	ext.Id = byte(n)
+
+	if len(fields) == 3 {
+		ext.Attributes = strings.TrimSpace(fields[2])
+	}
+	return ext, nil
+}
+
+func (this *ExtMap) String() string {
+	s := strconv.Itoa(int(this.Id))
+	if this.Direction != "" {
+		s += "/" + this.Direction
+	}
+	s += " " + this.Uri
+	if this.Attributes != "" {
+		s += " " + this.Attributes
+	}
+	return s
+}
+
+// Ssrc is "a=ssrc:<ssrc> <attribute>[:<value>]" of RFC5576.
+type Ssrc struct {
+	Ssrc      uint32
+	Attribute string
+	Value     string
+}
+
+func ParseSsrc(value string) (*Ssrc, error) {
+	pos := strings.IndexByte(value, ' ')
+	if pos <= 0 {
+		return nil, ErrBadAttribute
+	}
+	ssrc, err := strconv.ParseUint(value[:pos], 10, 32)
+	if err != nil {
+		return nil, ErrBadAttribute
+	}
+
+	attr, val := parseAttribute(strings.TrimSpace(value[pos+1:]))
+	if attr == "" {
+		return nil, ErrBadAttribute
+	}
+	return &Ssrc{Ssrc: uint32(ssrc), Attribute: attr, Value: val}, nil
+}
+
+func (this *Ssrc) String() string {
+	s := strconv.FormatUint(uint64(this.Ssrc), 10) + " " + this.Attribute
+	if this.Value != "" {
+		s += ":" + this.Value
+	}
+	return s
+}
+
+func parsePayloadType(value string) (byte, error) {
+	pt, err := strconv.ParseUint(value, 10, 8)
+	if err != nil || pt > rtp.RTP_MAX_PAYLOAD_TYPE {
+		return 0, ErrBadAttribute
+	}
+	return byte(pt), nil
+}
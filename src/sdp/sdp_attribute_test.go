@@ -0,0 +1,90 @@
+package sdp
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/lioneagle/goutil/src/test"
+)
+
+type testAttribute interface {
+	String() string
+}
+
+func TestSdpAttributeCodec(t *testing.T) {
+	testdata := []struct {
+		value  string
+		parse  func(value string) (testAttribute, error)
+		wanted testAttribute
+	}{
+		{"96 opus/48000/2", func(v string) (testAttribute, error) { return ParseRtpMap(v) }, &RtpMap{96, "opus", 48000, 2}},
+		{"0 PCMU/8000", func(v string) (testAttribute, error) { return ParseRtpMap(v) }, &RtpMap{0, "PCMU", 8000, 0}},
+		{"97 profile-level-id=42e01f;packetization-mode=1", func(v string) (testAttribute, error) { return ParseFmtp(v) }, &Fmtp{"97", "profile-level-id=42e01f;packetization-mode=1"}},
+		{"53020", func(v string) (testAttribute, error) { return ParseRtcpAttribute(v) }, &RtcpAttribute{Port: 53020}},
+		{"53020 IN IP6 2001:2345:6789:ABCD:EF01:2345:6789:ABCD", func(v string) (testAttribute, error) { return ParseRtcpAttribute(v) }, &RtcpAttribute{53020, "IN", "IP6", "2001:2345:6789:ABCD:EF01:2345:6789:ABCD"}},
+		{"* nack", func(v string) (testAttribute, error) { return ParseRtcpFb(v) }, &RtcpFb{"*", "nack", ""}},
+		{"96 ccm fir", func(v string) (testAttribute, error) { return ParseRtcpFb(v) }, &RtcpFb{"96", "ccm", "fir"}},
+		{"96 ccm tmmbr smaxpr=120", func(v string) (testAttribute, error) { return ParseRtcpFb(v) }, &RtcpFb{"96", "ccm", "tmmbr smaxpr=120"}},
+		{"2 urn:ietf:params:rtp-hdrext:toffset", func(v string) (testAttribute, error) { return ParseExtMap(v) }, &ExtMap{2, "", "urn:ietf:params:rtp-hdrext:toffset", ""}},
+		{"3/recvonly urn:example:ext x y", func(v string) (testAttribute, error) { return ParseExtMap(v) }, &ExtMap{3, "recvonly", "urn:example:ext", "x y"}},
+		{"4294967295 cname:user@example.com", func(v string) (testAttribute, error) { return ParseSsrc(v) }, &Ssrc{0xFFFFFFFF, "cname", "user@example.com"}},
+		{"12 msid:stream track", func(v string) (testAttribute, error) { return ParseSsrc(v) }, &Ssrc{12, "msid", "stream track"}},
+	}
+
+	for i, v := range testdata {
+		v := v
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			t.Parallel()
+
+			attr, err := v.parse(v.value)
+			test.EXPECT_EQ(t, err, nil, "")
+			test.EXPECT_EQ(t, attr, v.wanted, "")
+			test.EXPECT_EQ(t, attr.String(), v.value, "")
+		})
+	}
+}
+
+func TestSdpAttributeParseError(t *testing.T) {
+	testdata := []struct {
+		value string
+		parse func(value string) (testAttribute, error)
+	}{
+		{"96 opus", func(v string) (testAttribute, error) { return ParseRtpMap(v) }},
+		{"128 opus/48000", func(v string) (testAttribute, error) { return ParseRtpMap(v) }},
+		{"96 opus/48000/2/1", func(v string) (testAttribute, error) { return ParseRtpMap(v) }},
+		{"96", func(v string) (testAttribute, error) { return ParseFmtp(v) }},
+		{"70000", func(v string) (testAttribute, error) { return ParseRtcpAttribute(v) }},
+		{"5000 IN IP4", func(v string) (testAttribute, error) { return ParseRtcpAttribute(v) }},
+		{"96", func(v string) (testAttribute, error) { return ParseRtcpFb(v) }},
+		{"x nack", func(v string) (testAttribute, error) { return ParseRtcpFb(v) }},
+		{"0 urn:example:ext", func(v string) (testAttribute, error) { return ParseExtMap(v) }},
+		{"1", func(v string) (testAttribute, error) { return ParseExtMap(v) }},
+		{"cname:x", func(v string) (testAttribute, error) { return ParseSsrc(v) }},
+		{"-1 cname:x", func(v string) (testAttribute, error) { return ParseSsrc(v) }},
+	}
+
+	for i, v := range testdata {
+		_, err := v.parse(v.value)
+		test.EXPECT_EQ(t, err, ErrBadAttribute, "[%d]", i)
+	}
+}
+
+func TestAttributes(t *testing.T) {
+	var attrs Attributes
+	attrs.Add("a", "1")
+	attrs.Add("b", "")
+	attrs.Add("a", "2")
+
+	value, ok := attrs.Get("a")
+	test.EXPECT_EQ(t, value, "1", "")
+	test.EXPECT_EQ(t, ok, true, "")
+	test.EXPECT_EQ(t, attrs.GetAll("a"), []string{"1", "2"}, "")
+	test.EXPECT_EQ(t, attrs.Has("b"), true, "")
+	test.EXPECT_EQ(t, attrs.Has("c"), false, "")
+
+	attrs.Set("a", "3")
+	test.EXPECT_EQ(t, attrs, Attributes{{"b", ""}, {"a", "3"}}, "")
+
+	attrs.Remove("b")
+	test.EXPECT_EQ(t, attrs, Attributes{{"a", "3"}}, "")
+}
@@ -0,0 +1,358 @@
+package sdp
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"rtp"
+	"srtp"
+)
+
+const (
+	SDP_MEDIA_AUDIO = "audio"
+	SDP_MEDIA_VIDEO = "video"
+
+	SDP_PROTO_RTP_AVP       = "RTP/AVP"
+	SDP_PROTO_RTP_SAVP      = "RTP/SAVP"
+	SDP_PROTO_RTP_AVPF      = "RTP/AVPF"
+	SDP_PROTO_RTP_SAVPF     = "RTP/SAVPF"
+	SDP_PROTO_UDP_TLS_SAVPF = "UDP/TLS/RTP/SAVPF"
+)
+
+// MediaDescription is an "m=" section. NumPorts is 0 when not given.
+type MediaDescription struct {
+	Media         string
+	Port          uint16
+	NumPorts      int
+	Protos        string
+	Formats       []string
+	Information   string
+	Connections   []*Connection
+	Bandwidths    []Bandwidth
+	EncryptionKey string
+	Attributes    Attributes
+}
+
+func NewMediaDescription(media string, port uint16, protos string) *MediaDescription {
+	return &MediaDescription{Media: media, Port: port, Protos: protos}
+}
+
+func parseMediaLine(value string) (*MediaDescription, error) {
+	fields := strings.Fields(value)
+	if len(fields) < 3 {
+		return nil, ErrBadLine
+	}
+	media := &MediaDescription{Media: fields[0], Protos: fields[2], Formats: fields[3:]}
+
+	port := fields[1]
+	if pos := strings.IndexByte(port, '/'); pos >= 0 {
+		n, err := strconv.Atoi(port[pos+1:])
+		if err != nil || n < 1 {
+			return nil, ErrBadLine
+		}
+		media.NumPorts = n
+		port = port[:pos]
+	}
+	n, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return nil, ErrBadLine
+	}
+	media.Port = uint16(n)
+	return media, nil
+}
+
+func (this *MediaDescription) parseLine(typ byte, value string) (err error) {
+	switch typ {
+	case 'i':
+		this.Information = value
+	case 'c':
+		var conn *Connection
+		conn, err = parseConnection(value)
+		this.Connections = append(this.Connections, conn)
+	case 'b':
+		var bandwidth Bandwidth
+		bandwidth, err = parseBandwidth(value)
+		this.Bandwidths = append(this.Bandwidths, bandwidth)
+	case 'k':
+		this.EncryptionKey = value
+	case 'a':
+		this.Attributes.Add(parseAttribute(value))
+	}
+	return err
+}
+
+func (this *MediaDescription) write(w *sdpWriter) {
+	port := strconv.Itoa(int(this.Port))
+	if this.NumPorts != 0 {
+		port += "/" + strconv.Itoa(this.NumPorts)
+	}
+	fields := append([]string{this.Media, port, this.Protos}, this.Formats...)
+	w.line('m', strings.Join(fields, " "))
+	w.optional('i', this.Information)
+	for _, v := range this.Connections {
+		w.line('c', v.String())
+	}
+	for i := range this.Bandwidths {
+		w.line('b', this.Bandwidths[i].String())
+	}
+	w.optional('k', this.EncryptionKey)
+	w.attributes(this.Attributes)
+}
+
+// IsRtp returns whether the section carries rtp, whose formats are then
+// payload types.
+func (this *MediaDescription) IsRtp() bool {
+	return strings.Contains(this.Protos, "RTP/")
+}
+
+// GetPayloadTypes returns the formats as payload types in order of
+// preference.
+func (this *MediaDescription) GetPayloadTypes() ([]byte, error) {
+	types := make([]byte, 0, len(this.Formats))
+	for _, v := range this.Formats {
+		pt, err := parsePayloadType(v)
+		if err != nil {
+			return nil, err
+		}
+		types = append(types, pt)
+	}
+	return types, nil
+}
+
+// GetRtpMap returns the rtpmap of payloadType, nil when there is none.
+func (this *MediaDescription) GetRtpMap(payloadType byte) (*RtpMap, error) {
+	for _, v := range this.Attributes.GetAll(SDP_ATTR_RTPMAP) {
+		rtpmap, err := ParseRtpMap(v)
+		if err != nil {
+			return nil, err
+		}
+		if rtpmap.PayloadType == payloadType {
+			return rtpmap, nil
+		}
+	}
+	return nil, nil
+}
+
+// GetFmtp returns the format specific parameters of format.
+func (this *MediaDescription) GetFmtp(format string) (string, bool) {
+	for _, v := range this.Attributes.GetAll(SDP_ATTR_FMTP) {
+		fmtp, err := ParseFmtp(v)
+		if err == nil && fmtp.Format == format {
+			return fmtp.Params, true
+		}
+	}
+	return "", false
+}
+
+// GetRtcp returns the a=rtcp attribute, nil when there is none.
+func (this *MediaDescription) GetRtcp() (*RtcpAttribute, error) {
+	value, ok := this.Attributes.Get(SDP_ATTR_RTCP)
+	if !ok {
+		return nil, nil
+	}
+	return ParseRtcpAttribute(value)
+}
+
+func (this *MediaDescription) RtcpMux() bool {
+	return this.Attributes.Has(SDP_ATTR_RTCP_MUX)
+}
+
+// GetRtcpFbs returns the feedback of payloadType, wildcard ones included.
+func (this *MediaDescription) GetRtcpFbs(payloadType byte) ([]*RtcpFb, error) {
+	var fbs []*RtcpFb
+	for _, v := range this.Attributes.GetAll(SDP_ATTR_RTCP_FB) {
+		fb, err := ParseRtcpFb(v)
+		if err != nil {
+			return nil, err
+		}
+		if fb.Matches(payloadType) {
+			fbs = append(fbs, fb)
+		}
+	}
+	return fbs, nil
+}
+
+// HasRtcpFb returns whether payloadType negotiated the feedback typ with
+// param, "" for none.
+func (this *MediaDescription) HasRtcpFb(payloadType byte, typ, param string) bool {
+	fbs, _ := this.GetRtcpFbs(payloadType)
+	for _, v := range fbs {
+		if v.Type == typ && v.Param == param {
+			return true
+		}
+	}
+	return false
+}
+
+func (this *MediaDescription) GetExtMaps() ([]*ExtMap, error) {
+	var exts []*ExtMap
+	for _, v := range this.Attributes.GetAll(SDP_ATTR_EXTMAP) {
+		ext, err := ParseExtMap(v)
+		if err != nil {
+			return nil, err
+		}
+		exts = append(exts, ext)
+	}
+	return exts, nil
+}
+
+// ExtensionMap returns the header extension ids of the section.
+func (this *MediaDescription) ExtensionMap() (*rtp.RtpExtensionMap, error) {
+	exts, err := this.GetExtMaps()
+	if err != nil {
+		return nil, err
+	}
+
+	extmap := rtp.NewRtpExtensionMap()
+	for _, v := range exts {
+		err = extmap.Register(v.Id, v.Uri)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return extmap, nil
+}
+
+func (this *MediaDescription) GetSsrcs() ([]*Ssrc, error) {
+	var ssrcs []*Ssrc
+	for _, v := range this.Attributes.GetAll(SDP_ATTR_SSRC) {
+		ssrc, err := ParseSsrc(v)
+		if err != nil {
+			return nil, err
+		}
+		ssrcs = append(ssrcs, ssrc)
+	}
+	return ssrcs, nil
+}
+
+func (this *MediaDescription) GetMid() string {
+	mid, _ := this.Attributes.Get(SDP_ATTR_MID)
+	return mid
+}
+
+// GetDirection returns the direction attribute of the section, "" when
+// there is none. SessionDescription.GetDirection applies the defaults.
+func (this *MediaDescription) GetDirection() string {
+	return this.Attributes.getDirection()
+}
+
+func (this *MediaDescription) SetDirection(dir string) {
+	this.Attributes.setDirection(dir)
+}
+
+// GetPtime returns the packet time, which may have a fraction of a
+// millisecond.
+func (this *MediaDescription) GetPtime() (time.Duration, bool) {
+	value, ok := this.Attributes.Get(SDP_ATTR_PTIME)
+	if !ok {
+		return 0, false
+	}
+	ms, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || ms <= 0 {
+		return 0, false
+	}
+	return time.Duration(ms * float64(time.Millisecond)), true
+}
+
+func (this *MediaDescription) GetCryptos() ([]*srtp.SdesCrypto, error) {
+	var cryptos []*srtp.SdesCrypto
+	for _, v := range this.Attributes.GetAll(SDP_ATTR_CRYPTO) {
+		crypto, err := srtp.ParseSdesCrypto(v)
+		if err != nil {
+			return nil, err
+		}
+		cryptos = append(cryptos, crypto)
+	}
+	return cryptos, nil
+}
+
+func (this *MediaDescription) AddCrypto(crypto *srtp.SdesCrypto) {
+	this.Attributes.Add(SDP_ATTR_CRYPTO, crypto.String())
+}
+
+// AddPayload appends the payload type of profile to the formats with its
+// rtpmap and fmtp.
+func (this *MediaDescription) AddPayload(profile *rtp.RtpProfile) {
+	this.Formats = append(this.Formats, strconv.Itoa(int(profile.PayloadType)))
+
+	rtpmap := &RtpMap{PayloadType: profile.PayloadType, EncodingName: profile.Name, ClockRate: profile.ClockRate}
+	if profile.HasChannels && profile.Channels > 1 {
+		rtpmap.Channels = profile.Channels
+	}
+	this.Attributes.Add(SDP_ATTR_RTPMAP, rtpmap.String())
+	if profile.Fmtp != "" {
+		fmtp := &Fmtp{Format: strconv.Itoa(int(profile.PayloadType)), Params: profile.Fmtp}
+		this.Attributes.Add(SDP_ATTR_FMTP, fmtp.String())
+	}
+}
+
+// GetPayload returns the profile of payloadType, from its rtpmap or else
+// the static table, nil when neither knows it.
+func (this *MediaDescription) GetPayload(payloadType byte) (*rtp.RtpProfile, error) {
+	rtpmap, err := this.GetRtpMap(payloadType)
+	if err != nil {
+		return nil, err
+	}
+
+	var profile rtp.RtpProfile
+	if rtpmap != nil {
+		profile = rtp.RtpProfile{
+			Used:         true,
+			PayloadType:  payloadType,
+			Name:         rtpmap.EncodingName,
+			MediaType:    sdpMediaType(this.Media),
+			HasClockRate: true,
+			ClockRate:    rtpmap.ClockRate,
+		}
+		if rtpmap.Channels != 0 {
+			profile.HasChannels = true
+			profile.Channels = rtpmap.Channels
+		} else if this.Media == SDP_MEDIA_AUDIO {
+			// audio defaults to one channel, RFC8866 section 6.6
+			profile.HasChannels = true
+			profile.Channels = 1
+		}
+	} else if int(payloadType) < len(rtp.StaticRtpProfiles) && rtp.StaticRtpProfiles[payloadType].Used {
+		profile = rtp.StaticRtpProfiles[payloadType]
+	} else {
+		return nil, nil
+	}
+
+	profile.Fmtp, _ = this.GetFmtp(strconv.Itoa(int(payloadType)))
+	return &profile, nil
+}
+
+// PayloadRegistry returns the payload types of the section for the rtp
+// session. Dynamic payload types without rtpmap are left out.
+func (this *MediaDescription) PayloadRegistry() (*rtp.PayloadRegistry, error) {
+	registry := rtp.NewPayloadRegistry()
+	if !this.IsRtp() {
+		return registry, nil
+	}
+
+	types, err := this.GetPayloadTypes()
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range types {
+		profile, err := this.GetPayload(v)
+		if err != nil {
+			return nil, err
+		}
+		if profile != nil {
+			registry.Register(*profile)
+		}
+	}
+	return registry, nil
+}
+
+func sdpMediaType(media string) string {
+	switch media {
+	case SDP_MEDIA_AUDIO:
+		return "A"
+	case SDP_MEDIA_VIDEO:
+		return "V"
+	}
+	return ""
+}
@@ -0,0 +1,121 @@
+package sdp
+
+import (
+	"testing"
+	"time"
+
+	"rtp"
+	"srtp"
+
+	"github.com/lioneagle/goutil/src/test"
+)
+
+func TestMediaDescriptionAttributes(t *testing.T) {
+	sdp, err := ParseSessionDescription(testSdpOffer)
+	test.EXPECT_EQ(t, err, nil, "")
+	audio, video := sdp.Media[0], sdp.Media[1]
+
+	types, err := audio.GetPayloadTypes()
+	test.EXPECT_EQ(t, err, nil, "")
+	test.EXPECT_EQ(t, types, []byte{0, 96, 101}, "")
+
+	rtpmap, err := audio.GetRtpMap(96)
+	test.EXPECT_EQ(t, err, nil, "")
+	test.EXPECT_EQ(t, rtpmap, &RtpMap{96, "opus", 48000, 2}, "")
+	rtpmap, err = audio.GetRtpMap(0)
+	test.EXPECT_EQ(t, rtpmap == nil, true, "")
+
+	params, ok := audio.GetFmtp("101")
+	test.EXPECT_EQ(t, params, "0-16", "")
+	test.EXPECT_EQ(t, ok, true, "")
+
+	rtcp, err := audio.GetRtcp()
+	test.EXPECT_EQ(t, err, nil, "")
+	test.EXPECT_EQ(t, rtcp, &RtcpAttribute{53020, "IN", "IP4", "126.16.64.4"}, "")
+	test.EXPECT_EQ(t, audio.RtcpMux(), true, "")
+	test.EXPECT_EQ(t, video.RtcpMux(), false, "")
+
+	test.EXPECT_EQ(t, audio.HasRtcpFb(101, SDP_RTCP_FB_NACK, ""), true, "")
+	test.EXPECT_EQ(t, video.HasRtcpFb(96, SDP_RTCP_FB_NACK, SDP_RTCP_FB_PLI), true, "")
+	test.EXPECT_EQ(t, video.HasRtcpFb(97, SDP_RTCP_FB_NACK, SDP_RTCP_FB_PLI), false, "")
+	fbs, err := video.GetRtcpFbs(97)
+	test.EXPECT_EQ(t, fbs, []*RtcpFb{{"97", SDP_RTCP_FB_CCM, SDP_RTCP_FB_FIR}}, "")
+
+	extmap, err := audio.ExtensionMap()
+	test.EXPECT_EQ(t, err, nil, "")
+	id, ok := extmap.GetId(rtp.RTP_EXTENSION_URI_SDES_MID)
+	test.EXPECT_EQ(t, id, byte(3), "")
+	test.EXPECT_EQ(t, ok, true, "")
+
+	ssrcs, err := audio.GetSsrcs()
+	test.EXPECT_EQ(t, err, nil, "")
+	test.EXPECT_EQ(t, ssrcs, []*Ssrc{{314159, "cname", "user@example.com"}}, "")
+
+	test.EXPECT_EQ(t, audio.GetMid(), "audio", "")
+	test.EXPECT_EQ(t, video.GetMid(), "", "")
+
+	ptime, ok := audio.GetPtime()
+	test.EXPECT_EQ(t, ptime, 20*time.Millisecond, "")
+	test.EXPECT_EQ(t, ok, true, "")
+	_, ok = video.GetPtime()
+	test.EXPECT_EQ(t, ok, false, "")
+
+	cryptos, err := audio.GetCryptos()
+	test.EXPECT_EQ(t, err, nil, "")
+	test.EXPECT_EQ(t, len(cryptos), 1, "")
+	test.EXPECT_EQ(t, cryptos[0].Suite, srtp.SRTP_AES_CM_128_HMAC_SHA1_80, "")
+	test.EXPECT_EQ(t, cryptos[0].Keys[0].MkiLen, 4, "")
+}
+
+func TestMediaDescriptionPayloadRegistry(t *testing.T) {
+	sdp, err := ParseSessionDescription(testSdpOffer)
+	test.EXPECT_EQ(t, err, nil, "")
+
+	registry, err := sdp.Media[0].PayloadRegistry()
+	test.EXPECT_EQ(t, err, nil, "")
+	test.EXPECT_EQ(t, registry.GetPayloadTypes(), []byte{0, 96, 101}, "")
+	test.EXPECT_EQ(t, registry.GetName(0), "PCMU", "")
+	test.EXPECT_EQ(t, *registry.Lookup(96), rtp.RtpProfile{
+		Used:         true,
+		PayloadType:  96,
+		Name:         "opus",
+		MediaType:    "A",
+		HasClockRate: true,
+		ClockRate:    48000,
+		HasChannels:  true,
+		Channels:     2,
+		Fmtp:         "minptime=10;useinbandfec=1",
+	}, "")
+	test.EXPECT_EQ(t, registry.Lookup(101).Channels, byte(1), "")
+	test.EXPECT_EQ(t, registry.GetClockRate(101), uint32(8000), "")
+
+	registry, err = sdp.Media[1].PayloadRegistry()
+	test.EXPECT_EQ(t, err, nil, "")
+	test.EXPECT_EQ(t, registry.Lookup(97).MediaType, "V", "")
+	test.EXPECT_EQ(t, registry.Lookup(97).HasChannels, false, "")
+	test.EXPECT_EQ(t, registry.Lookup(97).FmtpParameters()["packetization-mode"], "1", "")
+
+	// dynamic payload types without rtpmap are left out
+	media := NewMediaDescription(SDP_MEDIA_AUDIO, 9, SDP_PROTO_RTP_AVP)
+	media.Formats = []string{"8", "98"}
+	registry, err = media.PayloadRegistry()
+	test.EXPECT_EQ(t, err, nil, "")
+	test.EXPECT_EQ(t, registry.GetPayloadTypes(), []byte{8}, "")
+}
+
+func TestMediaDescriptionAddPayload(t *testing.T) {
+	media := NewMediaDescription(SDP_MEDIA_AUDIO, 5004, SDP_PROTO_RTP_AVP)
+	media.AddPayload(&rtp.StaticRtpProfiles[0])
+	media.AddPayload(&rtp.RtpProfile{PayloadType: 96, Name: "opus", HasClockRate: true, ClockRate: 48000, HasChannels: true, Channels: 2, Fmtp: "stereo=1"})
+
+	test.EXPECT_EQ(t, media.Formats, []string{"0", "96"}, "")
+	test.EXPECT_EQ(t, media.Attributes, Attributes{
+		{SDP_ATTR_RTPMAP, "0 PCMU/8000"},
+		{SDP_ATTR_RTPMAP, "96 opus/48000/2"},
+		{SDP_ATTR_FMTP, "96 stereo=1"},
+	}, "")
+
+	registry, err := media.PayloadRegistry()
+	test.EXPECT_EQ(t, err, nil, "")
+	test.EXPECT_EQ(t, registry.Lookup(96).Fmtp, "stereo=1", "")
+}
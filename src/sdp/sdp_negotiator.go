@@ -0,0 +1,891 @@
+package sdp
+
+import (
+	"encoding/hex"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"rtp"
+	"srtp"
+)
+
+// H.264 format parameters of RFC6184 section 8.1
+const (
+	SDP_FMTP_H264_PROFILE_LEVEL_ID   = "profile-level-id"
+	SDP_FMTP_H264_PACKETIZATION_MODE = "packetization-mode"
+
+	SDP_H264_DEFAULT_PROFILE_LEVEL_ID   = "420010"
+	SDP_H264_DEFAULT_PACKETIZATION_MODE = "0"
+)
+
+var (
+	ErrNoOffer       = errors.New("sdp: no offer pending")
+	ErrGlare         = errors.New("sdp: offer received while an offer is pending")
+	ErrMediaRemoved  = errors.New("sdp: offer has fewer media than the previous one")
+	ErrBadAnswer     = errors.New("sdp: answer does not match the offer")
+	ErrNoPayloadType = errors.New("sdp: no free dynamic payload type")
+)
+
+// MediaCapability is what the local side supports for one "m=" section.
+// CryptoSuites not empty requires SDES-SRTP, RtcpFbs not empty makes the
+// offer AVPF. A Port of 0 disables the media.
+type MediaCapability struct {
+	Media string
+	Port  uint16
+	// in order of preference
+	Payloads []rtp.RtpProfile
+	// feedback offered and accepted for every payload type, Format is
+	// ignored
+	RtcpFbs []RtcpFb
+	// header extension uris
+	Extensions   []string
+	RtcpMux      bool
+	CryptoSuites []string
+	// Direction is the wanted direction, "" for sendrecv
+	Direction string
+	// Ptime is 0 and Ssrc is 0 to leave out the attribute
+	Ptime time.Duration
+	Ssrc  uint32
+	Cname string
+}
+
+func (this *MediaCapability) secure() bool {
+	return len(this.CryptoSuites) > 0
+}
+
+func (this *MediaCapability) protos() string {
+	protos := SDP_PROTO_RTP_AVP
+	if this.secure() {
+		protos = SDP_PROTO_RTP_SAVP
+	}
+	if len(this.RtcpFbs) > 0 {
+		protos += "F"
+	}
+	return protos
+}
+
+// match returns the local payload matching the remote one, nil when none
+// does.
+func (this *MediaCapability) match(remote *rtp.RtpProfile) *rtp.RtpProfile {
+	for i := range this.Payloads {
+		local := &this.Payloads[i]
+		if strings.EqualFold(local.Name, remote.Name) && local.ClockRate == remote.ClockRate &&
+			payloadChannels(local) == payloadChannels(remote) && fmtpCompatible(local, remote) {
+			return local
+		}
+	}
+	return nil
+}
+
+func (this *MediaCapability) hasRtcpFb(fb *RtcpFb) bool {
+	for _, v := range this.RtcpFbs {
+		if v.Type == fb.Type && v.Param == fb.Param {
+			return true
+		}
+	}
+	return false
+}
+
+func (this *MediaCapability) hasExtension(uri string) bool {
+	for _, v := range this.Extensions {
+		if v == uri {
+			return true
+		}
+	}
+	return false
+}
+
+func (this *MediaCapability) hasCryptoSuite(suite string) bool {
+	for _, v := range this.CryptoSuites {
+		if v == suite {
+			return true
+		}
+	}
+	return false
+}
+
+func payloadChannels(profile *rtp.RtpProfile) byte {
+	if !profile.HasChannels || profile.Channels == 0 {
+		return 1
+	}
+	return profile.Channels
+}
+
+// h264ProfilePattern maps a profile_idc and the profile-iop bits under mask to
+// a profile, so that the encodings RFC6184 section 8.1 allows for one
+// profile compare equal.
+type h264ProfilePattern struct {
+	idc     byte
+	mask    byte
+	value   byte
+	profile string
+}
+
+// the constraint_set0..2 flags are the top bits of profile-iop, the low four
+// reserved bits must be zero
+var h264ProfilePatterns = []h264ProfilePattern{
+	{0x42, 0x4f, 0x40, "constrained-baseline"},
+	{0x4d, 0x8f, 0x80, "constrained-baseline"},
+	{0x58, 0xcf, 0xc0, "constrained-baseline"},
+	{0x42, 0x4f, 0x00, "baseline"},
+	{0x58, 0xcf, 0x80, "baseline"},
+	{0x4d, 0xaf, 0x00, "main"},
+	{0x58, 0x8f, 0x00, "extended"},
+	{0x64, 0xff, 0x00, "high"},
+	{0x64, 0xff, 0x0c, "constrained-high"},
+}
+
+// h264Profile returns the profile of a profile-level-id. Unknown profiles
+// keep profile_idc and profile-iop as they are.
+func h264Profile(id string) (string, bool) {
+	data, err := hex.DecodeString(id)
+	if err != nil || len(data) != 3 {
+		return "", false
+	}
+	for _, v := range h264ProfilePatterns {
+		if data[0] == v.idc && data[1]&v.mask == v.value {
+			return v.profile, true
+		}
+	}
+	return id[:4], true
+}
+
+// fmtpCompatible checks the format parameters that must agree. For H.264 the
+// profile of profile-level-id and the packetization-mode must be equal, the
+// level may differ.
+func fmtpCompatible(local, remote *rtp.RtpProfile) bool {
+	if !strings.EqualFold(local.Name, "H264") {
+		return true
+	}
+
+	localParams, remoteParams := local.FmtpParameters(), remote.FmtpParameters()
+	param := func(params map[string]string, name, defaultValue string) string {
+		if v, ok := params[name]; ok && v != "" {
+			return strings.ToLower(v)
+		}
+		return defaultValue
+	}
+
+	localMode := param(localParams, SDP_FMTP_H264_PACKETIZATION_MODE, SDP_H264_DEFAULT_PACKETIZATION_MODE)
+	remoteMode := param(remoteParams, SDP_FMTP_H264_PACKETIZATION_MODE, SDP_H264_DEFAULT_PACKETIZATION_MODE)
+	if localMode != remoteMode {
+		return false
+	}
+
+	localId := param(localParams, SDP_FMTP_H264_PROFILE_LEVEL_ID, SDP_H264_DEFAULT_PROFILE_LEVEL_ID)
+	remoteId := param(remoteParams, SDP_FMTP_H264_PROFILE_LEVEL_ID, SDP_H264_DEFAULT_PROFILE_LEVEL_ID)
+	localProfile, ok := h264Profile(localId)
+	if !ok {
+		return false
+	}
+	remoteProfile, ok := h264Profile(remoteId)
+	return ok && localProfile == remoteProfile
+}
+
+func directionFlags(dir string) (send, recv bool) {
+	switch dir {
+	case SDP_DIRECTION_SENDONLY:
+		return true, false
+	case SDP_DIRECTION_RECVONLY:
+		return false, true
+	case SDP_DIRECTION_INACTIVE:
+		return false, false
+	}
+	return true, true
+}
+
+func directionOf(send, recv bool) string {
+	switch {
+	case send && recv:
+		return SDP_DIRECTION_SENDRECV
+	case send:
+		return SDP_DIRECTION_SENDONLY
+	case recv:
+		return SDP_DIRECTION_RECVONLY
+	}
+	return SDP_DIRECTION_INACTIVE
+}
+
+// negotiateDirection returns the local direction of RFC3264 section 6.1 from
+// the wanted local one and the one of the other side.
+func negotiateDirection(local, remote string) string {
+	localSend, localRecv := directionFlags(local)
+	remoteSend, remoteRecv := directionFlags(remote)
+	return directionOf(localSend && remoteRecv, localRecv && remoteSend)
+}
+
+// NegotiatedMedia is the outcome of the offer/answer for one "m=" section,
+// as the rtp session and transport of the media need it.
+type NegotiatedMedia struct {
+	Media string
+	Mid   string
+	// Rejected is set when either side declined the media with port 0,
+	// none of the fields below are set then
+	Rejected bool
+
+	// payload types both sides use, SendPayloadType is the preferred one
+	Payloads        *rtp.PayloadRegistry
+	SendPayloadType byte
+	RtcpFbs         []*RtcpFb
+	Extensions      *rtp.RtpExtensionMap
+	RtcpMux         bool
+	// Direction is the local one
+	Direction string
+
+	// transport addresses as "host:port"
+	RemoteRtpAddr  string
+	RemoteRtcpAddr string
+	RemoteSsrcs    []uint32
+	RemotePtime    time.Duration
+
+	// LocalCrypto protects what is sent, RemoteCrypto what is received
+	LocalCrypto  *srtp.SdesCrypto
+	RemoteCrypto *srtp.SdesCrypto
+
+	capability *MediaCapability
+}
+
+func (this *NegotiatedMedia) Send() bool {
+	send, _ := directionFlags(this.Direction)
+	return !this.Rejected && send
+}
+
+func (this *NegotiatedMedia) Recv() bool {
+	_, recv := directionFlags(this.Direction)
+	return !this.Rejected && recv
+}
+
+// HasRtcpFb returns whether the feedback typ with param was negotiated for
+// payloadType.
+func (this *NegotiatedMedia) HasRtcpFb(payloadType byte, typ, param string) bool {
+	for _, v := range this.RtcpFbs {
+		if v.Matches(payloadType) && v.Type == typ && v.Param == param {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply makes the rtp session use the negotiated payload types.
+func (this *NegotiatedMedia) Apply(session *rtp.Session) {
+	if !this.Rejected {
+		session.Payloads.Replace(this.Payloads)
+	}
+}
+
+// Negotiator runs the offer/answer model of RFC3264 for one session,
+// keeping payload types, extension ids and keys stable over re-offers.
+type Negotiator struct {
+	SessionId    uint64
+	Address      string
+	Capabilities []*MediaCapability
+
+	version   uint64
+	hold      bool
+	local     *SessionDescription
+	remote    *SessionDescription
+	media     []*NegotiatedMedia
+	offer     *SessionDescription
+	offerCaps []*MediaCapability
+	// local description and version before the pending offer
+	prevLocal   *SessionDescription
+	prevVersion uint64
+}
+
+// NewNegotiator creates a negotiator whose descriptions originate from
+// address.
+func NewNegotiator(sessionId uint64, address string, caps ...*MediaCapability) *Negotiator {
+	return &Negotiator{SessionId: sessionId, Address: address, Capabilities: caps, version: sessionId}
+}
+
+// Hold puts every media on hold with the next offer or answer, RFC3264
+// section 8.4.
+func (this *Negotiator) Hold() {
+	this.hold = true
+}
+
+func (this *Negotiator) Resume() {
+	this.hold = false
+}
+
+func (this *Negotiator) OnHold() bool {
+	return this.hold
+}
+
+// GetMedia returns the outcome of the last completed offer/answer.
+func (this *Negotiator) GetMedia() []*NegotiatedMedia {
+	return this.media
+}
+
+func (this *Negotiator) GetLocalDescription() *SessionDescription {
+	return this.local
+}
+
+func (this *Negotiator) GetRemoteDescription() *SessionDescription {
+	return this.remote
+}
+
+// wanted is the direction of cap with the hold applied: sending only what
+// was sent.
+func (this *Negotiator) wanted(cap *MediaCapability) string {
+	dir := cap.Direction
+	if dir == "" {
+		dir = SDP_DIRECTION_SENDRECV
+	}
+	if this.hold {
+		send, _ := directionFlags(dir)
+		return directionOf(send, false)
+	}
+	return dir
+}
+
+func (this *Negotiator) newDescription() *SessionDescription {
+	sdp := NewSessionDescription(this.SessionId, this.Address)
+	sdp.Origin.SessionVersion = this.version
+	return sdp
+}
+
+// stamp increments the version when sdp differs from the previous local
+// description, RFC3264 section 8.
+func (this *Negotiator) stamp(sdp *SessionDescription) {
+	sdp.Origin.SessionVersion = this.version
+	if this.local != nil && sdp.String() != this.local.String() {
+		this.version++
+		sdp.Origin.SessionVersion = this.version
+	}
+	this.local = sdp
+}
+
+// CreateOffer creates an initial offer or a re-offer, which keeps the
+// media of the previous exchange in place.
+func (this *Negotiator) CreateOffer() (*SessionDescription, error) {
+	offer := this.newDescription()
+	var caps []*MediaCapability
+	used := make(map[*MediaCapability]bool)
+
+	for i, v := range this.media {
+		cap := v.capability
+		if cap == nil {
+			// keep the slot of media without capability, disabled
+			media := *this.local.Media[i]
+			media.Port = 0
+			media.Connections, media.Bandwidths, media.Attributes = nil, nil, nil
+			offer.Media = append(offer.Media, &media)
+			caps = append(caps, nil)
+			continue
+		}
+
+		media, err := this.offerMedia(cap, v)
+		if err != nil {
+			return nil, err
+		}
+		offer.Media = append(offer.Media, media)
+		caps = append(caps, cap)
+		used[cap] = true
+	}
+
+	for _, v := range this.Capabilities {
+		if used[v] {
+			continue
+		}
+		media, err := this.offerMedia(v, nil)
+		if err != nil {
+			return nil, err
+		}
+		offer.Media = append(offer.Media, media)
+		caps = append(caps, v)
+	}
+
+	this.prevLocal, this.prevVersion = this.local, this.version
+	this.stamp(offer)
+	this.offer, this.offerCaps = offer, caps
+	return offer, nil
+}
+
+// CancelOffer drops the pending offer, as after a glare.
+func (this *Negotiator) CancelOffer() {
+	if this.offer == nil {
+		return
+	}
+	this.local, this.version = this.prevLocal, this.prevVersion
+	this.offer, this.offerCaps = nil, nil
+}
+
+func (this *Negotiator) offerMedia(cap *MediaCapability, prev *NegotiatedMedia) (*MediaDescription, error) {
+	media := NewMediaDescription(cap.Media, cap.Port, cap.protos())
+	if prev != nil && prev.Mid != "" {
+		media.Attributes.Add(SDP_ATTR_MID, prev.Mid)
+	}
+
+	// a payload type keeps the codec it was negotiated with, RFC3264
+	// section 8.3.2, other codecs move to free ones
+	reserved := make(map[byte]bool)
+	if prev != nil && prev.Payloads != nil {
+		for _, v := range prev.Payloads.GetPayloadTypes() {
+			reserved[v] = true
+		}
+	}
+	used := make(map[byte]bool)
+	for _, v := range cap.Payloads {
+		profile := v
+		if pt, ok := previousPayloadType(prev, &profile); ok && !used[pt] {
+			profile.PayloadType = pt
+		} else if reserved[profile.PayloadType] || used[profile.PayloadType] {
+			pt, ok := freePayloadType(reserved, used)
+			if !ok {
+				return nil, ErrNoPayloadType
+			}
+			profile.PayloadType = pt
+		}
+		used[profile.PayloadType] = true
+
+		media.AddPayload(&profile)
+		for _, fb := range cap.RtcpFbs {
+			fb.Format = strconv.Itoa(int(profile.PayloadType))
+			media.Attributes.Add(SDP_ATTR_RTCP_FB, fb.String())
+		}
+	}
+
+	usedIds := make(map[byte]bool)
+	ids := make(map[string]byte)
+	if prev != nil && prev.Extensions != nil {
+		for _, uri := range cap.Extensions {
+			if id, ok := prev.Extensions.GetId(uri); ok {
+				ids[uri] = id
+				usedIds[id] = true
+			}
+		}
+	}
+	next := byte(1)
+	for _, uri := range cap.Extensions {
+		id, ok := ids[uri]
+		if !ok {
+			for usedIds[next] {
+				next++
+			}
+			id = next
+			usedIds[id] = true
+		}
+		ext := &ExtMap{Id: id, Uri: uri}
+		media.Attributes.Add(SDP_ATTR_EXTMAP, ext.String())
+	}
+
+	if cap.RtcpMux {
+		media.Attributes.Add(SDP_ATTR_RTCP_MUX, "")
+	}
+
+	// the key in use is offered again with its tag
+	var kept *srtp.SdesCrypto
+	if prev != nil && prev.LocalCrypto != nil && cap.hasCryptoSuite(prev.LocalCrypto.Suite) {
+		kept = prev.LocalCrypto
+	}
+	tag := 0
+	for _, v := range cap.CryptoSuites {
+		if kept != nil && kept.Suite == v {
+			media.AddCrypto(kept)
+			continue
+		}
+		tag++
+		if kept != nil && kept.Tag == tag {
+			tag++
+		}
+		crypto, err := srtp.NewSdesCrypto(tag, v)
+		if err != nil {
+			return nil, err
+		}
+		media.AddCrypto(crypto)
+	}
+
+	media.SetDirection(this.wanted(cap))
+	this.addLocalAttributes(media, cap)
+	return media, nil
+}
+
+// addLocalAttributes adds the direction and the attributes describing the
+// local source.
+func (this *Negotiator) addLocalAttributes(media *MediaDescription, cap *MediaCapability) {
+	if cap.Ptime != 0 {
+		media.Attributes.Add(SDP_ATTR_PTIME, strconv.FormatInt(int64(cap.Ptime/time.Millisecond), 10))
+	}
+	if cap.Ssrc != 0 {
+		ssrc := &Ssrc{Ssrc: cap.Ssrc, Attribute: "cname", Value: cap.Cname}
+		media.Attributes.Add(SDP_ATTR_SSRC, ssrc.String())
+	}
+}
+
+// previousPayloadType returns the payload type a codec was negotiated with.
+func previousPayloadType(prev *NegotiatedMedia, profile *rtp.RtpProfile) (byte, bool) {
+	if prev == nil || prev.Payloads == nil {
+		return 0, false
+	}
+	for _, v := range prev.Payloads.GetPayloadTypes() {
+		negotiated := prev.Payloads.Lookup(v)
+		if strings.EqualFold(negotiated.Name, profile.Name) && negotiated.ClockRate == profile.ClockRate &&
+			payloadChannels(negotiated) == payloadChannels(profile) {
+			return v, true
+		}
+	}
+	return 0, false
+}
+
+func freePayloadType(reserved, used map[byte]bool) (byte, bool) {
+	for pt := byte(rtp.RTP_DYNAMIC_PAYLOAD_TYPE_MIN); pt <= rtp.RTP_DYNAMIC_PAYLOAD_TYPE_MAX; pt++ {
+		if !reserved[pt] && !used[pt] {
+			return pt, true
+		}
+	}
+	return 0, false
+}
+
+// ProcessOffer answers an initial offer or a re-offer.
+func (this *Negotiator) ProcessOffer(offer *SessionDescription) (*SessionDescription, []*NegotiatedMedia, error) {
+	if this.offer != nil {
+		return nil, nil, ErrGlare
+	}
+	if len(offer.Media) < len(this.media) {
+		return nil, nil, ErrMediaRemoved
+	}
+
+	answer := this.newDescription()
+	negotiated := make([]*NegotiatedMedia, len(offer.Media))
+	used := make(map[*MediaCapability]bool)
+
+	for i, v := range offer.Media {
+		var prev *NegotiatedMedia
+		if i < len(this.media) {
+			prev = this.media[i]
+		}
+		cap := this.findCapability(v, prev, used)
+
+		media, result, err := this.answerMedia(offer, v, cap, prev)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !result.Rejected {
+			used[cap] = true
+		}
+		answer.Media = append(answer.Media, media)
+		negotiated[i] = result
+	}
+
+	this.stamp(answer)
+	this.remote, this.media = offer, negotiated
+	return answer, negotiated, nil
+}
+
+// findCapability prefers the capability the slot was negotiated with.
+func (this *Negotiator) findCapability(media *MediaDescription, prev *NegotiatedMedia, used map[*MediaCapability]bool) *MediaCapability {
+	if prev != nil && prev.capability != nil && prev.capability.Media == media.Media && !used[prev.capability] {
+		return prev.capability
+	}
+	for _, v := range this.Capabilities {
+		if v.Media == media.Media && !used[v] {
+			return v
+		}
+	}
+	return nil
+}
+
+func rejectMedia(remote *MediaDescription) (*MediaDescription, *NegotiatedMedia) {
+	media := NewMediaDescription(remote.Media, 0, remote.Protos)
+	media.Formats = remote.Formats
+	mid := remote.GetMid()
+	if mid != "" {
+		media.Attributes.Add(SDP_ATTR_MID, mid)
+	}
+	return media, &NegotiatedMedia{Media: remote.Media, Mid: mid, Rejected: true}
+}
+
+func (this *Negotiator) answerMedia(offer *SessionDescription, remote *MediaDescription, cap *MediaCapability, prev *NegotiatedMedia) (*MediaDescription, *NegotiatedMedia, error) {
+	secure := strings.Contains(remote.Protos, "SAVP")
+	if cap == nil || cap.Port == 0 || remote.Port == 0 || !remote.IsRtp() || secure != cap.secure() {
+		media, result := rejectMedia(remote)
+		return media, result, nil
+	}
+
+	media := NewMediaDescription(remote.Media, cap.Port, remote.Protos)
+	result := &NegotiatedMedia{
+		Media:      remote.Media,
+		Mid:        remote.GetMid(),
+		Payloads:   rtp.NewPayloadRegistry(),
+		Extensions: rtp.NewRtpExtensionMap(),
+		capability: cap,
+	}
+	if result.Mid != "" {
+		media.Attributes.Add(SDP_ATTR_MID, result.Mid)
+	}
+
+	types, err := remote.GetPayloadTypes()
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, pt := range types {
+		offered, err := remote.GetPayload(pt)
+		if err != nil {
+			return nil, nil, err
+		}
+		if offered == nil {
+			continue
+		}
+		local := cap.match(offered)
+		if local == nil {
+			continue
+		}
+
+		// the offered payload type is used in both directions
+		profile := *local
+		profile.PayloadType = pt
+		if len(media.Formats) == 0 {
+			result.SendPayloadType = pt
+		}
+		media.AddPayload(&profile)
+		result.Payloads.Register(profile)
+
+		fbs, err := remote.GetRtcpFbs(pt)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, fb := range fbs {
+			if cap.hasRtcpFb(fb) {
+				fb := &RtcpFb{Format: strconv.Itoa(int(pt)), Type: fb.Type, Param: fb.Param}
+				media.Attributes.Add(SDP_ATTR_RTCP_FB, fb.String())
+				result.RtcpFbs = append(result.RtcpFbs, fb)
+			}
+		}
+	}
+	if len(media.Formats) == 0 {
+		media, result := rejectMedia(remote)
+		return media, result, nil
+	}
+
+	exts, err := remote.GetExtMaps()
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, v := range exts {
+		if cap.hasExtension(v.Uri) && result.Extensions.Register(v.Id, v.Uri) == nil {
+			ext := &ExtMap{Id: v.Id, Uri: v.Uri}
+			media.Attributes.Add(SDP_ATTR_EXTMAP, ext.String())
+		}
+	}
+
+	if remote.RtcpMux() && cap.RtcpMux {
+		media.Attributes.Add(SDP_ATTR_RTCP_MUX, "")
+		result.RtcpMux = true
+	}
+
+	if secure {
+		err = this.answerCrypto(remote, cap, prev, result)
+		if err != nil {
+			return nil, nil, err
+		}
+		if result.LocalCrypto == nil {
+			media, result := rejectMedia(remote)
+			return media, result, nil
+		}
+		media.AddCrypto(result.LocalCrypto)
+	}
+
+	result.Direction = negotiateDirection(this.wanted(cap), remoteDirection(offer, remote))
+	media.SetDirection(result.Direction)
+	this.addLocalAttributes(media, cap)
+
+	setRemote(result, offer, remote)
+	return media, result, nil
+}
+
+// answerCrypto accepts the first offered crypto of a supported suite,
+// keeping the local key of the previous exchange when the remote one is
+// offered again.
+func (this *Negotiator) answerCrypto(remote *MediaDescription, cap *MediaCapability, prev *NegotiatedMedia, result *NegotiatedMedia) error {
+	cryptos, err := remote.GetCryptos()
+	if err != nil {
+		return err
+	}
+
+	for _, v := range cryptos {
+		if !cap.hasCryptoSuite(v.Suite) || len(v.Keys) == 0 {
+			continue
+		}
+		result.RemoteCrypto = v
+		if prev != nil && prev.LocalCrypto != nil && prev.LocalCrypto.Tag == v.Tag && prev.LocalCrypto.Suite == v.Suite {
+			result.LocalCrypto = prev.LocalCrypto
+			return nil
+		}
+		result.LocalCrypto, err = srtp.NewSdesCrypto(v.Tag, v.Suite)
+		return err
+	}
+	return nil
+}
+
+// remoteDirection is the direction of remote, a connection address of
+// 0.0.0.0 being the hold of RFC2543.
+func remoteDirection(sdp *SessionDescription, remote *MediaDescription) string {
+	dir := sdp.GetDirection(remote)
+	conn := sdp.GetConnection(remote)
+	if conn != nil && conn.Address == "0.0.0.0" {
+		send, _ := directionFlags(dir)
+		return directionOf(send, false)
+	}
+	return dir
+}
+
+// setRemote fills in what the other side signalled about its transport
+// and sources.
+func setRemote(result *NegotiatedMedia, sdp *SessionDescription, remote *MediaDescription) {
+	host := ""
+	if conn := sdp.GetConnection(remote); conn != nil {
+		host = conn.Address
+	}
+	result.RemoteRtpAddr = net.JoinHostPort(host, strconv.Itoa(int(remote.Port)))
+
+	rtcp, _ := remote.GetRtcp()
+	switch {
+	case result.RtcpMux:
+		result.RemoteRtcpAddr = result.RemoteRtpAddr
+	case rtcp != nil:
+		rtcpHost := host
+		if rtcp.Address != "" {
+			rtcpHost = rtcp.Address
+		}
+		result.RemoteRtcpAddr = net.JoinHostPort(rtcpHost, strconv.Itoa(int(rtcp.Port)))
+	default:
+		result.RemoteRtcpAddr = net.JoinHostPort(host, strconv.Itoa(int(remote.Port)+1))
+	}
+
+	ssrcs, _ := remote.GetSsrcs()
+	for _, v := range ssrcs {
+		if n := len(result.RemoteSsrcs); n == 0 || result.RemoteSsrcs[n-1] != v.Ssrc {
+			result.RemoteSsrcs = append(result.RemoteSsrcs, v.Ssrc)
+		}
+	}
+	result.RemotePtime, _ = remote.GetPtime()
+}
+
+// ProcessAnswer completes the pending offer.
+func (this *Negotiator) ProcessAnswer(answer *SessionDescription) ([]*NegotiatedMedia, error) {
+	if this.offer == nil {
+		return nil, ErrNoOffer
+	}
+	if len(answer.Media) != len(this.offer.Media) {
+		return nil, ErrBadAnswer
+	}
+
+	negotiated := make([]*NegotiatedMedia, len(answer.Media))
+	for i, v := range answer.Media {
+		result, err := this.acceptMedia(answer, v, this.offer.Media[i], this.offerCaps[i])
+		if err != nil {
+			return nil, err
+		}
+		negotiated[i] = result
+	}
+
+	this.remote, this.media = answer, negotiated
+	this.offer, this.offerCaps = nil, nil
+	return negotiated, nil
+}
+
+func (this *Negotiator) acceptMedia(answer *SessionDescription, remote, local *MediaDescription, cap *MediaCapability) (*NegotiatedMedia, error) {
+	if cap == nil || local.Port == 0 || remote.Port == 0 {
+		// the slot is offered again with the next offer
+		_, result := rejectMedia(remote)
+		result.capability = cap
+		return result, nil
+	}
+
+	result := &NegotiatedMedia{
+		Media:      remote.Media,
+		Mid:        remote.GetMid(),
+		Payloads:   rtp.NewPayloadRegistry(),
+		Extensions: rtp.NewRtpExtensionMap(),
+		capability: cap,
+	}
+
+	types, err := remote.GetPayloadTypes()
+	if err != nil {
+		return nil, err
+	}
+	if len(types) == 0 {
+		return nil, ErrBadAnswer
+	}
+	result.SendPayloadType = types[0]
+	for _, pt := range types {
+		offered, err := local.GetPayload(pt)
+		if err != nil {
+			return nil, err
+		}
+		if offered == nil || !local.hasFormat(pt) {
+			return nil, ErrBadAnswer
+		}
+		result.Payloads.Register(*offered)
+
+		fbs, err := remote.GetRtcpFbs(pt)
+		if err != nil {
+			return nil, err
+		}
+		for _, fb := range fbs {
+			if local.HasRtcpFb(pt, fb.Type, fb.Param) {
+				result.RtcpFbs = append(result.RtcpFbs, &RtcpFb{Format: strconv.Itoa(int(pt)), Type: fb.Type, Param: fb.Param})
+			}
+		}
+	}
+
+	exts, err := remote.GetExtMaps()
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range exts {
+		if cap.hasExtension(v.Uri) {
+			result.Extensions.Register(v.Id, v.Uri)
+		}
+	}
+
+	result.RtcpMux = local.RtcpMux() && remote.RtcpMux()
+
+	if cap.secure() {
+		err = acceptCrypto(remote, local, result)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result.Direction = negotiateDirection(local.GetDirection(), remoteDirection(answer, remote))
+	setRemote(result, answer, remote)
+	return result, nil
+}
+
+// acceptCrypto pairs the answered crypto with the offered one of its tag.
+func acceptCrypto(remote, local *MediaDescription, result *NegotiatedMedia) error {
+	answered, err := remote.GetCryptos()
+	if err != nil {
+		return err
+	}
+	offered, err := local.GetCryptos()
+	if err != nil {
+		return err
+	}
+	if len(answered) != 1 || len(answered[0].Keys) == 0 {
+		return ErrBadAnswer
+	}
+
+	for _, v := range offered {
+		if v.Tag == answered[0].Tag && v.Suite == answered[0].Suite {
+			result.LocalCrypto, result.RemoteCrypto = v, answered[0]
+			return nil
+		}
+	}
+	return ErrBadAnswer
+}
+
+func (this *MediaDescription) hasFormat(payloadType byte) bool {
+	format := strconv.Itoa(int(payloadType))
+	for _, v := range this.Formats {
+		if v == format {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,398 @@
+package sdp
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"rtp"
+	"srtp"
+
+	"github.com/lioneagle/goutil/src/test"
+)
+
+func newTestAudioCapability(port uint16) *MediaCapability {
+	return &MediaCapability{
+		Media: SDP_MEDIA_AUDIO,
+		Port:  port,
+		Payloads: []rtp.RtpProfile{
+			{PayloadType: 111, Name: "opus", HasClockRate: true, ClockRate: 48000, HasChannels: true, Channels: 2, Fmtp: "useinbandfec=1"},
+			rtp.StaticRtpProfiles[0],
+			{PayloadType: 101, Name: "telephone-event", HasClockRate: true, ClockRate: 8000, Fmtp: "0-15"},
+		},
+		Extensions:   []string{rtp.RTP_EXTENSION_URI_AUDIO_LEVEL, rtp.RTP_EXTENSION_URI_SDES_MID},
+		RtcpMux:      true,
+		CryptoSuites: []string{srtp.SRTP_AES_CM_128_HMAC_SHA1_80, srtp.SRTP_AES_CM_128_HMAC_SHA1_32},
+		Ptime:        20 * time.Millisecond,
+		Ssrc:         0x11223344,
+		Cname:        "alice@example.com",
+	}
+}
+
+func newTestVideoCapability(port uint16, profileLevelId string) *MediaCapability {
+	return &MediaCapability{
+		Media: SDP_MEDIA_VIDEO,
+		Port:  port,
+		Payloads: []rtp.RtpProfile{
+			{PayloadType: 96, Name: "H264", HasClockRate: true, ClockRate: 90000, Fmtp: "profile-level-id=" + profileLevelId + ";packetization-mode=1"},
+			{PayloadType: 97, Name: "VP8", HasClockRate: true, ClockRate: 90000},
+		},
+		RtcpFbs:      []RtcpFb{{Type: SDP_RTCP_FB_NACK}, {Type: SDP_RTCP_FB_NACK, Param: SDP_RTCP_FB_PLI}},
+		Extensions:   []string{rtp.RTP_EXTENSION_URI_TOFFSET},
+		CryptoSuites: []string{srtp.SRTP_AES_CM_128_HMAC_SHA1_80},
+	}
+}
+
+func newTestNegotiators() (*Negotiator, *Negotiator) {
+	alice := NewNegotiator(100, "192.0.2.1", newTestAudioCapability(5000), newTestVideoCapability(5002, "42e01f"))
+
+	bobAudio := newTestAudioCapability(6000)
+	bobAudio.Payloads = []rtp.RtpProfile{
+		rtp.StaticRtpProfiles[8],
+		{PayloadType: 120, Name: "OPUS", HasClockRate: true, ClockRate: 48000, HasChannels: true, Channels: 2},
+		{PayloadType: 100, Name: "telephone-event", HasClockRate: true, ClockRate: 8000},
+	}
+	bobAudio.Extensions = []string{rtp.RTP_EXTENSION_URI_SDES_MID}
+	bobAudio.CryptoSuites = []string{srtp.SRTP_AES_CM_128_HMAC_SHA1_32}
+	bobAudio.Ssrc, bobAudio.Cname = 0x55667788, "bob@example.com"
+	bobVideo := newTestVideoCapability(6002, "42e028")
+	bobVideo.RtcpFbs = bobVideo.RtcpFbs[1:]
+	bob := NewNegotiator(200, "198.51.100.7", bobAudio, bobVideo)
+	return alice, bob
+}
+
+// exchange runs offerer's offer through the answerer and returns both
+// outcomes.
+func exchange(t *testing.T, offerer, answerer *Negotiator) ([]*NegotiatedMedia, []*NegotiatedMedia) {
+	offer, err := offerer.CreateOffer()
+	test.EXPECT_EQ(t, err, nil, "")
+
+	// the descriptions go through text as they would on the wire
+	remoteOffer, err := ParseSessionDescription(offer.String())
+	test.EXPECT_EQ(t, err, nil, "")
+	answer, answered, err := answerer.ProcessOffer(remoteOffer)
+	test.EXPECT_EQ(t, err, nil, "")
+
+	remoteAnswer, err := ParseSessionDescription(answer.String())
+	test.EXPECT_EQ(t, err, nil, "")
+	offered, err := offerer.ProcessAnswer(remoteAnswer)
+	test.EXPECT_EQ(t, err, nil, "")
+	return offered, answered
+}
+
+func TestNegotiatorOfferAnswer(t *testing.T) {
+	alice, bob := newTestNegotiators()
+	offered, answered := exchange(t, alice, bob)
+
+	test.EXPECT_EQ(t, len(offered), 2, "")
+	test.EXPECT_EQ(t, len(answered), 2, "")
+
+	audio := alice.GetLocalDescription().Media[0]
+	test.EXPECT_EQ(t, audio.Protos, SDP_PROTO_RTP_SAVP, "")
+	test.EXPECT_EQ(t, audio.Formats, []string{"111", "0", "101"}, "")
+	test.EXPECT_EQ(t, alice.GetLocalDescription().Media[1].Protos, SDP_PROTO_RTP_SAVPF, "")
+
+	// the answer keeps the offered payload types
+	answer := bob.GetLocalDescription()
+	test.EXPECT_EQ(t, answer.Media[0].Formats, []string{"111", "101"}, "")
+	test.EXPECT_EQ(t, answer.Media[0].Port, uint16(6000), "")
+	for _, v := range [][]*NegotiatedMedia{offered, answered} {
+		test.EXPECT_EQ(t, v[0].Rejected, false, "")
+		test.EXPECT_EQ(t, v[0].Payloads.GetPayloadTypes(), []byte{101, 111}, "")
+		test.EXPECT_EQ(t, v[0].SendPayloadType, byte(111), "")
+		test.EXPECT_EQ(t, v[0].RtcpMux, true, "")
+		test.EXPECT_EQ(t, v[0].Direction, SDP_DIRECTION_SENDRECV, "")
+		id, ok := v[0].Extensions.GetId(rtp.RTP_EXTENSION_URI_SDES_MID)
+		test.EXPECT_EQ(t, id, byte(2), "")
+		test.EXPECT_EQ(t, ok, true, "")
+		_, ok = v[0].Extensions.GetId(rtp.RTP_EXTENSION_URI_AUDIO_LEVEL)
+		test.EXPECT_EQ(t, ok, false, "")
+	}
+	test.EXPECT_EQ(t, offered[0].Payloads.Lookup(111).Fmtp, "useinbandfec=1", "")
+	test.EXPECT_EQ(t, answered[0].Payloads.Lookup(111).Name, "OPUS", "")
+
+	// the 32-bit suite is the one both support, each side has its own key
+	test.EXPECT_EQ(t, offered[0].LocalCrypto.Suite, srtp.SRTP_AES_CM_128_HMAC_SHA1_32, "")
+	test.EXPECT_EQ(t, offered[0].LocalCrypto.Tag, 2, "")
+	test.EXPECT_EQ(t, offered[0].LocalCrypto.Keys, answered[0].RemoteCrypto.Keys, "")
+	test.EXPECT_EQ(t, offered[0].RemoteCrypto.Keys, answered[0].LocalCrypto.Keys, "")
+	_, err := offered[0].LocalCrypto.NewSrtpContext()
+	test.EXPECT_EQ(t, err, nil, "")
+
+	test.EXPECT_EQ(t, offered[0].RemoteRtpAddr, "198.51.100.7:6000", "")
+	test.EXPECT_EQ(t, offered[0].RemoteRtcpAddr, "198.51.100.7:6000", "")
+	test.EXPECT_EQ(t, offered[0].RemoteSsrcs, []uint32{0x55667788}, "")
+	test.EXPECT_EQ(t, offered[0].RemotePtime, 20*time.Millisecond, "")
+	test.EXPECT_EQ(t, answered[0].RemoteRtpAddr, "192.0.2.1:5000", "")
+	test.EXPECT_EQ(t, answered[0].RemoteSsrcs, []uint32{0x11223344}, "")
+
+	// H.264 levels may differ, only the common feedback is kept
+	for _, v := range [][]*NegotiatedMedia{offered, answered} {
+		test.EXPECT_EQ(t, v[1].Rejected, false, "")
+		test.EXPECT_EQ(t, v[1].Payloads.GetPayloadTypes(), []byte{96, 97}, "")
+		test.EXPECT_EQ(t, v[1].HasRtcpFb(96, SDP_RTCP_FB_NACK, SDP_RTCP_FB_PLI), true, "")
+		test.EXPECT_EQ(t, v[1].HasRtcpFb(97, SDP_RTCP_FB_NACK, SDP_RTCP_FB_PLI), true, "")
+		test.EXPECT_EQ(t, v[1].HasRtcpFb(96, SDP_RTCP_FB_NACK, ""), false, "")
+		test.EXPECT_EQ(t, v[1].RtcpMux, false, "")
+	}
+	test.EXPECT_EQ(t, offered[1].Payloads.Lookup(96).FmtpParameters()[SDP_FMTP_H264_PROFILE_LEVEL_ID], "42e01f", "")
+	test.EXPECT_EQ(t, answered[1].Payloads.Lookup(96).FmtpParameters()[SDP_FMTP_H264_PROFILE_LEVEL_ID], "42e028", "")
+	test.EXPECT_EQ(t, offered[1].RemoteRtcpAddr, "198.51.100.7:6003", "")
+
+	session := rtp.NewSession("alice", 48000)
+	offered[0].Apply(session)
+	test.EXPECT_EQ(t, session.Payloads.GetClockRate(111), uint32(48000), "")
+}
+
+func TestNegotiatorReject(t *testing.T) {
+	alice, _ := newTestNegotiators()
+
+	testdata := []struct {
+		change func(cap *MediaCapability)
+	}{
+		// packetization-mode differs
+		{func(cap *MediaCapability) {
+			cap.Payloads = cap.Payloads[:1]
+			cap.Payloads[0].Fmtp = "profile-level-id=42e01f"
+		}},
+		// profile differs
+		{func(cap *MediaCapability) {
+			cap.Payloads = cap.Payloads[:1]
+			cap.Payloads[0].Fmtp = "profile-level-id=64001f;packetization-mode=1"
+		}},
+		// no srtp
+		{func(cap *MediaCapability) { cap.CryptoSuites = nil }},
+		// no common suite
+		{func(cap *MediaCapability) { cap.CryptoSuites = []string{srtp.SRTP_AEAD_AES_128_GCM} }},
+		// disabled
+		{func(cap *MediaCapability) { cap.Port = 0 }},
+	}
+
+	offer, err := alice.CreateOffer()
+	test.EXPECT_EQ(t, err, nil, "")
+
+	for i, v := range testdata {
+		v := v
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			t.Parallel()
+
+			cap := newTestVideoCapability(6002, "42e01f")
+			v.change(cap)
+			bob := NewNegotiator(200, "198.51.100.7", cap)
+
+			answer, answered, err := bob.ProcessOffer(offer)
+			test.EXPECT_EQ(t, err, nil, "")
+			test.EXPECT_EQ(t, answered[0].Rejected, true, "")
+			test.EXPECT_EQ(t, answered[1].Rejected, true, "")
+			test.EXPECT_EQ(t, answer.Media[1].Port, uint16(0), "")
+			test.EXPECT_EQ(t, answer.Media[1].Formats, offer.Media[1].Formats, "")
+		})
+	}
+}
+
+func TestFmtpCompatibleH264Profile(t *testing.T) {
+	testdata := []struct {
+		local  string
+		remote string
+		ok     bool
+	}{
+		// constrained baseline in its three encodings
+		{"42e01f", "42c01f", true},
+		{"42e01f", "4d801f", true},
+		{"42e01f", "58c01f", true},
+		{"42e01f", "42e028", true},
+		{"42001f", "42101f", true},
+		{"42001f", "42e01f", false},
+		{"42001f", "58801f", true},
+		{"4d001f", "4d101f", true},
+		{"4d001f", "42e01f", false},
+		{"64001f", "640c1f", false},
+		{"640c1f", "640c28", true},
+		{"f4001f", "f4001f", true},
+		{"f4001f", "f4101f", false},
+		{"42e01", "42e01f", false},
+		{"42e0zz", "42e01f", false},
+	}
+
+	for i, v := range testdata {
+		local := &rtp.RtpProfile{Name: "H264", Fmtp: "profile-level-id=" + v.local}
+		remote := &rtp.RtpProfile{Name: "H264", Fmtp: "profile-level-id=" + v.remote}
+		test.EXPECT_EQ(t, fmtpCompatible(local, remote), v.ok, "[%d]", i)
+		test.EXPECT_EQ(t, fmtpCompatible(remote, local), v.ok, "[%d]", i)
+	}
+}
+
+func TestNegotiateDirection(t *testing.T) {
+	testdata := []struct {
+		local  string
+		remote string
+		wanted string
+	}{
+		{SDP_DIRECTION_SENDRECV, SDP_DIRECTION_SENDRECV, SDP_DIRECTION_SENDRECV},
+		{SDP_DIRECTION_SENDRECV, SDP_DIRECTION_SENDONLY, SDP_DIRECTION_RECVONLY},
+		{SDP_DIRECTION_SENDRECV, SDP_DIRECTION_RECVONLY, SDP_DIRECTION_SENDONLY},
+		{SDP_DIRECTION_SENDRECV, SDP_DIRECTION_INACTIVE, SDP_DIRECTION_INACTIVE},
+		{SDP_DIRECTION_SENDONLY, SDP_DIRECTION_SENDRECV, SDP_DIRECTION_SENDONLY},
+		{SDP_DIRECTION_SENDONLY, SDP_DIRECTION_SENDONLY, SDP_DIRECTION_INACTIVE},
+		{SDP_DIRECTION_RECVONLY, SDP_DIRECTION_SENDONLY, SDP_DIRECTION_RECVONLY},
+		{SDP_DIRECTION_INACTIVE, SDP_DIRECTION_SENDRECV, SDP_DIRECTION_INACTIVE},
+	}
+
+	for i, v := range testdata {
+		test.EXPECT_EQ(t, negotiateDirection(v.local, v.remote), v.wanted, "[%d]", i)
+	}
+}
+
+func TestNegotiatorHoldResume(t *testing.T) {
+	alice, bob := newTestNegotiators()
+	exchange(t, alice, bob)
+	version := alice.GetLocalDescription().Origin.SessionVersion
+
+	alice.Hold()
+	test.EXPECT_EQ(t, alice.OnHold(), true, "")
+	offered, answered := exchange(t, alice, bob)
+	test.EXPECT_EQ(t, alice.GetLocalDescription().Origin.SessionVersion, version+1, "")
+	test.EXPECT_EQ(t, alice.GetLocalDescription().Media[0].GetDirection(), SDP_DIRECTION_SENDONLY, "")
+	test.EXPECT_EQ(t, bob.GetLocalDescription().Media[0].GetDirection(), SDP_DIRECTION_RECVONLY, "")
+	test.EXPECT_EQ(t, offered[0].Send(), true, "")
+	test.EXPECT_EQ(t, offered[0].Recv(), false, "")
+	test.EXPECT_EQ(t, answered[0].Send(), false, "")
+	test.EXPECT_EQ(t, answered[0].Recv(), true, "")
+
+	// bob holding too makes the media inactive
+	bob.Hold()
+	offered, answered = exchange(t, alice, bob)
+	test.EXPECT_EQ(t, offered[0].Direction, SDP_DIRECTION_INACTIVE, "")
+	test.EXPECT_EQ(t, answered[0].Direction, SDP_DIRECTION_INACTIVE, "")
+
+	alice.Resume()
+	bob.Resume()
+	offered, answered = exchange(t, alice, bob)
+	test.EXPECT_EQ(t, offered[0].Direction, SDP_DIRECTION_SENDRECV, "")
+	test.EXPECT_EQ(t, answered[0].Direction, SDP_DIRECTION_SENDRECV, "")
+	test.EXPECT_EQ(t, alice.GetLocalDescription().Origin.SessionVersion, version+3, "")
+}
+
+func TestNegotiatorLegacyHold(t *testing.T) {
+	alice, bob := newTestNegotiators()
+	offer, err := alice.CreateOffer()
+	test.EXPECT_EQ(t, err, nil, "")
+	offer.Connection.Address = "0.0.0.0"
+
+	_, answered, err := bob.ProcessOffer(offer)
+	test.EXPECT_EQ(t, err, nil, "")
+	test.EXPECT_EQ(t, answered[0].Direction, SDP_DIRECTION_RECVONLY, "")
+}
+
+func TestNegotiatorReoffer(t *testing.T) {
+	alice, bob := newTestNegotiators()
+	offered, _ := exchange(t, alice, bob)
+	key := offered[0].LocalCrypto.Keys[0].Key
+	version := bob.GetLocalDescription().Origin.SessionVersion
+	answer := bob.GetLocalDescription().String()
+
+	// a re-offer by bob keeps the payload types alice chose and the keys
+	bobMedia, aliceMedia := exchange(t, bob, alice)
+	test.EXPECT_EQ(t, bob.GetLocalDescription().Media[0].Formats, []string{"8", "111", "101"}, "")
+	test.EXPECT_EQ(t, bobMedia[0].Payloads.GetPayloadTypes(), []byte{101, 111}, "")
+	test.EXPECT_EQ(t, aliceMedia[0].Payloads.GetPayloadTypes(), []byte{101, 111}, "")
+	test.EXPECT_EQ(t, aliceMedia[0].LocalCrypto.Keys[0].Key, key, "")
+	test.EXPECT_EQ(t, bobMedia[0].LocalCrypto.Keys, aliceMedia[0].RemoteCrypto.Keys, "")
+	test.EXPECT_EQ(t, bobMedia[0].LocalCrypto.Tag, 2, "")
+	test.EXPECT_EQ(t, bob.GetLocalDescription().Origin.SessionVersion, version+1, "")
+
+	// an unchanged re-offer keeps the version of the answer
+	alice2, bob2 := newTestNegotiators()
+	exchange(t, alice2, bob2)
+	offer := alice2.GetLocalDescription()
+	answer2, _, err := bob2.ProcessOffer(offer)
+	test.EXPECT_EQ(t, err, nil, "")
+	test.EXPECT_EQ(t, answer2.Origin.SessionVersion, uint64(200), "")
+	test.EXPECT_EQ(t, strings.Contains(answer, "o=- 200 200 "), true, "")
+}
+
+func TestNegotiatorReofferPayloadTypes(t *testing.T) {
+	aliceVideo := newTestVideoCapability(5002, "42e01f")
+	aliceVideo.Payloads = []rtp.RtpProfile{aliceVideo.Payloads[1], aliceVideo.Payloads[0]}
+	aliceVideo.Payloads[0].PayloadType, aliceVideo.Payloads[1].PayloadType = 96, 97
+	alice := NewNegotiator(100, "192.0.2.1", aliceVideo)
+
+	bobVideo := newTestVideoCapability(6002, "42e01f")
+	bobVideo.Payloads = bobVideo.Payloads[:1]
+	bob := NewNegotiator(200, "198.51.100.7", bobVideo)
+
+	aliceMedia, _ := exchange(t, bob, alice)
+	test.EXPECT_EQ(t, aliceMedia[0].Payloads.GetName(96), "H264", "")
+
+	// H264 keeps 96 and VP8 moves away from it
+	exchange(t, alice, bob)
+	media := alice.GetLocalDescription().Media[0]
+	test.EXPECT_EQ(t, media.Formats, []string{"97", "96"}, "")
+	rtpMap, err := media.GetRtpMap(96)
+	test.EXPECT_EQ(t, err, nil, "")
+	test.EXPECT_EQ(t, rtpMap.EncodingName, "H264", "")
+	rtpMap, err = media.GetRtpMap(97)
+	test.EXPECT_EQ(t, err, nil, "")
+	test.EXPECT_EQ(t, rtpMap.EncodingName, "VP8", "")
+}
+
+func TestNegotiatorNoPayloadType(t *testing.T) {
+	video := newTestVideoCapability(6000, "42e01f")
+	vp8 := video.Payloads[1]
+	video.Payloads = video.Payloads[:1]
+	for i := 0; i < 31; i++ {
+		video.Payloads = append(video.Payloads, vp8)
+	}
+
+	// every dynamic payload type up to 127 is handed out
+	offer, err := NewNegotiator(100, "192.0.2.1", video).CreateOffer()
+	test.EXPECT_EQ(t, err, nil, "")
+	formats := offer.Media[0].Formats
+	test.EXPECT_EQ(t, len(formats), 32, "")
+	test.EXPECT_EQ(t, formats[len(formats)-1], "127", "")
+
+	// one more codec finds none free
+	video.Payloads = append(video.Payloads, vp8)
+	_, err = NewNegotiator(100, "192.0.2.1", video).CreateOffer()
+	test.EXPECT_EQ(t, err, ErrNoPayloadType, "")
+}
+
+func TestNegotiatorError(t *testing.T) {
+	alice, bob := newTestNegotiators()
+
+	_, err := alice.ProcessAnswer(NewSessionDescription(1, "192.0.2.9"))
+	test.EXPECT_EQ(t, err, ErrNoOffer, "")
+
+	offer, err := alice.CreateOffer()
+	test.EXPECT_EQ(t, err, nil, "")
+	_, _, err = alice.ProcessOffer(offer)
+	test.EXPECT_EQ(t, err, ErrGlare, "")
+
+	answer, _, err := bob.ProcessOffer(offer)
+	test.EXPECT_EQ(t, err, nil, "")
+
+	bad := *answer
+	bad.Media = bad.Media[:1]
+	_, err = alice.ProcessAnswer(&bad)
+	test.EXPECT_EQ(t, err, ErrBadAnswer, "")
+
+	bad = *answer
+	audio := *bad.Media[0]
+	audio.Formats = []string{"8"}
+	bad.Media = []*MediaDescription{&audio, bad.Media[1]}
+	_, err = alice.ProcessAnswer(&bad)
+	test.EXPECT_EQ(t, err, ErrBadAnswer, "")
+
+	alice.CancelOffer()
+	_, err = alice.ProcessAnswer(answer)
+	test.EXPECT_EQ(t, err, ErrNoOffer, "")
+
+	exchange(t, alice, bob)
+	offer, err = alice.CreateOffer()
+	test.EXPECT_EQ(t, err, nil, "")
+	alice.CancelOffer()
+	offer.Media = offer.Media[:1]
+	_, _, err = bob.ProcessOffer(offer)
+	test.EXPECT_EQ(t, err, ErrMediaRemoved, "")
+}
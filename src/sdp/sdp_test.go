@@ -0,0 +1,146 @@
+package sdp
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/lioneagle/goutil/src/test"
+)
+
+const testSdpOffer = "v=0\r\n" +
+	"o=jdoe 2890844526 2890842807 IN IP4 10.47.16.5\r\n" +
+	"s=SDP Seminar\r\n" +
+	"i=A Seminar on the session description protocol\r\n" +
+	"u=http://www.example.com/seminars/sdp.pdf\r\n" +
+	"e=j.doe@example.com (Jane Doe)\r\n" +
+	"c=IN IP4 224.2.17.12/127\r\n" +
+	"b=CT:1000\r\n" +
+	"t=2873397496 2873404696\r\n" +
+	"r=7d 1h 0 25h\r\n" +
+	"a=recvonly\r\n" +
+	"a=x-unknown:some value\r\n" +
+	"m=audio 49170 RTP/SAVPF 0 96 101\r\n" +
+	"c=IN IP4 10.47.16.5\r\n" +
+	"b=AS:64\r\n" +
+	"a=rtpmap:96 opus/48000/2\r\n" +
+	"a=fmtp:96 minptime=10;useinbandfec=1\r\n" +
+	"a=rtpmap:101 telephone-event/8000\r\n" +
+	"a=fmtp:101 0-16\r\n" +
+	"a=rtcp:53020 IN IP4 126.16.64.4\r\n" +
+	"a=rtcp-mux\r\n" +
+	"a=rtcp-fb:* nack\r\n" +
+	"a=extmap:1 urn:ietf:params:rtp-hdrext:ssrc-audio-level\r\n" +
+	"a=extmap:3/sendonly urn:ietf:params:rtp-hdrext:sdes:mid\r\n" +
+	"a=ssrc:314159 cname:user@example.com\r\n" +
+	"a=mid:audio\r\n" +
+	"a=ptime:20\r\n" +
+	"a=sendrecv\r\n" +
+	"a=crypto:1 AES_CM_128_HMAC_SHA1_80 inline:PS1uQCVeeCFCanVmcjkpPywjNWhcYD0mXXtxaVBR|2^20|1:4\r\n" +
+	"a=x-vendor\r\n" +
+	"m=video 51372/2 RTP/AVPF 96 97\r\n" +
+	"a=rtpmap:96 VP8/90000\r\n" +
+	"a=rtpmap:97 H264/90000\r\n" +
+	"a=fmtp:97 profile-level-id=42e01f;packetization-mode=1\r\n" +
+	"a=rtcp-fb:96 nack pli\r\n" +
+	"a=rtcp-fb:97 ccm fir\r\n" +
+	"a=inactive\r\n"
+
+func TestParseSessionDescription(t *testing.T) {
+	sdp, err := ParseSessionDescription(testSdpOffer)
+	test.EXPECT_EQ(t, err, nil, "")
+
+	test.EXPECT_EQ(t, sdp.Origin, Origin{"jdoe", 2890844526, 2890842807, "IN", "IP4", "10.47.16.5"}, "")
+	test.EXPECT_EQ(t, sdp.SessionName, "SDP Seminar", "")
+	test.EXPECT_EQ(t, sdp.Emails, []string{"j.doe@example.com (Jane Doe)"}, "")
+	test.EXPECT_EQ(t, *sdp.Connection, Connection{"IN", "IP4", "224.2.17.12", 127, 0}, "")
+	test.EXPECT_EQ(t, sdp.Bandwidths, []Bandwidth{{"CT", 1000}}, "")
+	test.EXPECT_EQ(t, sdp.Timings, []Timing{{2873397496, 2873404696, []string{"7d 1h 0 25h"}}}, "")
+	test.EXPECT_EQ(t, sdp.Attributes, Attributes{{"recvonly", ""}, {"x-unknown", "some value"}}, "")
+	test.EXPECT_EQ(t, len(sdp.Media), 2, "")
+
+	audio := sdp.Media[0]
+	test.EXPECT_EQ(t, audio.Media, "audio", "")
+	test.EXPECT_EQ(t, audio.Port, uint16(49170), "")
+	test.EXPECT_EQ(t, audio.Protos, "RTP/SAVPF", "")
+	test.EXPECT_EQ(t, audio.Formats, []string{"0", "96", "101"}, "")
+	test.EXPECT_EQ(t, audio.Bandwidths, []Bandwidth{{"AS", 64}}, "")
+	test.EXPECT_EQ(t, sdp.GetConnection(audio).Address, "10.47.16.5", "")
+	test.EXPECT_EQ(t, sdp.GetDirection(audio), SDP_DIRECTION_SENDRECV, "")
+
+	video := sdp.Media[1]
+	test.EXPECT_EQ(t, video.Port, uint16(51372), "")
+	test.EXPECT_EQ(t, video.NumPorts, 2, "")
+	test.EXPECT_EQ(t, sdp.GetConnection(video).Address, "224.2.17.12", "")
+	test.EXPECT_EQ(t, sdp.GetDirection(video), SDP_DIRECTION_INACTIVE, "")
+}
+
+func TestSessionDescriptionLossless(t *testing.T) {
+	testdata := []string{
+		testSdpOffer,
+		"v=0\r\no=- 1 1 IN IP6 ::1\r\ns=-\r\nc=IN IP6 ff15::101/3\r\nt=0 0\r\nt=1 2\r\nz=2882844526 -1h\r\nk=prompt\r\n" +
+			"m=application 9 UDP/DTLS/SCTP webrtc-datachannel\r\na=sctp-port:5000\r\na=x-empty\r\n",
+	}
+
+	for i, v := range testdata {
+		v := v
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			t.Parallel()
+
+			sdp, err := ParseSessionDescription(v)
+			test.EXPECT_EQ(t, err, nil, "")
+			test.EXPECT_EQ(t, sdp.String(), v, "")
+
+			// LF line endings give the same description
+			sdp, err = ParseSessionDescription(strings.Replace(v, "\r\n", "\n", -1))
+			test.EXPECT_EQ(t, err, nil, "")
+			test.EXPECT_EQ(t, sdp.String(), v, "")
+		})
+	}
+}
+
+func TestParseSessionDescriptionError(t *testing.T) {
+	testdata := []struct {
+		data string
+		line int
+		err  error
+	}{
+		{"v=1\r\no=- 1 1 IN IP4 0.0.0.0\r\ns=-\r\n", 1, ErrBadVersion},
+		{"o=- 1 1 IN IP4 0.0.0.0\r\ns=-\r\n", 1, ErrMissingLine},
+		{"v=0\r\ns=-\r\no=- 1 1 IN IP4 0.0.0.0\r\n", 3, ErrBadOrder},
+		{"v=0\r\no=- 1 1 IN IP4\r\ns=-\r\n", 2, ErrBadLine},
+		{"v=0\r\no=- 1 1 IN IP4 0.0.0.0\r\ns=-\r\nx=1\r\n", 4, ErrUnknownType},
+		{"v=0\r\no=- 1 1 IN IP4 0.0.0.0\r\ns=-\r\nc=IN IP4 1.2.3.4/1/2/3\r\n", 4, ErrBadLine},
+		{"v=0\r\no=- 1 1 IN IP4 0.0.0.0\r\ns=-\r\nb=AS\r\n", 4, ErrBadLine},
+		{"v=0\r\no=- 1 1 IN IP4 0.0.0.0\r\ns=-\r\nt=0\r\n", 4, ErrBadLine},
+		{"v=0\r\no=- 1 1 IN IP4 0.0.0.0\r\ns=-\r\nm=audio 70000 RTP/AVP 0\r\n", 4, ErrBadLine},
+		{"v=0\r\no=- 1 1 IN IP4 0.0.0.0\r\ns=-\r\nm=audio 9 RTP/AVP 0\r\na=sendrecv\r\nc=IN IP4 1.2.3.4\r\n", 6, ErrBadOrder},
+		{"v=0\r\no=- 1 1 IN IP4 0.0.0.0\r\ns=-\r\nm=audio 9 RTP/AVP 0\r\nt=0 0\r\n", 5, ErrUnknownType},
+		{"v=0\r\no=- 1 1 IN IP4 0.0.0.0\r\nbad\r\n", 3, ErrBadLine},
+		{"v=0\r\ns=-\r\n", 3, ErrMissingLine},
+	}
+
+	for i, v := range testdata {
+		_, err := ParseSessionDescription(v.data)
+		perr, ok := err.(*ParseError)
+		test.EXPECT_EQ(t, ok, true, "[%d]", i)
+		if ok {
+			test.EXPECT_EQ(t, perr.Line, v.line, "[%d]", i)
+			test.EXPECT_EQ(t, perr.Err, v.err, "[%d]", i)
+		}
+	}
+}
+
+func TestNewSessionDescription(t *testing.T) {
+	sdp := NewSessionDescription(1234, "192.0.2.1")
+	media := NewMediaDescription(SDP_MEDIA_AUDIO, 5004, SDP_PROTO_RTP_AVP)
+	media.SetDirection(SDP_DIRECTION_SENDONLY)
+	media.SetDirection(SDP_DIRECTION_RECVONLY)
+	sdp.Media = append(sdp.Media, media)
+
+	wanted := "v=0\r\no=- 1234 1234 IN IP4 192.0.2.1\r\ns=-\r\nc=IN IP4 192.0.2.1\r\nt=0 0\r\n" +
+		"m=audio 5004 RTP/AVP\r\na=recvonly\r\n"
+	test.EXPECT_EQ(t, sdp.String(), wanted, "")
+
+	test.EXPECT_EQ(t, NewSessionDescription(1, "2001:db8::1").Connection.AddrType, SDP_ADDR_TYPE_IP6, "")
+}
@@ -0,0 +1,85 @@
+package srtp
+
+import (
+	"encoding/binary"
+)
+
+// rtpAeadIv is the IV of RFC7714 section 8.1: 0x0000 || SSRC || ROC || SEQ
+// XOR the session salt.
+func (this *srtpSessionKeys) rtpAeadIv(ssrc, roc uint32, seq uint16) []byte {
+	iv := make([]byte, 12)
+	binary.BigEndian.PutUint32(iv[2:], ssrc)
+	binary.BigEndian.PutUint32(iv[6:], roc)
+	binary.BigEndian.PutUint16(iv[10:], seq)
+	for i := range iv {
+		iv[i] ^= this.salt[i]
+	}
+	return iv
+}
+
+// rtcpAeadIv is the IV of RFC7714 section 9.1: 0x0000 || SSRC || 0x0000 ||
+// SRTCP index XOR the session salt.
+func (this *srtpSessionKeys) rtcpAeadIv(ssrc, index uint32) []byte {
+	iv := make([]byte, 12)
+	binary.BigEndian.PutUint32(iv[2:], ssrc)
+	binary.BigEndian.PutUint32(iv[8:], index&SRTCP_INDEX_MARSK)
+	for i := range iv {
+		iv[i] ^= this.salt[i]
+	}
+	return iv
+}
+
+// sealRtp encrypts the payload after headerLen in place and appends the tag,
+// the header is the associated data.
+func (this *srtpSessionKeys) sealRtp(data []byte, headerLen int, ssrc, roc uint32, seq uint16) []byte {
+	return this.aead.Seal(data[:headerLen], this.rtpAeadIv(ssrc, roc, seq), data[headerLen:], data[:headerLen])
+}
+
+// openRtp authenticates and decrypts the cipher text after headerLen and
+// returns the length of the plain packet. data is not changed on an error.
+func (this *srtpSessionKeys) openRtp(data []byte, headerLen int, ssrc, roc uint32, seq uint16) (int, error) {
+	plain, err := this.aead.Open(nil, this.rtpAeadIv(ssrc, roc, seq), data[headerLen:], data[:headerLen])
+	if err != nil {
+		return 0, ErrAuthFailed
+	}
+	return headerLen + copy(data[headerLen:], plain), nil
+}
+
+// sealRtcp protects a compound packet as in RFC7714 section 9: with the E
+// flag of trailer the packet after its first 8 octets is encrypted, without
+// it the whole packet is associated data. The E flag and index follow as
+// associated data as well.
+func (this *srtpSessionKeys) sealRtcp(data []byte, ssrc uint32, trailer []byte) []byte {
+	word := binary.BigEndian.Uint32(trailer)
+	iv := this.rtcpAeadIv(ssrc, word)
+	if word&SRTCP_E_FLAG_MARSK == 0 {
+		aad := append(append([]byte(nil), data...), trailer...)
+		return this.aead.Seal(data, iv, nil, aad)
+	}
+
+	aad := append(append([]byte(nil), data[:SRTCP_HEADER_LEN]...), trailer...)
+	return this.aead.Seal(data[:SRTCP_HEADER_LEN], iv, data[SRTCP_HEADER_LEN:], aad)
+}
+
+// openRtcp authenticates data, the SRTCP packet up to the E flag and index
+// in trailer, decrypts it in place and returns the length of the compound
+// packet.
+func (this *srtpSessionKeys) openRtcp(data []byte, ssrc uint32, trailer []byte) (int, error) {
+	word := binary.BigEndian.Uint32(trailer)
+	iv := this.rtcpAeadIv(ssrc, word)
+	if word&SRTCP_E_FLAG_MARSK == 0 {
+		end := len(data) - this.aead.Overhead()
+		aad := append(append([]byte(nil), data[:end]...), trailer...)
+		if _, err := this.aead.Open(nil, iv, data[end:], aad); err != nil {
+			return 0, ErrAuthFailed
+		}
+		return end, nil
+	}
+
+	aad := append(append([]byte(nil), data[:SRTCP_HEADER_LEN]...), trailer...)
+	plain, err := this.aead.Open(nil, iv, data[SRTCP_HEADER_LEN:], aad)
+	if err != nil {
+		return 0, ErrAuthFailed
+	}
+	return SRTCP_HEADER_LEN + copy(data[SRTCP_HEADER_LEN:], plain), nil
+}
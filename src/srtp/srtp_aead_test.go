@@ -0,0 +1,161 @@
+package srtp
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"rtp"
+
+	"github.com/lioneagle/goutil/src/test"
+)
+
+// the test vectors of RFC7714 give the session keys, the context is made to
+// use them instead of derived ones
+func newTestAeadContext(name, key string) *SrtpContext {
+	profile := SrtpProfiles[name]
+	ctx, err := NewSrtpContext(profile, make([]byte, profile.KeyLen), make([]byte, profile.SaltLen))
+	if err != nil {
+		panic(err)
+	}
+
+	keys := &srtpSessionKeys{salt: fromHex("517569642070726f2071756f")}
+	keys.block, _ = aes.NewCipher(fromHex(key))
+	keys.aead, _ = cipher.NewGCM(keys.block)
+	ctx.srtpKeys = keys
+	ctx.srtcpKeys = keys
+	return ctx
+}
+
+const (
+	testAeadKey128 = "000102030405060708090a0b0c0d0e0f"
+	testAeadKey256 = "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"
+
+	// "Gallia est omnis divisa in partes tres"
+	testAeadRtp  = "8040f17b8041f8d35501a0b247616c6c696120657374206f6d6e69732064697669736120696e207061727465732074726573"
+	testAeadRtcp = "81c8000d4d6172734e5450314e545032525450200000042a0000e9304c756e61deadbeefdeadbeefdeadbeefdeadbeefdeadbeef"
+)
+
+// RFC7714 sections 16 and 17
+func TestSrtpAeadVectors(t *testing.T) {
+	testdata := []struct {
+		name        string
+		key         string
+		rtp         string
+		rtcp        string
+		unencrypted string
+	}{
+		{
+			SRTP_AEAD_AES_128_GCM, testAeadKey128,
+			"8040f17b8041f8d35501a0b2f24de3a3fb34de6cacba861c9d7e4bcabe633bd50d294e6f42a5f47a51c7d19b36de3adf8833899d7f27beb16a9152cf765ee4390cce",
+			"81c8000d4d61727363e94885dcdab67ca727d7662f6b7e997ff5c0f76c06f32dc676a5f1730d6fda4ce09b4686303ded0bb9275bc84aa45896cf4d2fc5abf87245d9eade800005d4",
+			"841dd9683dd78ec92ae58790125f62b3000005d4",
+		},
+		{
+			SRTP_AEAD_AES_256_GCM, testAeadKey256,
+			"8040f17b8041f8d35501a0b232b1de78a822fe12ef9f78fa332e33aab18012389a58e2f3b50b2a0276ffae0f1ba63799b87b7aa3db36dfffd6b0f9bb7878d7a76c13",
+			"81c8000d4d617273d50ae4d1f5ce5d304ba297e47d470c282c3ece5dbffe0a50a2eaa5c1110555be8415f658c61de0476f1b6fad1d1eb30c4446839f57ff6f6cb26ac3be800005d4",
+			"91db4afbfeee5a978fab4393ed2615fe000005d4",
+		},
+	}
+
+	for i, v := range testdata {
+		v := v
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			t.Parallel()
+
+			sender := newTestAeadContext(v.name, v.key)
+			receiver := newTestAeadContext(v.name, v.key)
+
+			packet := rtp.NewRtpPacket()
+			packet.CopyFromBytes(fromHex(testAeadRtp))
+			test.EXPECT_EQ(t, sender.ProtectRtp(packet), nil, "")
+			test.EXPECT_EQ(t, hex.EncodeToString(packet.Bytes()), v.rtp, "")
+			test.EXPECT_EQ(t, receiver.UnprotectRtp(packet), nil, "")
+			test.EXPECT_EQ(t, hex.EncodeToString(packet.Bytes()), testAeadRtp, "")
+
+			sender.rtcpStream(0x4d617273).index = 0x5d4
+			data, err := sender.ProtectRtcp(fromHex(testAeadRtcp))
+			test.EXPECT_EQ(t, err, nil, "")
+			test.EXPECT_EQ(t, hex.EncodeToString(data), v.rtcp, "")
+			data, err = receiver.UnprotectRtcp(data)
+			test.EXPECT_EQ(t, err, nil, "")
+			test.EXPECT_EQ(t, hex.EncodeToString(data), testAeadRtcp, "")
+
+			// E flag clear: the packet goes in clear, followed by the tag
+			sender.UnencryptedSrtcp = true
+			sender.rtcpStream(0x4d617273).index = 0x5d4
+			receiver = newTestAeadContext(v.name, v.key)
+			data, _ = sender.ProtectRtcp(fromHex(testAeadRtcp))
+			test.EXPECT_EQ(t, hex.EncodeToString(data), testAeadRtcp+v.unencrypted, "")
+			data, err = receiver.UnprotectRtcp(data)
+			test.EXPECT_EQ(t, err, nil, "")
+			test.EXPECT_EQ(t, hex.EncodeToString(data), testAeadRtcp, "")
+		})
+	}
+}
+
+func TestSrtpAeadErrors(t *testing.T) {
+	t.Parallel()
+
+	sender := newTestAeadContext(SRTP_AEAD_AES_128_GCM, testAeadKey128)
+	receiver := newTestAeadContext(SRTP_AEAD_AES_128_GCM, testAeadKey128)
+
+	packet := rtp.NewRtpPacket()
+	packet.CopyFromBytes(fromHex(testAeadRtp))
+	sender.ProtectRtp(packet)
+	protected := hex.EncodeToString(packet.Bytes())
+
+	// the header is authenticated too
+	packet.SetMarker()
+	test.EXPECT_EQ(t, receiver.UnprotectRtp(packet), ErrAuthFailed, "")
+	packet.ClearMarker()
+	test.EXPECT_EQ(t, hex.EncodeToString(packet.Bytes()), protected, "")
+	test.EXPECT_EQ(t, receiver.UnprotectRtp(packet), nil, "")
+
+	short := rtp.NewRtpPacket()
+	short.CopyFromBytes(fromHex(protected)[:20])
+	test.EXPECT_EQ(t, receiver.UnprotectRtp(short), ErrTooShort, "")
+
+	data, _ := sender.ProtectRtcp(fromHex(testAeadRtcp))
+	data[len(data)-1] ^= 1
+	_, err := receiver.UnprotectRtcp(data)
+	test.EXPECT_EQ(t, err, ErrAuthFailed, "")
+}
+
+func TestSrtpAeadByName(t *testing.T) {
+	t.Parallel()
+
+	testdata := []struct {
+		name    string
+		keyLen  int
+		saltLen int
+		size    int
+	}{
+		{SRTP_AEAD_AES_128_GCM, 16, 12, 44},
+		{SRTP_AEAD_AES_256_GCM, 32, 12, 44},
+		{SRTP_AES_CM_128_HMAC_SHA1_80, 16, 14, 38},
+	}
+
+	for i, v := range testdata {
+		key := make([]byte, v.keyLen)
+		salt := make([]byte, v.saltLen)
+		sender, err := NewSrtpContextByName(v.name, key, salt)
+		test.EXPECT_EQ(t, err, nil, "[%d]", i)
+		receiver, _ := NewSrtpContextByName(v.name, key, salt)
+
+		// derived session keys, with the MKI after the cipher text
+		sender.Mki = []byte{7}
+		receiver.Mki = []byte{7}
+		packet := newTestSrtpPacket(1)
+		test.EXPECT_EQ(t, sender.ProtectRtp(packet), nil, "[%d]", i)
+		test.EXPECT_EQ(t, len(packet.Bytes()), v.size+1, "[%d]", i)
+		test.EXPECT_EQ(t, receiver.UnprotectRtp(packet), nil, "[%d]", i)
+		test.EXPECT_EQ(t, packet.Bytes(), newTestSrtpPacket(1).Bytes(), "[%d]", i)
+	}
+
+	_, err := NewSrtpContextByName(SRTP_AEAD_AES_256_GCM, make([]byte, 16), make([]byte, 12))
+	test.EXPECT_EQ(t, err, ErrBadKeyLength, "")
+}
@@ -0,0 +1,404 @@
+package srtp
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"encoding/binary"
+	"errors"
+
+	"rtp"
+)
+
+const (
+	// the E flag and the 31-bit index after a SRTCP packet
+	SRTCP_INDEX_LEN    = 4
+	SRTCP_E_FLAG_MARSK = 0x80000000
+	SRTCP_INDEX_MARSK  = 0x7FFFFFFF
+
+	// the first octets of a rtcp packet are never encrypted: the header and
+	// the SSRC of the sender
+	SRTCP_HEADER_LEN = 8
+)
+
+var (
+	ErrUnknownProfile = errors.New("srtp: unknown crypto suite")
+	ErrBadKeyLength   = errors.New("srtp: bad master key or salt length")
+	ErrBadKdr         = errors.New("srtp: key derivation rate is not a power of 2 up to 2^24")
+	ErrTooShort       = errors.New("srtp: packet too short")
+	ErrBadMki         = errors.New("srtp: unknown MKI")
+	ErrAuthFailed     = errors.New("srtp: authentication failed")
+	ErrReplayed       = errors.New("srtp: replayed packet")
+	ErrKeyExpired     = errors.New("srtp: master key lifetime exceeded")
+)
+
+// srtpStream is the state of one SSRC of SRTP: the ROC and the highest
+// sequence number s_l of RFC3711 section 3.3.1.
+type srtpStream struct {
+	started bool
+	roc     uint32
+	lastSeq uint16
+	replay  ReplayWindow
+}
+
+// estimate returns the ROC guessed for seq and the resulting index, as in
+// RFC3711 appendix A.
+func (this *srtpStream) estimate(seq uint16) (uint32, uint64) {
+	v := this.roc
+	if this.started {
+		if this.lastSeq < 0x8000 {
+			if int(seq)-int(this.lastSeq) > 0x8000 && v > 0 {
+				v--
+			}
+		} else if int(this.lastSeq)-0x8000 > int(seq) {
+			v++
+		}
+	}
+	return v, uint64(v)<<16 | uint64(seq)
+}
+
+// update moves s_l and the ROC forward to a packet that passed.
+func (this *srtpStream) update(roc uint32, seq uint16) {
+	if !this.started || roc > this.roc || (roc == this.roc && seq > this.lastSeq) {
+		this.started = true
+		this.roc = roc
+		this.lastSeq = seq
+	}
+}
+
+// srtcpStream is the state of one SSRC of SRTCP.
+type srtcpStream struct {
+	index  uint32
+	replay ReplayWindow
+}
+
+// SrtpContext protects and unprotects the SRTP and SRTCP packets of one
+// master key. It keeps the state of every SSRC it sees, so one context serves
+// a direction of a session. It is not safe for concurrent use.
+type SrtpContext struct {
+	Profile *SrtpProfile
+	// session parameters of RFC4568 section 6.3, the AEAD suites always
+	// encrypt and authenticate SRTP
+	UnencryptedSrtp     bool
+	UnencryptedSrtcp    bool
+	UnauthenticatedSrtp bool
+	// Mki is sent after each packet when set, RFC3711 section 3.1
+	Mki []byte
+	// Lifetime is the number of SRTP and SRTCP packets the master key may
+	// process, 0 for no limit. A new master key is needed after it.
+	Lifetime uint64
+	// ReplayWindowSize is the number of indices the replay windows of new
+	// streams remember, SRTP_REPLAY_WINDOW_SIZE when smaller
+	ReplayWindowSize uint64
+
+	// the key derivation rate, 0 derives the session keys once
+	kdr         uint64
+	packets     uint64
+	master      cipher.Block
+	masterSalt  []byte
+	srtpKeys    *srtpSessionKeys
+	srtcpKeys   *srtpSessionKeys
+	streams     map[uint32]*srtpStream
+	rtcpStreams map[uint32]*srtcpStream
+}
+
+func NewSrtpContext(profile *SrtpProfile, masterKey, masterSalt []byte) (*SrtpContext, error) {
+	if len(masterKey) != profile.KeyLen || len(masterSalt) != profile.SaltLen {
+		return nil, ErrBadKeyLength
+	}
+	master, err := aes.NewCipher(masterKey)
+	if err != nil {
+		return nil, ErrBadKeyLength
+	}
+
+	return &SrtpContext{
+		Profile:     profile,
+		master:      master,
+		masterSalt:  append([]byte(nil), masterSalt...),
+		streams:     make(map[uint32]*srtpStream),
+		rtcpStreams: make(map[uint32]*srtcpStream),
+	}, nil
+}
+
+// SetKdr sets the key derivation rate, a power of 2 up to 2^24 or 0.
+func (this *SrtpContext) SetKdr(kdr uint64) error {
+	if kdr > SRTP_MAX_KDR || kdr&(kdr-1) != 0 {
+		return ErrBadKdr
+	}
+	this.kdr = kdr
+	this.srtpKeys = nil
+	this.srtcpKeys = nil
+	return nil
+}
+
+func (this *SrtpContext) GetKdr() uint64 {
+	return this.kdr
+}
+
+func (this *SrtpContext) stream(ssrc uint32) *srtpStream {
+	stream, ok := this.streams[ssrc]
+	if !ok {
+		stream = &srtpStream{}
+		stream.replay.Size = this.ReplayWindowSize
+		this.streams[ssrc] = stream
+	}
+	return stream
+}
+
+func (this *SrtpContext) rtcpStream(ssrc uint32) *srtcpStream {
+	stream, ok := this.rtcpStreams[ssrc]
+	if !ok {
+		stream = &srtcpStream{}
+		stream.replay.Size = this.ReplayWindowSize
+		this.rtcpStreams[ssrc] = stream
+	}
+	return stream
+}
+
+// GetPackets returns the number of packets processed with the master key.
+func (this *SrtpContext) GetPackets() uint64 {
+	return this.packets
+}
+
+// Expired reports whether the master key has reached its Lifetime.
+func (this *SrtpContext) Expired() bool {
+	return this.Lifetime != 0 && this.packets >= this.Lifetime
+}
+
+// GetRoc returns the rollover counter of ssrc.
+func (this *SrtpContext) GetRoc(ssrc uint32) uint32 {
+	return this.stream(ssrc).roc
+}
+
+// SetRoc sets the rollover counter of ssrc, as needed to join a stream that
+// has already wrapped.
+func (this *SrtpContext) SetRoc(ssrc uint32, roc uint32) {
+	this.stream(ssrc).roc = roc
+}
+
+// sessionKeys returns the keys for index, deriving them again when the key
+// derivation rate has moved r on.
+func (this *SrtpContext) sessionKeys(keys **srtpSessionKeys, firstLabel byte, index uint64) *srtpSessionKeys {
+	if *keys == nil || (this.kdr > 0 && (*keys).r != index/this.kdr) {
+		*keys = newSrtpSessionKeys(this.Profile, this.master, this.masterSalt, firstLabel, index, this.kdr)
+	}
+	return *keys
+}
+
+// rtpHeaderLen returns the header length of a packet whose payload may be
+// encrypted, which rules out RtpPacket.Validate for the padding.
+func rtpHeaderLen(data []byte) (int, error) {
+	if len(data) < rtp.RTP_HEADER_LEN {
+		return 0, ErrTooShort
+	}
+	n := rtp.RTP_HEADER_LEN + int(data[0]&rtp.RTP_CSRC_COUNT_MARSK)*4
+	if data[0]&rtp.RTP_EXTENSION_MARSK != 0 {
+		if n+4 > len(data) {
+			return 0, ErrTooShort
+		}
+		n += 4 + int(binary.BigEndian.Uint16(data[n+2:]))*4
+	}
+	if n > len(data) {
+		return 0, ErrTooShort
+	}
+	return n, nil
+}
+
+// rtpTagLen returns the length of the tag after the MKI, the AEAD suites
+// carry theirs in the cipher text.
+func (this *SrtpContext) rtpTagLen() int {
+	if this.Profile.Aead || this.UnauthenticatedSrtp {
+		return 0
+	}
+	return this.Profile.AuthTagLen
+}
+
+func (this *SrtpContext) rtcpTagLen() int {
+	if this.Profile.Aead {
+		return 0
+	}
+	return this.Profile.SrtcpAuthTagLen
+}
+
+// ProtectRtp encrypts the payload of packet in place and appends the MKI and
+// the authentication tag.
+func (this *SrtpContext) ProtectRtp(packet *rtp.RtpPacket) error {
+	if this.Expired() {
+		return ErrKeyExpired
+	}
+	data := packet.Bytes()
+	headerLen, err := rtpHeaderLen(data)
+	if err != nil {
+		return err
+	}
+
+	ssrc := packet.GetSsrc()
+	seq := packet.GetSequence()
+	stream := this.stream(ssrc)
+	roc, index := stream.estimate(seq)
+	keys := this.sessionKeys(&this.srtpKeys, SRTP_LABEL_RTP_ENCRYPTION, index)
+
+	if this.Profile.Aead {
+		data = keys.sealRtp(data, headerLen, ssrc, roc, seq)
+	} else if !this.UnencryptedSrtp {
+		keys.xorKeyStream(data[headerLen:], ssrc, index)
+	}
+	stream.update(roc, seq)
+
+	authLen := len(data)
+	data = append(data, this.Mki...)
+	if tagLen := this.rtpTagLen(); tagLen > 0 {
+		data = append(data, keys.authTag(data[:authLen], rocBytes(roc), tagLen)...)
+	}
+	packet.SetBytes(data)
+	this.packets++
+	return nil
+}
+
+// UnprotectRtp authenticates packet, checks it against the replay window and
+// decrypts it in place without the MKI and the tag. packet is left as it was
+// on an error.
+func (this *SrtpContext) UnprotectRtp(packet *rtp.RtpPacket) error {
+	if this.Expired() {
+		return ErrKeyExpired
+	}
+	data := packet.Bytes()
+	tagLen := this.rtpTagLen()
+	authLen := len(data) - len(this.Mki) - tagLen
+	if authLen < rtp.RTP_HEADER_LEN {
+		return ErrTooShort
+	}
+	headerLen, err := rtpHeaderLen(data[:authLen])
+	if err != nil {
+		return err
+	}
+	if this.Profile.Aead && authLen-headerLen < this.Profile.AuthTagLen {
+		return ErrTooShort
+	}
+	if !bytes.Equal(data[authLen:authLen+len(this.Mki)], this.Mki) {
+		return ErrBadMki
+	}
+
+	ssrc := packet.GetSsrc()
+	seq := packet.GetSequence()
+	stream := this.stream(ssrc)
+	roc, index := stream.estimate(seq)
+	if !stream.replay.Check(index) {
+		return ErrReplayed
+	}
+	keys := this.sessionKeys(&this.srtpKeys, SRTP_LABEL_RTP_ENCRYPTION, index)
+
+	if this.Profile.Aead {
+		authLen, err = keys.openRtp(data[:authLen], headerLen, ssrc, roc, seq)
+		if err != nil {
+			return err
+		}
+	} else {
+		if tagLen > 0 {
+			tag := keys.authTag(data[:authLen], rocBytes(roc), tagLen)
+			if !hmac.Equal(tag, data[len(data)-tagLen:]) {
+				return ErrAuthFailed
+			}
+		}
+		if !this.UnencryptedSrtp {
+			keys.xorKeyStream(data[headerLen:authLen], ssrc, index)
+		}
+	}
+
+	stream.update(roc, seq)
+	stream.replay.Accept(index)
+	packet.SetBytes(data[:authLen])
+	this.packets++
+	return nil
+}
+
+// ProtectRtcp encrypts a compound packet and appends the E flag and SRTCP
+// index, the MKI and the tag. data is encrypted in place and the result may
+// share its memory.
+func (this *SrtpContext) ProtectRtcp(data []byte) ([]byte, error) {
+	if this.Expired() {
+		return nil, ErrKeyExpired
+	}
+	if len(data) < SRTCP_HEADER_LEN {
+		return nil, ErrTooShort
+	}
+
+	ssrc := binary.BigEndian.Uint32(data[4:])
+	stream := this.rtcpStream(ssrc)
+	index := stream.index
+	stream.index = (stream.index + 1) & SRTCP_INDEX_MARSK
+	keys := this.sessionKeys(&this.srtcpKeys, SRTP_LABEL_RTCP_ENCRYPTION, uint64(index))
+
+	word := index
+	if !this.UnencryptedSrtcp {
+		word |= SRTCP_E_FLAG_MARSK
+	}
+	trailer := []byte{byte(word >> 24), byte(word >> 16), byte(word >> 8), byte(word)}
+
+	if this.Profile.Aead {
+		data = keys.sealRtcp(data, ssrc, trailer)
+	} else if !this.UnencryptedSrtcp {
+		keys.xorKeyStream(data[SRTCP_HEADER_LEN:], ssrc, uint64(index))
+	}
+
+	data = append(data, trailer...)
+	authLen := len(data)
+	data = append(data, this.Mki...)
+	if tagLen := this.rtcpTagLen(); tagLen > 0 {
+		data = append(data, keys.authTag(data[:authLen], nil, tagLen)...)
+	}
+	this.packets++
+	return data, nil
+}
+
+// UnprotectRtcp authenticates a SRTCP packet, checks it against the replay
+// window and returns the compound packet decrypted in place.
+func (this *SrtpContext) UnprotectRtcp(data []byte) ([]byte, error) {
+	if this.Expired() {
+		return nil, ErrKeyExpired
+	}
+	tagLen := this.rtcpTagLen()
+	authLen := len(data) - len(this.Mki) - tagLen
+	if authLen < SRTCP_HEADER_LEN+SRTCP_INDEX_LEN {
+		return nil, ErrTooShort
+	}
+	if this.Profile.Aead && authLen < SRTCP_HEADER_LEN+this.Profile.SrtcpAuthTagLen+SRTCP_INDEX_LEN {
+		return nil, ErrTooShort
+	}
+	if !bytes.Equal(data[authLen:authLen+len(this.Mki)], this.Mki) {
+		return nil, ErrBadMki
+	}
+
+	ssrc := binary.BigEndian.Uint32(data[4:])
+	end := authLen - SRTCP_INDEX_LEN
+	trailer := data[end:authLen]
+	word := binary.BigEndian.Uint32(trailer)
+	index := uint64(word & SRTCP_INDEX_MARSK)
+	stream := this.rtcpStream(ssrc)
+	if !stream.replay.Check(index) {
+		return nil, ErrReplayed
+	}
+	keys := this.sessionKeys(&this.srtcpKeys, SRTP_LABEL_RTCP_ENCRYPTION, index)
+
+	if this.Profile.Aead {
+		var err error
+		end, err = keys.openRtcp(data[:end], ssrc, trailer)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		tag := keys.authTag(data[:authLen], nil, tagLen)
+		if !hmac.Equal(tag, data[len(data)-tagLen:]) {
+			return nil, ErrAuthFailed
+		}
+		// the receiver follows the E flag whatever it would send itself
+		if word&SRTCP_E_FLAG_MARSK != 0 {
+			keys.xorKeyStream(data[SRTCP_HEADER_LEN:end], ssrc, index)
+		}
+	}
+
+	stream.replay.Accept(index)
+	this.packets++
+	return data[:end], nil
+}
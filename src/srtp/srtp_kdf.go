@@ -0,0 +1,110 @@
+package srtp
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/binary"
+	"hash"
+)
+
+// labels of the key derivation, RFC3711 section 4.3.2
+const (
+	SRTP_LABEL_RTP_ENCRYPTION  = 0x00
+	SRTP_LABEL_RTP_AUTH        = 0x01
+	SRTP_LABEL_RTP_SALT        = 0x02
+	SRTP_LABEL_RTCP_ENCRYPTION = 0x03
+	SRTP_LABEL_RTCP_AUTH       = 0x04
+	SRTP_LABEL_RTCP_SALT       = 0x05
+)
+
+// SRTP_MAX_KDR is the largest key derivation rate of RFC3711, 2^24
+const SRTP_MAX_KDR = 1 << 24
+
+// DeriveSrtpKey is the AES-CM PRF of RFC3711 section 4.3.1: the keystream of
+// the master key from the IV (label || r) XOR master salt, r being the index
+// divided by the key derivation rate, 0 when kdr is 0.
+func DeriveSrtpKey(masterKey, masterSalt []byte, label byte, index, kdr uint64, length int) ([]byte, error) {
+	block, err := aes.NewCipher(masterKey)
+	if err != nil {
+		return nil, ErrBadKeyLength
+	}
+	return deriveKey(block, masterSalt, label, index, kdr, length), nil
+}
+
+func deriveKey(master cipher.Block, masterSalt []byte, label byte, index, kdr uint64, length int) []byte {
+	r := uint64(0)
+	if kdr > 0 {
+		r = index / kdr
+	}
+
+	// key_id is 56 bits aligned to the right of the 112-bit salt
+	iv := make([]byte, aes.BlockSize)
+	copy(iv, masterSalt)
+	iv[7] ^= label
+	for i := 0; i < 6; i++ {
+		iv[13-i] ^= byte(r >> uint(8*i))
+	}
+
+	key := make([]byte, length)
+	cipher.NewCTR(master, iv).XORKeyStream(key, key)
+	return key
+}
+
+// srtpSessionKeys are the session keys of SRTP or SRTCP for one value of r.
+type srtpSessionKeys struct {
+	r     uint64
+	block cipher.Block
+	salt  []byte
+	auth  hash.Hash
+	aead  cipher.AEAD
+}
+
+func newSrtpSessionKeys(profile *SrtpProfile, master cipher.Block, masterSalt []byte, firstLabel byte, index, kdr uint64) *srtpSessionKeys {
+	keys := &srtpSessionKeys{}
+	if kdr > 0 {
+		keys.r = index / kdr
+	}
+
+	key := deriveKey(master, masterSalt, firstLabel, index, kdr, profile.KeyLen)
+	keys.block, _ = aes.NewCipher(key)
+	if profile.Aead {
+		keys.aead, _ = cipher.NewGCM(keys.block)
+	}
+	keys.salt = deriveKey(master, masterSalt, firstLabel+2, index, kdr, profile.SaltLen)
+	if profile.AuthKeyLen > 0 {
+		keys.auth = hmac.New(sha1.New, deriveKey(master, masterSalt, firstLabel+1, index, kdr, profile.AuthKeyLen))
+	}
+	return keys
+}
+
+// xorKeyStream applies the AES-CM keystream of RFC3711 section 4.1.1 with the
+// IV (salt * 2^16) XOR (ssrc * 2^64) XOR (index * 2^16).
+func (this *srtpSessionKeys) xorKeyStream(data []byte, ssrc uint32, index uint64) {
+	iv := make([]byte, aes.BlockSize)
+	copy(iv, this.salt)
+	iv[4] ^= byte(ssrc >> 24)
+	iv[5] ^= byte(ssrc >> 16)
+	iv[6] ^= byte(ssrc >> 8)
+	iv[7] ^= byte(ssrc)
+	for i := 0; i < 6; i++ {
+		iv[13-i] ^= byte(index >> uint(8*i))
+	}
+	cipher.NewCTR(this.block, iv).XORKeyStream(data, data)
+}
+
+// authTag is the HMAC-SHA1 of data, followed by the ROC for SRTP, truncated
+// to tagLen.
+func (this *srtpSessionKeys) authTag(data []byte, roc []byte, tagLen int) []byte {
+	this.auth.Reset()
+	this.auth.Write(data)
+	this.auth.Write(roc)
+	return this.auth.Sum(nil)[:tagLen]
+}
+
+func rocBytes(roc uint32) []byte {
+	buf := make([]byte, 4)
+	binary.BigEndian.PutUint32(buf, roc)
+	return buf
+}
@@ -0,0 +1,82 @@
+package srtp
+
+// names of the crypto suites as signalled in sdp, RFC4568 section 6.2
+const (
+	SRTP_AES_CM_128_HMAC_SHA1_80 = "AES_CM_128_HMAC_SHA1_80"
+	SRTP_AES_CM_128_HMAC_SHA1_32 = "AES_CM_128_HMAC_SHA1_32"
+	SRTP_AEAD_AES_128_GCM        = "AEAD_AES_128_GCM"
+	SRTP_AEAD_AES_256_GCM        = "AEAD_AES_256_GCM"
+)
+
+// SrtpProfile describes a crypto suite. Lengths are in octets.
+type SrtpProfile struct {
+	Name       string
+	KeyLen     int
+	SaltLen    int
+	AuthKeyLen int
+	AuthTagLen int
+	// SRTCP keeps the 80-bit tag with the 32-bit SRTP suite, RFC4568
+	// section 6.2.1
+	SrtcpAuthTagLen int
+	// AES-GCM of RFC7714, whose tag is part of the cipher text
+	Aead bool
+}
+
+var SrtpProfiles = map[string]*SrtpProfile{
+	SRTP_AES_CM_128_HMAC_SHA1_80: {
+		Name:            SRTP_AES_CM_128_HMAC_SHA1_80,
+		KeyLen:          16,
+		SaltLen:         14,
+		AuthKeyLen:      20,
+		AuthTagLen:      10,
+		SrtcpAuthTagLen: 10,
+	},
+	SRTP_AES_CM_128_HMAC_SHA1_32: {
+		Name:            SRTP_AES_CM_128_HMAC_SHA1_32,
+		KeyLen:          16,
+		SaltLen:         14,
+		AuthKeyLen:      20,
+		AuthTagLen:      4,
+		SrtcpAuthTagLen: 10,
+	},
+	SRTP_AEAD_AES_128_GCM: {
+		Name:            SRTP_AEAD_AES_128_GCM,
+		KeyLen:          16,
+		SaltLen:         12,
+		AuthTagLen:      16,
+		SrtcpAuthTagLen: 16,
+		Aead:            true,
+	},
+	SRTP_AEAD_AES_256_GCM: {
+		Name:            SRTP_AEAD_AES_256_GCM,
+		KeyLen:          32,
+		SaltLen:         12,
+		AuthTagLen:      16,
+		SrtcpAuthTagLen: 16,
+		Aead:            true,
+	},
+}
+
+// GetSrtpProfile returns the profile of the suite name.
+func GetSrtpProfile(name string) (*SrtpProfile, error) {
+	profile, ok := SrtpProfiles[name]
+	if !ok {
+		return nil, ErrUnknownProfile
+	}
+	return profile, nil
+}
+
+// NewSrtpContextByName creates a context for the suite negotiated by name.
+func NewSrtpContextByName(name string, masterKey, masterSalt []byte) (*SrtpContext, error) {
+	profile, err := GetSrtpProfile(name)
+	if err != nil {
+		return nil, err
+	}
+	return NewSrtpContext(profile, masterKey, masterSalt)
+}
+
+// MasterLen returns the length of the master key and salt together, as
+// carried in the inline key parameter of RFC4568.
+func (this *SrtpProfile) MasterLen() int {
+	return this.KeyLen + this.SaltLen
+}
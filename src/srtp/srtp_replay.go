@@ -0,0 +1,73 @@
+package srtp
+
+// SRTP_REPLAY_WINDOW_SIZE is the minimum of RFC3711 section 3.3.2
+const SRTP_REPLAY_WINDOW_SIZE = 64
+
+// ReplayWindow remembers which of the last Size indices were received. Older
+// indices are taken as replayed. A Size below SRTP_REPLAY_WINDOW_SIZE uses
+// SRTP_REPLAY_WINDOW_SIZE.
+type ReplayWindow struct {
+	Size uint64
+
+	started bool
+	top     uint64
+	// bit i is set when top-i was received
+	bitmap []uint64
+}
+
+func (this *ReplayWindow) size() uint64 {
+	if this.Size < SRTP_REPLAY_WINDOW_SIZE {
+		return SRTP_REPLAY_WINDOW_SIZE
+	}
+	return this.Size
+}
+
+// Check reports whether index is new and may be accepted.
+func (this *ReplayWindow) Check(index uint64) bool {
+	if !this.started || index > this.top {
+		return true
+	}
+	delta := this.top - index
+	if delta >= this.size() {
+		return false
+	}
+	return this.bitmap[delta/64]&(1<<(delta%64)) == 0
+}
+
+// Accept records index, which has passed Check and the authentication.
+func (this *ReplayWindow) Accept(index uint64) {
+	if !this.started {
+		this.started = true
+		this.top = index
+		this.bitmap = make([]uint64, (this.size()+63)/64)
+		this.bitmap[0] = 1
+		return
+	}
+
+	if index > this.top {
+		this.shift(index - this.top)
+		this.top = index
+		this.bitmap[0] |= 1
+		return
+	}
+	delta := this.top - index
+	this.bitmap[delta/64] |= 1 << (delta % 64)
+}
+
+// shift moves the window forward by n indices.
+func (this *ReplayWindow) shift(n uint64) {
+	words, bits := int(n/64), n%64
+	if n >= this.size() {
+		words, bits = len(this.bitmap), 0
+	}
+	for i := len(this.bitmap) - 1; i >= 0; i-- {
+		var v uint64
+		if j := i - words; j >= 0 {
+			v = this.bitmap[j] << bits
+			if bits > 0 && j > 0 {
+				v |= this.bitmap[j-1] >> (64 - bits)
+			}
+		}
+		this.bitmap[i] = v
+	}
+}
@@ -0,0 +1,319 @@
+package srtp
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// session parameters of RFC4568 section 6.3
+const (
+	SDES_KDR                  = "KDR"
+	SDES_UNENCRYPTED_SRTP     = "UNENCRYPTED_SRTP"
+	SDES_UNENCRYPTED_SRTCP    = "UNENCRYPTED_SRTCP"
+	SDES_UNAUTHENTICATED_SRTP = "UNAUTHENTICATED_SRTP"
+	SDES_WSH                  = "WSH"
+
+	SDES_KEY_METHOD_INLINE = "inline:"
+	SDES_MAX_KDR           = 24
+	SDES_MAX_MKI_LEN       = 128
+)
+
+var (
+	ErrBadCrypto    = errors.New("srtp: malformed crypto attribute")
+	ErrBadKeyParams = errors.New("srtp: malformed key parameters of crypto attribute")
+)
+
+// SdesKeyParams is one inline key of a crypto attribute. Lifetime and
+// MkiLen are 0 when not given.
+type SdesKeyParams struct {
+	// master key || master salt
+	Key      []byte
+	Lifetime uint64
+	MkiValue uint64
+	MkiLen   int
+}
+
+// SdesCrypto is the value of a "a=crypto:" attribute of RFC4568.
+type SdesCrypto struct {
+	Tag   int
+	Suite string
+	Keys  []SdesKeyParams
+
+	// Kdr is n of the key derivation rate 2^n, 0 for none
+	Kdr                 int
+	UnencryptedSrtp     bool
+	UnencryptedSrtcp    bool
+	UnauthenticatedSrtp bool
+	// Wsh is the replay window size hint, 0 when not given
+	Wsh int
+	// other session parameters as they were received
+	Params []string
+}
+
+// GenerateSdesKey returns fresh master key and salt for the suite name.
+func GenerateSdesKey(name string) ([]byte, error) {
+	profile, err := GetSrtpProfile(name)
+	if err != nil {
+		return nil, err
+	}
+	key := make([]byte, profile.MasterLen())
+	_, err = rand.Read(key)
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// NewSdesCrypto creates an offer of suite name with a fresh key.
+func NewSdesCrypto(tag int, name string) (*SdesCrypto, error) {
+	key, err := GenerateSdesKey(name)
+	if err != nil {
+		return nil, err
+	}
+	return &SdesCrypto{Tag: tag, Suite: name, Keys: []SdesKeyParams{{Key: key}}}, nil
+}
+
+// ParseSdesCrypto parses the attribute value, with or without the
+// "a=crypto:" in front.
+func ParseSdesCrypto(value string) (*SdesCrypto, error) {
+	value = strings.TrimPrefix(strings.TrimSpace(value), "a=")
+	value = strings.TrimPrefix(value, "crypto:")
+
+	fields := strings.Fields(value)
+	if len(fields) < 3 {
+		return nil, ErrBadCrypto
+	}
+
+	crypto := &SdesCrypto{Suite: fields[1]}
+	tag, err := strconv.ParseUint(fields[0], 10, 31)
+	if err != nil {
+		return nil, ErrBadCrypto
+	}
+	crypto.Tag = int(tag)
+
+	// keys of unknown suites are kept, the offer/answer skips them
+	profile, _ := GetSrtpProfile(crypto.Suite)
+	for _, v := range strings.Split(fields[2], ";") {
+		key, err := parseSdesKeyParams(v)
+		if err != nil {
+			return nil, err
+		}
+		if profile != nil && len(key.Key) != profile.MasterLen() {
+			return nil, ErrBadKeyParams
+		}
+		crypto.Keys = append(crypto.Keys, key)
+	}
+
+	for _, v := range fields[3:] {
+		err = crypto.parseSessionParam(v)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return crypto, nil
+}
+
+// parseSdesKeyParams parses "inline:<key||salt>[|lifetime][|MKI:length]".
+func parseSdesKeyParams(value string) (SdesKeyParams, error) {
+	params := SdesKeyParams{}
+	if !strings.HasPrefix(value, SDES_KEY_METHOD_INLINE) {
+		return params, ErrBadKeyParams
+	}
+
+	parts := strings.Split(value[len(SDES_KEY_METHOD_INLINE):], "|")
+	if len(parts) > 3 {
+		return params, ErrBadKeyParams
+	}
+
+	key, err := base64.StdEncoding.DecodeString(parts[0])
+	if err != nil {
+		key, err = base64.RawStdEncoding.DecodeString(parts[0])
+		if err != nil {
+			return params, ErrBadKeyParams
+		}
+	}
+	params.Key = key
+
+	for _, v := range parts[1:] {
+		if pos := strings.IndexByte(v, ':'); pos >= 0 {
+			if params.MkiLen != 0 {
+				return params, ErrBadKeyParams
+			}
+			mki, err1 := strconv.ParseUint(v[:pos], 10, 64)
+			length, err2 := strconv.Atoi(v[pos+1:])
+			if err1 != nil || err2 != nil || length < 1 || length > SDES_MAX_MKI_LEN {
+				return params, ErrBadKeyParams
+			}
+			// the value must fit in length octets
+			if length < 8 && mki>>uint(length*8) != 0 {
+				return params, ErrBadKeyParams
+			}
+			params.MkiValue = mki
+			params.MkiLen = length
+			continue
+		}
+
+		if params.Lifetime != 0 || params.MkiLen != 0 {
+			return params, ErrBadKeyParams
+		}
+		lifetime, ok := parseSdesLifetime(v)
+		if !ok {
+			return params, ErrBadKeyParams
+		}
+		params.Lifetime = lifetime
+	}
+	return params, nil
+}
+
+// parseSdesLifetime parses a decimal lifetime or "2^n".
+func parseSdesLifetime(value string) (uint64, bool) {
+	if strings.HasPrefix(value, "2^") {
+		n, err := strconv.ParseUint(value[2:], 10, 8)
+		if err != nil || n > 63 {
+			return 0, false
+		}
+		return 1 << n, true
+	}
+	lifetime, err := strconv.ParseUint(value, 10, 64)
+	if err != nil || lifetime == 0 {
+		return 0, false
+	}
+	return lifetime, true
+}
+
+func (this *SdesCrypto) parseSessionParam(value string) error {
+	name, arg := value, ""
+	if pos := strings.IndexByte(value, '='); pos >= 0 {
+		name, arg = value[:pos], value[pos+1:]
+	}
+
+	switch name {
+	case SDES_KDR:
+		kdr, err := strconv.Atoi(arg)
+		if err != nil || kdr < 0 || kdr > SDES_MAX_KDR {
+			return ErrBadCrypto
+		}
+		this.Kdr = kdr
+	case SDES_UNENCRYPTED_SRTP:
+		this.UnencryptedSrtp = true
+	case SDES_UNENCRYPTED_SRTCP:
+		this.UnencryptedSrtcp = true
+	case SDES_UNAUTHENTICATED_SRTP:
+		this.UnauthenticatedSrtp = true
+	case SDES_WSH:
+		wsh, err := strconv.Atoi(arg)
+		if err != nil || wsh < SRTP_REPLAY_WINDOW_SIZE {
+			return ErrBadCrypto
+		}
+		this.Wsh = wsh
+	default:
+		this.Params = append(this.Params, value)
+	}
+	return nil
+}
+
+func formatSdesLifetime(lifetime uint64) string {
+	if lifetime&(lifetime-1) == 0 {
+		n := 0
+		for lifetime > 1 {
+			lifetime >>= 1
+			n++
+		}
+		return "2^" + strconv.Itoa(n)
+	}
+	return strconv.FormatUint(lifetime, 10)
+}
+
+func (this *SdesKeyParams) String() string {
+	s := SDES_KEY_METHOD_INLINE + base64.StdEncoding.EncodeToString(this.Key)
+	if this.Lifetime != 0 {
+		s += "|" + formatSdesLifetime(this.Lifetime)
+	}
+	if this.MkiLen != 0 {
+		s += "|" + strconv.FormatUint(this.MkiValue, 10) + ":" + strconv.Itoa(this.MkiLen)
+	}
+	return s
+}
+
+// String returns the attribute value, without "a=crypto:".
+func (this *SdesCrypto) String() string {
+	keys := make([]string, len(this.Keys))
+	for i := range this.Keys {
+		keys[i] = this.Keys[i].String()
+	}
+
+	fields := []string{strconv.Itoa(this.Tag), this.Suite, strings.Join(keys, ";")}
+	if this.Kdr != 0 {
+		fields = append(fields, SDES_KDR+"="+strconv.Itoa(this.Kdr))
+	}
+	if this.UnencryptedSrtp {
+		fields = append(fields, SDES_UNENCRYPTED_SRTP)
+	}
+	if this.UnencryptedSrtcp {
+		fields = append(fields, SDES_UNENCRYPTED_SRTCP)
+	}
+	if this.UnauthenticatedSrtp {
+		fields = append(fields, SDES_UNAUTHENTICATED_SRTP)
+	}
+	if this.Wsh != 0 {
+		fields = append(fields, SDES_WSH+"="+strconv.Itoa(this.Wsh))
+	}
+	fields = append(fields, this.Params...)
+	return strings.Join(fields, " ")
+}
+
+// Mki returns the MKI of a key as sent in the packets, nil without MKI.
+func (this *SdesKeyParams) Mki() []byte {
+	if this.MkiLen == 0 {
+		return nil
+	}
+	mki := make([]byte, this.MkiLen)
+	value := this.MkiValue
+	for i := len(mki) - 1; i >= 0 && value > 0; i-- {
+		mki[i] = byte(value)
+		value >>= 8
+	}
+	return mki
+}
+
+// NewSrtpContext creates the context of the first key with the session
+// parameters of the attribute.
+func (this *SdesCrypto) NewSrtpContext() (*SrtpContext, error) {
+	return this.NewSrtpContextWithKey(0)
+}
+
+// NewSrtpContextWithKey creates the context of key n. The context takes the
+// MKI and lifetime of the key and the replay window size hint; when the key
+// has Expired the caller moves on to the next key or negotiates a new one.
+func (this *SdesCrypto) NewSrtpContextWithKey(n int) (*SrtpContext, error) {
+	profile, err := GetSrtpProfile(this.Suite)
+	if err != nil {
+		return nil, err
+	}
+	if n < 0 || n >= len(this.Keys) || len(this.Keys[n].Key) != profile.MasterLen() {
+		return nil, ErrBadKeyLength
+	}
+
+	key := this.Keys[n].Key
+	ctx, err := NewSrtpContext(profile, key[:profile.KeyLen], key[profile.KeyLen:])
+	if err != nil {
+		return nil, err
+	}
+
+	if this.Kdr != 0 {
+		err = ctx.SetKdr(1 << uint(this.Kdr))
+		if err != nil {
+			return nil, err
+		}
+	}
+	ctx.UnencryptedSrtp = this.UnencryptedSrtp
+	ctx.UnencryptedSrtcp = this.UnencryptedSrtcp
+	ctx.UnauthenticatedSrtp = this.UnauthenticatedSrtp
+	ctx.Mki = this.Keys[n].Mki()
+	ctx.Lifetime = this.Keys[n].Lifetime
+	ctx.ReplayWindowSize = uint64(this.Wsh)
+	return ctx, nil
+}
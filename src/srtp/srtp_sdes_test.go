@@ -0,0 +1,136 @@
+package srtp
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/lioneagle/goutil/src/test"
+)
+
+func TestParseSdesCrypto(t *testing.T) {
+	testdata := []struct {
+		value    string
+		ok       bool
+		tag      int
+		suite    string
+		keys     int
+		lifetime uint64
+		mki      []byte
+		kdr      int
+		wanted   string
+	}{
+		// RFC4568 section 4
+		{"a=crypto:1 AES_CM_128_HMAC_SHA1_80 inline:PS1uQCVeeCFCanVmcjkpPywjNWhcYD0mXXtxaVBR|2^20|1:4",
+			true, 1, SRTP_AES_CM_128_HMAC_SHA1_80, 1, 1 << 20, []byte{0, 0, 0, 1}, 0,
+			"1 AES_CM_128_HMAC_SHA1_80 inline:PS1uQCVeeCFCanVmcjkpPywjNWhcYD0mXXtxaVBR|2^20|1:4"},
+		{"crypto:2 AES_CM_128_HMAC_SHA1_32 inline:NzB4d1BINUAvLEw6UzF3WSJ+PSdFcGdUJShpX1Zj|1000 KDR=1 UNENCRYPTED_SRTCP FEC_ORDER=FEC_SRTP",
+			true, 2, SRTP_AES_CM_128_HMAC_SHA1_32, 1, 1000, nil, 1,
+			"2 AES_CM_128_HMAC_SHA1_32 inline:NzB4d1BINUAvLEw6UzF3WSJ+PSdFcGdUJShpX1Zj|1000 KDR=1 UNENCRYPTED_SRTCP FEC_ORDER=FEC_SRTP"},
+		{"3 AES_CM_128_HMAC_SHA1_80 inline:PS1uQCVeeCFCanVmcjkpPywjNWhcYD0mXXtxaVBR|2^20|1:4;inline:QUJjZGVmMTIzNDU2Nzg5QUJDREUwMTIzNDU2Nzg5|2^20|2:4 WSH=128",
+			true, 3, SRTP_AES_CM_128_HMAC_SHA1_80, 2, 1 << 20, []byte{0, 0, 0, 1}, 0,
+			"3 AES_CM_128_HMAC_SHA1_80 inline:PS1uQCVeeCFCanVmcjkpPywjNWhcYD0mXXtxaVBR|2^20|1:4;inline:QUJjZGVmMTIzNDU2Nzg5QUJDREUwMTIzNDU2Nzg5|2^20|2:4 WSH=128"},
+		// unknown suites parse so they can be declined
+		{"4 F8_128_HMAC_SHA1_80 inline:MTIzNDU2Nzg5QUJDREUwMTIzNDU2Nzg5QUJDREUw|7:2", true, 4, "F8_128_HMAC_SHA1_80", 1, 0, []byte{0, 7}, 0,
+			"4 F8_128_HMAC_SHA1_80 inline:MTIzNDU2Nzg5QUJDREUwMTIzNDU2Nzg5QUJDREUw|7:2"},
+		{"5 AES_CM_128_HMAC_SHA1_80 inline:PS1uQCVeeCFCanVmcjkpPywjNWhcYD0mXXtxaVBR|2^0|255:1", true, 5, SRTP_AES_CM_128_HMAC_SHA1_80, 1, 1, []byte{0xff}, 0,
+			"5 AES_CM_128_HMAC_SHA1_80 inline:PS1uQCVeeCFCanVmcjkpPywjNWhcYD0mXXtxaVBR|2^0|255:1"},
+		{"6 AES_CM_128_HMAC_SHA1_80 inline:PS1uQCVeeCFCanVmcjkpPywjNWhcYD0mXXtxaVBR|1", true, 6, SRTP_AES_CM_128_HMAC_SHA1_80, 1, 1, nil, 0,
+			"6 AES_CM_128_HMAC_SHA1_80 inline:PS1uQCVeeCFCanVmcjkpPywjNWhcYD0mXXtxaVBR|2^0"},
+
+		{"1 AES_CM_128_HMAC_SHA1_80", false, 0, "", 0, 0, nil, 0, ""},
+		{"x AES_CM_128_HMAC_SHA1_80 inline:PS1uQCVeeCFCanVmcjkpPywjNWhcYD0mXXtxaVBR", false, 0, "", 0, 0, nil, 0, ""},
+		{"1 AES_CM_128_HMAC_SHA1_80 uri:PS1uQCVeeCFCanVmcjkpPywjNWhcYD0mXXtxaVBR", false, 0, "", 0, 0, nil, 0, ""},
+		{"1 AES_CM_128_HMAC_SHA1_80 inline:PS1uQCVeeCFCanVmcjkpPywjNWhc", false, 0, "", 0, 0, nil, 0, ""},
+		{"1 AES_CM_128_HMAC_SHA1_80 inline:PS1uQCVeeCFCanVmcjkpPywjNWhcYD0mXXtxaVBR|1:4|2^20", false, 0, "", 0, 0, nil, 0, ""},
+		{"1 AES_CM_128_HMAC_SHA1_80 inline:PS1uQCVeeCFCanVmcjkpPywjNWhcYD0mXXtxaVBR|1:200", false, 0, "", 0, 0, nil, 0, ""},
+		{"1 AES_CM_128_HMAC_SHA1_80 inline:PS1uQCVeeCFCanVmcjkpPywjNWhcYD0mXXtxaVBR|2^x", false, 0, "", 0, 0, nil, 0, ""},
+		{"1 AES_CM_128_HMAC_SHA1_80 inline:PS1uQCVeeCFCanVmcjkpPywjNWhcYD0mXXtxaVBR|256:1", false, 0, "", 0, 0, nil, 0, ""},
+		{"1 AES_CM_128_HMAC_SHA1_80 inline:PS1uQCVeeCFCanVmcjkpPywjNWhcYD0mXXtxaVBR|2^64", false, 0, "", 0, 0, nil, 0, ""},
+		{"1 AES_CM_128_HMAC_SHA1_80 inline:PS1uQCVeeCFCanVmcjkpPywjNWhcYD0mXXtxaVBR KDR=25", false, 0, "", 0, 0, nil, 0, ""},
+		{"1 AES_CM_128_HMAC_SHA1_80 inline:PS1uQCVeeCFCanVmcjkpPywjNWhcYD0mXXtxaVBR WSH=10", false, 0, "", 0, 0, nil, 0, ""},
+	}
+
+	for i, v := range testdata {
+		v := v
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			t.Parallel()
+
+			crypto, err := ParseSdesCrypto(v.value)
+			test.EXPECT_EQ(t, err == nil, v.ok, "err = %v", err)
+			if err != nil {
+				return
+			}
+			test.EXPECT_EQ(t, crypto.Tag, v.tag, "")
+			test.EXPECT_EQ(t, crypto.Suite, v.suite, "")
+			test.EXPECT_EQ(t, len(crypto.Keys), v.keys, "")
+			test.EXPECT_EQ(t, crypto.Keys[0].Lifetime, v.lifetime, "")
+			test.EXPECT_EQ(t, crypto.Keys[0].Mki(), v.mki, "")
+			test.EXPECT_EQ(t, crypto.Kdr, v.kdr, "")
+			test.EXPECT_EQ(t, crypto.String(), v.wanted, "")
+		})
+	}
+}
+
+func TestSdesCryptoNewSrtpContext(t *testing.T) {
+	t.Parallel()
+
+	for i, name := range []string{SRTP_AES_CM_128_HMAC_SHA1_80, SRTP_AES_CM_128_HMAC_SHA1_32, SRTP_AEAD_AES_128_GCM, SRTP_AEAD_AES_256_GCM} {
+		offer, err := NewSdesCrypto(1, name)
+		test.EXPECT_EQ(t, err, nil, "[%d]", i)
+		offer.Keys[0].MkiValue = 1
+		offer.Keys[0].MkiLen = 2
+		offer.UnencryptedSrtcp = true
+
+		// the peer gets the attribute as text
+		received, err := ParseSdesCrypto("a=crypto:" + offer.String())
+		test.EXPECT_EQ(t, err, nil, "[%d]", i)
+
+		sender, err := offer.NewSrtpContext()
+		test.EXPECT_EQ(t, err, nil, "[%d]", i)
+		receiver, err := received.NewSrtpContext()
+		test.EXPECT_EQ(t, err, nil, "[%d]", i)
+		test.EXPECT_EQ(t, receiver.Profile.Name, name, "[%d]", i)
+		test.EXPECT_EQ(t, receiver.Mki, []byte{0, 1}, "[%d]", i)
+		test.EXPECT_EQ(t, receiver.UnencryptedSrtcp, true, "[%d]", i)
+
+		packet := newTestSrtpPacket(5)
+		sender.ProtectRtp(packet)
+		test.EXPECT_EQ(t, receiver.UnprotectRtp(packet), nil, "[%d]", i)
+		test.EXPECT_EQ(t, packet.Bytes(), newTestSrtpPacket(5).Bytes(), "[%d]", i)
+	}
+
+	crypto, _ := ParseSdesCrypto("1 AES_CM_128_HMAC_SHA1_80 inline:PS1uQCVeeCFCanVmcjkpPywjNWhcYD0mXXtxaVBR KDR=4")
+	ctx, err := crypto.NewSrtpContext()
+	test.EXPECT_EQ(t, err, nil, "")
+	test.EXPECT_EQ(t, ctx.GetKdr(), uint64(16), "")
+
+	crypto, _ = ParseSdesCrypto("1 F8_128_HMAC_SHA1_80 inline:PS1uQCVeeCFCanVmcjkpPywjNWhcYD0mXXtxaVBR")
+	_, err = crypto.NewSrtpContext()
+	test.EXPECT_EQ(t, err, ErrUnknownProfile, "")
+
+	// the other keys, their lifetime and the window size hint
+	crypto, _ = ParseSdesCrypto("3 AES_CM_128_HMAC_SHA1_80 inline:PS1uQCVeeCFCanVmcjkpPywjNWhcYD0mXXtxaVBR|2^20|1:4;inline:QUJjZGVmMTIzNDU2Nzg5QUJDREUwMTIzNDU2Nzg5|2|2:4 WSH=128")
+	ctx, err = crypto.NewSrtpContextWithKey(1)
+	test.EXPECT_EQ(t, err, nil, "")
+	test.EXPECT_EQ(t, ctx.Mki, []byte{0, 0, 0, 2}, "")
+	test.EXPECT_EQ(t, ctx.Lifetime, uint64(2), "")
+	test.EXPECT_EQ(t, ctx.ReplayWindowSize, uint64(128), "")
+	_, err = crypto.NewSrtpContextWithKey(2)
+	test.EXPECT_EQ(t, err, ErrBadKeyLength, "")
+
+	for i := 0; i < 3; i++ {
+		err = ctx.ProtectRtp(newTestSrtpPacket(uint16(i)))
+		if i < 2 {
+			test.EXPECT_EQ(t, err, nil, "[%d]", i)
+		} else {
+			test.EXPECT_EQ(t, err, ErrKeyExpired, "[%d]", i)
+		}
+	}
+	test.EXPECT_EQ(t, ctx.Expired(), true, "")
+	test.EXPECT_EQ(t, ctx.GetPackets(), uint64(2), "")
+
+	a, _ := GenerateSdesKey(SRTP_AEAD_AES_256_GCM)
+	b, _ := GenerateSdesKey(SRTP_AEAD_AES_256_GCM)
+	test.EXPECT_EQ(t, len(a), 44, "")
+	test.EXPECT_EQ(t, string(a) != string(b), true, "")
+}
@@ -0,0 +1,339 @@
+package srtp
+
+import (
+	"crypto/aes"
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"rtp"
+
+	"github.com/lioneagle/goutil/src/test"
+)
+
+func fromHex(s string) []byte {
+	data, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+// master key and salt of RFC3711 appendix B.3, also used by the libsrtp
+// test driver
+var (
+	testMasterKey  = fromHex("e1f97a0d3e018be0d64fa32c06de4139")
+	testMasterSalt = fromHex("0ec675ad498afeebb6960b3aabe6")
+)
+
+func newTestSrtpContext(name string) *SrtpContext {
+	ctx, err := NewSrtpContext(SrtpProfiles[name], testMasterKey, testMasterSalt)
+	if err != nil {
+		panic(err)
+	}
+	return ctx
+}
+
+func newTestSrtpPacket(seq uint16) *rtp.RtpPacket {
+	packet := rtp.NewRtpPacket()
+	packet.CopyFromBytes(fromHex("800f1234decafbadcafebabeabababababababababababababababab"))
+	packet.SetSequence(seq)
+	return packet
+}
+
+// RFC3711 appendix B.2
+func TestSrtpAesCmKeystream(t *testing.T) {
+	t.Parallel()
+
+	keys := &srtpSessionKeys{salt: fromHex("f0f1f2f3f4f5f6f7f8f9fafbfcfd")}
+	keys.block, _ = aes.NewCipher(fromHex("2b7e151628aed2a6abf7158809cf4f3c"))
+
+	keystream := make([]byte, 16*0x10002)
+	keys.xorKeyStream(keystream, 0, 0)
+
+	testdata := []struct {
+		counter int
+		wanted  string
+	}{
+		{0x0000, "e03ead0935c95e80e166b16dd92b4eb4"},
+		{0x0001, "d23513162b02d0f72a43a2fe4a5f97ab"},
+		{0x0002, "41e95b3bb0a2e8dd477901e4fca894c0"},
+		{0xfeff, "ec8cdf7398607cb0f2d21675ea9ea1e4"},
+		{0xff00, "362b7c3c6773516318a077d7fc5073ae"},
+		{0xff01, "6a2cc3787889374fbeb4c81b17ba6c44"},
+	}
+
+	for i, v := range testdata {
+		block := keystream[v.counter*16 : v.counter*16+16]
+		test.EXPECT_EQ(t, hex.EncodeToString(block), v.wanted, "[%d]", i)
+	}
+}
+
+// RFC3711 appendix B.3
+func TestDeriveSrtpKey(t *testing.T) {
+	t.Parallel()
+
+	testdata := []struct {
+		label  byte
+		length int
+		wanted string
+	}{
+		{SRTP_LABEL_RTP_ENCRYPTION, 16, "c61e7a93744f39ee10734afe3ff7a087"},
+		{SRTP_LABEL_RTP_SALT, 14, "30cbbc08863d8c85d49db34a9ae1"},
+		{SRTP_LABEL_RTP_AUTH, 20, "cebe321f6ff7716b6fd4ab49af256a156d38baa4"},
+	}
+
+	for i, v := range testdata {
+		key, err := DeriveSrtpKey(testMasterKey, testMasterSalt, v.label, 0, 0, v.length)
+		test.EXPECT_EQ(t, err, nil, "[%d]", i)
+		test.EXPECT_EQ(t, hex.EncodeToString(key), v.wanted, "[%d]", i)
+	}
+
+	// r = index DIV kdr changes the keys, within a rate they stay
+	a, _ := DeriveSrtpKey(testMasterKey, testMasterSalt, 0, 0xFFFF, 0x10000, 16)
+	b, _ := DeriveSrtpKey(testMasterKey, testMasterSalt, 0, 0x10000, 0x10000, 16)
+	test.EXPECT_EQ(t, hex.EncodeToString(a), "c61e7a93744f39ee10734afe3ff7a087", "")
+	test.EXPECT_EQ(t, hex.EncodeToString(a) != hex.EncodeToString(b), true, "")
+}
+
+func TestSrtpProtectRtp(t *testing.T) {
+	t.Parallel()
+
+	// reference packets of the libsrtp test driver
+	testdata := []struct {
+		profile string
+		wanted  string
+	}{
+		{SRTP_AES_CM_128_HMAC_SHA1_80, "800f1234decafbadcafebabe4e55dc4ce79978d88ca4d215949d2402b78d6acc99ea179b8dbb"},
+		{SRTP_AES_CM_128_HMAC_SHA1_32, "800f1234decafbadcafebabe4e55dc4ce79978d88ca4d215949d2402b78d6acc"},
+	}
+
+	for i, v := range testdata {
+		packet := newTestSrtpPacket(0x1234)
+		test.EXPECT_EQ(t, newTestSrtpContext(v.profile).ProtectRtp(packet), nil, "[%d]", i)
+		test.EXPECT_EQ(t, hex.EncodeToString(packet.Bytes()), v.wanted, "[%d]", i)
+
+		receiver := newTestSrtpContext(v.profile)
+		test.EXPECT_EQ(t, receiver.UnprotectRtp(packet), nil, "[%d]", i)
+		test.EXPECT_EQ(t, packet.Bytes(), newTestSrtpPacket(0x1234).Bytes(), "[%d]", i)
+	}
+}
+
+func TestSrtpProtectRtcp(t *testing.T) {
+	t.Parallel()
+
+	plain := "81c8000bcafebabeabababababababababababababababab"
+	sender := newTestSrtpContext(SRTP_AES_CM_128_HMAC_SHA1_32)
+	// the libsrtp reference packet was the second one sent
+	sender.rtcpStream(0xcafebabe).index = 1
+
+	data, err := sender.ProtectRtcp(fromHex(plain))
+	test.EXPECT_EQ(t, err, nil, "")
+	test.EXPECT_EQ(t, hex.EncodeToString(data), "81c8000bcafebabe7128035be487b9bdbef89041f977a5a880000001993e08cd54d6c1230798", "")
+
+	receiver := newTestSrtpContext(SRTP_AES_CM_128_HMAC_SHA1_32)
+	data, err = receiver.UnprotectRtcp(data)
+	test.EXPECT_EQ(t, err, nil, "")
+	test.EXPECT_EQ(t, hex.EncodeToString(data), plain, "")
+
+	// without encryption the E flag is clear and the receiver follows it
+	sender.UnencryptedSrtcp = true
+	data, _ = sender.ProtectRtcp(fromHex(plain))
+	test.EXPECT_EQ(t, hex.EncodeToString(data[:28]), plain+"00000002", "")
+	data, err = receiver.UnprotectRtcp(data)
+	test.EXPECT_EQ(t, err, nil, "")
+	test.EXPECT_EQ(t, hex.EncodeToString(data), plain, "")
+}
+
+func TestSrtpRocEstimation(t *testing.T) {
+	t.Parallel()
+
+	sender := newTestSrtpContext(SRTP_AES_CM_128_HMAC_SHA1_80)
+	receiver := newTestSrtpContext(SRTP_AES_CM_128_HMAC_SHA1_80)
+
+	var packets []*rtp.RtpPacket
+	for _, seq := range []uint16{65533, 65534, 65535, 0, 1, 2} {
+		packet := newTestSrtpPacket(seq)
+		sender.ProtectRtp(packet)
+		packets = append(packets, packet)
+	}
+	test.EXPECT_EQ(t, sender.GetRoc(0xcafebabe), uint32(1), "")
+
+	// reordered around the wrap
+	for i, j := range []int{0, 1, 3, 2, 5, 4} {
+		test.EXPECT_EQ(t, receiver.UnprotectRtp(packets[j]), nil, "[%d]", i)
+		test.EXPECT_EQ(t, packets[j].GetPayload()[0], byte(0xab), "[%d]", i)
+	}
+	test.EXPECT_EQ(t, receiver.GetRoc(0xcafebabe), uint32(1), "")
+
+	// a receiver joining late needs the ROC
+	late := newTestSrtpContext(SRTP_AES_CM_128_HMAC_SHA1_80)
+	packet := newTestSrtpPacket(3)
+	sender.ProtectRtp(packet)
+	test.EXPECT_EQ(t, late.UnprotectRtp(packet), ErrAuthFailed, "")
+	late.SetRoc(0xcafebabe, 1)
+	test.EXPECT_EQ(t, late.UnprotectRtp(packet), nil, "")
+}
+
+func TestSrtpUnprotectRtpErrors(t *testing.T) {
+	t.Parallel()
+
+	sender := newTestSrtpContext(SRTP_AES_CM_128_HMAC_SHA1_80)
+	receiver := newTestSrtpContext(SRTP_AES_CM_128_HMAC_SHA1_80)
+
+	packet := newTestSrtpPacket(100)
+	sender.ProtectRtp(packet)
+	protected := append([]byte(nil), packet.Bytes()...)
+
+	tampered := rtp.NewRtpPacket()
+	tampered.CopyFromBytes(protected)
+	tampered.Bytes()[20] ^= 1
+	test.EXPECT_EQ(t, receiver.UnprotectRtp(tampered), ErrAuthFailed, "")
+	// a failed packet is left as it was
+	test.EXPECT_EQ(t, len(tampered.Bytes()), len(protected), "")
+
+	test.EXPECT_EQ(t, receiver.UnprotectRtp(packet), nil, "")
+	packet.CopyFromBytes(protected)
+	test.EXPECT_EQ(t, receiver.UnprotectRtp(packet), ErrReplayed, "")
+
+	short := rtp.NewRtpPacket()
+	short.CopyFromBytes(protected[:20])
+	test.EXPECT_EQ(t, receiver.UnprotectRtp(short), ErrTooShort, "")
+
+	// too old for the window
+	old := newTestSrtpPacket(100 - SRTP_REPLAY_WINDOW_SIZE)
+	sender.ProtectRtp(old)
+	test.EXPECT_EQ(t, receiver.UnprotectRtp(old), ErrReplayed, "")
+}
+
+func TestSrtpOptions(t *testing.T) {
+	testdata := []struct {
+		unencrypted     bool
+		unauthenticated bool
+		mki             []byte
+		size            int
+	}{
+		{false, false, nil, 38},
+		{true, false, nil, 38},
+		{false, true, nil, 28},
+		{false, false, []byte{0, 0, 0, 1}, 42},
+	}
+
+	for i, v := range testdata {
+		v := v
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			t.Parallel()
+
+			sender := newTestSrtpContext(SRTP_AES_CM_128_HMAC_SHA1_80)
+			receiver := newTestSrtpContext(SRTP_AES_CM_128_HMAC_SHA1_80)
+			for _, ctx := range []*SrtpContext{sender, receiver} {
+				ctx.UnencryptedSrtp = v.unencrypted
+				ctx.UnauthenticatedSrtp = v.unauthenticated
+				ctx.Mki = v.mki
+				ctx.SetKdr(1 << 1)
+			}
+
+			for seq := uint16(0); seq < 4; seq++ {
+				packet := newTestSrtpPacket(seq)
+				test.EXPECT_EQ(t, sender.ProtectRtp(packet), nil, "[%d]", seq)
+				test.EXPECT_EQ(t, len(packet.Bytes()), v.size, "[%d]", seq)
+				test.EXPECT_EQ(t, packet.GetPayload()[0] == 0xab, v.unencrypted, "[%d]", seq)
+				test.EXPECT_EQ(t, receiver.UnprotectRtp(packet), nil, "[%d]", seq)
+				test.EXPECT_EQ(t, packet.Bytes(), newTestSrtpPacket(seq).Bytes(), "[%d]", seq)
+			}
+
+			if v.mki != nil {
+				receiver.Mki = []byte{0, 0, 0, 2}
+				packet := newTestSrtpPacket(10)
+				sender.ProtectRtp(packet)
+				test.EXPECT_EQ(t, receiver.UnprotectRtp(packet), ErrBadMki, "")
+			}
+		})
+	}
+}
+
+func TestSrtpContextErrors(t *testing.T) {
+	t.Parallel()
+
+	profile, err := GetSrtpProfile(SRTP_AES_CM_128_HMAC_SHA1_80)
+	test.EXPECT_EQ(t, err, nil, "")
+	_, err = GetSrtpProfile("F8_128_HMAC_SHA1_80")
+	test.EXPECT_EQ(t, err, ErrUnknownProfile, "")
+
+	_, err = NewSrtpContext(profile, testMasterKey[:15], testMasterSalt)
+	test.EXPECT_EQ(t, err, ErrBadKeyLength, "")
+
+	ctx := newTestSrtpContext(SRTP_AES_CM_128_HMAC_SHA1_80)
+	test.EXPECT_EQ(t, ctx.SetKdr(3), ErrBadKdr, "")
+	test.EXPECT_EQ(t, ctx.SetKdr(1<<25), ErrBadKdr, "")
+	test.EXPECT_EQ(t, ctx.SetKdr(1<<24), nil, "")
+	test.EXPECT_EQ(t, ctx.GetKdr(), uint64(1<<24), "")
+}
+
+func TestReplayWindow(t *testing.T) {
+	t.Parallel()
+
+	testdata := []struct {
+		index  uint64
+		wanted bool
+	}{
+		{100, true},
+		{100, false},
+		{99, true},
+		{99, false},
+		{164, true},
+		{100, false},
+		{101, true},
+		{300, true},
+		{236, false},
+		{237, true},
+	}
+
+	window := ReplayWindow{}
+	for i, v := range testdata {
+		ok := window.Check(v.index)
+		test.EXPECT_EQ(t, ok, v.wanted, "[%d]", i)
+		if ok {
+			window.Accept(v.index)
+		}
+	}
+}
+
+func TestReplayWindowSize(t *testing.T) {
+	t.Parallel()
+
+	testdata := []struct {
+		index  uint64
+		wanted bool
+	}{
+		{1000, true},
+		{900, true},
+		{900, false},
+		{873, true},
+		{872, false},
+		{1065, true},
+		{1000, false},
+		{1001, true},
+		{1001, false},
+		{938, true},
+		{937, false},
+		// the bits move across the words
+		{1100, true},
+		{1065, false},
+		{1001, false},
+		{1002, true},
+		{1200, true},
+		{1073, true},
+		{1072, false},
+		{1100, false},
+	}
+
+	window := ReplayWindow{Size: 128}
+	for i, v := range testdata {
+		ok := window.Check(v.index)
+		test.EXPECT_EQ(t, ok, v.wanted, "[%d]", i)
+		if ok {
+			window.Accept(v.index)
+		}
+	}
+}
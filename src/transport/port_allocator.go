@@ -0,0 +1,137 @@
+package transport
+
+import (
+	"errors"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"syscall"
+)
+
+const (
+	TRANSPORT_DEFAULT_MIN_PORT = 10000
+	TRANSPORT_DEFAULT_MAX_PORT = 20000
+)
+
+// WSAEADDRINUSE, winsock does not return syscall.EADDRINUSE
+const TRANSPORT_WSAEADDRINUSE = syscall.Errno(10048)
+
+var (
+	ErrBadPortRange = errors.New("transport: port range holds no even port pair")
+	ErrNoFreePort   = errors.New("transport: no free port in range")
+)
+
+// PortAllocator binds udp sockets on even ports from [MinPort, MaxPort], the
+// rtcp socket of a pair on the next odd port. Ports are handed out round
+// robin so a port just released is not reused at once.
+type PortAllocator struct {
+	Host    string
+	MinPort int
+	MaxPort int
+
+	mutex sync.Mutex
+	next  int
+}
+
+func NewPortAllocator(host string, minPort, maxPort int) (*PortAllocator, error) {
+	if minPort&1 == 1 {
+		minPort++
+	}
+	if minPort <= 0 || minPort+1 > maxPort || maxPort > 0xFFFF {
+		return nil, ErrBadPortRange
+	}
+	return &PortAllocator{Host: host, MinPort: minPort, MaxPort: maxPort, next: minPort}, nil
+}
+
+func (this *PortAllocator) listen(port int) (*net.UDPConn, error) {
+	addr, err := net.ResolveUDPAddr("udp", net.JoinHostPort(this.Host, strconv.Itoa(port)))
+	if err != nil {
+		return nil, err
+	}
+	return net.ListenUDP("udp", addr)
+}
+
+// isAddrInUse reports whether err is the bind failure of a busy port, the
+// only one worth trying the next port for.
+func isAddrInUse(err error) bool {
+	opErr, ok := err.(*net.OpError)
+	if !ok {
+		return false
+	}
+	sysErr, ok := opErr.Err.(*os.SyscallError)
+	if !ok {
+		return false
+	}
+	errno, ok := sysErr.Err.(syscall.Errno)
+	return ok && (errno == syscall.EADDRINUSE || errno == TRANSPORT_WSAEADDRINUSE)
+}
+
+// nextPorts returns the even ports to try, each once, starting after the
+// last port handed out.
+func (this *PortAllocator) nextPorts() []int {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	var ports []int
+	for port := this.next; port+1 <= this.MaxPort; port += 2 {
+		ports = append(ports, port)
+	}
+	for port := this.MinPort; port < this.next; port += 2 {
+		ports = append(ports, port)
+	}
+	return ports
+}
+
+// advance moves the next port past port, which has just been handed out.
+func (this *PortAllocator) advance(port int) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	this.next = port + 2
+	if this.next+1 > this.MaxPort {
+		this.next = this.MinPort
+	}
+}
+
+// ListenPair binds an even port for rtp and the next port for rtcp. Only
+// busy ports are skipped, any other error is returned as is.
+func (this *PortAllocator) ListenPair() (*net.UDPConn, *net.UDPConn, error) {
+	for _, port := range this.nextPorts() {
+		rtpConn, err := this.listen(port)
+		if err != nil {
+			if isAddrInUse(err) {
+				continue
+			}
+			return nil, nil, err
+		}
+		rtcpConn, err := this.listen(port + 1)
+		if err != nil {
+			rtpConn.Close()
+			if isAddrInUse(err) {
+				continue
+			}
+			return nil, nil, err
+		}
+		this.advance(port)
+		return rtpConn, rtcpConn, nil
+	}
+	return nil, nil, ErrNoFreePort
+}
+
+// Listen binds a single even port, for rtp and rtcp multiplexed together.
+// Errors other than a busy port are returned as is.
+func (this *PortAllocator) Listen() (*net.UDPConn, error) {
+	for _, port := range this.nextPorts() {
+		conn, err := this.listen(port)
+		if err != nil {
+			if isAddrInUse(err) {
+				continue
+			}
+			return nil, err
+		}
+		this.advance(port)
+		return conn, nil
+	}
+	return nil, ErrNoFreePort
+}
@@ -0,0 +1,92 @@
+package transport
+
+import (
+	"net"
+	"testing"
+
+	"github.com/lioneagle/goutil/src/test"
+)
+
+func TestNewPortAllocator(t *testing.T) {
+	testdata := []struct {
+		minPort int
+		maxPort int
+		ok      bool
+		wanted  int
+	}{
+		{10000, 20000, true, 10000},
+		{10001, 20000, true, 10002},
+		{10001, 10003, true, 10002},
+		{10001, 10002, false, 0},
+		{0, 100, false, 0},
+		{60000, 70000, false, 0},
+	}
+
+	for i, v := range testdata {
+		allocator, err := NewPortAllocator("127.0.0.1", v.minPort, v.maxPort)
+		test.EXPECT_EQ(t, err == nil, v.ok, "[%d]", i)
+		if err == nil {
+			test.EXPECT_EQ(t, allocator.MinPort, v.wanted, "[%d]", i)
+		}
+	}
+}
+
+func TestPortAllocatorListenPair(t *testing.T) {
+	allocator, _ := NewPortAllocator("127.0.0.1", 41000, 41009)
+
+	// the first pair is taken by someone else
+	busy, err := allocator.listen(41001)
+	if err != nil {
+		t.Skipf("cannot bind test port: %v", err)
+	}
+	defer busy.Close()
+
+	rtpConn, rtcpConn, err := allocator.ListenPair()
+	test.EXPECT_EQ(t, err, nil, "")
+	defer rtpConn.Close()
+	defer rtcpConn.Close()
+	test.EXPECT_EQ(t, rtpConn.LocalAddr().(*net.UDPAddr).Port, 41002, "")
+	test.EXPECT_EQ(t, rtcpConn.LocalAddr().(*net.UDPAddr).Port, 41003, "")
+
+	conn, err := allocator.Listen()
+	test.EXPECT_EQ(t, err, nil, "")
+	defer conn.Close()
+	test.EXPECT_EQ(t, conn.LocalAddr().(*net.UDPAddr).Port, 41004, "")
+
+	var conns []*net.UDPConn
+	for {
+		rtpConn, rtcpConn, err := allocator.ListenPair()
+		if err != nil {
+			test.EXPECT_EQ(t, err, ErrNoFreePort, "")
+			break
+		}
+		conns = append(conns, rtpConn, rtcpConn)
+	}
+	for _, v := range conns {
+		v.Close()
+	}
+	test.EXPECT_EQ(t, len(conns), 4, "")
+}
+
+func TestPortAllocatorListenError(t *testing.T) {
+	// an address of no local interface fails for every port, it is not a
+	// busy port to skip
+	allocator, _ := NewPortAllocator("192.0.2.1", 41000, 41009)
+
+	_, _, err := allocator.ListenPair()
+	test.EXPECT_EQ(t, err != nil && err != ErrNoFreePort, true, "err = %v", err)
+	test.EXPECT_EQ(t, isAddrInUse(err), false, "")
+
+	_, err = allocator.Listen()
+	test.EXPECT_EQ(t, err != nil && err != ErrNoFreePort, true, "err = %v", err)
+
+	// binding a bound port is the error that is skipped
+	allocator.Host = "127.0.0.1"
+	busy, err := allocator.listen(41000)
+	if err != nil {
+		t.Skipf("cannot bind test port: %v", err)
+	}
+	defer busy.Close()
+	_, err = allocator.listen(41000)
+	test.EXPECT_EQ(t, isAddrInUse(err), true, "err = %v", err)
+}
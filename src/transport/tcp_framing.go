@@ -0,0 +1,251 @@
+package transport
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync"
+)
+
+// framings of rtp and rtcp on a byte stream
+type Framing int
+
+const (
+	// a 16-bit length before each packet, RFC4571
+	TRANSPORT_FRAMING_RFC4571 Framing = iota
+	// '$', a channel octet and a 16-bit length before each packet, RFC2326
+	// section 10.12
+	TRANSPORT_FRAMING_INTERLEAVED
+)
+
+const (
+	TRANSPORT_RFC4571_HEADER_LEN     = 2
+	TRANSPORT_INTERLEAVED_HEADER_LEN = 4
+	TRANSPORT_INTERLEAVED_MAGIC      = '$'
+	TRANSPORT_MAX_FRAME_LEN          = 0xFFFF
+
+	TRANSPORT_DEFAULT_QUEUE_SIZE = 64
+)
+
+var (
+	ErrFrameTooLarge  = errors.New("transport: packet too large for a frame")
+	ErrNotInterleaved = errors.New("transport: data is not an interleaved frame")
+	ErrWouldBlock     = errors.New("transport: write queue full")
+)
+
+func (this Framing) String() string {
+	switch this {
+	case TRANSPORT_FRAMING_RFC4571:
+		return "rfc4571"
+	case TRANSPORT_FRAMING_INTERLEAVED:
+		return "interleaved"
+	}
+	return "unknown"
+}
+
+func (this Framing) HeaderLen() int {
+	if this == TRANSPORT_FRAMING_INTERLEAVED {
+		return TRANSPORT_INTERLEAVED_HEADER_LEN
+	}
+	return TRANSPORT_RFC4571_HEADER_LEN
+}
+
+// AppendFrame appends data framed with channel to buf. channel is not sent
+// with RFC4571.
+func (this Framing) AppendFrame(buf []byte, channel byte, data []byte) ([]byte, error) {
+	if len(data) > TRANSPORT_MAX_FRAME_LEN {
+		return buf, ErrFrameTooLarge
+	}
+	if this == TRANSPORT_FRAMING_INTERLEAVED {
+		buf = append(buf, TRANSPORT_INTERLEAVED_MAGIC, channel)
+	}
+	buf = append(buf, byte(len(data)>>8), byte(len(data)))
+	return append(buf, data...), nil
+}
+
+// FrameReader reads frames from a byte stream, however it is segmented.
+type FrameReader struct {
+	Framing Framing
+	reader  *bufio.Reader
+}
+
+func NewFrameReader(r io.Reader, framing Framing) *FrameReader {
+	return &FrameReader{Framing: framing, reader: bufio.NewReader(r)}
+}
+
+// Reader returns the buffered stream, for the rtsp messages sent between
+// interleaved frames.
+func (this *FrameReader) Reader() *bufio.Reader {
+	return this.reader
+}
+
+// ReadFrame returns the next frame and its channel, 0 with RFC4571. With
+// interleaved framing it returns ErrNotInterleaved without consuming anything
+// when the stream does not continue with a frame. A stream ending inside a
+// frame gives io.ErrUnexpectedEOF.
+func (this *FrameReader) ReadFrame() (channel byte, data []byte, err error) {
+	if this.Framing == TRANSPORT_FRAMING_INTERLEAVED {
+		magic, err := this.reader.Peek(1)
+		if err != nil {
+			return 0, nil, err
+		}
+		if magic[0] != TRANSPORT_INTERLEAVED_MAGIC {
+			return 0, nil, ErrNotInterleaved
+		}
+	}
+
+	header := make([]byte, this.Framing.HeaderLen())
+	_, err = io.ReadFull(this.reader, header)
+	if err != nil {
+		return 0, nil, err
+	}
+	if this.Framing == TRANSPORT_FRAMING_INTERLEAVED {
+		channel = header[1]
+	}
+
+	data = make([]byte, binary.BigEndian.Uint16(header[len(header)-2:]))
+	_, err = io.ReadFull(this.reader, data)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	return channel, data, nil
+}
+
+// Skip discards the stream up to the next interleaved frame.
+func (this *FrameReader) Skip() error {
+	_, err := this.reader.ReadBytes(TRANSPORT_INTERLEAVED_MAGIC)
+	if err != nil {
+		return err
+	}
+	return this.reader.UnreadByte()
+}
+
+// FrameWriter writes frames from a queue of limited size in its own
+// goroutine. When the peer does not keep up the queue fills: Write then
+// blocks and TryWrite fails with ErrWouldBlock.
+type FrameWriter struct {
+	Framing Framing
+
+	writer io.Writer
+	queue  chan []byte
+	closed chan struct{}
+	done   chan struct{}
+	once   sync.Once
+
+	mutex sync.Mutex
+	err   error
+}
+
+func NewFrameWriter(w io.Writer, framing Framing, queueSize int) *FrameWriter {
+	if queueSize < 1 {
+		queueSize = TRANSPORT_DEFAULT_QUEUE_SIZE
+	}
+	writer := &FrameWriter{
+		Framing: framing,
+		writer:  w,
+		queue:   make(chan []byte, queueSize),
+		closed:  make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go writer.loop()
+	return writer
+}
+
+func (this *FrameWriter) loop() {
+	defer close(this.done)
+
+	for {
+		select {
+		case frame := <-this.queue:
+			_, err := this.writer.Write(frame)
+			if err != nil {
+				this.mutex.Lock()
+				this.err = err
+				this.mutex.Unlock()
+				return
+			}
+		case <-this.closed:
+			return
+		}
+	}
+}
+
+// Err returns the error that stopped the writer, ErrClosed after Close.
+func (this *FrameWriter) Err() error {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return this.err
+}
+
+// Pending returns the number of frames waiting in the queue.
+func (this *FrameWriter) Pending() int {
+	return len(this.queue)
+}
+
+func (this *FrameWriter) stopped() error {
+	if err := this.Err(); err != nil {
+		return err
+	}
+	return ErrClosed
+}
+
+// Write queues data, waiting while the queue is full.
+func (this *FrameWriter) Write(channel byte, data []byte) error {
+	frame, err := this.Framing.AppendFrame(nil, channel, data)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case <-this.done:
+		return this.stopped()
+	default:
+	}
+
+	select {
+	case this.queue <- frame:
+		return nil
+	case <-this.done:
+		return this.stopped()
+	}
+}
+
+// TryWrite queues data, or fails with ErrWouldBlock when the queue is full.
+func (this *FrameWriter) TryWrite(channel byte, data []byte) error {
+	frame, err := this.Framing.AppendFrame(nil, channel, data)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case <-this.done:
+		return this.stopped()
+	default:
+	}
+
+	select {
+	case this.queue <- frame:
+		return nil
+	default:
+		return ErrWouldBlock
+	}
+}
+
+// Close stops the writer and drops the frames still queued. It does not
+// close the stream, which has to be closed first when a write may block.
+func (this *FrameWriter) Close() {
+	this.once.Do(func() {
+		close(this.closed)
+	})
+	<-this.done
+
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	if this.err == nil {
+		this.err = ErrClosed
+	}
+}
@@ -0,0 +1,146 @@
+package transport
+
+import (
+	"errors"
+	"net"
+	"sync"
+
+	"rtcp"
+	"rtp"
+)
+
+var ErrUnknownChannel = errors.New("transport: frame on an unknown interleaved channel")
+
+// TcpTransport sends and receives a session over a stream connection. With
+// RFC4571 rtp and rtcp share the connection as with rtcp-mux, interleaved
+// framing sends them on RtpChannel and RtcpChannel.
+type TcpTransport struct {
+	Conn        net.Conn
+	Framing     Framing
+	RtpChannel  byte
+	RtcpChannel byte
+
+	reader *FrameReader
+	writer *FrameWriter
+	mutex  sync.Mutex
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// NewTcpTransport uses conn already connected, with interleaved channels 0
+// and 1.
+func NewTcpTransport(conn net.Conn, framing Framing) *TcpTransport {
+	return &TcpTransport{
+		Conn:        conn,
+		Framing:     framing,
+		RtpChannel:  0,
+		RtcpChannel: 1,
+		reader:      NewFrameReader(conn, framing),
+		writer:      NewFrameWriter(conn, framing, TRANSPORT_DEFAULT_QUEUE_SIZE),
+	}
+}
+
+// SetChannels sets the interleaved channels negotiated by rtsp.
+func (this *TcpTransport) SetChannels(rtpChannel, rtcpChannel byte) {
+	this.RtpChannel = rtpChannel
+	this.RtcpChannel = rtcpChannel
+}
+
+func (this *TcpTransport) Start(handler *Handler) {
+	this.wg.Add(1)
+	go this.readLoop(handler)
+}
+
+func (this *TcpTransport) isClosed() bool {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return this.closed
+}
+
+// readLoop reads frames until the connection is closed or fails. Frames that
+// do not parse and data between interleaved frames are reported and skipped.
+// Any read error ends the loop, even a temporary one: it may have come in the
+// middle of a frame and the stream would be out of step after it.
+func (this *TcpTransport) readLoop(handler *Handler) {
+	defer this.wg.Done()
+
+	localAddr := this.Conn.LocalAddr()
+	from := this.Conn.RemoteAddr()
+	for {
+		channel, data, err := this.reader.ReadFrame()
+		if err == ErrNotInterleaved {
+			handler.error(&TransportError{Op: "parse", LocalAddr: localAddr, Addr: from, Err: err})
+			err = this.reader.Skip()
+			if err == nil {
+				continue
+			}
+		}
+		if err != nil {
+			if this.isClosed() {
+				return
+			}
+			handler.error(&TransportError{Op: "read", LocalAddr: localAddr, Addr: from, Err: err})
+			return
+		}
+
+		if this.Framing == TRANSPORT_FRAMING_RFC4571 || channel == this.RtpChannel {
+			parsePacket(handler, data, false, localAddr, from)
+		} else if channel == this.RtcpChannel {
+			parsePacket(handler, data, true, localAddr, from)
+		} else {
+			handler.error(&TransportError{Op: "parse", LocalAddr: localAddr, Addr: from, Err: ErrUnknownChannel})
+		}
+	}
+}
+
+func (this *TcpTransport) writeError(err error) error {
+	if err == nil || err == ErrClosed || err == ErrWouldBlock || err == ErrFrameTooLarge {
+		return err
+	}
+	return &TransportError{Op: "write", LocalAddr: this.Conn.LocalAddr(), Addr: this.Conn.RemoteAddr(), Err: err}
+}
+
+// WriteRtp queues packet, waiting while the peer does not keep up.
+func (this *TcpTransport) WriteRtp(packet *rtp.RtpPacket) error {
+	return this.writeError(this.writer.Write(this.RtpChannel, packet.Bytes()))
+}
+
+// TryWriteRtp queues packet, or fails with ErrWouldBlock so that media can be
+// dropped instead of delayed.
+func (this *TcpTransport) TryWriteRtp(packet *rtp.RtpPacket) error {
+	return this.writeError(this.writer.TryWrite(this.RtpChannel, packet.Bytes()))
+}
+
+func (this *TcpTransport) WriteRtcp(packets []rtcp.RtcpPacket) error {
+	data, err := rtcp.MarshalRtcpCompound(packets)
+	if err != nil {
+		return err
+	}
+
+	channel := this.RtcpChannel
+	if this.Framing == TRANSPORT_FRAMING_RFC4571 {
+		channel = this.RtpChannel
+	}
+	return this.writeError(this.writer.Write(channel, data))
+}
+
+// Pending returns the number of frames waiting to be sent.
+func (this *TcpTransport) Pending() int {
+	return this.writer.Pending()
+}
+
+// Close closes the connection and waits for the reading goroutine.
+func (this *TcpTransport) Close() error {
+	this.mutex.Lock()
+	if this.closed {
+		this.mutex.Unlock()
+		return nil
+	}
+	this.closed = true
+	this.mutex.Unlock()
+
+	err := this.Conn.Close()
+	this.writer.Close()
+	this.wg.Wait()
+	return err
+}
@@ -0,0 +1,198 @@
+package transport
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"rtcp"
+
+	"github.com/lioneagle/goutil/src/test"
+)
+
+func TestFramingAppendFrame(t *testing.T) {
+	t.Parallel()
+
+	testdata := []struct {
+		framing Framing
+		channel byte
+		data    []byte
+		wanted  []byte
+	}{
+		{TRANSPORT_FRAMING_RFC4571, 0, []byte{1, 2, 3}, []byte{0, 3, 1, 2, 3}},
+		{TRANSPORT_FRAMING_RFC4571, 5, []byte{}, []byte{0, 0}},
+		{TRANSPORT_FRAMING_INTERLEAVED, 1, []byte{1, 2}, []byte{'$', 1, 0, 2, 1, 2}},
+	}
+
+	for i, v := range testdata {
+		frame, err := v.framing.AppendFrame(nil, v.channel, v.data)
+		test.EXPECT_EQ(t, err, nil, "[%d]", i)
+		test.EXPECT_EQ(t, frame, v.wanted, "[%d]", i)
+	}
+
+	_, err := TRANSPORT_FRAMING_RFC4571.AppendFrame(nil, 0, make([]byte, TRANSPORT_MAX_FRAME_LEN+1))
+	test.EXPECT_EQ(t, err, ErrFrameTooLarge, "")
+}
+
+func TestFrameReader(t *testing.T) {
+	type frame struct {
+		channel byte
+		data    []byte
+	}
+
+	testdata := []struct {
+		framing Framing
+		stream  []byte
+		frames  []frame
+		err     error
+	}{
+		{TRANSPORT_FRAMING_RFC4571, []byte{0, 2, 1, 2, 0, 1, 3}, []frame{{0, []byte{1, 2}}, {0, []byte{3}}}, io.EOF},
+		{TRANSPORT_FRAMING_RFC4571, []byte{0, 3, 1, 2}, nil, io.ErrUnexpectedEOF},
+		{TRANSPORT_FRAMING_RFC4571, []byte{0}, nil, io.ErrUnexpectedEOF},
+		{TRANSPORT_FRAMING_INTERLEAVED, []byte{'$', 0, 0, 1, 7, '$', 1, 0, 2, 8, 9}, []frame{{0, []byte{7}}, {1, []byte{8, 9}}}, io.EOF},
+		{TRANSPORT_FRAMING_INTERLEAVED, []byte{'$', 0, 0, 1, 7, 'R', 'T'}, []frame{{0, []byte{7}}}, ErrNotInterleaved},
+	}
+
+	for i, v := range testdata {
+		v := v
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			t.Parallel()
+
+			// one octet per read, as from the smallest tcp segments
+			reader := NewFrameReader(iotest.OneByteReader(bytes.NewReader(v.stream)), v.framing)
+			for j, f := range v.frames {
+				channel, data, err := reader.ReadFrame()
+				test.EXPECT_EQ(t, err, nil, "[%d]", j)
+				test.EXPECT_EQ(t, channel, f.channel, "[%d]", j)
+				test.EXPECT_EQ(t, data, f.data, "[%d]", j)
+			}
+			_, _, err := reader.ReadFrame()
+			test.EXPECT_EQ(t, err, v.err, "")
+		})
+	}
+}
+
+func TestFrameReaderSkip(t *testing.T) {
+	t.Parallel()
+
+	stream := []byte("RTSP/1.0 200 OK\r\n\r\n$\x00\x00\x01\x07")
+	reader := NewFrameReader(bytes.NewReader(stream), TRANSPORT_FRAMING_INTERLEAVED)
+
+	_, _, err := reader.ReadFrame()
+	test.EXPECT_EQ(t, err, ErrNotInterleaved, "")
+	line, _ := reader.Reader().ReadString('\n')
+	test.EXPECT_EQ(t, line, "RTSP/1.0 200 OK\r\n", "")
+
+	test.EXPECT_EQ(t, reader.Skip(), nil, "")
+	_, data, err := reader.ReadFrame()
+	test.EXPECT_EQ(t, err, nil, "")
+	test.EXPECT_EQ(t, data, []byte{7}, "")
+}
+
+// blockingWriter blocks every write until release is closed.
+type blockingWriter struct {
+	release chan struct{}
+	buf     bytes.Buffer
+}
+
+func (this *blockingWriter) Write(data []byte) (int, error) {
+	<-this.release
+	return this.buf.Write(data)
+}
+
+func TestFrameWriterBackPressure(t *testing.T) {
+	t.Parallel()
+
+	w := &blockingWriter{release: make(chan struct{})}
+	writer := NewFrameWriter(w, TRANSPORT_FRAMING_RFC4571, 2)
+
+	// the first frame is taken by the writing goroutine, then the queue fills
+	test.EXPECT_EQ(t, writer.Write(0, []byte{1}), nil, "")
+	for writer.Pending() != 0 {
+		time.Sleep(time.Millisecond)
+	}
+	test.EXPECT_EQ(t, writer.TryWrite(0, []byte{2}), nil, "")
+	test.EXPECT_EQ(t, writer.TryWrite(0, []byte{3}), nil, "")
+	test.EXPECT_EQ(t, writer.TryWrite(0, []byte{4}), ErrWouldBlock, "")
+	test.EXPECT_EQ(t, writer.Pending(), 2, "")
+
+	close(w.release)
+	for writer.Pending() != 0 {
+		time.Sleep(time.Millisecond)
+	}
+	writer.Close()
+	test.EXPECT_EQ(t, w.buf.Bytes(), []byte{0, 1, 1, 0, 1, 2, 0, 1, 3}, "")
+	test.EXPECT_EQ(t, writer.Write(0, []byte{5}), ErrClosed, "")
+}
+
+func TestTcpTransportExchange(t *testing.T) {
+	for i, framing := range []Framing{TRANSPORT_FRAMING_RFC4571, TRANSPORT_FRAMING_INTERLEAVED} {
+		a, b := net.Pipe()
+		local := NewTcpTransport(a, framing)
+		remote := NewTcpTransport(b, framing)
+		local.SetChannels(2, 3)
+		remote.SetChannels(2, 3)
+		var _ Transport = local
+
+		handler, received := newTestHandler()
+		remote.Start(handler)
+
+		test.EXPECT_EQ(t, local.WriteRtp(newTestTransportPacket(7)), nil, "[%d]", i)
+		test.EXPECT_EQ(t, local.WriteRtcp([]rtcp.RtcpPacket{&rtcp.RtcpReceiverReport{Ssrc: 0x5678}}), nil, "[%d]", i)
+
+		select {
+		case packet := <-received.rtp:
+			test.EXPECT_EQ(t, packet.GetSequence(), uint16(7), "[%d]", i)
+		case <-time.After(time.Second):
+			t.Errorf("[%d]: no rtp packet", i)
+		}
+		select {
+		case packets := <-received.rtcp:
+			test.EXPECT_EQ(t, packets[0].(*rtcp.RtcpReceiverReport).Ssrc, uint32(0x5678), "[%d]", i)
+		case <-time.After(time.Second):
+			t.Errorf("[%d]: no rtcp packet", i)
+		}
+
+		test.EXPECT_EQ(t, remote.Close(), nil, "[%d]", i)
+		test.EXPECT_EQ(t, local.Close(), nil, "[%d]", i)
+		test.EXPECT_EQ(t, len(received.errors), 0, "[%d]", i)
+	}
+}
+
+func TestTcpTransportReadTimeout(t *testing.T) {
+	a, b := net.Pipe()
+	defer a.Close()
+	remote := NewTcpTransport(b, TRANSPORT_FRAMING_RFC4571)
+	handler, received := newTestHandler()
+
+	// the deadline passes after the length of a frame has been read
+	b.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	remote.Start(handler)
+	a.Write([]byte{0, 16})
+
+	select {
+	case err := <-received.errors:
+		test.EXPECT_EQ(t, err.(*TransportError).Temporary(), true, "err = %v", err)
+	case <-time.After(time.Second):
+		t.Fatalf("no read error")
+	}
+
+	// the loop has ended instead of reading the payload as a new frame
+	done := make(chan struct{})
+	go func() {
+		remote.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Errorf("read loop still running")
+	}
+	remote.Close()
+	test.EXPECT_EQ(t, len(received.errors), 0, "")
+	test.EXPECT_EQ(t, len(received.rtp), 0, "")
+}
@@ -0,0 +1,117 @@
+package transport
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"rtcp"
+	"rtp"
+)
+
+// rtcp packet types take the second octet 192..223 which rtp payload types
+// with the marker bit set do not use, RFC5761 section 4
+const (
+	TRANSPORT_RTCP_MUX_MIN = 192
+	TRANSPORT_RTCP_MUX_MAX = 223
+
+	// big enough for any udp datagram
+	TRANSPORT_MAX_PACKET_SIZE = 0xFFFF
+)
+
+var (
+	ErrNoRemoteAddr = errors.New("transport: no remote address")
+	ErrClosed       = errors.New("transport: closed")
+)
+
+// Handler receives what a transport reads. The functions are called from the
+// reading goroutines of the transport, a nil function drops the event.
+type Handler struct {
+	OnRtp   func(packet *rtp.RtpPacket, from net.Addr)
+	OnRtcp  func(packets []rtcp.RtcpPacket, from net.Addr)
+	OnError func(err error)
+}
+
+func (this *Handler) rtp(packet *rtp.RtpPacket, from net.Addr) {
+	if this.OnRtp != nil {
+		this.OnRtp(packet, from)
+	}
+}
+
+func (this *Handler) rtcp(packets []rtcp.RtcpPacket, from net.Addr) {
+	if this.OnRtcp != nil {
+		this.OnRtcp(packets, from)
+	}
+}
+
+func (this *Handler) error(err error) {
+	if this.OnError != nil {
+		this.OnError(err)
+	}
+}
+
+// Transport carries the rtp and rtcp packets of one session.
+type Transport interface {
+	// Start reads packets into handler until Close
+	Start(handler *Handler)
+	WriteRtp(packet *rtp.RtpPacket) error
+	WriteRtcp(packets []rtcp.RtcpPacket) error
+	// Close stops reading and returns when the reading goroutines are done
+	Close() error
+}
+
+// TransportError reports a failure on one socket: Op is "read", "write" or
+// "parse".
+type TransportError struct {
+	Op        string
+	LocalAddr net.Addr
+	Addr      net.Addr
+	Err       error
+}
+
+func (this *TransportError) Error() string {
+	s := "transport: " + this.Op
+	if this.LocalAddr != nil {
+		s += " " + this.LocalAddr.String()
+	}
+	if this.Addr != nil {
+		s += fmt.Sprintf(" (%s)", this.Addr)
+	}
+	return s + ": " + this.Err.Error()
+}
+
+// Temporary reports whether the socket may still be used.
+func (this *TransportError) Temporary() bool {
+	if this.Op == "parse" {
+		return true
+	}
+	err, ok := this.Err.(net.Error)
+	return ok && err.Temporary()
+}
+
+// IsRtcpPacket tells rtcp from rtp on a rtcp-mux socket.
+func IsRtcpPacket(data []byte) bool {
+	return len(data) >= 2 && data[1] >= TRANSPORT_RTCP_MUX_MIN && data[1] <= TRANSPORT_RTCP_MUX_MAX
+}
+
+// parsePacket parses data read from a socket, with rtcpOnly set when the socket
+// carries rtcp only.
+func parsePacket(handler *Handler, data []byte, rtcpOnly bool, localAddr, from net.Addr) {
+	if rtcpOnly || IsRtcpPacket(data) {
+		packets, err := rtcp.ParseRtcpPackets(data)
+		if err != nil {
+			handler.error(&TransportError{Op: "parse", LocalAddr: localAddr, Addr: from, Err: err})
+			return
+		}
+		handler.rtcp(packets, from)
+		return
+	}
+
+	packet := rtp.NewRtpPacket()
+	err := packet.Parse(data)
+	if err != nil {
+		handler.error(&TransportError{Op: "parse", LocalAddr: localAddr, Addr: from, Err: err})
+		return
+	}
+	handler.rtp(packet, from)
+}
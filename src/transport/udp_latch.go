@@ -0,0 +1,119 @@
+package transport
+
+import (
+	"net"
+
+	"rtcp"
+)
+
+// policies of symmetric rtp from RFC4961: the send address follows the source
+// address of the packets received
+type LatchPolicy int
+
+const (
+	// send to the signalled address only
+	TRANSPORT_LATCH_OFF LatchPolicy = iota
+	// latch to the first source and keep it for the rest of the session
+	TRANSPORT_LATCH_ONCE
+	// latch again when a new SSRC is received from another address
+	TRANSPORT_LATCH_SSRC_CHANGE
+)
+
+func (this LatchPolicy) String() string {
+	switch this {
+	case TRANSPORT_LATCH_OFF:
+		return "off"
+	case TRANSPORT_LATCH_ONCE:
+		return "once"
+	case TRANSPORT_LATCH_SSRC_CHANGE:
+		return "ssrc-change"
+	}
+	return "unknown"
+}
+
+// Latch chooses the send address of one stream, rtp or rtcp, from the valid
+// packets received on it. A candidate source, address and SSRC, has to send
+// Required packets in a row before it is latched, so that a few spoofed
+// packets cannot take the stream over.
+type Latch struct {
+	Policy   LatchPolicy
+	Required int
+
+	latched bool
+	addr    *net.UDPAddr
+	ssrc    uint32
+
+	candidate     *net.UDPAddr
+	candidateSsrc uint32
+	count         int
+}
+
+func NewLatch(policy LatchPolicy, required int) *Latch {
+	if required < 1 {
+		required = 1
+	}
+	return &Latch{Policy: policy, Required: required}
+}
+
+func sameUdpAddr(a, b *net.UDPAddr) bool {
+	return a.Port == b.Port && a.IP.Equal(b.IP) && a.Zone == b.Zone
+}
+
+// Latched returns the address latched to, nil before the first latch.
+func (this *Latch) Latched() *net.UDPAddr {
+	return this.addr
+}
+
+// Observe accounts a valid packet of ssrc from addr and returns true when
+// the stream has latched to addr.
+func (this *Latch) Observe(ssrc uint32, addr *net.UDPAddr) bool {
+	if this.Policy == TRANSPORT_LATCH_OFF {
+		return false
+	}
+
+	if this.latched {
+		if sameUdpAddr(addr, this.addr) {
+			// the latched source goes on, a spoofer has to start over
+			this.ssrc = ssrc
+			this.candidate = nil
+			this.count = 0
+			return false
+		}
+		if this.Policy == TRANSPORT_LATCH_ONCE || ssrc == this.ssrc {
+			return false
+		}
+	}
+
+	if this.candidate != nil && this.candidateSsrc == ssrc && sameUdpAddr(addr, this.candidate) {
+		this.count++
+	} else {
+		this.candidate = addr
+		this.candidateSsrc = ssrc
+		this.count = 1
+	}
+	if this.count < this.Required {
+		return false
+	}
+
+	this.latched = true
+	this.addr = this.candidate
+	this.ssrc = this.candidateSsrc
+	this.candidate = nil
+	this.count = 0
+	return true
+}
+
+// rtcpSourceSsrc returns the SSRC of the sender of a compound packet, which
+// starts with a SR or RR. Reduced-size packets have none.
+func rtcpSourceSsrc(packets []rtcp.RtcpPacket) (uint32, bool) {
+	if len(packets) == 0 {
+		return 0, false
+	}
+	switch v := packets[0].(type) {
+	case *rtcp.RtcpSenderReport:
+		return v.Ssrc, true
+	case *rtcp.RtcpReceiverReport:
+		return v.Ssrc, true
+	}
+	return 0, false
+}
@@ -0,0 +1,99 @@
+package transport
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lioneagle/goutil/src/test"
+)
+
+func newTestUdpAddr(port int) *net.UDPAddr {
+	return &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: port}
+}
+
+func TestLatchObserve(t *testing.T) {
+	type observe struct {
+		ssrc   uint32
+		port   int
+		wanted bool
+	}
+
+	testdata := []struct {
+		policy   LatchPolicy
+		required int
+		packets  []observe
+		latched  int
+	}{
+		{TRANSPORT_LATCH_OFF, 1, []observe{{1, 5000, false}, {1, 5000, false}}, 0},
+		{TRANSPORT_LATCH_ONCE, 1, []observe{{1, 5000, true}, {1, 5000, false}, {2, 6000, false}}, 5000},
+		{TRANSPORT_LATCH_ONCE, 3, []observe{{1, 5000, false}, {1, 5000, false}, {1, 5000, true}}, 5000},
+		// a packet of another source breaks the run
+		{TRANSPORT_LATCH_ONCE, 2, []observe{{1, 5000, false}, {1, 6000, false}, {1, 5000, false}, {1, 5000, true}}, 5000},
+		{TRANSPORT_LATCH_ONCE, 2, []observe{{1, 5000, false}, {2, 5000, false}, {2, 5000, true}}, 5000},
+		{TRANSPORT_LATCH_SSRC_CHANGE, 1, []observe{{1, 5000, true}, {2, 6000, true}, {2, 5000, false}}, 6000},
+		// the same SSRC from another address does not move the latch
+		{TRANSPORT_LATCH_SSRC_CHANGE, 1, []observe{{1, 5000, true}, {1, 6000, false}}, 5000},
+		{TRANSPORT_LATCH_SSRC_CHANGE, 2, []observe{{1, 5000, false}, {1, 5000, true}, {2, 6000, false}, {1, 5000, false}, {2, 6000, false}, {2, 6000, true}}, 6000},
+		// a new SSRC from the latched address is taken over
+		{TRANSPORT_LATCH_SSRC_CHANGE, 1, []observe{{1, 5000, true}, {2, 5000, false}, {1, 6000, true}}, 6000},
+	}
+
+	for i, v := range testdata {
+		v := v
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			t.Parallel()
+
+			latch := NewLatch(v.policy, v.required)
+			for j, p := range v.packets {
+				test.EXPECT_EQ(t, latch.Observe(p.ssrc, newTestUdpAddr(p.port)), p.wanted, "[%d]", j)
+			}
+			if v.latched == 0 {
+				test.EXPECT_EQ(t, latch.Latched() == nil, true, "")
+			} else {
+				test.EXPECT_EQ(t, latch.Latched().Port, v.latched, "")
+			}
+		})
+	}
+}
+
+func TestUdpTransportLatch(t *testing.T) {
+	local, remote := newTestUdpPair(t, true)
+	defer local.Close()
+	defer remote.Close()
+
+	// the peer behind a NAT sends from another port than signalled
+	allocator, _ := NewPortAllocator("127.0.0.1", 44000, 44999)
+	nat, err := ListenUdp(allocator, true)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer nat.Close()
+	nat.SetRemote(local.RtpConn.LocalAddr().(*net.UDPAddr), nil)
+
+	logs := &bytes.Buffer{}
+	local.Logger = log.New(logs, "", 0)
+	local.RtpLatch = NewLatch(TRANSPORT_LATCH_ONCE, 2)
+	handler, received := newTestHandler()
+	local.Start(handler)
+
+	natAddr := nat.RtpConn.LocalAddr().(*net.UDPAddr)
+	for seq := uint16(0); seq < 2; seq++ {
+		nat.WriteRtp(newTestTransportPacket(seq))
+		select {
+		case <-received.rtp:
+		case <-time.After(time.Second):
+			t.Fatalf("no rtp packet")
+		}
+	}
+
+	rtpAddr, rtcpAddr := local.GetRemote()
+	test.EXPECT_EQ(t, rtpAddr.Port, natAddr.Port, "")
+	test.EXPECT_EQ(t, rtcpAddr.Port, natAddr.Port, "")
+	test.EXPECT_EQ(t, local.GetAddressChanges(), 2, "")
+	test.EXPECT_EQ(t, strings.Count(logs.String(), "changed to"), 2, "")
+}
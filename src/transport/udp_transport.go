@@ -0,0 +1,245 @@
+package transport
+
+import (
+	"log"
+	"net"
+	"sync"
+
+	"rtcp"
+	"rtp"
+)
+
+// UdpTransport sends and receives a session over a rtp socket and a rtcp
+// socket, or over the rtp socket alone with rtcp-mux from RFC5761.
+type UdpTransport struct {
+	RtpConn *net.UDPConn
+	// nil with rtcp-mux
+	RtcpConn *net.UDPConn
+	// RtpLatch and RtcpLatch, when set before Start, move the send addresses
+	// to the source addresses of the packets received. With rtcp-mux and no
+	// RtcpLatch rtcp follows rtp.
+	RtpLatch  *Latch
+	RtcpLatch *Latch
+	// Logger logs the changes of the send addresses, nil for none
+	Logger *log.Logger
+
+	mutex          sync.Mutex
+	remoteRtp      *net.UDPAddr
+	remoteRtcp     *net.UDPAddr
+	addressChanges int
+	closed         bool
+	wg             sync.WaitGroup
+}
+
+// NewUdpTransport uses sockets already bound, rtcpConn is nil for rtcp-mux.
+func NewUdpTransport(rtpConn, rtcpConn *net.UDPConn) *UdpTransport {
+	return &UdpTransport{RtpConn: rtpConn, RtcpConn: rtcpConn}
+}
+
+// ListenUdp binds a port pair from allocator, or a single port with mux.
+func ListenUdp(allocator *PortAllocator, mux bool) (*UdpTransport, error) {
+	if mux {
+		conn, err := allocator.Listen()
+		if err != nil {
+			return nil, err
+		}
+		return NewUdpTransport(conn, nil), nil
+	}
+
+	rtpConn, rtcpConn, err := allocator.ListenPair()
+	if err != nil {
+		return nil, err
+	}
+	return NewUdpTransport(rtpConn, rtcpConn), nil
+}
+
+func (this *UdpTransport) Mux() bool {
+	return this.RtcpConn == nil
+}
+
+// SetRemote sets where packets are sent. rtcpAddr may be nil, then rtcp goes
+// to the rtp port plus one, or to the rtp port with rtcp-mux.
+func (this *UdpTransport) SetRemote(rtpAddr, rtcpAddr *net.UDPAddr) {
+	if rtcpAddr == nil && rtpAddr != nil {
+		rtcpAddr = &net.UDPAddr{IP: rtpAddr.IP, Port: rtpAddr.Port, Zone: rtpAddr.Zone}
+		if !this.Mux() {
+			rtcpAddr.Port++
+		}
+	}
+
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.remoteRtp = rtpAddr
+	this.remoteRtcp = rtcpAddr
+}
+
+func (this *UdpTransport) GetRemote() (rtpAddr, rtcpAddr *net.UDPAddr) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return this.remoteRtp, this.remoteRtcp
+}
+
+// SetLatch latches rtp and rtcp with the same policy.
+func (this *UdpTransport) SetLatch(policy LatchPolicy, required int) {
+	this.RtpLatch = NewLatch(policy, required)
+	this.RtcpLatch = NewLatch(policy, required)
+}
+
+// GetAddressChanges returns how often a latch has changed a send address.
+func (this *UdpTransport) GetAddressChanges() int {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return this.addressChanges
+}
+
+func (this *UdpTransport) latch(latch *Latch, rtcpStream bool, ssrc uint32, from net.Addr) {
+	addr, ok := from.(*net.UDPAddr)
+	if !ok {
+		return
+	}
+
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	if !latch.Observe(ssrc, addr) {
+		return
+	}
+	this.setLatched(rtcpStream, ssrc, addr)
+	if !rtcpStream && this.Mux() && this.RtcpLatch == nil {
+		this.setLatched(true, ssrc, addr)
+	}
+}
+
+func (this *UdpTransport) setLatched(rtcpStream bool, ssrc uint32, addr *net.UDPAddr) {
+	name, remote := "rtp", &this.remoteRtp
+	if rtcpStream {
+		name, remote = "rtcp", &this.remoteRtcp
+	}
+	if *remote != nil && sameUdpAddr(*remote, addr) {
+		return
+	}
+
+	if this.Logger != nil {
+		this.Logger.Printf("transport: %s remote address %v changed to %v by ssrc 0x%08x", name, *remote, addr, ssrc)
+	}
+	*remote = addr
+	this.addressChanges++
+}
+
+// latchHandler passes the packets to handler after the latches saw them.
+func (this *UdpTransport) latchHandler(handler *Handler) *Handler {
+	return &Handler{
+		OnRtp: func(packet *rtp.RtpPacket, from net.Addr) {
+			if this.RtpLatch != nil {
+				this.latch(this.RtpLatch, false, packet.GetSsrc(), from)
+			}
+			handler.rtp(packet, from)
+		},
+		OnRtcp: func(packets []rtcp.RtcpPacket, from net.Addr) {
+			if ssrc, ok := rtcpSourceSsrc(packets); ok && this.RtcpLatch != nil {
+				this.latch(this.RtcpLatch, true, ssrc, from)
+			}
+			handler.rtcp(packets, from)
+		},
+		OnError: handler.OnError,
+	}
+}
+
+func (this *UdpTransport) Start(handler *Handler) {
+	if this.RtpLatch != nil || this.RtcpLatch != nil {
+		handler = this.latchHandler(handler)
+	}
+
+	this.wg.Add(1)
+	go this.readLoop(this.RtpConn, false, handler)
+	if this.RtcpConn != nil {
+		this.wg.Add(1)
+		go this.readLoop(this.RtcpConn, true, handler)
+	}
+}
+
+func (this *UdpTransport) isClosed() bool {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return this.closed
+}
+
+// readLoop reads conn until it is closed or fails. Packets that do not parse
+// and temporary errors are reported and skipped.
+func (this *UdpTransport) readLoop(conn *net.UDPConn, rtcpOnly bool, handler *Handler) {
+	defer this.wg.Done()
+
+	buf := make([]byte, TRANSPORT_MAX_PACKET_SIZE)
+	for {
+		n, from, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			if this.isClosed() {
+				return
+			}
+			e := &TransportError{Op: "read", LocalAddr: conn.LocalAddr(), Err: err}
+			handler.error(e)
+			if e.Temporary() {
+				continue
+			}
+			return
+		}
+
+		// parsed packets may keep slices of what they were parsed from
+		data := make([]byte, n)
+		copy(data, buf[:n])
+		parsePacket(handler, data, rtcpOnly, conn.LocalAddr(), from)
+	}
+}
+
+func (this *UdpTransport) write(conn *net.UDPConn, data []byte, addr *net.UDPAddr) error {
+	if this.isClosed() {
+		return ErrClosed
+	}
+	if addr == nil {
+		return ErrNoRemoteAddr
+	}
+	_, err := conn.WriteToUDP(data, addr)
+	if err != nil {
+		return &TransportError{Op: "write", LocalAddr: conn.LocalAddr(), Addr: addr, Err: err}
+	}
+	return nil
+}
+
+func (this *UdpTransport) WriteRtp(packet *rtp.RtpPacket) error {
+	addr, _ := this.GetRemote()
+	return this.write(this.RtpConn, packet.Bytes(), addr)
+}
+
+func (this *UdpTransport) WriteRtcp(packets []rtcp.RtcpPacket) error {
+	data, err := rtcp.MarshalRtcpCompound(packets)
+	if err != nil {
+		return err
+	}
+
+	_, addr := this.GetRemote()
+	conn := this.RtcpConn
+	if conn == nil {
+		conn = this.RtpConn
+	}
+	return this.write(conn, data, addr)
+}
+
+// Close closes the sockets and waits for the reading goroutines.
+func (this *UdpTransport) Close() error {
+	this.mutex.Lock()
+	if this.closed {
+		this.mutex.Unlock()
+		return nil
+	}
+	this.closed = true
+	this.mutex.Unlock()
+
+	err := this.RtpConn.Close()
+	if this.RtcpConn != nil {
+		if e := this.RtcpConn.Close(); err == nil {
+			err = e
+		}
+	}
+	this.wg.Wait()
+	return err
+}
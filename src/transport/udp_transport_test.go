@@ -0,0 +1,165 @@
+package transport
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"rtcp"
+	"rtp"
+
+	"github.com/lioneagle/goutil/src/test"
+)
+
+func TestIsRtcpPacket(t *testing.T) {
+	t.Parallel()
+
+	testdata := []struct {
+		data   []byte
+		wanted bool
+	}{
+		{[]byte{0x80, 0x00}, false},
+		{[]byte{0x80, 0x60}, false},
+		{[]byte{0x80, 0xe0}, false},
+		{[]byte{0x80, 0xbf}, false},
+		{[]byte{0x80, 0xc0}, true},
+		{[]byte{0x80, 0xc8}, true},
+		{[]byte{0x80, 0xcf}, true},
+		{[]byte{0x80, 0xdf}, true},
+		{[]byte{0x80}, false},
+	}
+
+	for i, v := range testdata {
+		test.EXPECT_EQ(t, IsRtcpPacket(v.data), v.wanted, "[%d]", i)
+	}
+}
+
+type testReceived struct {
+	rtp    chan *rtp.RtpPacket
+	rtcp   chan []rtcp.RtcpPacket
+	errors chan error
+}
+
+func newTestHandler() (*Handler, *testReceived) {
+	received := &testReceived{
+		rtp:    make(chan *rtp.RtpPacket, 10),
+		rtcp:   make(chan []rtcp.RtcpPacket, 10),
+		errors: make(chan error, 10),
+	}
+	handler := &Handler{
+		OnRtp:   func(packet *rtp.RtpPacket, from net.Addr) { received.rtp <- packet },
+		OnRtcp:  func(packets []rtcp.RtcpPacket, from net.Addr) { received.rtcp <- packets },
+		OnError: func(err error) { received.errors <- err },
+	}
+	return handler, received
+}
+
+func newTestTransportPacket(seq uint16) *rtp.RtpPacket {
+	packet := rtp.NewRtpPacket()
+	packet.Alloc(rtp.RTP_HEADER_LEN + 4)
+	packet.SetVersion(rtp.RTP_VERSION)
+	packet.SetPayloadType(96)
+	packet.SetMarker()
+	packet.SetSequence(seq)
+	packet.SetSsrc(0x1234)
+	return packet
+}
+
+func newTestUdpPair(t *testing.T, mux bool) (*UdpTransport, *UdpTransport) {
+	allocator, _ := NewPortAllocator("127.0.0.1", 42000, 42999)
+	local, err := ListenUdp(allocator, mux)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	remote, err := ListenUdp(allocator, mux)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	local.SetRemote(remote.RtpConn.LocalAddr().(*net.UDPAddr), nil)
+	remote.SetRemote(local.RtpConn.LocalAddr().(*net.UDPAddr), nil)
+	return local, remote
+}
+
+func TestUdpTransportExchange(t *testing.T) {
+	for i, mux := range []bool{false, true} {
+		local, remote := newTestUdpPair(t, mux)
+		var _ Transport = local
+
+		handler, received := newTestHandler()
+		remote.Start(handler)
+
+		_, rtcpAddr := local.GetRemote()
+		if mux {
+			test.EXPECT_EQ(t, rtcpAddr.Port, remote.RtpConn.LocalAddr().(*net.UDPAddr).Port, "[%d]", i)
+		} else {
+			test.EXPECT_EQ(t, rtcpAddr.Port, remote.RtcpConn.LocalAddr().(*net.UDPAddr).Port, "[%d]", i)
+		}
+
+		test.EXPECT_EQ(t, local.WriteRtp(newTestTransportPacket(7)), nil, "[%d]", i)
+		test.EXPECT_EQ(t, local.WriteRtcp([]rtcp.RtcpPacket{&rtcp.RtcpReceiverReport{Ssrc: 0x5678}}), nil, "[%d]", i)
+
+		select {
+		case packet := <-received.rtp:
+			test.EXPECT_EQ(t, packet.GetSequence(), uint16(7), "[%d]", i)
+			test.EXPECT_EQ(t, packet.GetSsrc(), uint32(0x1234), "[%d]", i)
+		case <-time.After(time.Second):
+			t.Errorf("[%d]: no rtp packet", i)
+		}
+		select {
+		case packets := <-received.rtcp:
+			test.EXPECT_EQ(t, len(packets), 1, "[%d]", i)
+			test.EXPECT_EQ(t, packets[0].(*rtcp.RtcpReceiverReport).Ssrc, uint32(0x5678), "[%d]", i)
+		case <-time.After(time.Second):
+			t.Errorf("[%d]: no rtcp packet", i)
+		}
+
+		test.EXPECT_EQ(t, local.Close(), nil, "[%d]", i)
+		test.EXPECT_EQ(t, remote.Close(), nil, "[%d]", i)
+		test.EXPECT_EQ(t, remote.Close(), nil, "[%d]", i)
+		test.EXPECT_EQ(t, len(received.errors), 0, "[%d]", i)
+		test.EXPECT_EQ(t, local.WriteRtp(newTestTransportPacket(8)), ErrClosed, "[%d]", i)
+	}
+}
+
+func TestUdpTransportParseError(t *testing.T) {
+	local, remote := newTestUdpPair(t, true)
+	defer local.Close()
+	defer remote.Close()
+
+	handler, received := newTestHandler()
+	remote.Start(handler)
+
+	addr, _ := local.GetRemote()
+	local.RtpConn.WriteToUDP([]byte{0x40, 0x00, 0x00}, addr)
+	local.WriteRtp(newTestTransportPacket(1))
+
+	select {
+	case err := <-received.errors:
+		e, ok := err.(*TransportError)
+		test.EXPECT_EQ(t, ok, true, "")
+		test.EXPECT_EQ(t, e.Op, "parse", "")
+		test.EXPECT_EQ(t, e.Err, rtp.ErrTooShort, "")
+		test.EXPECT_EQ(t, e.Temporary(), true, "")
+	case <-time.After(time.Second):
+		t.Errorf("no parse error")
+	}
+
+	// the loop goes on after a bad packet
+	select {
+	case packet := <-received.rtp:
+		test.EXPECT_EQ(t, packet.GetSequence(), uint16(1), "")
+	case <-time.After(time.Second):
+		t.Errorf("no rtp packet")
+	}
+}
+
+func TestUdpTransportNoRemote(t *testing.T) {
+	allocator, _ := NewPortAllocator("127.0.0.1", 43000, 43999)
+	transport, err := ListenUdp(allocator, false)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer transport.Close()
+
+	test.EXPECT_EQ(t, transport.WriteRtp(newTestTransportPacket(1)), ErrNoRemoteAddr, "")
+}